@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mdp/internal/browser"
+	"mdp/internal/config"
+	"mdp/internal/converter"
+	"mdp/internal/diff"
+	"mdp/internal/filetree"
+	"mdp/internal/git"
+	"mdp/internal/linkrewriter"
+	"mdp/internal/notebook"
+	"mdp/internal/template"
+)
+
+// runDiff handles the 'mdp diff' subcommand.
+func runDiff(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printDiffUsage()
+			return nil
+		}
+	}
+
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	outputFlag := fs.String("output", "", "Write HTML to file instead of opening browser")
+	fs.StringVar(outputFlag, "O", "", "Write HTML to file instead of opening browser (shorthand)")
+	safeFlag := fs.Bool("safe", false, "Render untrusted markdown safely")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v\nRun 'mdp diff --help' for usage", err)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("Usage: mdp diff <old>..<new> [path...]\nRun 'mdp diff --help' for more information")
+	}
+
+	oldRev, newRev, symmetric, err := parseRange(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	paths := fs.Args()[1:]
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	if *safeFlag {
+		cfg.Safe = true
+	}
+	opts, err := newRenderOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	repo, err := git.Open(".")
+	if err != nil {
+		return fmt.Errorf("Error opening git repository: %v", err)
+	}
+
+	entries, err := diffEntries(repo, oldRev, newRev, symmetric, paths, opts)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No markdown changes between %s and %s\n", oldRev, newRev)
+		return nil
+	}
+
+	// Rewrite relative .md links to fragment identifiers and make heading
+	// IDs unique across files
+	rewriter := linkrewriter.New(entries)
+	for i := range entries {
+		entries[i].Content = rewriter.RewriteLinks(entries[i].Content, entries[i].RelPath)
+		entries[i].Content = linkrewriter.ScopeHeadingIDs(entries[i].Content, entries[i].ID)
+	}
+
+	tree := filetree.BuildTree(entries)
+	title := fmt.Sprintf("%s - Markdown Diff", fs.Arg(0))
+	fullHTML := template.GenerateDiff(title, tree, entries, opts.template...)
+
+	// Determine output path
+	outputPath := *outputFlag
+	openBrowser := false
+	if outputPath == "" {
+		outputPath = filepath.Join("/tmp", "mdpreview-diff.html")
+		openBrowser = true
+	}
+
+	if err := os.WriteFile(outputPath, []byte(fullHTML), 0644); err != nil {
+		return fmt.Errorf("Error writing HTML file: %v", err)
+	}
+
+	if openBrowser {
+		if err := browser.Open(outputPath); err != nil {
+			return fmt.Errorf("Error opening browser: %v", err)
+		}
+		fmt.Printf("Opened diff of %d files in browser\n", len(entries))
+	} else {
+		fmt.Printf("Wrote %s\n", outputPath)
+	}
+	return nil
+}
+
+// parseRange splits a revision range "A..B" or "A...B". An empty side means
+// HEAD, as in git. With three dots the diff starts from the merge base.
+func parseRange(arg string) (oldRev, newRev string, symmetric bool, err error) {
+	sep := ".."
+	if strings.Contains(arg, "...") {
+		sep = "..."
+		symmetric = true
+	}
+	i := strings.Index(arg, sep)
+	if i == -1 {
+		return "", "", false, fmt.Errorf("invalid revision range: %s (expected <old>..<new>)", arg)
+	}
+	oldRev, newRev = arg[:i], arg[i+len(sep):]
+	if oldRev == "" {
+		oldRev = "HEAD"
+	}
+	if newRev == "" {
+		newRev = "HEAD"
+	}
+	return oldRev, newRev, symmetric, nil
+}
+
+// diffEntries renders a block-level diff of every markdown file and
+// notebook under paths that differs between the two revisions. Each side is
+// read and converted with opts as of its revision, so notebooks, includes,
+// wiki titles and embeds all come from that revision.
+func diffEntries(repo *git.Repo, oldRev, newRev string, symmetric bool, paths []string, opts renderOptions) ([]filetree.FileEntry, error) {
+	newHash, err := repo.ResolveRev(newRev)
+	if err != nil {
+		return nil, err
+	}
+	oldHash, err := repo.ResolveRev(oldRev)
+	if err != nil {
+		return nil, err
+	}
+	if symmetric {
+		if oldHash, err = repo.MergeBase(oldHash, newHash); err != nil {
+			return nil, err
+		}
+	}
+
+	var relPaths []string
+	for _, p := range paths {
+		rel, err := repo.RelPath(p)
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, rel)
+	}
+
+	oldFiles, err := listMarkdown(repo, oldHash, relPaths)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := listMarkdown(repo, newHash, relPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []string
+	for path := range oldFiles {
+		all = append(all, path)
+	}
+	for path := range newFiles {
+		if !oldFiles[path] {
+			all = append(all, path)
+		}
+	}
+	sort.Strings(all)
+
+	// Wiki links and embeds resolve against the files of the same revision
+	var wikiFiles, oldWikiFiles, newWikiFiles []string
+	for _, path := range all {
+		file := filepath.Join(repo.Dir, filepath.FromSlash(path))
+		wikiFiles = append(wikiFiles, file)
+		if oldFiles[path] {
+			oldWikiFiles = append(oldWikiFiles, file)
+		}
+		if newFiles[path] {
+			newWikiFiles = append(newWikiFiles, file)
+		}
+	}
+	wikiBase := findCommonBase(wikiFiles)
+	oldOpts, newOpts := opts, opts
+	oldOpts.read = (&revSource{repo: repo, rev: oldHash}).readFile
+	newOpts.read = (&revSource{repo: repo, rev: newHash}).readFile
+	oldConv := converter.New(oldOpts.converterFor(oldWikiFiles, wikiBase)...)
+	newConv := converter.New(newOpts.converterFor(newWikiFiles, wikiBase)...)
+
+	var changed []string
+	var contents [][2][]byte
+	for i, path := range all {
+		var old, new []byte
+		if oldFiles[path] {
+			if old, err = oldOpts.readFile(wikiFiles[i]); err != nil {
+				return nil, fmt.Errorf("Error reading %s: %v", path, err)
+			}
+		}
+		if newFiles[path] {
+			if new, err = newOpts.readFile(wikiFiles[i]); err != nil {
+				return nil, fmt.Errorf("Error reading %s: %v", path, err)
+			}
+		}
+		if oldFiles[path] && newFiles[path] && bytes.Equal(old, new) {
+			continue
+		}
+		changed = append(changed, path)
+		contents = append(contents, [2][]byte{old, new})
+	}
+
+	var osPaths []string
+	for _, path := range changed {
+		osPaths = append(osPaths, filepath.FromSlash(path))
+	}
+	baseDir := findCommonBase(osPaths)
+
+	var entries []filetree.FileEntry
+	for i, path := range changed {
+		status := diff.Modified
+		switch {
+		case !oldFiles[path]:
+			status = diff.Added
+		case !newFiles[path]:
+			status = diff.Removed
+		}
+
+		wikiPath := relativePath(filepath.Join(repo.Dir, osPaths[i]), wikiBase)
+		oldBlocks, err := oldConv.ConvertBlocksFile(contents[i][0], wikiPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error converting %s: %v", path, err)
+		}
+		newBlocks, err := newConv.ConvertBlocksFile(contents[i][1], wikiPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error converting %s: %v", path, err)
+		}
+
+		relPath := strings.TrimPrefix(osPaths[i], baseDir)
+		relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
+
+		entries = append(entries, filetree.FileEntry{
+			ID:      sanitizeID(relPath),
+			Path:    path,
+			Name:    strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath)),
+			RelPath: relPath,
+			Content: diff.HTML(path, status, diff.Compute(oldBlocks, newBlocks)),
+			Status:  string(status),
+		})
+	}
+	return entries, nil
+}
+
+// listMarkdown returns the set of markdown files and notebooks under paths
+// in rev.
+func listMarkdown(repo *git.Repo, rev string, paths []string) (map[string]bool, error) {
+	files, err := repo.ListFiles(rev, paths...)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool)
+	for _, f := range files {
+		if strings.HasSuffix(strings.ToLower(f), ".md") || notebook.IsNotebook(f) {
+			set[f] = true
+		}
+	}
+	return set, nil
+}
+
+func printDiffUsage() {
+	fmt.Println(`mdp diff - Render the changes to markdown between two git revisions
+
+Usage:
+  mdp diff [options] <old>..<new> [path...]
+
+Options:
+  -O, --output <file>          Write HTML to file instead of opening browser
+  --safe                       Render untrusted markdown safely
+
+Both versions of each changed markdown file or Jupyter notebook are rendered
+and compared block by block.
+Files are read with the local git command; the working tree is not touched.
+Use <old>...<new> to compare against the merge base, like git diff.
+
+Examples:
+  mdp diff main..feature docs/
+  mdp diff v1.0..v2.0 README.md
+  mdp diff -O review.html main...`)
+}
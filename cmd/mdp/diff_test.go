@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newGitRepo creates a repository with main and feature branches, and
+// changes into it.
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write("docs/guide.md", "# Guide\n\nOld intro.\n\n## Usage\n\nRun it.\n")
+	write("docs/old.md", "# Old\n")
+	write("docs/same.md", "# Same\n")
+	write("notes.md", "# Outside docs\n")
+	write("docs/analysis.ipynb", notebookJSON("Old analysis."))
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	git("checkout", "-q", "-b", "feature")
+	write("docs/guide.md", "# Guide\n\nNew intro, see [[new]].\n\n![[same]]\n\n## Usage\n\nRun it.\n")
+	write("docs/new.md", "# New\n")
+	write("notes.md", "# Changed outside docs\n")
+	write("docs/analysis.ipynb", notebookJSON("New analysis."))
+	if err := os.Remove(filepath.Join(dir, "docs/old.md")); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "feature")
+
+	t.Chdir(dir)
+	return dir
+}
+
+// notebookJSON returns a notebook with a single markdown cell.
+func notebookJSON(text string) string {
+	return `{"cells": [{"cell_type": "markdown", "metadata": {}, "source": ["` + text + `"]}], "metadata": {}, "nbformat": 4, "nbformat_minor": 5}`
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := newGitRepo(t)
+	outputFile := filepath.Join(dir, "review.html")
+
+	if err := run([]string{"diff", "-O", outputFile, "main..feature", "docs/"}); err != nil {
+		t.Fatalf("run(diff) failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	html := string(content)
+
+	checks := []string{
+		`data-file="guide-md" data-status="modified"`,
+		`data-file="new-md" data-status="added"`,
+		`data-file="old-md" data-status="removed"`,
+		`data-file="analysis-ipynb" data-status="modified"`,
+		`<div class="diff-old"><p>Old analysis.</p>`,
+		`<div class="diff-new"><p>New analysis.</p>`,
+		`<div class="diff-old"><p>Old intro.</p>`,
+		`<div class="diff-new"><p>New intro, see <a href="#new-md" class="wikilink">new</a>.</p>`,
+		"<title>main..feature - Markdown Diff</title>",
+	}
+	for _, check := range checks {
+		if !strings.Contains(html, check) {
+			t.Errorf("expected diff to contain %q", check)
+		}
+	}
+	for _, unwanted := range []string{`data-file="same-md"`, "Outside docs"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("expected diff not to contain %q", unwanted)
+		}
+	}
+
+	// The working tree is untouched
+	if _, err := os.Stat(filepath.Join(dir, "docs", "new.md")); err != nil {
+		t.Errorf("expected working tree to stay on feature: %v", err)
+	}
+}
+
+func TestRunDiff_ReadsRevisions(t *testing.T) {
+	dir := newGitRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "docs", "same.md"), []byte("# Same\n\nUncommitted.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outputFile := filepath.Join(dir, "review.html")
+
+	if err := run([]string{"diff", "-O", outputFile, "main..feature", "docs/"}); err != nil {
+		t.Fatalf("run(diff) failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	html := string(content)
+	if !strings.Contains(html, `data-source="same.md"`) {
+		t.Error("expected the new guide to embed same.md")
+	}
+	if strings.Contains(html, "Uncommitted.") {
+		t.Error("expected embeds to be read from the revision, not the working tree")
+	}
+}
+
+func TestRunDiff_Errors(t *testing.T) {
+	newGitRepo(t)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"diff"}, "Usage: mdp diff"},
+		{[]string{"diff", "main"}, "invalid revision range"},
+		{[]string{"diff", "main..nope"}, "unknown revision nope"},
+	}
+	for _, tt := range tests {
+		err := run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("run(%v) error = %v, want containing %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		arg       string
+		old, new  string
+		symmetric bool
+	}{
+		{"main..feature", "main", "feature", false},
+		{"v1.0...v2.0", "v1.0", "v2.0", true},
+		{"main..", "main", "HEAD", false},
+		{"...feature", "HEAD", "feature", true},
+	}
+	for _, tt := range tests {
+		old, new, symmetric, err := parseRange(tt.arg)
+		if err != nil {
+			t.Errorf("parseRange(%q) returned error: %v", tt.arg, err)
+			continue
+		}
+		if old != tt.old || new != tt.new || symmetric != tt.symmetric {
+			t.Errorf("parseRange(%q) = %q, %q, %v, want %q, %q, %v", tt.arg, old, new, symmetric, tt.old, tt.new, tt.symmetric)
+		}
+	}
+}
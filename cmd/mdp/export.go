@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"mdp/internal/config"
+	"mdp/internal/converter"
+	"mdp/internal/epub"
+	"mdp/internal/filetree"
+	"mdp/internal/linkrewriter"
+	"mdp/internal/pdf"
+	"mdp/internal/template"
+)
+
+// runExport handles the 'mdp export' subcommand.
+func runExport(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printExportUsage()
+			return nil
+		}
+	}
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	formatFlag := fs.String("format", "", "Output format")
+	outputFlag := fs.String("output", "", "Output file")
+	fs.StringVar(outputFlag, "O", "", "Output file (shorthand)")
+	titleFlag := fs.String("title", "", "Document title")
+	pageBreaksFlag := fs.Bool("page-breaks", false, "Start each file on a new page (pdf only)")
+	themeFlag := fs.String("theme", "", "Color scheme: light, dark or system")
+	codeThemeFlag := fs.String("code-theme", "", "Chroma style for code")
+	safeFlag := fs.Bool("safe", false, "Render untrusted markdown safely")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v\nRun 'mdp export --help' for usage", err)
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("Usage: mdp export --format <format> <path>\nRun 'mdp export --help' for more information")
+	}
+
+	switch *formatFlag {
+	case "epub", "pdf":
+	case "":
+		return fmt.Errorf("missing --format\nRun 'mdp export --help' for usage")
+	default:
+		return fmt.Errorf("unsupported export format: %s", *formatFlag)
+	}
+
+	files, err := resolveFiles(fs.Args(), namedTables)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("No markdown files found")
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	if *safeFlag {
+		cfg.Safe = true
+	}
+	if *themeFlag != "" {
+		cfg.Theme = *themeFlag
+	}
+	if *codeThemeFlag != "" {
+		cfg.CodeThemeLight = *codeThemeFlag
+		cfg.CodeThemeDark = *codeThemeFlag
+	}
+	opts, err := newRenderOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	baseDir := findCommonBase(files)
+
+	title := *titleFlag
+	if title == "" {
+		title = exportName(baseDir, files)
+	}
+
+	outputPath := *outputFlag
+	if outputPath == "" {
+		outputPath = exportName(baseDir, files) + "." + *formatFlag
+	}
+
+	var data []byte
+	switch *formatFlag {
+	case "epub":
+		data, err = exportEPUB(files, baseDir, title, opts)
+	case "pdf":
+		codeStyle := cfg.CodeThemeLight
+		if cfg.Theme == "dark" {
+			codeStyle = cfg.CodeThemeDark
+		}
+		data, err = exportPDF(files, baseDir, title, *pageBreaksFlag, cfg.Theme == "dark", codeStyle, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("Error writing %s: %v", outputPath, err)
+	}
+	fmt.Printf("Wrote %s\n", outputPath)
+	return nil
+}
+
+// exportEPUB converts files into an EPUB book in sidebar order.
+func exportEPUB(files []string, baseDir, title string, opts renderOptions) ([]byte, error) {
+	conv := converter.New(append(opts.converterFor(files, baseDir), converter.WithXHTML())...)
+	entries, err := loadEntries(files, baseDir, conv, opts.readFile)
+	if err != nil {
+		return nil, err
+	}
+	backlinks := linkrewriter.New(entries).Backlinks(entries)
+	for i := range entries {
+		entries[i].Backlinks = backlinks[entries[i].ID]
+	}
+
+	tree := filetree.BuildTree(entries)
+	var ordered []filetree.FileEntry
+	for _, f := range tree.Files() {
+		ordered = append(ordered, *f)
+	}
+
+	var buf bytes.Buffer
+	err = epub.Write(&buf, epub.Book{
+		Title:      title,
+		Files:      ordered,
+		Stylesheet: template.MarkdownCSS(opts.template...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating EPUB: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// exportPDF renders files into a paginated PDF in sidebar order.
+func exportPDF(files []string, baseDir, title string, pageBreaks, dark bool, codeStyle string, opts renderOptions) ([]byte, error) {
+	// The PDF is laid out from the parsed markdown, so each file is read
+	// once and not converted to HTML
+	sources := make(map[string][]byte, len(files))
+	entries := make([]filetree.FileEntry, 0, len(files))
+	for _, path := range files {
+		source, err := opts.readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %v", path, err)
+		}
+		sources[path] = source
+		entries = append(entries, newFileEntry(path, baseDir))
+	}
+	read := func(path string) ([]byte, error) {
+		if source, ok := sources[path]; ok {
+			return source, nil
+		}
+		return opts.readFile(path)
+	}
+	conv := converter.New(opts.converterWith(files, baseDir, read)...)
+
+	tree := filetree.BuildTree(entries)
+	var chapters []pdf.Chapter
+	for _, f := range tree.Files() {
+		chapters = append(chapters, pdf.Chapter{
+			Entry:  *f,
+			Source: sources[f.Path],
+			Root:   conv.ParseFile(sources[f.Path], f.RelPath),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Write(&buf, pdf.Document{
+		Title:      title,
+		Chapters:   chapters,
+		PageBreaks: pageBreaks,
+		CodeStyle:  codeStyle,
+		Dark:       dark,
+		Warn: func(msg string) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating PDF: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// exportName derives a base name for exported documents: the file name for
+// a single file, otherwise the name of the common directory.
+func exportName(baseDir string, files []string) string {
+	if len(files) == 1 {
+		name := filepath.Base(files[0])
+		return name[:len(name)-len(filepath.Ext(name))]
+	}
+	if name := filepath.Base(baseDir); name != "." && name != string(filepath.Separator) && name != "" {
+		return name
+	}
+	return "export"
+}
+
+func printExportUsage() {
+	fmt.Println(`Usage: mdp export --format <format> [options] <path>...
+
+Export markdown files to another format. Multiple files and directories
+are combined in sidebar order.
+
+Formats:
+  epub       EPUB 3 e-book with a table of contents built from headings
+  pdf        Paginated PDF with cover page, table of contents and page numbers
+
+Options:
+  --format <format>      Output format (required)
+  -O, --output <file>    Output file (default: <name>.<format>)
+  --title <title>        Document title (default: file or directory name)
+  --page-breaks          Start each file on a new page (pdf only)
+  --theme <mode>         Color scheme: light, dark or system (default: the
+                         theme setting; system leaves EPUB readers to choose
+                         and prints PDFs light)
+  --code-theme <name>    Chroma style for code (default: code_theme_light,
+                         or code_theme_dark with the dark theme)
+  --safe                 Omit raw HTML and script URLs from untrusted markdown
+  -h, --help             Show this help message`)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunExport_MissingFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "test.md")
+	if err := os.WriteFile(inputFile, []byte("# Test"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	err := run([]string{"export", inputFile})
+	if err == nil {
+		t.Fatal("expected error when --format is missing")
+	}
+	if !strings.Contains(err.Error(), "--format") {
+		t.Errorf("expected missing format error, got: %v", err)
+	}
+}
+
+func TestRunExport_UnsupportedFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "test.md")
+	if err := os.WriteFile(inputFile, []byte("# Test"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	err := run([]string{"export", "--format", "docx", inputFile})
+	if err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+	if !strings.Contains(err.Error(), "unsupported export format") {
+		t.Errorf("expected unsupported format error, got: %v", err)
+	}
+}
+
+func TestRunExport_Help(t *testing.T) {
+	if err := run([]string{"export", "--help"}); err != nil {
+		t.Errorf("export --help should not return error, got: %v", err)
+	}
+}
+
+func TestRunExport_EPUB(t *testing.T) {
+	tmpDir := t.TempDir()
+	docsDir := filepath.Join(tmpDir, "docs")
+	if err := os.MkdirAll(filepath.Join(docsDir, "guide"), 0755); err != nil {
+		t.Fatalf("failed to create docs dir: %v", err)
+	}
+
+	files := map[string]string{
+		"intro.md":         "# Intro\n\nSee [install](guide/install.md).",
+		"guide/install.md": "# Install\n\n## Linux\n\nRun it.",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(docsDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	outputFile := filepath.Join(tmpDir, "book.epub")
+	if err := run([]string{"export", "--format", "epub", "-O", outputFile, docsDir}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	zr, err := zip.OpenReader(outputFile)
+	if err != nil {
+		t.Fatalf("failed to open epub: %v", err)
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	joined := strings.Join(names, "\n")
+
+	for _, want := range []string{"mimetype", "OEBPS/nav.xhtml", "OEBPS/intro-md.xhtml", "OEBPS/guide-install-md.xhtml"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected epub to contain %s, got: %v", want, names)
+		}
+	}
+}
+
+func TestRunExport_Themes(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	inputFile := filepath.Join(tmpDir, "guide.md")
+	if err := os.WriteFile(inputFile, []byte("# Guide\n\n```go\nfunc main() {}\n```\n"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	epubFile := filepath.Join(tmpDir, "guide.epub")
+	if err := run([]string{"export", "--format", "epub", "--theme", "dark", "--code-theme", "monokai", "-O", epubFile, inputFile}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	zr, err := zip.OpenReader(epubFile)
+	if err != nil {
+		t.Fatalf("failed to open epub: %v", err)
+	}
+	defer zr.Close()
+	var css []byte
+	for _, f := range zr.File {
+		if f.Name == "OEBPS/style.css" {
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			css, _ = io.ReadAll(r)
+			r.Close()
+		}
+	}
+	if strings.Contains(string(css), "prefers-color-scheme") {
+		t.Error("expected the stylesheet to be fixed to the dark theme")
+	}
+	if !strings.Contains(string(css), "/* Background */ .markdown-body .hl-bg { color: #f8f8f2; background-color: #272822; }") {
+		t.Error("expected the monokai code theme in the stylesheet")
+	}
+
+	pdfFile := filepath.Join(tmpDir, "guide.pdf")
+	if err := run([]string{"export", "--format", "pdf", "--theme", "dark", "--code-theme", "monokai", "-O", pdfFile, inputFile}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	for _, args := range [][]string{{"--theme", "sepia"}, {"--code-theme", "nope"}} {
+		args = append(append([]string{"export", "--format", "pdf"}, args...), "-O", pdfFile, inputFile)
+		if err := run(args); err == nil {
+			t.Errorf("run(%v) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestExportName(t *testing.T) {
+	tests := []struct {
+		baseDir  string
+		files    []string
+		expected string
+	}{
+		{"/a/docs", []string{"/a/docs/guide.md"}, "guide"},
+		{"/a/docs", []string{"/a/docs/x.md", "/a/docs/y.md"}, "docs"},
+		{".", []string{"x.md", "y.md"}, "export"},
+	}
+
+	for _, tc := range tests {
+		result := exportName(tc.baseDir, tc.files)
+		if result != tc.expected {
+			t.Errorf("exportName(%q, %v) = %q, want %q", tc.baseDir, tc.files, result, tc.expected)
+		}
+	}
+}
+
+func TestRunExport_PDF(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "guide.md")
+	if err := os.WriteFile(inputFile, []byte("# Guide\n\n## Usage\n\nRun `mdp`."), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "guide.pdf")
+	if err := run([]string{"export", "--format", "pdf", "--page-breaks", "-O", outputFile, inputFile}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read pdf: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "%PDF-") {
+		t.Errorf("expected PDF output, got %q", data[:min(len(data), 8)])
+	}
+}
+
+func TestExportPDF_ReadsEachFileOnce(t *testing.T) {
+	files := map[string]string{
+		"/docs/a.md": "# A\n\nSee [[b]].\n",
+		"/docs/b.md": "# B\n",
+	}
+	reads := make(map[string]int)
+	opts := renderOptions{read: func(path string) ([]byte, error) {
+		reads[path]++
+		content, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}}
+
+	if _, err := exportPDF([]string{"/docs/a.md", "/docs/b.md"}, "/docs", "Docs", false, false, "", opts); err != nil {
+		t.Fatalf("exportPDF() error = %v", err)
+	}
+	for path := range files {
+		if reads[path] != 1 {
+			t.Errorf("%s was read %d times, want once", path, reads[path])
+		}
+	}
+}
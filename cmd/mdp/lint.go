@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"mdp/internal/config"
+	"mdp/internal/converter"
+	"mdp/internal/lint"
+)
+
+// runLint handles the 'mdp lint' subcommand.
+func runLint(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printLintUsage()
+			return nil
+		}
+	}
+
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	formatFlag := fs.String("format", "text", "Output format: text, json or sarif")
+	outputFlag := fs.String("output", "", "Write results to file instead of stdout")
+	fs.StringVar(outputFlag, "O", "", "Write results to file instead of stdout (shorthand)")
+	disableFlag := fs.String("disable", "", "Comma-separated rules to turn off")
+	lineLengthFlag := fs.Int("line-length", 0, "Longest line allowed by MD013")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v\nRun 'mdp lint --help' for usage", err)
+	}
+
+	switch *formatFlag {
+	case "text", "json", "sarif":
+	default:
+		return fmt.Errorf("unsupported lint format: %s (must be text, json or sarif)", *formatFlag)
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := resolveFiles(paths, markdownOnly)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("No markdown files found")
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	if *disableFlag != "" {
+		for _, name := range strings.Split(*disableFlag, ",") {
+			cfg.Lint.Disable = append(cfg.Lint.Disable, strings.TrimSpace(name))
+		}
+	}
+	if *lineLengthFlag > 0 {
+		cfg.Lint.LineLength = *lineLengthFlag
+	}
+	opts, err := newRenderOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	linter := lint.New(converter.New(opts.converterFor(files, findCommonBase(files))...), opts.lint...)
+	var problems []lint.Problem
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %v", path, err)
+		}
+		problems = append(problems, linter.Lint(path, content)...)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			return fmt.Errorf("Error writing results: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *formatFlag {
+	case "json":
+		err = lint.WriteJSON(out, problems)
+	case "sarif":
+		err = lint.WriteSARIF(out, problems, version)
+	default:
+		err = lint.WriteText(out, problems)
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing results: %v", err)
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("Found 1 problem")
+	default:
+		return fmt.Errorf("Found %d problems", len(problems))
+	}
+}
+
+func printLintUsage() {
+	var rules strings.Builder
+	for _, rule := range lint.Rules {
+		fmt.Fprintf(&rules, "\n  %s  %-22s %s", rule.ID, rule.Name, rule.Description)
+	}
+
+	fmt.Printf(`mdp lint - Check markdown for common problems
+
+Usage:
+  mdp lint [options] [path...]
+
+Options:
+  --format <format>            Output format: text (default), json or sarif
+  -O, --output <file>          Write results to file instead of stdout
+  --disable <rules>            Comma-separated rules to turn off, by ID or name
+  --line-length <n>            Longest line allowed by MD013 (default %d)
+
+Rules:%s
+
+Paths default to the current directory. Rules can also be configured in
+.mdp/config.json:
+
+  {"lint": {"disable": ["MD013"], "line_length": 100}}
+
+Exits with status 1 if any problems are found.
+
+Examples:
+  mdp lint README.md
+  mdp lint --disable line-length docs/
+  mdp lint --format sarif -O mdp.sarif .
+`, lint.DefaultLineLength, rules.String())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	if err := os.WriteFile("good.md", []byte("# Good\n\nText.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("bad.md", []byte("# Bad\n\n### Skipped\n\n![](x.png)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"lint", "good.md"}); err != nil {
+		t.Errorf("run(lint good.md) = %v, want no error", err)
+	}
+
+	output := filepath.Join(dir, "out.txt")
+	err := run([]string{"lint", "-O", output, "bad.md"})
+	if err == nil || err.Error() != "Found 2 problems" {
+		t.Errorf("run(lint bad.md) = %v, want 2 problems", err)
+	}
+	text, _ := os.ReadFile(output)
+	if !strings.Contains(string(text), "bad.md:3:1: MD001/heading-increment") {
+		t.Errorf("unexpected text output:\n%s", text)
+	}
+
+	run([]string{"lint", "--format", "json", "-O", output, "--disable", "no-alt-text", "."})
+	data, _ := os.ReadFile(output)
+	var problems []struct{ File, Rule string }
+	if err := json.Unmarshal(data, &problems); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, data)
+	}
+	if len(problems) != 1 || problems[0].Rule != "MD001" {
+		t.Errorf("JSON problems = %+v, want only MD001", problems)
+	}
+}
+
+func TestRunLint_Errors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"lint", "--format", "xml", "."}, "unsupported lint format"},
+		{[]string{"lint", "--disable", "MD999", "."}, "unknown lint rule: MD999"},
+	}
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.md", []byte("# A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		err := run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("run(%v) = %v, want error containing %q", tt.args, err, tt.want)
+		}
+	}
+}
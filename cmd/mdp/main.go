@@ -1,22 +1,33 @@
 package main
 
 import (
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	gitignore "github.com/sabhiram/go-gitignore"
 
 	"mdp/internal/browser"
+	"mdp/internal/config"
 	"mdp/internal/converter"
+	"mdp/internal/diagram"
 	"mdp/internal/filetree"
+	"mdp/internal/git"
+	"mdp/internal/include"
 	"mdp/internal/linkrewriter"
+	"mdp/internal/lint"
+	"mdp/internal/notebook"
 	"mdp/internal/server"
+	"mdp/internal/spell"
 	"mdp/internal/template"
 	"mdp/internal/updater"
 )
@@ -42,6 +53,16 @@ func run(args []string) error {
 			return nil
 		case "upgrade":
 			return runUpgrade(args[1:])
+		case "export":
+			return runExport(args[1:])
+		case "diff":
+			return runDiff(args[1:])
+		case "show":
+			return runShow(args[1:])
+		case "lint":
+			return runLint(args[1:])
+		case "spell":
+			return runSpell(args[1:])
 		}
 	}
 
@@ -60,6 +81,15 @@ func run(args []string) error {
 	portFlag := fs.Int("port", 8080, "Port for live reload server (only with --serve)")
 	outputFlag := fs.String("output", "", "Write HTML to file instead of opening browser")
 	fs.StringVar(outputFlag, "O", "", "Write HTML to file instead of opening browser (shorthand)")
+	safeFlag := fs.Bool("safe", false, "Render untrusted markdown safely")
+	codeThemeLightFlag := fs.String("code-theme-light", "", "Chroma style for code in light mode")
+	codeThemeDarkFlag := fs.String("code-theme-dark", "", "Chroma style for code in dark mode")
+	themeFlag := fs.String("theme", "", "Initial color scheme: light, dark or system")
+	cssFlag := fs.String("css", "", "Custom CSS file")
+	layoutFlag := fs.String("layout", "", "Custom html/template layout file")
+	slidesFlag := fs.Bool("slides", false, "Present markdown as slides")
+	revFlag := fs.String("rev", "", "Read markdown from a git revision instead of the working tree")
+	csvFlag := fs.Bool("csv", false, "Include .csv and .tsv files when previewing directories")
 
 	// Parse flags
 	if err := fs.Parse(args); err != nil {
@@ -77,7 +107,29 @@ func run(args []string) error {
 		return fmt.Errorf("Usage: mdp <markdown-file.md>\nRun 'mdp --help' for more information")
 	}
 
-	files, err := resolveFiles(fileArgs)
+	// Validate flag combinations
+	if *outputFlag != "" && *serveFlag {
+		return fmt.Errorf("cannot use --output with --serve")
+	}
+	if *revFlag != "" && *serveFlag {
+		return fmt.Errorf("cannot use --rev with --serve")
+	}
+
+	tables := namedTables
+	if *csvFlag {
+		tables = allTables
+	}
+	var rev *revSource
+	var files []string
+	var err error
+	if *revFlag != "" {
+		if rev, err = newRevSource(*revFlag); err != nil {
+			return err
+		}
+		files, err = rev.resolveFiles(fileArgs, tables)
+	} else {
+		files, err = resolveFiles(fileArgs, tables)
+	}
 	if err != nil {
 		return err
 	}
@@ -86,27 +138,342 @@ func run(args []string) error {
 		return fmt.Errorf("No markdown files found")
 	}
 
-	// Validate flag combinations
-	if *outputFlag != "" && *serveFlag {
-		return fmt.Errorf("cannot use --output with --serve")
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	if *safeFlag {
+		cfg.Safe = true
+	}
+	if *codeThemeLightFlag != "" {
+		cfg.CodeThemeLight = *codeThemeLightFlag
+	}
+	if *codeThemeDarkFlag != "" {
+		cfg.CodeThemeDark = *codeThemeDarkFlag
+	}
+	if *themeFlag != "" {
+		cfg.Theme = *themeFlag
+	}
+	if *cssFlag != "" {
+		cfg.CSS = *cssFlag
+	}
+	if *layoutFlag != "" {
+		cfg.Layout = *layoutFlag
+	}
+	opts, err := newRenderOptions(cfg)
+	if err != nil {
+		return err
+	}
+	if rev != nil {
+		opts.read = rev.readFile
+		opts.rev = rev.rev
 	}
 
 	// Serve mode with live reload
 	if *serveFlag {
-		return runServe(files, *portFlag)
+		return runServe(files, *portFlag, *slidesFlag, opts)
 	}
 
 	// Static mode (original behavior)
+	if *slidesFlag {
+		return runSlides(files, *outputFlag, opts)
+	}
 	if len(files) == 1 {
-		return runSingleFile(files[0], *outputFlag)
+		return runSingleFile(files[0], *outputFlag, opts)
 	}
 
-	return runMultiFile(files, *outputFlag)
+	return runMultiFile(files, *outputFlag, opts)
+}
+
+// renderOptions holds the converter and template options for previews.
+type renderOptions struct {
+	converter []converter.Option
+	template  []template.Option
+	lint      []lint.Option
+	notebook  []notebook.Option
+	spell     config.SpellConfig
+	read      func(path string) ([]byte, error) // nil reads the working tree
+	rev       string                            // Commit selected with --rev, empty for the working tree
+	includes  bool                              // Expand include directives
+}
+
+// readFile reads a markdown file from the working tree, or from the git
+// revision selected with --rev, with its include directives expanded. CSV
+// and TSV files are read as Markdown showing them as a table, and notebooks
+// as Markdown showing their cells and outputs.
+func (o renderOptions) readFile(path string) ([]byte, error) {
+	content, err := o.readSource(path)
+	if err == nil && converter.IsTableFile(path) {
+		return converter.TableFileMarkdown(path, content), nil
+	}
+	if err == nil && notebook.IsNotebook(path) {
+		return notebook.Markdown(content, o.notebook...)
+	}
+	if err != nil || !o.includes {
+		return content, err
+	}
+	content, _, err = include.Expand(content, path, o.readSource, include.WithRoot(filepath.Dir(path)))
+	return content, err
+}
+
+// readSource reads a file as it is, without expanding includes.
+func (o renderOptions) readSource(path string) ([]byte, error) {
+	if o.read != nil {
+		return o.read(path)
+	}
+	return os.ReadFile(path)
+}
+
+// newRenderOptions derives preview options from the merged configuration.
+func newRenderOptions(cfg *config.Config) (renderOptions, error) {
+	// Untrusted markdown must not pull in other files
+	opts := renderOptions{includes: !cfg.Safe}
+	if cfg.Safe {
+		opts.converter = append(opts.converter, converter.WithSafeMode())
+		opts.template = append(opts.template, template.WithSafeMode())
+		opts.notebook = append(opts.notebook, notebook.WithSafeMode())
+	}
+
+	for _, name := range []string{cfg.CodeThemeLight, cfg.CodeThemeDark} {
+		if name != "" && !template.IsCodeTheme(name) {
+			return opts, fmt.Errorf("unknown code theme: %s\nAvailable themes: %s", name, strings.Join(template.CodeThemes(), ", "))
+		}
+	}
+	if cfg.CodeThemeLight != "" || cfg.CodeThemeDark != "" {
+		opts.template = append(opts.template, template.WithCodeThemes(cfg.CodeThemeLight, cfg.CodeThemeDark))
+	}
+
+	if cfg.Theme != "" {
+		if !template.IsColorScheme(cfg.Theme) {
+			return opts, fmt.Errorf("invalid theme: %s (must be one of %s)", cfg.Theme, strings.Join(template.ColorSchemes, ", "))
+		}
+		opts.template = append(opts.template, template.WithColorScheme(cfg.Theme))
+	}
+
+	if cfg.CSS != "" {
+		css, err := template.ReadCustomCSS(cfg.CSS)
+		if err != nil {
+			return opts, err
+		}
+		opts.template = append(opts.template, template.WithCustomCSS(css))
+	}
+	if cfg.Layout != "" {
+		layout, err := template.ParseLayout(cfg.Layout)
+		if err != nil {
+			return opts, err
+		}
+		opts.template = append(opts.template, template.WithLayout(layout))
+	}
+
+	extensions := converter.DefaultExtensions
+	if cfg.Extensions != nil {
+		extensions = nil
+		for _, name := range cfg.Extensions {
+			ext, ok := converter.LookupExtension(name)
+			if !ok {
+				return opts, fmt.Errorf("unknown markdown extension: %s\nAvailable extensions: %s", name, extensionNames())
+			}
+			extensions = append(extensions, ext)
+		}
+	}
+	opts.converter = append(opts.converter, converter.WithExtensions(extensions...))
+
+	if len(cfg.Emoji) > 0 {
+		images := make(map[string]string, len(cfg.Emoji))
+		for name, image := range cfg.Emoji {
+			if !converter.IsEmojiShortcode(name) {
+				return opts, fmt.Errorf("invalid emoji shortcode: %s (use letters, digits, _, + and -)", name)
+			}
+			src, err := emojiImage(image)
+			if err != nil {
+				return opts, err
+			}
+			images[name] = src
+		}
+		opts.converter = append(opts.converter, converter.WithCustomEmoji(images))
+	}
+
+	for _, setting := range cfg.Ignored {
+		fmt.Fprintf(os.Stderr, "Ignoring %s\n", setting)
+	}
+	diagrams, err := diagramOptions(cfg.Diagrams)
+	if err != nil {
+		return opts, err
+	}
+	opts.converter = append(opts.converter, diagrams...)
+	renderers, err := rendererOptions(cfg.Renderers)
+	if err != nil {
+		return opts, err
+	}
+	opts.converter = append(opts.converter, renderers...)
+
+	for _, name := range cfg.Lint.Disable {
+		if _, ok := lint.LookupRule(name); !ok {
+			return opts, fmt.Errorf("unknown lint rule: %s", name)
+		}
+	}
+	opts.lint = []lint.Option{lint.WithDisabled(cfg.Lint.Disable...), lint.WithLineLength(cfg.Lint.LineLength)}
+	opts.spell = cfg.Spell
+	return opts, nil
+}
+
+// extensionNames lists the optional markdown extensions for messages.
+func extensionNames() string {
+	names := make([]string, len(converter.Extensions))
+	for i, ext := range converter.Extensions {
+		names[i] = string(ext)
+	}
+	return strings.Join(names, ", ")
+}
+
+// diagramOptions returns the converter options that render diagrams with
+// local tools, running the commands in the config instead of the defaults.
+// Rendered diagrams are cached, so only changed ones run a tool again.
+func diagramOptions(commands map[string][]string) ([]converter.Option, error) {
+	for lang := range commands {
+		if diagram.DefaultCommand(lang) == nil {
+			return nil, fmt.Errorf("unknown diagram language: %s\nAvailable diagram languages: %s", lang, strings.Join(diagram.Languages(), ", "))
+		}
+	}
+
+	var opts []converter.Option
+	for _, lang := range diagram.Languages() {
+		command, ok := commands[lang]
+		if !ok {
+			command = diagram.DefaultCommand(lang)
+		}
+		if len(command) == 0 {
+			continue
+		}
+		renderOpts := append(diagramCache(), diagram.WithEnv(diagram.Sandbox(lang)...))
+		opts = append(opts, converter.WithDiagram(lang, diagram.New(command, renderOpts...).Render))
+	}
+	return opts, nil
+}
+
+// rendererOptions returns the converter options that render code blocks
+// with the external commands in the config. They are added after the
+// built-in diagrams, so they replace them for the same language.
+func rendererOptions(renderers map[string]config.RendererConfig) ([]converter.Option, error) {
+	langs := make([]string, 0, len(renderers))
+	for lang := range renderers {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	var opts []converter.Option
+	for _, lang := range langs {
+		renderer := renderers[lang]
+		if lang == "" || strings.ContainsAny(lang, " \t\n") {
+			return nil, fmt.Errorf("invalid renderer language: %q", lang)
+		}
+		if len(renderer.Command) == 0 {
+			return nil, fmt.Errorf("renderer for %s has no command", lang)
+		}
+		renderOpts := diagramCache()
+		if renderer.Timeout != "" {
+			timeout, err := time.ParseDuration(renderer.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout for renderer %s: %s (use a duration such as 10s)", lang, renderer.Timeout)
+			}
+			renderOpts = append(renderOpts, diagram.WithTimeout(timeout))
+		}
+		opts = append(opts, converter.WithDiagram(lang, diagram.New(renderer.Command, renderOpts...).Render))
+	}
+	return opts, nil
+}
+
+// diagramCache returns the option that caches rendered diagrams in the mdp
+// cache directory, or none if it is unknown.
+func diagramCache() []diagram.Option {
+	cacheDir, err := updater.GetCacheDir()
+	if err != nil {
+		return nil
+	}
+	return []diagram.Option{diagram.WithCache(filepath.Join(cacheDir, "diagrams"))}
+}
+
+// emojiImage returns the image source for a custom emoji. Image files are
+// embedded as data URLs so they show wherever the preview is written.
+func emojiImage(image string) (string, error) {
+	if config.IsURL(image) {
+		return image, nil
+	}
+	data, err := os.ReadFile(image)
+	if err != nil {
+		return "", fmt.Errorf("Error reading emoji image %s: %v", image, err)
+	}
+	mediaType := mime.TypeByExtension(filepath.Ext(image))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// converterFor returns the converter options for previewing files, with
+// wiki links resolved against them. baseDir is their common directory.
+func (o renderOptions) converterFor(files []string, baseDir string) []converter.Option {
+	return o.converterWith(files, baseDir, o.readFile)
+}
+
+// converterWith is like converterFor, reading the files with read.
+func (o renderOptions) converterWith(files []string, baseDir string, read func(string) ([]byte, error)) []converter.Option {
+	pages := make([]converter.WikiPage, 0, len(files))
+	for _, path := range files {
+		page := converter.WikiPage{RelPath: relativePath(path, baseDir)}
+		if content, err := read(path); err == nil {
+			page.Title = converter.Title(content)
+		}
+		pages = append(pages, page)
+	}
+	wiki := converter.WithWikiLinks(pages, func(relPath string) ([]byte, error) {
+		return read(filepath.Join(baseDir, relPath))
+	})
+
+	opts := make([]converter.Option, 0, len(o.converter)+1)
+	opts = append(opts, o.converter...)
+	return append(opts, wiki)
+}
+
+// loadDictionary reads the configured dictionary, or the built-in one, and
+// adds the words from the config. Word lists are added by the caller.
+func (o renderOptions) loadDictionary() (*spell.Dictionary, error) {
+	var dict *spell.Dictionary
+	var err error
+	if o.spell.Dictionary != "" {
+		dict, err = spell.LoadDictionaryFiles(o.spell.Dictionary)
+	} else {
+		dict, err = spell.Builtin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	dict.Add(o.spell.Words...)
+	return dict, nil
 }
 
 // runServe starts the live reload server.
-func runServe(files []string, port int) error {
-	srv, err := server.New(port, files)
+func runServe(files []string, port int, slides bool, opts renderOptions) error {
+	serverOpts := []server.Option{
+		server.WithConverterOptions(opts.converterFor(files, findCommonBase(files))...),
+		server.WithTemplateOptions(opts.template...),
+		server.WithLintOptions(opts.lint...),
+		server.WithNotebookOptions(opts.notebook...),
+	}
+	if slides {
+		serverOpts = append(serverOpts, server.WithSlides())
+	}
+	if opts.includes {
+		serverOpts = append(serverOpts, server.WithIncludes())
+	}
+	if !opts.spell.Disable {
+		dict, err := opts.loadDictionary()
+		if err != nil {
+			return err
+		}
+		serverOpts = append(serverOpts, server.WithSpelling(dict, config.WordLists(".")...))
+	}
+	srv, err := server.New(port, files, serverOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to create server: %w", err)
 	}
@@ -141,8 +508,39 @@ Options:
 	return updater.PerformUpgrade(version, *forceFlag)
 }
 
+// fileKinds selects the files that resolveFiles accepts alongside
+// Markdown.
+type fileKinds int
+
+const (
+	markdownOnly fileKinds = iota // Markdown only
+	namedTables                   // Notebooks, and CSV and TSV files given by name
+	allTables                     // Notebooks, and CSV and TSV files given by name or found in directories
+)
+
+// accepts reports whether a file is previewed. named is true for files
+// given on the command line, false for files found in a directory.
+func (k fileKinds) accepts(name string, named bool) bool {
+	if strings.HasSuffix(strings.ToLower(name), ".md") {
+		return true
+	}
+	if k == markdownOnly {
+		return false
+	}
+	return notebook.IsNotebook(name) || (converter.IsTableFile(name) && (k == allTables || named))
+}
+
+// errExtension returns the error for a file given by name that k does not
+// accept.
+func (k fileKinds) errExtension(name string) error {
+	if k == markdownOnly {
+		return fmt.Errorf("Error: File must have .md extension: %s", name)
+	}
+	return fmt.Errorf("Error: File must have .md, .ipynb, .csv or .tsv extension: %s", name)
+}
+
 // resolveFiles expands directories and validates all paths.
-func resolveFiles(args []string) ([]string, error) {
+func resolveFiles(args []string, kinds fileKinds) ([]string, error) {
 	var files []string
 	for _, arg := range args {
 		info, err := os.Stat(arg)
@@ -151,14 +549,14 @@ func resolveFiles(args []string) ([]string, error) {
 		}
 
 		if info.IsDir() {
-			discovered, err := discoverMarkdownFiles(arg)
+			discovered, err := discoverMarkdownFiles(arg, kinds)
 			if err != nil {
 				return nil, err
 			}
 			files = append(files, discovered...)
 		} else {
-			if !strings.HasSuffix(strings.ToLower(arg), ".md") {
-				return nil, fmt.Errorf("Error: File must have .md extension: %s", arg)
+			if !kinds.accepts(arg, true) {
+				return nil, kinds.errExtension(arg)
 			}
 			files = append(files, arg)
 		}
@@ -167,8 +565,9 @@ func resolveFiles(args []string) ([]string, error) {
 }
 
 // discoverMarkdownFiles walks a directory recursively to find all .md files,
-// respecting .gitignore files at each level of the directory tree.
-func discoverMarkdownFiles(dir string) ([]string, error) {
+// and the notebooks, CSV and TSV files that kinds accepts, respecting
+// .gitignore files at each level of the directory tree.
+func discoverMarkdownFiles(dir string, kinds fileKinds) ([]string, error) {
 	var files []string
 
 	// Map of directory path to its gitignore matcher
@@ -204,7 +603,7 @@ func discoverMarkdownFiles(dir string) ([]string, error) {
 		}
 
 		// Check if it's a markdown file
-		if strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+		if kinds.accepts(d.Name(), false) {
 			files = append(files, path)
 		}
 		return nil
@@ -240,14 +639,14 @@ func isIgnored(path, baseDir string, matchers map[string]*gitignore.GitIgnore) b
 
 // runSingleFile handles single file preview (original behavior).
 // If outputPath is provided, writes to that path instead of /tmp and skips browser.
-func runSingleFile(filePath string, outputPath string) error {
-	markdownContent, err := os.ReadFile(filePath)
+func runSingleFile(filePath string, outputPath string, opts renderOptions) error {
+	markdownContent, err := opts.readFile(filePath)
 	if err != nil {
 		return fmt.Errorf("Error reading file: %v", err)
 	}
 
-	conv := converter.New()
-	htmlContent, err := conv.Convert(markdownContent)
+	conv := converter.New(opts.converterFor([]string{filePath}, filepath.Dir(filePath))...)
+	htmlContent, err := conv.ConvertFile(markdownContent, filepath.Base(filePath))
 	if err != nil {
 		return fmt.Errorf("Error converting markdown: %v", err)
 	}
@@ -255,7 +654,11 @@ func runSingleFile(filePath string, outputPath string) error {
 	filename := filepath.Base(filePath)
 	title := strings.TrimSuffix(filename, filepath.Ext(filename))
 
-	fullHTML := template.Generate(title, htmlContent)
+	templateOpts := opts.template[:len(opts.template):len(opts.template)]
+	if info, ok := git.Lookup(opts.rev, []string{filePath})[filePath]; ok {
+		templateOpts = append(templateOpts, template.WithFileMeta(info.Status, info.Author, info.Date))
+	}
+	fullHTML := template.Generate(title, htmlContent, templateOpts...)
 
 	// Determine output path
 	openBrowser := false
@@ -282,45 +685,30 @@ func runSingleFile(filePath string, outputPath string) error {
 
 // runMultiFile handles multiple files preview with sidebar.
 // If outputPath is provided, writes to that path instead of /tmp and skips browser.
-func runMultiFile(filePaths []string, outputPath string) error {
-	conv := converter.New()
-
+func runMultiFile(filePaths []string, outputPath string, opts renderOptions) error {
 	baseDir := findCommonBase(filePaths)
+	conv := converter.New(opts.converterFor(filePaths, baseDir)...)
 
-	var entries []filetree.FileEntry
-	for _, path := range filePaths {
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("Error reading %s: %v", path, err)
-		}
-
-		htmlContent, err := conv.Convert(content)
-		if err != nil {
-			return fmt.Errorf("Error converting %s: %v", path, err)
-		}
-
-		relPath := strings.TrimPrefix(path, baseDir)
-		relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
-
-		entries = append(entries, filetree.FileEntry{
-			ID:      sanitizeID(relPath),
-			Path:    path,
-			Name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
-			RelPath: relPath,
-			Content: htmlContent,
-		})
+	entries, err := loadEntries(filePaths, baseDir, conv, opts.readFile)
+	if err != nil {
+		return err
 	}
+	addGitInfo(entries, opts.rev)
 
-	// Rewrite relative .md links to fragment identifiers
+	// Collect backlinks, then rewrite relative .md links to fragment
+	// identifiers and make heading IDs unique across files
 	rewriter := linkrewriter.New(entries)
+	backlinks := rewriter.Backlinks(entries)
 	for i := range entries {
+		entries[i].Backlinks = backlinks[entries[i].ID]
 		entries[i].Content = rewriter.RewriteLinks(entries[i].Content, entries[i].RelPath)
+		entries[i].Content = linkrewriter.ScopeHeadingIDs(entries[i].Content, entries[i].ID)
 	}
 
 	tree := filetree.BuildTree(entries)
 
 	title := generateTitle(baseDir, filePaths)
-	fullHTML := template.GenerateMulti(title, tree, entries)
+	fullHTML := template.GenerateMulti(title, tree, entries, opts.template...)
 
 	// Determine output path
 	openBrowser := false
@@ -344,6 +732,110 @@ func runMultiFile(filePaths []string, outputPath string) error {
 	return nil
 }
 
+// runSlides presents the files as one slide deck.
+// If outputPath is provided, writes to that path instead of /tmp and skips browser.
+func runSlides(filePaths []string, outputPath string, opts renderOptions) error {
+	baseDir := findCommonBase(filePaths)
+	conv := converter.New(opts.converterFor(filePaths, baseDir)...)
+
+	var slides []converter.Slide
+	for _, path := range filePaths {
+		content, err := opts.readFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %v", path, err)
+		}
+
+		fileSlides, err := conv.ConvertSlides(content, relativePath(path, baseDir))
+		if err != nil {
+			return fmt.Errorf("Error converting %s: %v", path, err)
+		}
+		slides = append(slides, fileSlides...)
+	}
+
+	title := generateTitle(baseDir, filePaths)
+	if len(filePaths) == 1 {
+		filename := filepath.Base(filePaths[0])
+		title = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	fullHTML := template.GenerateSlides(title, slides, opts.template...)
+
+	// Determine output path
+	openBrowser := false
+	if outputPath == "" {
+		outputPath = filepath.Join("/tmp", "mdpreview-slides.html")
+		openBrowser = true
+	}
+
+	if err := os.WriteFile(outputPath, []byte(fullHTML), 0644); err != nil {
+		return fmt.Errorf("Error writing HTML file: %v", err)
+	}
+
+	if openBrowser {
+		if err := browser.Open(outputPath); err != nil {
+			return fmt.Errorf("Error opening browser: %v", err)
+		}
+		fmt.Printf("Opened %d slides in browser\n", len(slides))
+	} else {
+		fmt.Printf("Wrote %s\n", outputPath)
+	}
+	return nil
+}
+
+// loadEntries reads and converts each file into a FileEntry, with paths
+// relative to baseDir.
+func loadEntries(filePaths []string, baseDir string, conv *converter.Converter, read func(string) ([]byte, error)) ([]filetree.FileEntry, error) {
+	var entries []filetree.FileEntry
+	for _, path := range filePaths {
+		content, err := read(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %v", path, err)
+		}
+
+		entry := newFileEntry(path, baseDir)
+		entry.Content, err = conv.ConvertFile(content, entry.RelPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error converting %s: %v", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// newFileEntry returns the FileEntry for path, without content.
+func newFileEntry(path, baseDir string) filetree.FileEntry {
+	relPath := relativePath(path, baseDir)
+	return filetree.FileEntry{
+		ID:      sanitizeID(relPath),
+		Path:    path,
+		Name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		RelPath: relPath,
+	}
+}
+
+// relativePath returns path relative to baseDir, the common directory of
+// the previewed files.
+func relativePath(path, baseDir string) string {
+	relPath := strings.TrimPrefix(path, baseDir)
+	return strings.TrimPrefix(relPath, string(filepath.Separator))
+}
+
+// addGitInfo sets the change status and last commit of entries whose files
+// are in a git repository. With a revision, only the last commit is set.
+func addGitInfo(entries []filetree.FileEntry, rev string) {
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.Path
+	}
+	info := git.Lookup(rev, paths)
+	for i := range entries {
+		if fi, ok := info[entries[i].Path]; ok {
+			entries[i].Status = fi.Status
+			entries[i].Author = fi.Author
+			entries[i].Modified = fi.Date
+		}
+	}
+}
+
 // sanitizeID converts a path to a valid HTML id attribute.
 func sanitizeID(path string) string {
 	id := strings.ReplaceAll(path, "/", "-")
@@ -409,6 +901,11 @@ Usage:
   mdp <file.md>                Preview single markdown file
   mdp <file1.md> <file2.md>    Preview multiple files with sidebar
   mdp <directory>              Preview all .md files in directory
+  mdp export [options] <path>  Export markdown to another format
+  mdp diff <old>..<new> [path] Render markdown changes between git revisions
+  mdp show <rev>:<path>        Preview a file or directory as of a git revision
+  mdp lint [path...]           Check markdown for common problems
+  mdp spell [path...]          Check spelling in markdown
   mdp upgrade                  Upgrade mdp to the latest version
   mdp -h, --help               Show this help message
   mdp -v, --version            Show version
@@ -417,6 +914,55 @@ Options:
   -O, --output <file>          Write HTML to file instead of opening browser
   --serve                      Start live reload server instead of opening browser
   --port <port>                Port for live reload server (default: 8080)
+  --safe                       Render untrusted markdown: omit raw HTML, block
+                               script URLs and apply a Content-Security-Policy
+  --code-theme-light <name>    Chroma style for code in light mode (e.g. vs)
+  --code-theme-dark <name>     Chroma style for code in dark mode (e.g. monokai)
+  --theme <mode>               Initial color scheme: light, dark or system
+                               (default: system)
+  --css <file>                 Custom CSS applied after the built-in styles
+  --layout <file>              Go html/template replacing the page layout
+  --rev <commit>               Read markdown from a git revision (branch, tag
+                               or commit) instead of the working tree
+  --slides                     Present as slides, split at --- or H1/H2
+                               headings (with --serve, opens /slides)
+  --csv                        Include .csv and .tsv files when previewing
+                               directories
+
+Export Options:
+  --format <format>            Output format: epub, pdf
+  -O, --output <file>          Output file (default: <name>.<format>)
+  --title <title>              Document title
+  --page-breaks                Start each file on a new page (pdf only)
+
+Configuration:
+  Settings are read from ~/.config/mdp/config.json and then .mdp/config.json
+  in the current directory. Flags take precedence.
+
+  {"safe": true}               Always use --safe
+  {"code_theme_dark": "dracula"}
+                               Default for --code-theme-dark (also
+                               code_theme_light)
+  {"theme": "dark"}            Default for --theme
+  {"css": "brand.css"}         Default for --css (also layout); relative to
+                               the config file's directory
+  {"extensions": ["footnote"]}
+                               Markdown extensions to enable (default:
+                               footnote, definition-list, emoji; also
+                               typographer, attributes)
+  {"emoji": {"shipit": "emoji/shipit.png"}}
+                               Custom :shortcodes: shown as images; relative
+                               to the config file's directory
+  {"diagrams": {"plantuml": ["java", "-jar", "plantuml.jar", "-tsvg", "-pipe"]}}
+                               Command that renders dot, plantuml or d2
+                               blocks to SVG from stdin; [] turns one off
+                               (user config only)
+  {"renderers": {"bob": {"command": ["svgbob"], "timeout": "10s"}}}
+                               Command that renders bob code blocks to HTML
+                               or SVG from stdin (user config only)
+  {"spell": {"words": ["mdp"]}}
+                               Words to accept when spell checking (also
+                               listed one per line in .mdp/words.txt)
 
 Upgrade Options:
   --force                      Force upgrade even if already up to date
@@ -424,9 +970,20 @@ Upgrade Options:
 Examples:
   mdp README.md                Preview single file
   mdp docs/                    Preview all markdown in docs/
+  mdp analysis.ipynb           Preview a Jupyter notebook with its outputs
+  mdp data.csv                 Preview a CSV file as a sortable table
   mdp README.md CHANGELOG.md   Preview multiple files with sidebar
   mdp -O site.html docs/       Convert docs to single HTML file
   mdp --serve README.md        Start live reload server for single file
   mdp --serve --port 3000 .    Live reload all markdown in current directory
+  mdp --safe README.md         Preview a third-party README safely
+  mdp --theme light -O doc.html README.md
+                               Export HTML that opens in light mode
+  mdp --code-theme-dark monokai README.md
+                               Highlight code with Monokai in dark mode
+  mdp --slides deck.md         Present deck.md (P opens the presenter view)
+  mdp diff main..feature docs/ Review doc changes on a branch
+  mdp --rev v1.0 docs/         Preview docs as released in v1.0
+  mdp export --format epub docs/  Package docs as an EPUB book
   mdp upgrade                  Upgrade to the latest version`)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/base64"
 	"os"
 	"path/filepath"
 	"strings"
@@ -57,7 +58,7 @@ func TestRun_InvalidExtension(t *testing.T) {
 	if err == nil {
 		t.Error("expected error for invalid extension")
 	}
-	if !strings.Contains(err.Error(), ".md extension") {
+	if !strings.Contains(err.Error(), ".md, .ipynb, .csv or .tsv extension") {
 		t.Errorf("expected extension error, got: %v", err)
 	}
 }
@@ -268,7 +269,7 @@ func TestResolveFiles_SingleFile(t *testing.T) {
 		t.Fatalf("failed to create temp file: %v", err)
 	}
 
-	files, err := resolveFiles([]string{tmpFile})
+	files, err := resolveFiles([]string{tmpFile}, namedTables)
 	if err != nil {
 		t.Fatalf("resolveFiles failed: %v", err)
 	}
@@ -297,7 +298,7 @@ func TestResolveFiles_Directory(t *testing.T) {
 		t.Fatalf("failed to create file: %v", err)
 	}
 
-	files, err := resolveFiles([]string{tmpDir})
+	files, err := resolveFiles([]string{tmpDir}, namedTables)
 	if err != nil {
 		t.Fatalf("resolveFiles failed: %v", err)
 	}
@@ -307,6 +308,48 @@ func TestResolveFiles_Directory(t *testing.T) {
 	}
 }
 
+func TestResolveFiles_Tables(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.md", "analysis.ipynb", "data.csv", "data.tsv", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	csvFile := filepath.Join(tmpDir, "data.csv")
+
+	tests := []struct {
+		name    string
+		args    []string
+		kinds   fileKinds
+		want    int
+		wantErr string
+	}{
+		{"named csv", []string{csvFile}, namedTables, 1, ""},
+		{"named csv not accepted", []string{csvFile}, markdownOnly, 0, "must have .md extension"},
+		{"named txt", []string{filepath.Join(tmpDir, "notes.txt")}, namedTables, 0, "must have .md, .ipynb, .csv or .tsv extension"},
+		{"directory skips tables", []string{tmpDir}, namedTables, 2, ""},
+		{"directory with tables", []string{tmpDir}, allTables, 4, ""},
+		{"markdown only", []string{tmpDir}, markdownOnly, 1, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := resolveFiles(tc.args, tc.kinds)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveFiles failed: %v", err)
+			}
+			if len(files) != tc.want {
+				t.Errorf("expected %d files, got %v", tc.want, files)
+			}
+		})
+	}
+}
+
 func TestSanitizeID(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -530,3 +573,737 @@ func TestRun_OutputFlag_Directory(t *testing.T) {
 		}
 	}
 }
+
+func TestRun_SafeFlag(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "untrusted.md")
+	input := "# Untrusted\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1))"
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.html")
+	if err := run([]string{"--safe", "-O", outputFile, inputFile}); err != nil {
+		t.Fatalf("run() with --safe failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	html := string(content)
+
+	if strings.Contains(html, "alert(1)</script>") || strings.Contains(html, `href="javascript:`) {
+		t.Error("expected raw HTML and javascript: URLs to be removed")
+	}
+	if !strings.Contains(html, "Content-Security-Policy") {
+		t.Error("expected Content-Security-Policy meta tag")
+	}
+}
+
+func TestRun_CodeThemeFlags(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "code.md")
+	if err := os.WriteFile(inputFile, []byte("```go\nfunc main() {}\n```"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.html")
+	if err := run([]string{"--code-theme-dark", "monokai", "-O", outputFile, inputFile}); err != nil {
+		t.Fatalf("run() with --code-theme-dark failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(content), "@media (prefers-color-scheme: dark) {\n/* Background */ .markdown-body .hl-bg { color: #f8f8f2; background-color: #272822; }") {
+		t.Error("expected monokai styles for dark mode in output")
+	}
+}
+
+func TestRun_UnknownCodeTheme(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "code.md")
+	if err := os.WriteFile(inputFile, []byte("# Code"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	err := run([]string{"--code-theme-light", "nope", "-O", filepath.Join(tmpDir, "out.html"), inputFile})
+	if err == nil {
+		t.Fatal("expected error for unknown code theme")
+	}
+	if !strings.Contains(err.Error(), "unknown code theme: nope") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRun_ThemeFlag(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "doc.md")
+	if err := os.WriteFile(inputFile, []byte("# Doc"), 0644); err != nil {
+		t.Fatalf("failed to create input file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.html")
+	if err := run([]string{"--theme", "dark", "-O", outputFile, inputFile}); err != nil {
+		t.Fatalf("run() with --theme failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(content), "|| 'dark';") {
+		t.Error("expected export to default to dark mode")
+	}
+
+	err = run([]string{"--theme", "sepia", "-O", outputFile, inputFile})
+	if err == nil || !strings.Contains(err.Error(), "invalid theme: sepia") {
+		t.Errorf("expected invalid theme error, got: %v", err)
+	}
+}
+
+func TestRun_CustomCSSAndLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "doc.md")
+	cssFile := filepath.Join(tmpDir, "brand.css")
+	layoutFile := filepath.Join(tmpDir, "layout.html")
+	files := map[string]string{
+		inputFile:  "# Doc",
+		cssFile:    "h1 { color: #ff6600; }",
+		layoutFile: "<html><head>{{.Head}}</head><body><div class=\"brand\"></div>{{.Content}}{{.Scripts}}</body></html>",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.html")
+	if err := run([]string{"--css", cssFile, "--layout", layoutFile, "-O", outputFile, inputFile}); err != nil {
+		t.Fatalf("run() with --css and --layout failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	for _, want := range []string{"h1 { color: #ff6600; }", `<div class="brand"></div>`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	err = run([]string{"--css", filepath.Join(tmpDir, "missing.css"), "-O", outputFile, inputFile})
+	if err == nil || !strings.Contains(err.Error(), "missing.css") {
+		t.Errorf("expected error for missing CSS file, got: %v", err)
+	}
+}
+
+func TestRun_SlidesFlag(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "deck.md")
+	if err := os.WriteFile(inputFile, []byte("# Title\n\n---\n\n## Agenda\n\n<!-- keep it short -->\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "deck.html")
+	if err := run([]string{"--slides", "-O", outputFile, inputFile}); err != nil {
+		t.Fatalf("run() with --slides failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	html := string(content)
+	if n := strings.Count(html, `<section class="slide markdown-body"`); n != 2 {
+		t.Errorf("expected 2 slides, got %d", n)
+	}
+	if !strings.Contains(html, "<title>deck</title>") {
+		t.Error("expected the file name as title")
+	}
+	if !strings.Contains(html, "<p>keep it short</p>") {
+		t.Error("expected speaker notes from the HTML comment")
+	}
+
+	// Wiki links resolve like in the preview
+	if err := os.WriteFile(inputFile, []byte("# Title\n\nSee [[notes]].\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notesFile := filepath.Join(tmpDir, "notes.md")
+	if err := os.WriteFile(notesFile, []byte("# Notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"--slides", "-O", outputFile, inputFile, notesFile}); err != nil {
+		t.Fatalf("run() with --slides failed: %v", err)
+	}
+	content, _ = os.ReadFile(outputFile)
+	if !strings.Contains(string(content), `<a href="notes.md" class="wikilink">notes</a>`) {
+		t.Error("expected the wiki link to be rendered as a link")
+	}
+}
+
+func TestRun_GitStatus(t *testing.T) {
+	dir := newGitRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "docs", "same.md"), []byte("# Edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, "docs/"}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	if !strings.Contains(html, `data-file="same-md" data-status="modified"`) {
+		t.Error("expected modified file to be badged")
+	}
+	if strings.Contains(html, `data-file="guide-md" data-status`) {
+		t.Error("expected unchanged file not to be badged")
+	}
+	if !strings.Contains(html, "</time> by Test") {
+		t.Error("expected last commit author")
+	}
+
+	// A revision shows history but not working tree status
+	if err := run([]string{"-O", outputFile, "--rev", "main", "docs/same.md"}); err != nil {
+		t.Fatalf("run(--rev) failed: %v", err)
+	}
+	content, _ = os.ReadFile(outputFile)
+	if strings.Contains(string(content), `class="file-meta-status"`) || !strings.Contains(string(content), "</time> by Test") {
+		t.Error("expected --rev to show the last commit without status")
+	}
+}
+
+func TestRun_WikiLinks(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(dir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"index.md":        "# Home\n\nSee [[Deep Thoughts|the notes]] and [[Missing]].\n\n![[deep#Part]]\n",
+		"notes/deep.md":   "# Deep Thoughts\n\n## Part\n\nBack to [[index]].\n",
+		"notes/unused.md": "# Unused\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, dir}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	for _, want := range []string{
+		`<a href="#notes-deep-md" class="wikilink">the notes</a>`,
+		`<a href="#index-md" class="wikilink">index</a>`,
+		`class="wikilink wikilink-unresolved" title="No page named Missing"`,
+		`<div class="wikilink-embed" data-source="notes/deep.md">`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+}
+
+func TestRun_Backlinks(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	files := map[string]string{
+		"index.md": "# Home\n\nStart with the [guide](guide.md). Then read on.\n",
+		"guide.md": "# Guide\n\nSee [[index]] for more.\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, dir}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	for _, want := range []string{
+		`<a class="backlinks-file" href="#index-md" title="index.md">index</a><p class="backlinks-excerpt">Start with the guide.</p>`,
+		`<a class="backlinks-file" href="#guide-md" title="guide.md">guide</a><p class="backlinks-excerpt">See index for more.</p>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+}
+
+func TestRun_Include(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"shared.md":     "# Shared Steps\n\nline two\n\nline four\n",
+		"docs/guide.md": "# Guide\n\n<!-- include: ../shared.md shift=1 lines=1-3 -->\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := filepath.Join(dir, "docs", "guide.md")
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, input}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	if !strings.Contains(html, `<h2 id="shared-steps">Shared Steps</h2>`) || !strings.Contains(html, "line two") {
+		t.Error("expected the included lines with their heading shifted")
+	}
+	if strings.Contains(html, "line four") {
+		t.Error("expected lines outside the range to be left out")
+	}
+
+	// Untrusted markdown cannot include other files
+	if err := run([]string{"--safe", "-O", outputFile, input}); err != nil {
+		t.Fatalf("run() with --safe failed: %v", err)
+	}
+	content, _ = os.ReadFile(outputFile)
+	if strings.Contains(string(content), "line two") {
+		t.Error("expected includes to be skipped in safe mode")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "shared.md"), []byte("<!-- include: docs/guide.md -->\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-O", outputFile, input})
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected an include cycle error, got %v", err)
+	}
+}
+
+func TestRun_IncludeCode(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	files := map[string]string{
+		"server.go": "package server\n\n// region handler\nfunc handle() string {\n\treturn \"ok\"\n}\n// endregion\n",
+		"arch.md":   "# Architecture\n\n<!-- include-code: server.go region=handler -->\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, filepath.Join(dir, "arch.md")}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	if !strings.Contains(html, `class="hl-chroma"`) || !strings.Contains(html, `<span class="hl-nf">handle</span>`) {
+		t.Error("expected the region to be highlighted as Go code")
+	}
+	if strings.Contains(html, "package server") {
+		t.Error("expected only the region to be included")
+	}
+}
+
+func TestRun_Extensions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	files := map[string]string{
+		"a.md": "# A\n\nFirst[^1]\n\n[^1]: Note in a.\n",
+		"b.md": "# B\n\nSecond[^1]\n\nTerm\n: Definition\n\n[^1]: Note in b.\n",
+		"c.md": "# C {#custom}\n\nRun \"mdp --serve\"...\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, dir}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	for _, want := range []string{
+		`<a href="#a-md-fn:1" class="footnote-ref" role="doc-noteref">1</a>`,
+		`<li id="a-md-fn:1">`,
+		`<a href="#b-md-fn:1" class="footnote-ref" role="doc-noteref">1</a>`,
+		`<li id="b-md-fn:1">`,
+		"<dt>Term</dt>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	// Typographer and attributes are opt-in, as GitHub shows text as written
+	for _, want := range []string{`<h1 id="c-md-c-custom">C {#custom}</h1>`, "Run &quot;mdp --serve&quot;..."} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	// The config can turn extensions off or name unknown ones
+	t.Chdir(dir)
+	configPath := filepath.Join(dir, ".mdp", "config.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"extensions": ["footnote"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-O", outputFile, filepath.Join(dir, "b.md")}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err = os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if strings.Contains(string(content), "<dt>") {
+		t.Error("expected definition lists to be disabled")
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"extensions": ["typographer", "attributes"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-O", outputFile, filepath.Join(dir, "c.md")}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err = os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	for _, want := range []string{`<h1 id="custom">C</h1>`, "Run &ldquo;mdp &ndash;serve&rdquo;&hellip;"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"extensions": ["toc"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-O", outputFile, filepath.Join(dir, "b.md")})
+	if err == nil || !strings.Contains(err.Error(), "unknown markdown extension: toc") {
+		t.Errorf("expected unknown extension error, got %v", err)
+	}
+}
+
+func TestRun_UniqueHeadingIDs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	files := map[string]string{
+		"a.md": "# A\n\n## Usage\n\nSee [usage](#usage), [B](b.md), [B usage](b.md#usage) and [[b#Usage|B wiki]].\n",
+		"b.md": "# B\n\n## Usage\n\nSee [[#Usage]].\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, dir}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	html := string(content)
+	for _, want := range []string{
+		`<h2 id="a-md-usage">Usage</h2>`,
+		`<a href="#a-md-usage">usage</a>`,
+		`<a href="#b-md">B</a>`,
+		`<a href="#b-md-usage">B usage</a>`,
+		`<a href="#b-md-usage" class="wikilink">B wiki</a>`,
+		`<h2 id="b-md-usage">Usage</h2>`,
+		`<a href="#b-md-usage" class="wikilink">Usage</a>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	if strings.Contains(html, `id="usage"`) {
+		t.Error("expected no unscoped heading IDs")
+	}
+}
+
+func TestRun_CustomEmoji(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	if err := os.MkdirAll(filepath.Join(dir, ".mdp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".mdp", "parrot.svg"), []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, ".mdp", "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"emoji": {"parrot": "parrot.svg"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(input, []byte("Ship it :rocket: :parrot:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, input}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	want := `Ship it 🚀 <img class="emoji" src="data:image/svg+xml;base64,` + base64.StdEncoding.EncodeToString([]byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)) + `" alt=":parrot:" title=":parrot:">`
+	if !strings.Contains(string(content), want) {
+		t.Errorf("expected output to contain %q", want)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"emoji": {"party parrot": "parrot.svg"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-O", outputFile, input})
+	if err == nil || !strings.Contains(err.Error(), "invalid emoji shortcode: party parrot") {
+		t.Errorf("expected invalid shortcode error, got %v", err)
+	}
+}
+
+func TestRun_CSV(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, "people.csv"), []byte("name,team\nAda,<!-- include: x.md -->\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Readme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, "people.csv"}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	for _, want := range []string{"<title>people</title>", `<div class="csv-table">`, "<th>name</th>", "<td>&lt;!-- include: x.md --&gt;</td>", "csv-table-filter"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	if err := run([]string{"-O", outputFile, "--csv", "."}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err = os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !strings.Contains(string(content), `id="people-csv"`) || !strings.Contains(string(content), `id="readme-md"`) {
+		t.Error("expected the CSV and Markdown files in the multi-file output")
+	}
+}
+
+func TestRun_Notebook(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	nb := `{"nbformat": 4, "metadata": {"language_info": {"name": "python"}}, "cells": [
+		{"cell_type": "markdown", "metadata": {}, "source": ["# Results\n"]},
+		{"cell_type": "code", "execution_count": 1, "metadata": {}, "source": ["print('done')"],
+		 "outputs": [{"output_type": "stream", "name": "stdout", "text": ["done\n"]}]}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "analysis.ipynb"), []byte(nb), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("See [the analysis](analysis.ipynb).\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, "."}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	for _, want := range []string{
+		`id="analysis-ipynb"`,
+		`<h1 id="analysis-ipynb-results">Results</h1>`,
+		`<div class="code-block-title">In [1]</div>`,
+		`<a href="#analysis-ipynb">the analysis</a>`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "analysis.ipynb"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-O", outputFile, "analysis.ipynb"})
+	if err == nil || !strings.Contains(err.Error(), "invalid notebook") {
+		t.Errorf("expected invalid notebook error, got %v", err)
+	}
+}
+
+func TestRun_Diagrams(t *testing.T) {
+	dir := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(dir)
+	configPath := filepath.Join(home, ".config", "mdp", "config.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"diagrams": {"dot": ["sh", "-c", "echo '<svg><text>rendered</text></svg>'"], "plantuml": ["mdp-no-such-tool"], "d2": []}}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A previewed project cannot choose the commands that run
+	if err := os.MkdirAll(filepath.Join(dir, ".mdp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	projectConfig := `{"diagrams": {"dot": ["sh", "-c", "echo '<svg>project</svg>'"]}}`
+	if err := os.WriteFile(filepath.Join(dir, ".mdp", "config.json"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "doc.md")
+	source := "```dot\na -> b\n```\n\n```plantuml\n@startuml\n@enduml\n```\n\n```d2\nx -> y\n```\n"
+	if err := os.WriteFile(input, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, input}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	for _, want := range []string{
+		"<div class=\"diagram diagram-dot\">\n<svg><text>rendered</text></svg>",
+		"Could not render plantuml diagram:</strong> mdp-no-such-tool is not installed or not in PATH",
+		`<code class="language-d2">`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	if strings.Contains(string(content), "<svg>project</svg>") {
+		t.Error("expected the project config's diagram command to be ignored")
+	}
+	if entries, err := os.ReadDir(filepath.Join(home, ".cache", "mdp", "diagrams")); err != nil || len(entries) != 1 {
+		t.Errorf("expected the rendered diagram to be cached, got %v, %v", entries, err)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"diagrams": {"mermaid": ["mmdc"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run([]string{"-O", outputFile, input})
+	if err == nil || !strings.Contains(err.Error(), "unknown diagram language: mermaid") {
+		t.Errorf("expected unknown diagram language error, got %v", err)
+	}
+}
+
+func TestRun_Renderers(t *testing.T) {
+	dir := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(dir)
+	configPath := filepath.Join(home, ".config", "mdp", "config.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"renderers": {
+		"bob": {"command": ["sh", "-c", "printf '<b>'; tr -d '\\n'; printf '</b>'"], "timeout": "5s"},
+		"dot": {"command": ["sh", "-c", "echo '<svg>custom</svg>'"]},
+		"slow": {"command": ["sleep", "5"], "timeout": "100ms"}
+	}}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A previewed project cannot choose the commands that run
+	if err := os.MkdirAll(filepath.Join(dir, ".mdp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	projectConfig := `{"renderers": {"bob": {"command": ["sh", "-c", "echo project"]}}}`
+	if err := os.WriteFile(filepath.Join(dir, ".mdp", "config.json"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "doc.md")
+	source := "```bob\n+--+\n```\n\n```dot\na -> b\n```\n\n```slow\nx\n```\n"
+	if err := os.WriteFile(input, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.html")
+	if err := run([]string{"-O", outputFile, input}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	for _, want := range []string{
+		"<div class=\"diagram diagram-bob\">\n<b>+--+</b>\n</div>\n<details class=\"diagram-source\"><summary>View source</summary>",
+		"<svg>custom</svg>",
+		"Could not render slow diagram:</strong> sleep timed out after 100ms",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	if strings.Contains(string(content), "project") {
+		t.Error("expected the project config's renderer to be ignored")
+	}
+
+	for _, tc := range []struct {
+		config  string
+		wantErr string
+	}{
+		{`{"renderers": {"bob": {"command": []}}}`, "renderer for bob has no command"},
+		{`{"renderers": {"bob": {"command": ["svgbob"], "timeout": "soon"}}}`, "invalid timeout for renderer bob: soon"},
+		{`{"renderers": {"my lang": {"command": ["x"]}}}`, `invalid renderer language: "my lang"`},
+	} {
+		if err := os.WriteFile(configPath, []byte(tc.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := run([]string{"-O", outputFile, input})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+		}
+	}
+}
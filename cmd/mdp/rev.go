@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mdp/internal/git"
+)
+
+// runShow handles the 'mdp show' subcommand, which previews markdown as of
+// a revision using git's <rev>:<path> syntax. Paths are relative to the top
+// of the repository unless they start with ./ or ../, as in git show.
+func runShow(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printShowUsage()
+			return nil
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: mdp show <rev>:<path>\nRun 'mdp show --help' for more information")
+	}
+
+	spec := args[len(args)-1]
+	rev, name, ok := strings.Cut(spec, ":")
+	if !ok || rev == "" || name == "" {
+		return fmt.Errorf("invalid object name: %s (expected <rev>:<path>)", spec)
+	}
+
+	if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+		repo, err := git.Open(".")
+		if err != nil {
+			return fmt.Errorf("Error opening git repository: %v", err)
+		}
+		name = filepath.Join(repo.Dir, filepath.FromSlash(name))
+	}
+
+	flags := args[: len(args)-1 : len(args)-1]
+	return run(append(flags, "--rev", rev, name))
+}
+
+// revSource reads markdown from a revision in the git object database
+// instead of the working tree.
+type revSource struct {
+	repo *git.Repo
+	rev  string // Resolved commit hash
+}
+
+// newRevSource opens the repository containing the current directory at rev.
+func newRevSource(rev string) (*revSource, error) {
+	repo, err := git.Open(".")
+	if err != nil {
+		return nil, fmt.Errorf("Error opening git repository: %v", err)
+	}
+	hash, err := repo.ResolveRev(rev)
+	if err != nil {
+		return nil, err
+	}
+	return &revSource{repo: repo, rev: hash}, nil
+}
+
+// resolveFiles expands directories and validates all paths like the
+// package-level resolveFiles, but against the files in the revision.
+func (s *revSource) resolveFiles(args []string, kinds fileKinds) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		rel, err := s.repo.RelPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		listed, err := s.repo.ListFiles(s.rev, rel)
+		if err != nil {
+			return nil, err
+		}
+		if len(listed) == 0 {
+			return nil, fmt.Errorf("Error accessing %s: not found in %s", arg, s.rev[:12])
+		}
+
+		// A file lists as itself; anything else is a directory
+		if len(listed) == 1 && listed[0] == rel {
+			if !kinds.accepts(arg, true) {
+				return nil, kinds.errExtension(arg)
+			}
+			files = append(files, arg)
+			continue
+		}
+
+		var found []string
+		for _, name := range listed {
+			sub := strings.TrimPrefix(name, rel+"/")
+			if rel == "." {
+				sub = name
+			}
+			if !kinds.accepts(name, false) || hasHiddenDir(sub) {
+				continue
+			}
+			found = append(found, filepath.Join(arg, filepath.FromSlash(sub)))
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// readFile reads a file, given by its working tree path, from the revision.
+func (s *revSource) readFile(name string) ([]byte, error) {
+	rel, err := s.repo.RelPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ReadFile(s.rev, rel)
+}
+
+// hasHiddenDir reports whether a slash-separated path is inside a hidden
+// directory, which directory previews skip.
+func hasHiddenDir(name string) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if strings.HasPrefix(path.Base(dir), ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func printShowUsage() {
+	fmt.Println(`mdp show - Preview markdown as of a git revision
+
+Usage:
+  mdp show [options] <rev>:<path>
+
+The path is relative to the top of the repository, or to the current
+directory if it starts with ./ or ../. It can be a file or a directory.
+Options are the same as for mdp, except --serve.
+
+Examples:
+  mdp show v1.0:README.md
+  mdp show main:docs/
+  mdp show -O release.html v2.0:./guide.md`)
+}
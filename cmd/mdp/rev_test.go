@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_RevFlag(t *testing.T) {
+	dir := newGitRepo(t)
+	outputFile := filepath.Join(t.TempDir(), "docs.html")
+
+	if err := run([]string{"--rev", "main", "-O", outputFile, "docs/"}); err != nil {
+		t.Fatalf("run() with --rev failed: %v", err)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	html := string(content)
+
+	for _, want := range []string{"Old intro.", `data-file="old-md"`, `data-file="same-md"`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{"New intro", `data-file="new-md"`} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("expected output not to contain %q from the working tree", unwanted)
+		}
+	}
+
+	// The working tree is untouched
+	if _, err := os.Stat(filepath.Join(dir, "docs", "old.md")); !os.IsNotExist(err) {
+		t.Error("expected docs/old.md to stay deleted in the working tree")
+	}
+}
+
+func TestRunShow(t *testing.T) {
+	dir := newGitRepo(t)
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		spec string
+		want string
+	}{
+		{"root relative", dir, "main:docs/old.md", "<h1 id=\"old\">Old</h1>"},
+		{"root relative from subdirectory", filepath.Join(dir, "docs"), "main:docs/guide.md", "Old intro."},
+		{"current directory relative", filepath.Join(dir, "docs"), "main:./guide.md", "Old intro."},
+		{"tag or commit", dir, "HEAD~1:notes.md", "Outside docs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(tt.dir)
+			outputFile := filepath.Join(t.TempDir(), "show.html")
+			if err := run([]string{"show", "-O", outputFile, tt.spec}); err != nil {
+				t.Fatalf("run(show %s) failed: %v", tt.spec, err)
+			}
+			content, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("failed to read output file: %v", err)
+			}
+			if !strings.Contains(string(content), tt.want) {
+				t.Errorf("expected output to contain %q", tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_RevErrors(t *testing.T) {
+	newGitRepo(t)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--rev", "main", "--serve", "docs/"}, "cannot use --rev with --serve"},
+		{[]string{"--rev", "nope", "docs/"}, "unknown revision nope"},
+		{[]string{"--rev", "main", "docs/new.md"}, "not found"},
+		{[]string{"show", "docs/guide.md"}, "invalid object name"},
+		{[]string{"show", "main:docs/missing.md"}, "not found"},
+	}
+	for _, tt := range tests {
+		err := run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("run(%v) error = %v, want containing %q", tt.args, err, tt.want)
+		}
+	}
+}
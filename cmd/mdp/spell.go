@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"mdp/internal/config"
+	"mdp/internal/converter"
+	"mdp/internal/spell"
+)
+
+// runSpell handles the 'mdp spell' subcommand.
+func runSpell(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printSpellUsage()
+			return nil
+		}
+	}
+
+	fs := flag.NewFlagSet("spell", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	formatFlag := fs.String("format", "text", "Output format: text or json")
+	outputFlag := fs.String("output", "", "Write results to file instead of stdout")
+	fs.StringVar(outputFlag, "O", "", "Write results to file instead of stdout (shorthand)")
+	listFlag := fs.Bool("list", false, "Print each misspelled word once, as a word list")
+	dictionaryFlag := fs.String("dictionary", "", "Hunspell dictionary to use instead of the built-in one")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v\nRun 'mdp spell --help' for usage", err)
+	}
+
+	switch *formatFlag {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unsupported spell format: %s (must be text or json)", *formatFlag)
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := resolveFiles(paths, markdownOnly)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("No markdown files found")
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return err
+	}
+	if *dictionaryFlag != "" {
+		cfg.Spell.Dictionary = *dictionaryFlag
+	}
+	opts, err := newRenderOptions(cfg)
+	if err != nil {
+		return err
+	}
+	dict, err := opts.loadDictionary()
+	if err != nil {
+		return err
+	}
+	for _, path := range config.WordLists(".") {
+		if err := dict.AddWordFile(path); err != nil {
+			return err
+		}
+	}
+
+	checker := spell.New(converter.New(opts.converterFor(files, findCommonBase(files))...), dict)
+	var misspellings []spell.Misspelling
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %v", path, err)
+		}
+		misspellings = append(misspellings, checker.Check(path, content)...)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			return fmt.Errorf("Error writing results: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch {
+	case *listFlag:
+		err = spell.WriteList(out, misspellings)
+	case *formatFlag == "json":
+		err = spell.WriteJSON(out, misspellings)
+	default:
+		err = spell.WriteText(out, misspellings)
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing results: %v", err)
+	}
+
+	switch len(misspellings) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("Found 1 misspelled word")
+	default:
+		return fmt.Errorf("Found %d misspelled words", len(misspellings))
+	}
+}
+
+func printSpellUsage() {
+	fmt.Println(`mdp spell - Check spelling in markdown
+
+Usage:
+  mdp spell [options] [path...]
+
+Options:
+  --format <format>            Output format: text (default) or json
+  -O, --output <file>          Write results to file instead of stdout
+  --list                       Print each misspelled word once, sorted, in the
+                               format of a word list
+  --dictionary <path>          Hunspell dictionary (path.aff and path.dic) to
+                               use instead of the built-in English one
+
+Code, links, raw HTML and YAML front matter are not checked, nor are words
+that look like code or names: with digits, underscores, dots or slashes,
+all in capitals, or in camelCase.
+
+Words in ~/.config/mdp/words.txt and .mdp/words.txt (one per line) are
+accepted, as are words listed in .mdp/config.json:
+
+  {"spell": {"words": ["goldmark"], "dictionary": "dict/en_GB"}}
+
+Paths default to the current directory. Exits with status 1 if any
+misspelled words are found.
+
+Examples:
+  mdp spell README.md
+  mdp spell --format json docs/
+  mdp spell --list docs/ >> .mdp/words.txt`)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSpell(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	if err := os.WriteFile("good.md", []byte("# Good\n\nThe text is fine.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("bad.md", []byte("# Bad\n\nTeh zorp and the `blix`.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"spell", "good.md"}); err != nil {
+		t.Errorf("run(spell good.md) = %v, want no error", err)
+	}
+
+	output := filepath.Join(dir, "out.txt")
+	err := run([]string{"spell", "-O", output, "bad.md"})
+	if err == nil || err.Error() != "Found 2 misspelled words" {
+		t.Errorf("run(spell bad.md) = %v, want 2 misspelled words", err)
+	}
+	text, _ := os.ReadFile(output)
+	if !strings.Contains(string(text), "bad.md:3:1: Teh (did you mean The") {
+		t.Errorf("unexpected text output:\n%s", text)
+	}
+
+	// Words in the project word list and config are accepted
+	if err := os.MkdirAll(".mdp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(".mdp", "words.txt"), []byte("zorp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run([]string{"spell", "--format", "json", "-O", output, "."})
+	data, _ := os.ReadFile(output)
+	var misspellings []struct{ File, Word string }
+	if err := json.Unmarshal(data, &misspellings); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, data)
+	}
+	if len(misspellings) != 1 || misspellings[0].Word != "Teh" {
+		t.Errorf("JSON misspellings = %+v, want only Teh", misspellings)
+	}
+
+	if err := os.WriteFile(filepath.Join(".mdp", "config.json"), []byte(`{"spell": {"words": ["Teh"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"spell", "."}); err != nil {
+		t.Errorf("run(spell .) with config words = %v, want no error", err)
+	}
+
+	// Wiki links to the checked files are links, whose text is skipped
+	if err := os.WriteFile("good.md", []byte("# Good\n\nThe text is fine, see [[bad|Blixish notes]].\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"spell", "."}); err != nil {
+		t.Errorf("run(spell .) with a wiki link = %v, want no error", err)
+	}
+}
+
+func TestRunSpell_List(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(dir)
+	if err := os.WriteFile("a.md", []byte("Zorp the zorp, then blix.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "words.txt")
+	run([]string{"spell", "--list", "-O", output, "a.md"})
+	data, _ := os.ReadFile(output)
+	if string(data) != "Zorp\nblix\nzorp\n" {
+		t.Errorf("--list output = %q", data)
+	}
+}
+
+func TestRunSpell_Errors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"spell", "--format", "sarif", "."}, "unsupported spell format"},
+		{[]string{"spell", "--dictionary", "missing/xx", "."}, "Error reading dictionary"},
+	}
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.md", []byte("# A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		err := run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("run(%v) = %v, want error containing %q", tt.args, err, tt.want)
+		}
+	}
+}
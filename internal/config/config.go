@@ -0,0 +1,217 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds settings read from configuration files. Command-line flags
+// take precedence over these values.
+type Config struct {
+	// Safe renders markdown as untrusted content (see --safe).
+	Safe bool `json:"safe"`
+
+	// CodeThemeLight and CodeThemeDark are Chroma style names used to
+	// highlight code in light and dark mode (see --code-theme-light).
+	CodeThemeLight string `json:"code_theme_light"`
+	CodeThemeDark  string `json:"code_theme_dark"`
+
+	// Theme is the initial color scheme: light, dark or system (see --theme).
+	Theme string `json:"theme"`
+
+	// CSS and Layout are paths to a custom stylesheet and html/template
+	// layout (see --css and --layout). Relative paths in a config file are
+	// relative to the directory containing that file.
+	CSS    string `json:"css"`
+	Layout string `json:"layout"`
+
+	// Extensions lists the markdown extensions to enable on top of GitHub
+	// Flavored Markdown: footnote, definition-list, typographer, attributes
+	// and emoji. Footnote, definition-list and emoji are enabled when it is
+	// not set.
+	Extensions []string `json:"extensions"`
+
+	// Emoji adds custom emoji shortcodes, mapping names without colons to
+	// image URLs or files. Relative file paths are relative to the
+	// directory containing the config file.
+	Emoji map[string]string `json:"emoji"`
+
+	// Diagrams sets the commands that render dot, plantuml and d2 code
+	// blocks, as a program and its arguments. The command reads the
+	// diagram on stdin and writes SVG to stdout. An empty command shows
+	// that language's blocks as code. Only the user config can set it.
+	Diagrams map[string][]string `json:"diagrams"`
+
+	// Renderers maps other code block languages to commands that render
+	// them. The command reads the block on stdin and writes HTML or SVG to
+	// stdout. Renderers take precedence over the built-in diagrams. Only
+	// the user config can set it.
+	Renderers map[string]RendererConfig `json:"renderers"`
+
+	// Lint configures the rules used by mdp lint and the lint panel in
+	// serve mode.
+	Lint LintConfig `json:"lint"`
+
+	// Spell configures the dictionary used by mdp spell and the spelling
+	// highlights in serve mode.
+	Spell SpellConfig `json:"spell"`
+
+	// Ignored lists the settings a project config tried to set that only
+	// the user config can, with the reason, such as "diagrams in
+	// .mdp/config.json: commands are only read from the user config".
+	Ignored []string `json:"-"`
+}
+
+// RendererConfig holds the settings of an external code block renderer.
+type RendererConfig struct {
+	// Command is the program to run and its arguments.
+	Command []string `json:"command"`
+
+	// Timeout is how long the command may run, as a duration such as "10s"
+	// (default 30s).
+	Timeout string `json:"timeout"`
+}
+
+// LintConfig holds lint rule settings.
+type LintConfig struct {
+	// Disable lists rules to turn off, by ID (MD013) or name (line-length).
+	Disable []string `json:"disable"`
+
+	// LineLength is the longest line allowed by MD013 (default 80).
+	LineLength int `json:"line_length"`
+}
+
+// SpellConfig holds spell checking settings.
+type SpellConfig struct {
+	// Dictionary is a Hunspell dictionary used instead of the built-in
+	// English one, as a path to its .aff and .dic files without the
+	// extension (e.g. "dict/en_GB").
+	Dictionary string `json:"dictionary"`
+
+	// Words are accepted in addition to the dictionary and word lists.
+	Words []string `json:"words"`
+
+	// Disable turns off the spelling highlights in serve mode. mdp spell
+	// still works.
+	Disable bool `json:"disable"`
+}
+
+// ProjectDir is the per-project directory for mdp files, relative to the
+// working directory.
+const ProjectDir = ".mdp"
+
+const fileName = "config.json"
+
+// userOnly lists the settings that run commands. They are only read from
+// the user config, so previewing a repository never runs commands that
+// the repository chose.
+var userOnly = []string{"diagrams", "renderers"}
+
+// wordListName is the file name of user and project word lists.
+const wordListName = "words.txt"
+
+// GetUserConfigPath returns the path to the user config file
+// (~/.config/mdp/config.json).
+func GetUserConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "mdp", fileName), nil
+}
+
+// WordLists returns the paths of the user word list
+// (~/.config/mdp/words.txt) and the project word list in dir
+// (.mdp/words.txt). They list words to accept when spell checking, one per
+// line, and need not exist.
+func WordLists(dir string) []string {
+	var paths []string
+	if userPath, err := GetUserConfigPath(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(userPath), wordListName))
+	}
+	return append(paths, filepath.Join(dir, ProjectDir, wordListName))
+}
+
+// Load reads the user config and then the project config in dir
+// (.mdp/config.json), so project settings override user settings. Settings
+// that run commands are ignored in the project config, as is turning safe
+// mode off, and listed in Ignored. Missing files are not an error.
+func Load(dir string) (*Config, error) {
+	var paths []string
+	if userPath, err := GetUserConfigPath(); err == nil {
+		paths = append(paths, userPath)
+	}
+	projectPath := filepath.Join(dir, ProjectDir, fileName)
+	paths = append(paths, projectPath)
+
+	cfg := &Config{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading config %s: %v", path, err)
+		}
+		before := *cfg
+		before.Emoji = maps.Clone(cfg.Emoji)
+		before.Diagrams = maps.Clone(cfg.Diagrams)
+		before.Renderers = maps.Clone(cfg.Renderers)
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("Error parsing config %s: %v", path, err)
+		}
+		if path == projectPath {
+			cfg.ignoreUserOnly(data, path, before)
+		}
+		cfg.resolvePaths(filepath.Dir(path), before)
+	}
+	return cfg, nil
+}
+
+// ignoreUserOnly restores the settings in userOnly to their values before
+// the config file at path, whose content is data, was read. Safe mode
+// stays on if it was, since it guards against the project itself.
+func (c *Config) ignoreUserOnly(data []byte, path string, before Config) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return
+	}
+	for _, key := range userOnly {
+		if _, ok := keys[key]; ok {
+			c.Ignored = append(c.Ignored, key+" in "+path+": commands are only read from the user config")
+		}
+	}
+	c.Diagrams = before.Diagrams
+	c.Renderers = before.Renderers
+	if before.Safe && !c.Safe {
+		c.Ignored = append(c.Ignored, "safe in "+path+": a project cannot turn safe mode off")
+		c.Safe = true
+	}
+}
+
+// resolvePaths makes file paths set since before relative to dir.
+func (c *Config) resolvePaths(dir string, before Config) {
+	for _, field := range []struct{ value, old *string }{
+		{&c.CSS, &before.CSS},
+		{&c.Layout, &before.Layout},
+		{&c.Spell.Dictionary, &before.Spell.Dictionary},
+	} {
+		if *field.value != *field.old && *field.value != "" && !filepath.IsAbs(*field.value) {
+			*field.value = filepath.Join(dir, *field.value)
+		}
+	}
+	for name, image := range c.Emoji {
+		if image != before.Emoji[name] && !IsURL(image) && !filepath.IsAbs(image) {
+			c.Emoji[name] = filepath.Join(dir, image)
+		}
+	}
+}
+
+// IsURL reports whether image is a URL rather than a file path.
+func IsURL(image string) bool {
+	return strings.Contains(image, "://") || strings.HasPrefix(image, "data:")
+}
@@ -0,0 +1,290 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func TestLoad_NoFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Safe {
+		t.Error("expected defaults when no config files exist")
+	}
+}
+
+func TestLoad_UserConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"safe": true, "code_theme_dark": "monokai"}`)
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if !cfg.Safe {
+		t.Error("expected safe mode from user config")
+	}
+	if cfg.CodeThemeDark != "monokai" {
+		t.Errorf("expected dark code theme from user config, got %q", cfg.CodeThemeDark)
+	}
+}
+
+func TestLoad_ProjectOverridesUser(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"theme": "dark"}`)
+
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"theme": "light"}`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Theme != "light" {
+		t.Error("expected project config to override user config")
+	}
+}
+
+func TestLoad_InvalidJSON(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"safe": `)
+
+	_, err := Load(dir)
+	if err == nil {
+		t.Fatal("expected error for invalid config")
+	}
+	if !strings.Contains(err.Error(), "config.json") {
+		t.Errorf("expected error to name the config file, got: %v", err)
+	}
+}
+
+func TestLoad_RelativePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"css": "brand.css", "layout": "/abs/layout.html"}`)
+
+	dir := t.TempDir()
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if want := filepath.Join(home, ".config", "mdp", "brand.css"); cfg.CSS != want {
+		t.Errorf("CSS = %q, want %q", cfg.CSS, want)
+	}
+	if cfg.Layout != "/abs/layout.html" {
+		t.Errorf("expected absolute layout path to be unchanged, got %q", cfg.Layout)
+	}
+
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"css": "docs.css"}`)
+	cfg, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if want := filepath.Join(dir, ".mdp", "docs.css"); cfg.CSS != want {
+		t.Errorf("CSS = %q, want %q", cfg.CSS, want)
+	}
+}
+
+func TestLoad_SpellSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"spell": {"dictionary": "dict/en_GB", "words": ["goldmark"]}}`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if want := filepath.Join(dir, ".mdp", "dict", "en_GB"); cfg.Spell.Dictionary != want {
+		t.Errorf("expected dictionary %s relative to the config file, got %s", want, cfg.Spell.Dictionary)
+	}
+	if len(cfg.Spell.Words) != 1 || cfg.Spell.Words[0] != "goldmark" {
+		t.Errorf("expected words from project config, got %v", cfg.Spell.Words)
+	}
+
+	want := []string{
+		filepath.Join(home, ".config", "mdp", "words.txt"),
+		filepath.Join(dir, ".mdp", "words.txt"),
+	}
+	if got := WordLists(dir); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("WordLists() = %v, want %v", got, want)
+	}
+}
+
+func TestLoad_LintSettingsMerge(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"lint": {"disable": ["MD013"]}}`)
+
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"lint": {"line_length": 120}}`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if len(cfg.Lint.Disable) != 1 || cfg.Lint.Disable[0] != "MD013" {
+		t.Errorf("expected disabled rules from user config, got %v", cfg.Lint.Disable)
+	}
+	if cfg.Lint.LineLength != 120 {
+		t.Errorf("expected line length from project config, got %d", cfg.Lint.LineLength)
+	}
+}
+
+func TestLoad_Extensions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Extensions != nil {
+		t.Errorf("expected no extensions when unset, got %v", cfg.Extensions)
+	}
+
+	// An empty list turns every extension off, unlike an unset one
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"extensions": []}`)
+	cfg, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Extensions == nil || len(cfg.Extensions) != 0 {
+		t.Errorf("expected an empty extension list, got %#v", cfg.Extensions)
+	}
+}
+
+func TestLoad_Diagrams(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"diagrams": {"plantuml": ["java", "-jar", "plantuml.jar", "-tsvg", "-pipe"], "d2": []}}`)
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if got := strings.Join(cfg.Diagrams["plantuml"], " "); got != "java -jar plantuml.jar -tsvg -pipe" {
+		t.Errorf("Diagrams[plantuml] = %q", got)
+	}
+	if command, ok := cfg.Diagrams["d2"]; !ok || len(command) != 0 {
+		t.Errorf("Diagrams[d2] = %v, want an empty command", command)
+	}
+}
+
+func TestLoad_ProjectCannotSetCommands(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"diagrams": {"d2": ["d2", "-", "-"]}}`)
+
+	dir := t.TempDir()
+	projectPath := filepath.Join(dir, ".mdp", "config.json")
+	writeConfig(t, projectPath, `{"safe": true, "diagrams": {"d2": ["sh", "-c", "touch pwned"], "dot": ["sh"]}, "renderers": {"bob": {"command": ["sh"]}}}`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if !cfg.Safe {
+		t.Error("expected the project config's other settings to apply")
+	}
+	if len(cfg.Diagrams) != 1 || strings.Join(cfg.Diagrams["d2"], " ") != "d2 - -" {
+		t.Errorf("Diagrams = %v, want only the user config's d2 command", cfg.Diagrams)
+	}
+	if len(cfg.Renderers) != 0 {
+		t.Errorf("Renderers = %v, want none from the project config", cfg.Renderers)
+	}
+	want := []string{
+		"diagrams in " + projectPath + ": commands are only read from the user config",
+		"renderers in " + projectPath + ": commands are only read from the user config",
+	}
+	if !slices.Equal(cfg.Ignored, want) {
+		t.Errorf("Ignored = %v, want %v", cfg.Ignored, want)
+	}
+}
+
+func TestLoad_ProjectCannotTurnOffSafe(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"safe": true}`)
+
+	dir := t.TempDir()
+	projectPath := filepath.Join(dir, ".mdp", "config.json")
+	writeConfig(t, projectPath, `{"safe": false, "theme": "dark"}`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if !cfg.Safe {
+		t.Error("expected the user config's safe mode to stay on")
+	}
+	if cfg.Theme != "dark" {
+		t.Error("expected the project config's other settings to apply")
+	}
+	if want := []string{"safe in " + projectPath + ": a project cannot turn safe mode off"}; !slices.Equal(cfg.Ignored, want) {
+		t.Errorf("Ignored = %v, want %v", cfg.Ignored, want)
+	}
+}
+
+func TestLoad_Renderers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"renderers": {"bob": {"command": ["svgbob", "--stdin"], "timeout": "10s"}}}`)
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	bob := cfg.Renderers["bob"]
+	if strings.Join(bob.Command, " ") != "svgbob --stdin" || bob.Timeout != "10s" {
+		t.Errorf("Renderers[bob] = %+v", bob)
+	}
+}
+
+func TestLoad_EmojiMerge(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig(t, filepath.Join(home, ".config", "mdp", "config.json"), `{"emoji": {"parrot": "parrot.gif", "shipit": "https://example.com/shipit.png"}}`)
+
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".mdp", "config.json"), `{"emoji": {"logo": "img/logo.svg"}}`)
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	want := map[string]string{
+		"parrot": filepath.Join(home, ".config", "mdp", "parrot.gif"),
+		"shipit": "https://example.com/shipit.png",
+		"logo":   filepath.Join(dir, ".mdp", "img", "logo.svg"),
+	}
+	if len(cfg.Emoji) != len(want) {
+		t.Fatalf("Emoji = %v, want %v", cfg.Emoji, want)
+	}
+	for name, image := range want {
+		if cfg.Emoji[name] != image {
+			t.Errorf("Emoji[%s] = %q, want %q", name, cfg.Emoji[name], image)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// codeBlockAttr is the attribute holding a fenced code block's options.
+var codeBlockAttr = []byte("mdp-code-block")
+
+var (
+	// fenceOptionPattern matches an option after the language in a fence's
+	// info string: {3-5,8}, key="value", key=value or a bare flag.
+	fenceOptionPattern = regexp.MustCompile(`\{([^}]*)\}|([A-Za-z][\w-]*)(?:=("[^"]*"|'[^']*'|\S+))?`)
+	// lineRangePattern matches a line number or range such as 3-5.
+	lineRangePattern = regexp.MustCompile(`^(\d+)(?:-(\d+))?$`)
+)
+
+// CodeBlockOptions are the options of a fenced code block, given after the
+// language in its info string:
+//
+//	```go title="main.go" linenos {3-5} diff
+type CodeBlockOptions struct {
+	// Title is shown above the block, usually a file name.
+	Title string
+	// LineNumbers shows line numbers, starting at FirstLine.
+	LineNumbers bool
+	FirstLine   int
+	// Highlight lists ranges of lines to highlight, counted from 1 at the
+	// top of the block.
+	Highlight [][2]int
+	// Diff marks lines starting with + as added and - as removed; the
+	// markers, and the space before an unchanged line, are not part of
+	// the code.
+	Diff bool
+}
+
+// ParseCodeBlockOptions parses the options in a fence's info string. ok is
+// false if the info string has no options this package knows, in which case
+// it is left for the highlighting extension's own {attribute} syntax.
+func ParseCodeBlockOptions(info string) (opts CodeBlockOptions, ok bool) {
+	_, rest, _ := strings.Cut(strings.TrimSpace(info), " ")
+	for _, m := range fenceOptionPattern.FindAllStringSubmatch(rest, -1) {
+		if m[0][0] == '{' {
+			ranges, valid := parseLineRanges(m[1])
+			if !valid {
+				return CodeBlockOptions{}, false
+			}
+			opts.Highlight = append(opts.Highlight, ranges...)
+			ok = true
+			continue
+		}
+		value := strings.Trim(m[3], `"'`)
+		switch strings.ToLower(m[2]) {
+		case "title":
+			opts.Title = value
+		case "linenos":
+			opts.LineNumbers = true
+			if n, err := strconv.Atoi(value); err == nil {
+				opts.FirstLine = n
+			}
+		case "diff":
+			opts.Diff = true
+		default:
+			continue
+		}
+		ok = true
+	}
+	if opts.FirstLine == 0 {
+		opts.FirstLine = 1
+	}
+	return opts, ok
+}
+
+// parseLineRanges parses a list of lines and ranges such as "1,3-5".
+func parseLineRanges(s string) ([][2]int, bool) {
+	var ranges [][2]int
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		m := lineRangePattern.FindStringSubmatch(field)
+		if m == nil {
+			return nil, false
+		}
+		start, _ := strconv.Atoi(m[1])
+		end := start
+		if m[2] != "" {
+			end, _ = strconv.Atoi(m[2])
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, len(ranges) > 0
+}
+
+// codeBlock holds the options of a fenced code block and, for diffs, the
+// marker of each line.
+type codeBlock struct {
+	CodeBlockOptions
+	markers []byte
+}
+
+// codeBlocks extends the highlighting extension with fence options and
+// diagrams.
+type codeBlocks struct {
+	options  []highlighting.Option
+	diagrams map[string]RenderFunc
+}
+
+func (e *codeBlocks) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(codeBlockTransformer{}, 200),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&codeBlockRenderer{highlighter: highlighting.NewHTMLRenderer(e.options...), diagrams: e.diagrams}, 200),
+	))
+}
+
+// codeBlockTransformer parses the options of fenced code blocks. Line
+// numbers and highlighted lines are handed to the highlighting extension
+// as its hl_lines, linenos and linenostart attributes, and diff markers are
+// cut from the lines so the code highlights as usual.
+type codeBlockTransformer struct{}
+
+func (codeBlockTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		fence, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok || fence.Info == nil {
+			return ast.WalkContinue, nil
+		}
+		opts, ok := ParseCodeBlockOptions(string(fence.Info.Segment.Value(source)))
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+
+		block := &codeBlock{CodeBlockOptions: opts}
+		if opts.Diff {
+			lines := fence.Lines()
+			block.markers = make([]byte, lines.Len())
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				value := line.Value(source)
+				if len(value) == 0 {
+					continue
+				}
+				switch value[0] {
+				case '+', '-':
+					block.markers[i] = value[0]
+					lines.Set(i, line.WithStart(line.Start+1))
+				case ' ':
+					// A context line
+					lines.Set(i, line.WithStart(line.Start+1))
+				}
+			}
+		}
+
+		fence.SetAttribute(codeBlockAttr, block)
+		if opts.LineNumbers {
+			fence.SetAttribute([]byte("linenos"), true)
+			fence.SetAttribute([]byte("linenostart"), float64(opts.FirstLine))
+		}
+		if len(opts.Highlight) > 0 {
+			lines := make([]interface{}, len(opts.Highlight))
+			for i, r := range opts.Highlight {
+				lines[i] = []byte(strconv.Itoa(r[0]) + "-" + strconv.Itoa(r[1]))
+			}
+			fence.SetAttribute([]byte("hl_lines"), lines)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// codeBlockRenderer renders fenced code blocks with the highlighting
+// extension, adding the title above the block and marking diff lines.
+// Diagrams are rendered with their renderers, and other csv and tsv blocks
+// as tables.
+type codeBlockRenderer struct {
+	highlighter renderer.NodeRenderer
+	highlight   renderer.NodeRendererFunc
+	diagrams    map[string]RenderFunc
+}
+
+// SetOption passes renderer options such as XHTML on to the highlighter.
+func (r *codeBlockRenderer) SetOption(name renderer.OptionName, value interface{}) {
+	if s, ok := r.highlighter.(renderer.SetOptioner); ok {
+		s.SetOption(name, value)
+	}
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	r.highlighter.RegisterFuncs(registerFunc(func(kind ast.NodeKind, f renderer.NodeRendererFunc) {
+		if kind == ast.KindFencedCodeBlock {
+			r.highlight = f
+		}
+	}))
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		fence := n.(*ast.FencedCodeBlock)
+		if ok, err := r.renderDiagram(w, source, fence); ok || err != nil {
+			return ast.WalkContinue, err
+		}
+		if renderTable(w, source, fence) {
+			return ast.WalkContinue, nil
+		}
+	}
+	value, _ := n.AttributeString(string(codeBlockAttr))
+	block, _ := value.(*codeBlock)
+	if !entering || block == nil || (block.Title == "" && block.markers == nil) {
+		return r.highlight(w, source, n, entering)
+	}
+
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	status, err := r.highlight(bw, source, n, entering)
+	if err != nil {
+		return status, err
+	}
+	if err := bw.Flush(); err != nil {
+		return status, err
+	}
+	html := buf.String()
+	if block.markers != nil {
+		html = markDiffLines(html, block.markers)
+	}
+
+	if block.Title == "" {
+		_, _ = w.WriteString(html)
+		return status, nil
+	}
+	_, _ = w.WriteString(`<div class="code-block"><div class="code-block-title">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(block.Title)))
+	_, _ = w.WriteString("</div>\n")
+	_, _ = w.WriteString(html)
+	_, _ = w.WriteString("</div>\n")
+	return status, nil
+}
+
+// highlightedLine is the start of a line in highlighted code.
+const highlightedLine = `<span class="hl-line`
+
+// markDiffLines adds a class to the highlighted lines marked + or -. Code
+// that was not highlighted has no lines to mark and is returned as is.
+func markDiffLines(html string, markers []byte) string {
+	var buf strings.Builder
+	for i := 0; ; i++ {
+		j := strings.Index(html, highlightedLine)
+		if j < 0 {
+			break
+		}
+		j += len(highlightedLine)
+		buf.WriteString(html[:j])
+		html = html[j:]
+		if i < len(markers) {
+			switch markers[i] {
+			case '+':
+				buf.WriteString(" code-line-added")
+			case '-':
+				buf.WriteString(" code-line-removed")
+			}
+		}
+	}
+	buf.WriteString(html)
+	return buf.String()
+}
+
+// registerFunc adapts a function to renderer.NodeRendererFuncRegisterer.
+type registerFunc func(ast.NodeKind, renderer.NodeRendererFunc)
+
+func (f registerFunc) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	f(kind, fn)
+}
@@ -1,41 +1,105 @@
 package converter
 
 import (
+	"bytes"
 	"strings"
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
-	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
 // Converter handles markdown to HTML conversion.
 type Converter struct {
-	md goldmark.Markdown
+	md   goldmark.Markdown
+	wiki *wikiIndex
+}
+
+// Option configures optional Converter behavior.
+type Option func(*options)
+
+type options struct {
+	xhtml       bool
+	safe        bool
+	wiki        *wikiIndex
+	extensions  []Extension
+	customEmoji map[string]string
+	diagrams    map[string]RenderFunc
+}
+
+// WithXHTML makes the converter emit XHTML-compatible markup
+// (self-closing void elements), as required by EPUB.
+func WithXHTML() Option {
+	return func(o *options) {
+		o.xhtml = true
+	}
+}
+
+// WithSafeMode renders untrusted markdown: raw HTML is omitted and links
+// with script-capable URLs (javascript:, vbscript:, data: other than
+// images, file:) are dropped.
+func WithSafeMode() Option {
+	return func(o *options) {
+		o.safe = true
+	}
 }
 
 // New creates a new Converter with GFM support and syntax highlighting.
-func New() *Converter {
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,
-			highlighting.NewHighlighting(
-				highlighting.WithFormatOptions(
-					chromahtml.WithClasses(true),
-					chromahtml.ClassPrefix("hl-"),
-				),
+func New(opts ...Option) *Converter {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var rendererOptions []renderer.Option
+	parserOptions := []parser.Option{
+		parser.WithAutoHeadingID(),
+	}
+	if o.safe {
+		parserOptions = append(parserOptions, parser.WithASTTransformers(
+			util.Prioritized(safeURLTransformer{}, 100),
+		))
+	} else {
+		rendererOptions = append(rendererOptions, html.WithUnsafe()) // Allow raw HTML in markdown
+	}
+	if o.xhtml {
+		rendererOptions = append(rendererOptions, html.WithXHTML())
+	}
+
+	c := &Converter{wiki: o.wiki}
+	diagrams := o.diagrams
+	if o.safe {
+		diagrams = nil
+	}
+	extensions := []goldmark.Extender{
+		extension.GFM,
+		&codeBlocks{diagrams: diagrams, options: []highlighting.Option{
+			highlighting.WithFormatOptions(
+				chromahtml.WithClasses(true),
+				chromahtml.ClassPrefix("hl-"),
 			),
-		),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-		),
-		goldmark.WithRendererOptions(
-			html.WithUnsafe(), // Allow raw HTML in markdown
-		),
+		}},
+	}
+	if o.wiki != nil {
+		extensions = append(extensions, &wikiExtension{c: c, idx: o.wiki})
+	}
+	optional, optionalParserOptions := extenders(o)
+	extensions = append(extensions, optional...)
+	parserOptions = append(parserOptions, optionalParserOptions...)
+
+	c.md = goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parserOptions...),
+		goldmark.WithRendererOptions(rendererOptions...),
 	)
-	return &Converter{md: md}
+	return c
 }
 
 // Convert transforms markdown content into HTML.
@@ -46,3 +110,97 @@ func (c *Converter) Convert(markdown []byte) (string, error) {
 	}
 	return buf.String(), nil
 }
+
+// Parse parses markdown into a goldmark AST using the same configuration as
+// Convert, for output formats that render the document themselves.
+func (c *Converter) Parse(markdown []byte) ast.Node {
+	return c.md.Parser().Parse(text.NewReader(markdown))
+}
+
+// ConvertBlocks transforms markdown into HTML one top-level block at a
+// time, for comparing documents block by block.
+func (c *Converter) ConvertBlocks(markdown []byte) ([]string, error) {
+	return c.renderBlocks(c.Parse(markdown), markdown)
+}
+
+// renderBlocks renders each top-level block of doc on its own.
+func (c *Converter) renderBlocks(doc ast.Node, markdown []byte) ([]string, error) {
+	var nodes []ast.Node
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		nodes = append(nodes, n)
+	}
+
+	blocks := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		block, err := c.render([]ast.Node{n}, markdown)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// render renders a sequence of top-level blocks as a document.
+func (c *Converter) render(blocks []ast.Node, source []byte) (string, error) {
+	if len(blocks) == 0 {
+		return "", nil
+	}
+	doc := ast.NewDocument()
+	for _, n := range blocks {
+		doc.AppendChild(doc, n)
+	}
+	var buf strings.Builder
+	if err := c.md.Renderer().Render(&buf, source, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// safeURLTransformer drops script-capable link destinations. The HTML
+// renderer already omits them from links and images when raw HTML is
+// disabled, but not from autolinks, and it does not ignore the whitespace
+// and control characters that browsers skip when parsing a URL scheme.
+type safeURLTransformer struct{}
+
+func (safeURLTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var autoLinks []*ast.AutoLink
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Link:
+			if isDangerousURL(node.Destination) {
+				node.Destination = nil
+			}
+		case *ast.Image:
+			if isDangerousURL(node.Destination) {
+				node.Destination = nil
+			}
+		case *ast.AutoLink:
+			if isDangerousURL(node.URL(source)) {
+				autoLinks = append(autoLinks, node)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	// Replace dangerous autolinks with their text
+	for _, link := range autoLinks {
+		link.Parent().ReplaceChild(link.Parent(), link, ast.NewString(link.Label(source)))
+	}
+}
+
+// isDangerousURL reports whether url could run script when followed.
+func isDangerousURL(url []byte) bool {
+	resolved := util.ResolveEntityNames(util.ResolveNumericReferences(url))
+	cleaned := bytes.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, resolved)
+	return html.IsDangerousURL(cleaned)
+}
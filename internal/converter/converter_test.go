@@ -1,8 +1,15 @@
 package converter
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
 	"strings"
 	"testing"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
 )
 
 func TestConvert_BasicMarkdown(t *testing.T) {
@@ -235,3 +242,593 @@ func TestConvert_SupportedLanguages(t *testing.T) {
 		})
 	}
 }
+
+func TestConvert_XHTML(t *testing.T) {
+	conv := New(WithXHTML())
+
+	result, err := conv.Convert([]byte("line one  \nline two\n\n---\n\n![alt](img.png)"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"<br />", "<hr />", `<img src="img.png" alt="alt" />`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected XHTML output to contain %q, got: %s", want, result)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	conv := New()
+
+	doc := conv.Parse([]byte("# Title\n\n| a |\n|---|\n| 1 |\n"))
+
+	heading, ok := doc.FirstChild().(*ast.Heading)
+	if !ok {
+		t.Fatalf("expected first node to be a heading, got %T", doc.FirstChild())
+	}
+	if id, _ := heading.AttributeString("id"); string(id.([]byte)) != "title" {
+		t.Errorf("expected auto heading ID %q, got %q", "title", id)
+	}
+	if _, ok := heading.NextSibling().(*extast.Table); !ok {
+		t.Errorf("expected GFM table after heading, got %T", heading.NextSibling())
+	}
+}
+
+func TestConvert_SafeMode(t *testing.T) {
+	conv := New(WithSafeMode())
+
+	input := `<script>alert(1)</script>
+
+Text <img src=x onerror="alert(1)"> inline.
+
+[click](javascript:alert(1)) [tab](java&#9;script:alert(1)) <javascript:alert(1)> [ok](https://example.com)
+
+![img](data:image/png;base64,AAAA)
+`
+	result, err := conv.Convert([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, unwanted := range []string{"<script", "onerror", `href="javascript:`, "java%09script"} {
+		if strings.Contains(result, unwanted) {
+			t.Errorf("expected safe output not to contain %q, got: %s", unwanted, result)
+		}
+	}
+	for _, want := range []string{
+		"<!-- raw HTML omitted -->",
+		`<a href="https://example.com">ok</a>`,
+		`<img src="data:image/png;base64,AAAA" alt="img">`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected safe output to contain %q, got: %s", want, result)
+		}
+	}
+}
+
+func TestConvertSlides(t *testing.T) {
+	conv := New()
+
+	tests := []struct {
+		name    string
+		input   string
+		content []string
+		notes   []string
+	}{
+		{
+			name:    "thematic breaks",
+			input:   "# Deck\n\n---\n\n## One\n\n### Detail\n\n---\n\n## Two\n",
+			content: []string{`<h1 id="deck">Deck</h1>`, `<h2 id="one">One</h2>` + "\n" + `<h3 id="detail">Detail</h3>`, `<h2 id="two">Two</h2>`},
+			notes:   []string{"", "", ""},
+		},
+		{
+			name:    "headings without breaks",
+			input:   "Intro\n\n# A\n\ntext\n\n### Sub\n\n## B\n",
+			content: []string{"<p>Intro</p>", `<h1 id="a">A</h1>` + "\n<p>text</p>\n" + `<h3 id="sub">Sub</h3>`, `<h2 id="b">B</h2>`},
+			notes:   []string{"", "", ""},
+		},
+		{
+			name:    "speaker notes",
+			input:   "## Plan\n\n<!-- Mention the <budget>\n\nThen pause -->\n\n- item\n\nNote: say **this**\n\nand that\n",
+			content: []string{`<h2 id="plan">Plan</h2>` + "\n<ul>\n<li>item</li>\n</ul>"},
+			notes:   []string{"<p>Mention the &lt;budget&gt;</p>\n<p>Then pause</p>\n<p>say <strong>this</strong></p>\n<p>and that</p>"},
+		},
+		{
+			name:    "empty slides are dropped",
+			input:   "---\n\n# Only\n\n---\n\n<!-- nothing to show -->\n\n---\n",
+			content: []string{`<h1 id="only">Only</h1>`},
+			notes:   []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slides, err := conv.ConvertSlides([]byte(tt.input), "deck.md")
+			if err != nil {
+				t.Fatalf("ConvertSlides() returned error: %v", err)
+			}
+			if len(slides) != len(tt.content) {
+				t.Fatalf("ConvertSlides() returned %d slides, want %d: %+v", len(slides), len(tt.content), slides)
+			}
+			for i, slide := range slides {
+				if got := strings.TrimSpace(slide.Content); got != tt.content[i] {
+					t.Errorf("slide %d content = %q, want %q", i, got, tt.content[i])
+				}
+				if got := strings.TrimSpace(slide.Notes); got != tt.notes[i] {
+					t.Errorf("slide %d notes = %q, want %q", i, got, tt.notes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConvertBlocks(t *testing.T) {
+	blocks, err := New().ConvertBlocks([]byte("# Title\n\nFirst paragraph.\n\n- one\n- two\n"))
+	if err != nil {
+		t.Fatalf("ConvertBlocks() returned error: %v", err)
+	}
+	want := []string{
+		"<h1 id=\"title\">Title</h1>\n",
+		"<p>First paragraph.</p>\n",
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n",
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("ConvertBlocks() returned %d blocks, want %d: %q", len(blocks), len(want), blocks)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("block %d = %q, want %q", i, blocks[i], want[i])
+		}
+	}
+}
+
+// wikiTestPages returns a converter with wiki links over files.
+func wikiTestConverter(files map[string]string) *Converter {
+	var pages []WikiPage
+	for path, content := range files {
+		pages = append(pages, WikiPage{RelPath: path, Title: Title([]byte(content))})
+	}
+	return New(WithWikiLinks(pages, func(relPath string) ([]byte, error) {
+		content, ok := files[relPath]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}))
+}
+
+func TestConvertSlides_WikiLinks(t *testing.T) {
+	c := wikiTestConverter(map[string]string{
+		"index.md":      "# Home\n",
+		"notes/deck.md": "",
+	})
+
+	slides, err := c.ConvertSlides([]byte("# Deck\n\nBack to [[index]].\n"), "notes/deck.md")
+	if err != nil {
+		t.Fatalf("ConvertSlides() returned error: %v", err)
+	}
+	if want := `<a href="../index.md" class="wikilink">index</a>`; len(slides) != 1 || !strings.Contains(slides[0].Content, want) {
+		t.Errorf("ConvertSlides() = %+v, want a slide containing %s", slides, want)
+	}
+}
+
+func TestConvertFile_WikiLinks(t *testing.T) {
+	c := wikiTestConverter(map[string]string{
+		"index.md":        "# Home\n",
+		"Page Two.md":     "# Second Page\n",
+		"notes/deep.md":   "---\ntitle: Deep Thoughts\n---\ntext\n",
+		"archive/deep.md": "# Old\n",
+	})
+
+	tests := []struct {
+		name     string
+		markdown string
+		source   string
+		want     string
+	}{
+		{"file name", "[[page two]]", "index.md", `<a href="Page%20Two.md" class="wikilink">page two</a>`},
+		{"title", "[[Second Page]]", "index.md", `<a href="Page%20Two.md" class="wikilink">Second Page</a>`},
+		{"front matter title", "[[deep thoughts]]", "index.md", `<a href="notes/deep.md" class="wikilink">deep thoughts</a>`},
+		{"heading and alias", "[[Page Two#Getting Started|start here]]", "index.md", `<a href="Page%20Two.md#getting-started" class="wikilink">start here</a>`},
+		{"heading label", "[[Page Two#Setup]]", "index.md", `<a href="Page%20Two.md#setup" class="wikilink">Page Two &gt; Setup</a>`},
+		{"same page heading", "[[#Local Heading]]", "index.md", `<a href="#local-heading" class="wikilink">Local Heading</a>`},
+		{"relative to source", "[[index]]", "notes/deep.md", `<a href="../index.md" class="wikilink">index</a>`},
+		{"shortest path", "[[deep]]", "index.md", `<a href="notes/deep.md" class="wikilink">deep</a>`},
+		{"same directory first", "[[deep]]", "archive/other.md", `<a href="deep.md" class="wikilink">deep</a>`},
+		{"path", "[[archive/deep]]", "index.md", `<a href="archive/deep.md" class="wikilink">archive/deep</a>`},
+		{"unresolved", "[[Nowhere|go]]", "index.md", `<a class="wikilink wikilink-unresolved" title="No page named Nowhere">go</a>`},
+		{"image", "![[diagram.png]]", "index.md", `<img src="diagram.png" alt="diagram.png" class="wikilink-embed">`},
+		{"image size", "![[my diagram.png|300x200]]", "index.md", `<img src="my%20diagram.png" alt="my diagram.png" class="wikilink-embed" width="300" height="200">`},
+		{"inline embed", "See ![[Page Two]] here", "index.md", `See <a href="Page%20Two.md" class="wikilink">Page Two</a> here`},
+		{"not a wiki link", "[[a] b] and [text](url)", "index.md", `<a href="url">text</a>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := c.ConvertFile([]byte(tt.markdown), tt.source)
+			if err != nil {
+				t.Fatalf("ConvertFile() error = %v", err)
+			}
+			if !strings.Contains(html, tt.want) {
+				t.Errorf("ConvertFile(%q) = %s, want it to contain %s", tt.markdown, html, tt.want)
+			}
+		})
+	}
+
+	// Without WithWikiLinks, [[...]] is plain text
+	if html, _ := New().Convert([]byte("[[Page Two]]")); !strings.Contains(html, "[[Page Two]]") {
+		t.Errorf("Convert() without wiki links = %s", html)
+	}
+	blocks, err := c.ConvertBlocksFile([]byte("# Deep\n\nSee [[index]].\n"), "notes/deep.md")
+	if err != nil {
+		t.Fatalf("ConvertBlocksFile() error = %v", err)
+	}
+	if want := `<p>See <a href="../index.md" class="wikilink">index</a>.</p>`; len(blocks) != 2 || !strings.Contains(blocks[1], want) {
+		t.Errorf("ConvertBlocksFile() = %q, want a second block containing %s", blocks, want)
+	}
+}
+
+func TestConvertFile_WikiEmbeds(t *testing.T) {
+	c := wikiTestConverter(map[string]string{
+		"index.md":      "# Home\n\n![[guide#Setup]]\n\n![[notes/note]]\n",
+		"guide.md":      "# Guide\n\nIntro.\n\n## Setup\n\nStep one.\n\n### Details\n\nMore.\n\n## Usage\n\nLater.\n",
+		"notes/note.md": "A [link](other.md), ![img](img/a.png) and [[guide]].\n\n![[index]]\n",
+	})
+
+	html, err := c.ConvertFile([]byte("# Home\n\n![[guide#Setup]]\n\n![[notes/note]]\n"), "index.md")
+	if err != nil {
+		t.Fatalf("ConvertFile() error = %v", err)
+	}
+	for _, want := range []string{
+		`<div class="wikilink-embed" data-source="guide.md">`,
+		`<a class="wikilink" href="guide.md#setup">guide &gt; Setup</a>`,
+		"<p>Step one.</p>",
+		"<p>More.</p>",
+		// Links in embedded pages are relative to the embedding page
+		`<a href="notes/other.md">link</a>`,
+		`<img src="notes/img/a.png" alt="img">`,
+		`<a href="guide.md" class="wikilink">guide</a>`,
+		`<p class="wikilink-unresolved">Cannot embed index.md in itself</p>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected embed output to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "Intro.") || strings.Contains(html, "Later.") {
+		t.Errorf("expected only the Setup section to be embedded, got:\n%s", html)
+	}
+	if strings.Contains(html, "<p><div") {
+		t.Error("expected embeds to replace their paragraph")
+	}
+
+	html, _ = c.ConvertFile([]byte("![[guide#Nowhere]]\n"), "index.md")
+	if !strings.Contains(html, "No heading Nowhere in guide.md") {
+		t.Errorf("expected missing heading error, got:\n%s", html)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"---\ntitle: \"From Front Matter\"\n---\n# Heading\n", "From Front Matter"},
+		{"Intro\n\n## Sub\n\n# The *Main* Title\n", "The Main Title"},
+		{"No headings\n", ""},
+	}
+	for _, tt := range tests {
+		if got := Title([]byte(tt.markdown)); got != tt.want {
+			t.Errorf("Title(%q) = %q, want %q", tt.markdown, got, tt.want)
+		}
+	}
+}
+
+func TestConvert_Extensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		ext      Extension
+		markdown string
+		want     string
+	}{
+		{"footnote", Footnote, "Text[^1]\n\n[^1]: Note.\n", `<a href="#fn:1" class="footnote-ref" role="doc-noteref">1</a>`},
+		{"definition list", DefinitionList, "Term\n: Definition\n", "<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>"},
+		{"typographer", Typographer, `"Quoted" -- text...`, "&ldquo;Quoted&rdquo; &ndash; text&hellip;"},
+		{"attributes", Attributes, "# Heading {#custom .big}\n", `<h1 id="custom" class="big">Heading</h1>`},
+		{"emoji", Emoji, "Ship it :rocket:", "Ship it 🚀"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := New().Convert([]byte(tt.markdown))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if strings.Contains(html, tt.want) {
+				t.Errorf("expected %s to be off by default, got %s", tt.ext, html)
+			}
+
+			html, err = New(WithExtensions(tt.ext)).Convert([]byte(tt.markdown))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if !strings.Contains(html, tt.want) {
+				t.Errorf("Convert(%q) = %s, want it to contain %s", tt.markdown, html, tt.want)
+			}
+		})
+	}
+
+	if ext, ok := LookupExtension("Definition-List"); !ok || ext != DefinitionList {
+		t.Errorf("LookupExtension() = %q, %v, want %q", ext, ok, DefinitionList)
+	}
+	if _, ok := LookupExtension("toc"); ok {
+		t.Error("expected unknown extension not to be found")
+	}
+}
+
+func TestConvertFile_FootnoteIDs(t *testing.T) {
+	c := New(WithExtensions(Footnote))
+	markdown := []byte("Text[^note]\n\n[^note]: Note.\n")
+
+	html, err := c.ConvertFile(markdown, "docs/Setup Guide.md")
+	if err != nil {
+		t.Fatalf("ConvertFile() error = %v", err)
+	}
+	for _, want := range []string{
+		`<sup id="docs-setup-guide-md-fnref:1"><a href="#docs-setup-guide-md-fn:1" class="footnote-ref" role="doc-noteref">1</a></sup>`,
+		`<li id="docs-setup-guide-md-fn:1">`,
+		`<a href="#docs-setup-guide-md-fnref:1" class="footnote-backref" role="doc-backlink">`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %s in %s", want, html)
+		}
+	}
+
+	// Without a file the IDs are left as they are
+	html, err = c.Convert(markdown)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !strings.Contains(html, `<li id="fn:1">`) {
+		t.Errorf("expected unprefixed footnote ID, got %s", html)
+	}
+}
+
+func TestConvert_CustomEmoji(t *testing.T) {
+	c := New(WithExtensions(Emoji), WithCustomEmoji(map[string]string{
+		"parrot": "https://example.com/parrot.gif",
+		"rocket": "img/rocket.png",
+	}))
+
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"custom", ":parrot:", `<img class="emoji" src="https://example.com/parrot.gif" alt=":parrot:" title=":parrot:">`},
+		{"overrides built-in", ":rocket:", `<img class="emoji" src="img/rocket.png" alt=":rocket:" title=":rocket:">`},
+		{"built-in", ":tada: :+1:", "🎉 👍"},
+		{"image only on GitHub", ":octocat:", "<p>:octocat:</p>"},
+		{"unknown", ":nope: 12:30:45", "<p>:nope: 12:30:45</p>"},
+		{"code", "`:tada:`", "<code>:tada:</code>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := c.Convert([]byte(tt.markdown))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if !strings.Contains(html, tt.want) {
+				t.Errorf("Convert(%q) = %s, want it to contain %s", tt.markdown, html, tt.want)
+			}
+		})
+	}
+
+	html, err := New(WithExtensions(Emoji), WithXHTML(), WithCustomEmoji(map[string]string{"parrot": "p.gif"})).Convert([]byte(":parrot:"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !strings.Contains(html, `title=":parrot:" />`) {
+		t.Errorf("expected a self-closing image in XHTML, got %s", html)
+	}
+
+	for name, want := range map[string]bool{"parrot": true, "+1": true, "thumbs_up-2": true, "": false, "two words": false, "a:b": false} {
+		if got := IsEmojiShortcode(name); got != want {
+			t.Errorf("IsEmojiShortcode(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseCodeBlockOptions(t *testing.T) {
+	tests := []struct {
+		info   string
+		want   CodeBlockOptions
+		wantOK bool
+	}{
+		{`go title="main.go" {3-5}`, CodeBlockOptions{Title: "main.go", FirstLine: 1, Highlight: [][2]int{{3, 5}}}, true},
+		{`go title='my file.go' linenos`, CodeBlockOptions{Title: "my file.go", LineNumbers: true, FirstLine: 1}, true},
+		{`js linenos=10 {1,4-6} diff`, CodeBlockOptions{LineNumbers: true, FirstLine: 10, Highlight: [][2]int{{1, 1}, {4, 6}}, Diff: true}, true},
+		{`go`, CodeBlockOptions{FirstLine: 1}, false},
+		{`go {hl_lines=[2]}`, CodeBlockOptions{}, false},
+		{`go other=value`, CodeBlockOptions{FirstLine: 1}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseCodeBlockOptions(tt.info)
+		if ok != tt.wantOK || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParseCodeBlockOptions(%q) = %+v, %v, want %+v, %v", tt.info, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestConvert_CodeBlockOptions(t *testing.T) {
+	conv := New()
+
+	tests := []struct {
+		name     string
+		markdown string
+		want     []string
+	}{
+		{
+			name:     "title",
+			markdown: "```go title=\"main.go\"\npackage main\n```\n",
+			want:     []string{`<div class="code-block"><div class="code-block-title">main.go</div>` + "\n" + `<pre tabindex="0" class="hl-chroma">`, "</code></pre></div>"},
+		},
+		{
+			name:     "title escaped",
+			markdown: "```text title=\"<b>\"\nplain\n```\n",
+			want:     []string{`<div class="code-block-title">&lt;b&gt;</div>`},
+		},
+		{
+			name:     "highlighted lines",
+			markdown: "```go {2}\npackage main\nfunc main() {}\n```\n",
+			want:     []string{`<span class="hl-line"><span class="hl-cl"><span class="hl-kn">package`, `<span class="hl-line hl-hl"><span class="hl-cl"><span class="hl-kd">func`},
+		},
+		{
+			name:     "line numbers",
+			markdown: "```go linenos=9\npackage main\n```\n",
+			want:     []string{`<span class="hl-ln">9</span>`},
+		},
+		{
+			name:     "diff",
+			markdown: "```go diff\n package main\n-func old() {}\n+func main() {}\n```\n",
+			want: []string{
+				`<span class="hl-line"><span class="hl-cl"><span class="hl-kn">package</span>`,
+				`<span class="hl-line code-line-removed"><span class="hl-cl"><span class="hl-kd">func</span> <span class="hl-nf">old</span>`,
+				`<span class="hl-line code-line-added"><span class="hl-cl"><span class="hl-kd">func</span> <span class="hl-nf">main</span>`,
+			},
+		},
+		{
+			name:     "highlighting attributes",
+			markdown: "```go {hl_lines=[1]}\npackage main\n```\n",
+			want:     []string{`<span class="hl-line hl-hl">`},
+		},
+		{
+			name:     "mermaid",
+			markdown: "```mermaid\ngraph TD\n```\n",
+			want:     []string{`<pre><code class="language-mermaid">graph TD`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := conv.Convert([]byte(tt.markdown))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("expected %s in %s", want, html)
+				}
+			}
+		})
+	}
+}
+
+func TestConvert_CSVTable(t *testing.T) {
+	conv := New()
+
+	tests := []struct {
+		name     string
+		markdown string
+		want     []string
+	}{
+		{
+			name:     "csv",
+			markdown: "```csv\nname,count\n\"Smith, J\",3\n<b>,4\n```\n",
+			want: []string{
+				"<div class=\"csv-table\">\n<table>\n<thead>\n<tr>\n<th>name</th>\n<th>count</th>\n</tr>\n</thead>\n<tbody>\n",
+				"<td>Smith, J</td>\n<td>3</td>",
+				"<td>&lt;b&gt;</td>",
+			},
+		},
+		{
+			name:     "tsv",
+			markdown: "```tsv\na\tb\n1\t2\n```\n",
+			want:     []string{"<th>a</th>\n<th>b</th>", "<td>1</td>\n<td>2</td>"},
+		},
+		{
+			name:     "ragged rows",
+			markdown: "```csv\na,b,c\n1\n```\n",
+			want:     []string{"<td>1</td>\n<td></td>\n<td></td>"},
+		},
+		{
+			name:     "header only",
+			markdown: "```csv\na,b\n```\n",
+			want:     []string{"</thead>\n</table>"},
+		},
+		{
+			name:     "empty",
+			markdown: "```csv\n```\n",
+			want:     []string{`<pre><code class="language-csv"></code></pre>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := conv.Convert([]byte(tt.markdown))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("expected %q in %s", want, html)
+				}
+			}
+		})
+	}
+}
+
+func TestTableFileMarkdown(t *testing.T) {
+	if !IsTableFile("data/People.CSV") || !IsTableFile("x.tsv") || IsTableFile("README.md") {
+		t.Error("IsTableFile() did not match by extension")
+	}
+	got := string(TableFileMarkdown("x.tsv", []byte("a\tb")))
+	if want := "```tsv\na\tb\n```\n"; got != want {
+		t.Errorf("TableFileMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestConvert_Diagrams(t *testing.T) {
+	render := func(source []byte) ([]byte, error) {
+		if bytes.Contains(source, []byte("bad")) {
+			return nil, errors.New("dot failed: syntax error")
+		}
+		return []byte("<?xml version=\"1.0\"?>\n<!DOCTYPE svg>\n<!-- Generated -->\n<svg><text>" + strings.TrimSpace(string(source)) + "</text></svg>\n"), nil
+	}
+	c := New(WithDiagram("dot", render))
+
+	html, err := c.Convert([]byte("```dot\na -> b\n```\n"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	want := "<div class=\"diagram diagram-dot\">\n<svg><text>a -> b</text></svg>\n</div>\n" +
+		"<details class=\"diagram-source\"><summary>View source</summary>\n<pre><code class=\"language-dot\">a -&gt; b\n"
+	if !strings.HasPrefix(html, want) || !strings.HasSuffix(html, "</pre>\n</details>\n") {
+		t.Errorf("Convert() = %q, want the diagram and its source", html)
+	}
+
+	html, err = New(WithDiagram("csv", render)).Convert([]byte("```csv\na,b\n```\n"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !strings.Contains(html, `<div class="diagram diagram-csv">`) {
+		t.Errorf("expected a renderer to take precedence over built-in csv tables, got %s", html)
+	}
+
+	html, err = c.Convert([]byte("```dot\nbad\n```\n"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	for _, want := range []string{
+		`<div class="diagram-error" role="alert"><strong>Could not render dot diagram:</strong> dot failed: syntax error</div>`,
+		`<pre><code class="language-dot">bad`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in %s", want, html)
+		}
+	}
+
+	html, err = New(WithDiagram("dot", render), WithSafeMode()).Convert([]byte("```dot\na -> b\n```\n"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if strings.Contains(html, "<svg>") {
+		t.Errorf("expected diagrams to show as code in safe mode, got %s", html)
+	}
+}
@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+
+	"mdp/internal/include"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// IsTableFile reports whether path is a CSV or TSV file, which is shown as
+// a table rather than converted as Markdown.
+func IsTableFile(path string) bool {
+	return tableLanguage(path) != ""
+}
+
+// TableFileMarkdown returns the Markdown for a CSV or TSV file: its content
+// in a csv or tsv fenced code block, which renders as a table.
+func TableFileMarkdown(path string, content []byte) []byte {
+	return include.CodeBlock(content, tableLanguage(path))
+}
+
+// tableLanguage returns the fence language for a CSV or TSV file, or "".
+func tableLanguage(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".tsv":
+		return "tsv"
+	}
+	return ""
+}
+
+// renderTable renders the content of a csv or tsv fenced code block as a
+// table, with the first row as its header. ok is false if the block is not
+// csv or tsv, or its content cannot be parsed, in which case it is left to
+// render as code.
+func renderTable(w util.BufWriter, source []byte, fence *ast.FencedCodeBlock) (ok bool) {
+	lang := strings.ToLower(string(fence.Language(source)))
+	if lang != "csv" && lang != "tsv" {
+		return false
+	}
+
+	var content bytes.Buffer
+	lines := fence.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		content.Write(line.Value(source))
+	}
+	reader := csv.NewReader(&content)
+	if lang == "tsv" {
+		reader.Comma = '\t'
+	}
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return false
+	}
+
+	columns := 0
+	for _, record := range records {
+		columns = max(columns, len(record))
+	}
+	writeRow := func(record []string, cell string) {
+		_, _ = w.WriteString("<tr>\n")
+		for i := 0; i < columns; i++ {
+			value := ""
+			if i < len(record) {
+				value = record[i]
+			}
+			_, _ = w.WriteString("<" + cell + ">")
+			_, _ = w.Write(util.EscapeHTML([]byte(value)))
+			_, _ = w.WriteString("</" + cell + ">\n")
+		}
+		_, _ = w.WriteString("</tr>\n")
+	}
+
+	_, _ = w.WriteString("<div class=\"csv-table\">\n<table>\n<thead>\n")
+	writeRow(records[0], "th")
+	_, _ = w.WriteString("</thead>\n")
+	if len(records) > 1 {
+		_, _ = w.WriteString("<tbody>\n")
+		for _, record := range records[1:] {
+			writeRow(record, "td")
+		}
+		_, _ = w.WriteString("</tbody>\n")
+	}
+	_, _ = w.WriteString("</table>\n</div>\n")
+	return true
+}
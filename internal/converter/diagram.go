@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// RenderFunc renders the content of a fenced code block, such as the source
+// of a diagram, to HTML or SVG.
+type RenderFunc func(source []byte) ([]byte, error)
+
+// WithDiagram renders fenced code blocks in lang with render, such as a
+// diagram tool or another external command, inlining its output in place
+// of the code. The source stays available in a collapsed details element.
+// If render fails, the error is shown above the code. Blocks are not
+// rendered in safe mode, where they show as code.
+func WithDiagram(lang string, render RenderFunc) Option {
+	return func(o *options) {
+		if o.diagrams == nil {
+			o.diagrams = make(map[string]RenderFunc)
+		}
+		o.diagrams[strings.ToLower(lang)] = render
+	}
+}
+
+// renderDiagram renders a fenced code block with the diagram renderer for
+// its language, followed by its highlighted source. ok is false if there
+// is none; an error rendering the diagram is written before the block,
+// which is then left to render as code.
+func (r *codeBlockRenderer) renderDiagram(w util.BufWriter, source []byte, fence *ast.FencedCodeBlock) (ok bool, err error) {
+	lang := strings.ToLower(string(fence.Language(source)))
+	render := r.diagrams[lang]
+	if render == nil {
+		return false, nil
+	}
+
+	var content bytes.Buffer
+	lines := fence.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		content.Write(line.Value(source))
+	}
+	out, err := render(content.Bytes())
+	if err != nil {
+		_, _ = w.WriteString(`<div class="diagram-error" role="alert"><strong>Could not render ` + string(util.EscapeHTML([]byte(lang))) + ` diagram:</strong> `)
+		_, _ = w.Write(util.EscapeHTML([]byte(err.Error())))
+		_, _ = w.WriteString("</div>\n")
+		return false, nil
+	}
+
+	_, _ = w.WriteString(`<div class="diagram diagram-` + string(util.EscapeHTML([]byte(lang))) + `">` + "\n")
+	_, _ = w.Write(trimXMLProlog(out))
+	_, _ = w.WriteString("\n</div>\n")
+	_, _ = w.WriteString("<details class=\"diagram-source\"><summary>View source</summary>\n")
+	if _, err := r.highlight(w, source, fence, true); err != nil {
+		return true, err
+	}
+	_, _ = w.WriteString("</details>\n")
+	return true, nil
+}
+
+// trimXMLProlog strips the XML declaration, doctype and comments that tools
+// write before an SVG document, which cannot appear inside HTML.
+func trimXMLProlog(out []byte) []byte {
+	for {
+		out = bytes.TrimSpace(out)
+		var end []byte
+		switch {
+		case bytes.HasPrefix(out, []byte("<?")):
+			end = []byte("?>")
+		case bytes.HasPrefix(out, []byte("<!--")):
+			end = []byte("-->")
+		case bytes.HasPrefix(bytes.ToUpper(out[:min(len(out), 9)]), []byte("<!DOCTYPE")):
+			end = []byte(">")
+		default:
+			return out
+		}
+		i := bytes.Index(out, end)
+		if i < 0 {
+			return out
+		}
+		out = out[i+len(end):]
+	}
+}
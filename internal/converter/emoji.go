@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
+	emojiast "github.com/yuin/goldmark-emoji/ast"
+	"github.com/yuin/goldmark-emoji/definition"
+	"github.com/yuin/goldmark/util"
+)
+
+// shortcodePattern matches the name of an emoji shortcode, without colons.
+var shortcodePattern = regexp.MustCompile(`^[a-zA-Z0-9_+-]+$`)
+
+// IsEmojiShortcode reports whether name can be used as an emoji shortcode
+// such as :name:.
+func IsEmojiShortcode(name string) bool {
+	return shortcodePattern.MatchString(name)
+}
+
+// WithCustomEmoji adds emoji shortcodes rendered as images, mapping names
+// without colons to image URLs. They take precedence over the built-in
+// GitHub shortcodes and need the Emoji extension.
+func WithCustomEmoji(images map[string]string) Option {
+	return func(o *options) {
+		if o.customEmoji == nil {
+			o.customEmoji = make(map[string]string)
+		}
+		for name, src := range images {
+			o.customEmoji[name] = src
+		}
+	}
+}
+
+// newEmoji returns the emoji extension with GitHub's shortcodes rendered as
+// Unicode, and custom shortcodes rendered as images.
+func newEmoji(custom map[string]string) goldmark.Extender {
+	emojis := definition.Github()
+	if len(custom) > 0 {
+		list := make([]definition.Emoji, 0, len(custom))
+		for name := range custom {
+			list = append(list, definition.NewEmoji(name, nil, name))
+		}
+		emojis = definition.NewEmojis(list...)
+		emojis.Add(definition.Github())
+	}
+
+	render := func(w util.BufWriter, source []byte, n *emojiast.Emoji, config *emoji.RendererConfig) {
+		name := string(n.ShortName)
+		if src, ok := custom[name]; ok {
+			closing := ">"
+			if config.XHTML {
+				closing = " />"
+			}
+			fmt.Fprintf(w, `<img class="emoji" src="%s" alt=":%s:" title=":%s:"%s`,
+				util.EscapeHTML([]byte(src)), name, name, closing)
+			return
+		}
+		if !n.Value.IsUnicode() {
+			// GitHub's own images such as :octocat: have no Unicode form
+			fmt.Fprintf(w, ":%s:", name)
+			return
+		}
+		_, _ = w.WriteString(string(n.Value.Unicode))
+	}
+
+	return emoji.New(
+		emoji.WithEmojis(emojis),
+		emoji.WithRenderingMethod(emoji.Func),
+		emoji.WithRendererFunc(render),
+	)
+}
@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Extension names an optional markdown extension. GitHub Flavored
+// Markdown is always on.
+type Extension string
+
+const (
+	// Footnote adds footnotes: text[^1] with [^1]: Note.
+	Footnote Extension = "footnote"
+	// DefinitionList adds definition lists: a term followed by ": Definition".
+	DefinitionList Extension = "definition-list"
+	// Typographer replaces straight quotes, dashes and ellipses with their
+	// typographic forms.
+	Typographer Extension = "typographer"
+	// Attributes sets heading IDs and classes: # Heading {#id .class}.
+	Attributes Extension = "attributes"
+	// Emoji replaces GitHub shortcodes such as :rocket: with emoji.
+	Emoji Extension = "emoji"
+)
+
+// Extensions lists every optional extension.
+var Extensions = []Extension{Footnote, DefinitionList, Typographer, Attributes, Emoji}
+
+// DefaultExtensions are the extensions enabled unless the config lists
+// others. Typographer and Attributes change text that GitHub leaves alone,
+// such as --flag and {braces}, so they are opt-in.
+var DefaultExtensions = []Extension{Footnote, DefinitionList, Emoji}
+
+// LookupExtension returns the extension with the given name.
+func LookupExtension(name string) (Extension, bool) {
+	for _, ext := range Extensions {
+		if string(ext) == strings.ToLower(name) {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// WithExtensions enables optional markdown extensions.
+func WithExtensions(exts ...Extension) Option {
+	return func(o *options) {
+		o.extensions = append(o.extensions, exts...)
+	}
+}
+
+// fileMetaKey is the document metadata key holding the file a document
+// was read from.
+const fileMetaKey = "mdp-file"
+
+// extenders returns the goldmark extensions and parser options for the
+// optional extensions enabled in o.
+func extenders(o options) ([]goldmark.Extender, []parser.Option) {
+	var extensions []goldmark.Extender
+	var parserOptions []parser.Option
+	for _, ext := range o.extensions {
+		switch ext {
+		case Footnote:
+			extensions = append(extensions, extension.NewFootnote(
+				extension.WithFootnoteIDPrefixFunction(footnotePrefix),
+			))
+			parserOptions = append(parserOptions, parser.WithASTTransformers(
+				util.Prioritized(fileMetaTransformer{}, 50),
+			))
+		case DefinitionList:
+			extensions = append(extensions, extension.DefinitionList)
+		case Typographer:
+			extensions = append(extensions, extension.Typographer)
+		case Attributes:
+			parserOptions = append(parserOptions, parser.WithAttribute())
+		case Emoji:
+			extensions = append(extensions, newEmoji(o.customEmoji))
+		}
+	}
+	return extensions, parserOptions
+}
+
+// fileMetaTransformer records the file a document was read from with
+// ConvertFile or ParseFile in its metadata.
+type fileMetaTransformer struct{}
+
+func (fileMetaTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	src := sourceOf(pc)
+	if src.relPath == "" {
+		return
+	}
+	file := src.root
+	if src.relPath != src.root {
+		file += "/" + src.relPath
+	}
+	doc.AddMeta(fileMetaKey, file)
+}
+
+// footnotePrefix prefixes footnote IDs with the ID of the file they are in,
+// so footnotes from several files can share a page.
+func footnotePrefix(n ast.Node) []byte {
+	doc := n.OwnerDocument()
+	if doc == nil {
+		return nil
+	}
+	file, _ := doc.Meta()[fileMetaKey].(string)
+	if file == "" {
+		return nil
+	}
+	return []byte(fileID(file) + "-")
+}
+
+// fileID returns the ID of a file's section in multi-file pages.
+func fileID(relPath string) string {
+	id := strings.NewReplacer("/", "-", "\\", "-", ".", "-", " ", "-").Replace(relPath)
+	return strings.TrimLeft(strings.ToLower(id), "-")
+}
@@ -0,0 +1,153 @@
+package converter
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Slide is one slide of a presentation.
+type Slide struct {
+	Content string // Rendered HTML
+	Notes   string // Rendered speaker notes, empty if there are none
+}
+
+// notePrefixes start a paragraph of speaker notes.
+var notePrefixes = []string{"Notes:", "Note:"}
+
+// ConvertSlides transforms markdown into slides. The document is split at
+// thematic breaks (---), or before each H1 and H2 if it has none. HTML
+// comments become speaker notes, as does a paragraph starting with "Note:"
+// together with everything after it on the same slide. Wiki links
+// resolve relative to relPath, as in ConvertFile.
+func (c *Converter) ConvertSlides(markdown []byte, relPath string) ([]Slide, error) {
+	doc := c.ParseFile(markdown, relPath)
+
+	var slides []Slide
+	for _, group := range splitSlides(doc) {
+		content, notes := separateNotes(group, markdown)
+		if len(content) == 0 {
+			continue
+		}
+
+		var slide Slide
+		var err error
+		if slide.Content, err = c.render(content, markdown); err != nil {
+			return nil, err
+		}
+		if slide.Notes, err = c.render(notes.nodes, markdown); err != nil {
+			return nil, err
+		}
+		slide.Notes = notes.comments + slide.Notes
+		slides = append(slides, slide)
+	}
+	return slides, nil
+}
+
+// splitSlides groups the top-level blocks of doc into slides.
+func splitSlides(doc ast.Node) [][]ast.Node {
+	var blocks []ast.Node
+	hasBreak := false
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		blocks = append(blocks, n)
+		if n.Kind() == ast.KindThematicBreak {
+			hasBreak = true
+		}
+	}
+
+	groups := [][]ast.Node{nil}
+	for _, n := range blocks {
+		last := len(groups) - 1
+		if hasBreak {
+			if n.Kind() == ast.KindThematicBreak {
+				groups = append(groups, nil)
+				continue
+			}
+		} else if h, ok := n.(*ast.Heading); ok && h.Level <= 2 && len(groups[last]) > 0 {
+			groups = append(groups, nil)
+			last++
+		}
+		groups[last] = append(groups[last], n)
+	}
+	return groups
+}
+
+// slideNotes holds the speaker notes of one slide.
+type slideNotes struct {
+	comments string     // HTML comments, already rendered
+	nodes    []ast.Node // "Note:" paragraph and what follows it
+}
+
+// separateNotes splits the blocks of a slide into content and notes.
+func separateNotes(blocks []ast.Node, source []byte) ([]ast.Node, slideNotes) {
+	var content []ast.Node
+	var notes slideNotes
+	for i, n := range blocks {
+		if comment, ok := htmlComment(n, source); ok {
+			notes.comments += commentNotes(comment)
+			continue
+		}
+		if stripNotePrefix(n, source) {
+			notes.nodes = blocks[i:]
+			break
+		}
+		content = append(content, n)
+	}
+	return content, notes
+}
+
+// htmlComment returns the text of an HTML comment block.
+func htmlComment(n ast.Node, source []byte) (string, bool) {
+	block, ok := n.(*ast.HTMLBlock)
+	if !ok || block.HTMLBlockType != ast.HTMLBlockType2 {
+		return "", false
+	}
+	var buf bytes.Buffer
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		buf.Write(segment.Value(source))
+	}
+	if block.HasClosure() {
+		buf.Write(block.ClosureLine.Value(source))
+	}
+	comment := strings.TrimSpace(buf.String())
+	comment = strings.TrimPrefix(comment, "<!--")
+	comment = strings.TrimSuffix(comment, "-->")
+	return strings.TrimSpace(comment), true
+}
+
+// commentNotes renders comment text as escaped paragraphs.
+func commentNotes(comment string) string {
+	var buf strings.Builder
+	for _, para := range strings.Split(comment, "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			buf.WriteString("<p>" + html.EscapeString(para) + "</p>\n")
+		}
+	}
+	return buf.String()
+}
+
+// stripNotePrefix reports whether n is a paragraph starting with a note
+// prefix, and removes the prefix from it.
+func stripNotePrefix(n ast.Node, source []byte) bool {
+	para, ok := n.(*ast.Paragraph)
+	if !ok {
+		return false
+	}
+	first, ok := para.FirstChild().(*ast.Text)
+	if !ok {
+		return false
+	}
+	value := first.Segment.Value(source)
+	for _, prefix := range notePrefixes {
+		if bytes.HasPrefix(value, []byte(prefix)) {
+			rest := bytes.TrimLeft(value[len(prefix):], " \t")
+			first.Segment = first.Segment.WithStart(first.Segment.Stop - len(rest))
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,597 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// WikiPage is a document that wiki links can point to.
+type WikiPage struct {
+	RelPath string // Relative to the directory of the previewed files
+	Title   string // Matched as well as the file name, optional
+}
+
+// WithWikiLinks resolves Obsidian-style wiki links against pages:
+// [[Page]], [[Page#Heading|alias]] and [[#Heading]] become links to the
+// page's .md file, ![[image.png|300]] becomes an image and ![[Page]] on a
+// line of its own embeds the page, or one section of it. read returns the
+// markdown of a page, by RelPath, for embedding. Pages are matched by
+// path, file name or title, ignoring case. Links that match no page are
+// marked with the wikilink-unresolved class.
+//
+// Use ConvertFile so links are relative to the converted document.
+func WithWikiLinks(pages []WikiPage, read func(relPath string) ([]byte, error)) Option {
+	return func(o *options) {
+		o.wiki = newWikiIndex(pages, read)
+	}
+}
+
+// ConvertFile transforms markdown into HTML like Convert, resolving wiki
+// links relative to relPath, the document's path among the wiki pages.
+func (c *Converter) ConvertFile(markdown []byte, relPath string) (string, error) {
+	var buf strings.Builder
+	pc := newWikiContext(relPath, nil)
+	if err := c.md.Convert(markdown, &buf, parser.WithContext(pc)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ParseFile parses markdown like Parse, resolving wiki links relative to
+// relPath.
+func (c *Converter) ParseFile(markdown []byte, relPath string) ast.Node {
+	return c.md.Parser().Parse(text.NewReader(markdown), parser.WithContext(newWikiContext(relPath, nil)))
+}
+
+// ConvertBlocksFile transforms markdown into HTML one block at a time like
+// ConvertBlocks, resolving wiki links relative to relPath.
+func (c *Converter) ConvertBlocksFile(markdown []byte, relPath string) ([]string, error) {
+	return c.renderBlocks(c.ParseFile(markdown, relPath), markdown)
+}
+
+// Title returns the title of a markdown document: the title field of its
+// YAML front matter, or else the text of its first level 1 heading.
+func Title(markdown []byte) string {
+	if bytes.HasPrefix(markdown, []byte("---\n")) || bytes.HasPrefix(markdown, []byte("---\r\n")) {
+		lines := strings.Split(string(markdown), "\n")
+		for _, line := range lines[1:] {
+			line = strings.TrimRight(line, "\r")
+			if line == "---" || line == "..." {
+				break
+			}
+			if value, ok := strings.CutPrefix(line, "title:"); ok {
+				return strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+	}
+
+	var title string
+	doc := parser.NewParser(
+		parser.WithBlockParsers(parser.DefaultBlockParsers()...),
+		parser.WithInlineParsers(parser.DefaultInlineParsers()...),
+	).Parse(text.NewReader(markdown))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering && h.Level == 1 {
+			title = plainText(h, markdown)
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return title
+}
+
+// plainText returns the text of n's inline content.
+func plainText(n ast.Node, source []byte) string {
+	var buf strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := c.(type) {
+		case *ast.Text:
+			buf.Write(node.Segment.Value(source))
+		case *ast.String:
+			buf.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// wikiIndex looks up wiki pages by name.
+type wikiIndex struct {
+	byKey map[string][]string // lowercased path, name or title -> RelPaths
+	read  func(relPath string) ([]byte, error)
+}
+
+func newWikiIndex(pages []WikiPage, read func(string) ([]byte, error)) *wikiIndex {
+	idx := &wikiIndex{byKey: make(map[string][]string), read: read}
+	add := func(key, relPath string) {
+		key = wikiKey(key)
+		for _, p := range idx.byKey[key] {
+			if p == relPath {
+				return
+			}
+		}
+		idx.byKey[key] = append(idx.byKey[key], relPath)
+	}
+	for _, page := range pages {
+		relPath := filepath.ToSlash(page.RelPath)
+		withoutExt := strings.TrimSuffix(relPath, path.Ext(relPath))
+		add(relPath, relPath)
+		add(withoutExt, relPath)
+		add(path.Base(withoutExt), relPath)
+		if page.Title != "" {
+			add(page.Title, relPath)
+		}
+	}
+	return idx
+}
+
+// wikiKey normalizes a page name for lookups, like linkrewriter does for
+// paths: forward slashes, no leading ./ and lowercase.
+func wikiKey(name string) string {
+	name = strings.ReplaceAll(strings.TrimSpace(name), "\\", "/")
+	name = strings.TrimPrefix(name, "./")
+	return strings.ToLower(name)
+}
+
+// resolve returns the RelPath of the page target names, as linked from
+// the page at source. Paths relative to the source's directory are tried
+// first. If several pages share a name, the one in the source's directory
+// wins, then the one with the shortest path.
+func (idx *wikiIndex) resolve(target, source string) (string, bool) {
+	dir := path.Dir(filepath.ToSlash(source))
+	if strings.Contains(target, "/") && dir != "." {
+		if paths := idx.byKey[wikiKey(path.Join(dir, target))]; len(paths) > 0 {
+			return paths[0], true
+		}
+	}
+	paths := idx.byKey[wikiKey(target)]
+	if len(paths) == 0 {
+		return "", false
+	}
+	candidates := append([]string(nil), paths...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := path.Dir(candidates[i]) == dir, path.Dir(candidates[j]) == dir
+		if a != b {
+			return a
+		}
+		if len(candidates[i]) != len(candidates[j]) {
+			return len(candidates[i]) < len(candidates[j])
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates[0], true
+}
+
+// wikiSource is the document being parsed, the document its HTML ends up
+// in and the chain of documents embedding it, for resolving links and
+// detecting embed cycles.
+type wikiSource struct {
+	relPath string
+	root    string // Links are relative to this document
+	chain   []string
+}
+
+var wikiSourceKey = parser.NewContextKey()
+
+func newWikiContext(relPath string, chain []string) parser.Context {
+	relPath = filepath.ToSlash(relPath)
+	root := relPath
+	if len(chain) > 0 {
+		root = chain[0]
+	}
+	pc := parser.NewContext()
+	pc.Set(wikiSourceKey, &wikiSource{relPath: relPath, root: root, chain: chain})
+	return pc
+}
+
+func sourceOf(pc parser.Context) *wikiSource {
+	if s, ok := pc.Get(wikiSourceKey).(*wikiSource); ok {
+		return s
+	}
+	return &wikiSource{}
+}
+
+// maxEmbedDepth limits how deeply embedded pages can embed other pages.
+const maxEmbedDepth = 8
+
+// imageExtensions are the embeds shown as images.
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true, ".bmp": true,
+}
+
+// KindWikiLink is the node kind of unresolved wiki links and of page
+// embeds.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is a wiki link that matched no page, or a page embed. Resolved
+// links and image embeds are parsed as ast.Link and ast.Image instead.
+type WikiLink struct {
+	ast.BaseInline
+	Target   string // Page name, without the heading
+	Fragment string // Heading, if any
+	Embed    bool
+
+	// RelPath is the embedded page, empty for unresolved links
+	RelPath string
+}
+
+// Kind implements ast.Node.
+func (n *WikiLink) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+// Dump implements ast.Node.
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Target":   n.Target,
+		"Fragment": n.Fragment,
+		"Embed":    strconv.FormatBool(n.Embed),
+	}, nil)
+}
+
+// KindWikiEmbed is the node kind of embedded pages.
+var KindWikiEmbed = ast.NewNodeKind("WikiEmbed")
+
+// WikiEmbed is a page embedded on a line of its own, already rendered.
+type WikiEmbed struct {
+	ast.BaseBlock
+	RelPath string
+	Title   string
+	Href    string
+	HTML    string
+}
+
+// Kind implements ast.Node.
+func (n *WikiEmbed) Kind() ast.NodeKind {
+	return KindWikiEmbed
+}
+
+// Dump implements ast.Node.
+func (n *WikiEmbed) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"RelPath": n.RelPath}, nil)
+}
+
+// IsRaw implements ast.Node. Embeds have no inline content to parse.
+func (n *WikiEmbed) IsRaw() bool {
+	return true
+}
+
+// wikiExtension adds wiki links to a converter's goldmark instance.
+type wikiExtension struct {
+	c   *Converter
+	idx *wikiIndex
+}
+
+func (e *wikiExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Before the link parser, which would see [[ as nested labels
+		parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{e.idx}, 199)),
+		parser.WithASTTransformers(util.Prioritized(&wikiEmbedTransformer{e}, 500)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&wikiRenderer{}, 500)))
+}
+
+// wikiLinkParser parses [[...]] and ![[...]].
+type wikiLinkParser struct {
+	idx *wikiIndex
+}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'[', '!'}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	embed := bytes.HasPrefix(line, []byte("![["))
+	if !embed && !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	start := 2
+	if embed {
+		start = 3
+	}
+	end := bytes.Index(line[start:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := string(line[start : start+end])
+	if strings.TrimSpace(inner) == "" || strings.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+	block.Advance(start + end + 2)
+
+	target, alias, hasAlias := strings.Cut(inner, "|")
+	target, fragment, _ := strings.Cut(target, "#")
+	target, fragment, alias = strings.TrimSpace(target), strings.TrimSpace(fragment), strings.TrimSpace(alias)
+	src := sourceOf(pc)
+	source := src.relPath
+
+	label := alias
+	if !hasAlias || alias == "" {
+		switch {
+		case target == "":
+			label = fragment
+		case fragment != "":
+			label = target + " > " + fragment
+		default:
+			label = target
+		}
+	}
+
+	if embed && imageExtensions[strings.ToLower(path.Ext(target))] {
+		img := ast.NewImage(ast.NewLink())
+		img.Destination = []byte(rebase(escapePath(target), source, src.root))
+		img.SetAttributeString("class", []byte("wikilink-embed"))
+		alt := target
+		if hasAlias {
+			// ![[image.png|300]] and ![[image.png|300x200]] set the size
+			if w, h, ok := parseSize(alias); ok {
+				img.SetAttributeString("width", []byte(w))
+				if h != "" {
+					img.SetAttributeString("height", []byte(h))
+				}
+			} else if alias != "" {
+				alt = alias
+			}
+		}
+		img.AppendChild(img, ast.NewString([]byte(alt)))
+		return img
+	}
+
+	relPath := source
+	ok := target == ""
+	if !ok && p.idx != nil {
+		relPath, ok = p.idx.resolve(target, source)
+	}
+	if !ok {
+		n := &WikiLink{Target: target, Fragment: fragment, Embed: embed}
+		n.AppendChild(n, ast.NewString([]byte(label)))
+		return n
+	}
+	if embed && target != "" {
+		n := &WikiLink{Target: target, Fragment: fragment, Embed: true, RelPath: relPath}
+		n.AppendChild(n, ast.NewString([]byte(label)))
+		return n
+	}
+
+	link := ast.NewLink()
+	link.Destination = []byte(wikiHref(relPath, fragment, src.root, target == "" && src.root == source))
+	link.SetAttributeString("class", []byte("wikilink"))
+	link.AppendChild(link, ast.NewString([]byte(label)))
+	return link
+}
+
+// parseSize parses an image size such as "300" or "300x200".
+func parseSize(s string) (string, string, bool) {
+	w, h, _ := strings.Cut(s, "x")
+	if _, err := strconv.Atoi(w); err != nil {
+		return "", "", false
+	}
+	if h != "" {
+		if _, err := strconv.Atoi(h); err != nil {
+			return "", "", false
+		}
+	}
+	return w, h, true
+}
+
+// wikiHref returns the link from the page at source to the page at
+// relPath and its heading, if any. samePage links only to the heading.
+func wikiHref(relPath, heading, source string, samePage bool) string {
+	var fragment string
+	if heading != "" {
+		fragment = "#" + headingID(heading)
+	}
+	if samePage {
+		return fragment
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(source)), filepath.FromSlash(relPath))
+	if err != nil {
+		rel = relPath
+	}
+	return escapePath(filepath.ToSlash(rel)) + fragment
+}
+
+// headingID returns the ID goldmark generates for a heading's text.
+func headingID(heading string) string {
+	return string(parser.NewContext().IDs().Generate([]byte(heading), ast.KindHeading))
+}
+
+// escapePath escapes a relative path for use in a URL.
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// wikiEmbedTransformer renders embedded pages. An embed alone in a
+// paragraph replaces the paragraph with the page; one inside text becomes
+// a link to it.
+type wikiEmbedTransformer struct {
+	e *wikiExtension
+}
+
+func (t *wikiEmbedTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var embeds []*WikiLink
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*WikiLink); ok && entering && link.Embed && link.RelPath != "" {
+			embeds = append(embeds, link)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	source := sourceOf(pc)
+	if source.root != source.relPath {
+		rebaseLinks(doc, source.relPath, source.root)
+	}
+	for _, link := range embeds {
+		href := wikiHref(link.RelPath, link.Fragment, source.root, false)
+		parent := link.Parent()
+		if parent.Kind() != ast.KindParagraph || parent.ChildCount() != 1 {
+			a := ast.NewLink()
+			a.Destination = []byte(href)
+			a.SetAttributeString("class", []byte("wikilink"))
+			a.AppendChild(a, ast.NewString([]byte(plainText(link, nil))))
+			parent.ReplaceChild(parent, link, a)
+			continue
+		}
+
+		embed := &WikiEmbed{RelPath: link.RelPath, Title: plainText(link, nil), Href: href}
+		chain := append(append([]string(nil), source.chain...), source.relPath)
+		embed.HTML = t.e.c.embed(link.RelPath, link.Fragment, chain)
+		parent.Parent().ReplaceChild(parent.Parent(), parent, embed)
+	}
+}
+
+// rebaseLinks makes the relative links and images in an embedded page,
+// written relative to relPath, relative to root instead.
+func rebaseLinks(doc ast.Node, relPath, root string) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Link:
+			if !isWikiNode(node) {
+				node.Destination = []byte(rebase(string(node.Destination), relPath, root))
+			}
+		case *ast.Image:
+			if !isWikiNode(node) {
+				node.Destination = []byte(rebase(string(node.Destination), relPath, root))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// isWikiNode reports whether n was parsed from a wiki link, and so is
+// already relative to the root document.
+func isWikiNode(n ast.Node) bool {
+	_, ok := n.AttributeString("class")
+	return ok
+}
+
+// rebase rewrites dest, relative to the document at relPath, to be
+// relative to the document at root. Absolute URLs and fragments are
+// returned unchanged.
+func rebase(dest, relPath, root string) string {
+	if dest == "" || relPath == root || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "/") || strings.Contains(dest, ":") {
+		return dest
+	}
+	target := path.Join(path.Dir(relPath), dest)
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(root)), filepath.FromSlash(target))
+	if err != nil {
+		return dest
+	}
+	return filepath.ToSlash(rel)
+}
+
+// embed renders the page at relPath, or the section under heading, for
+// embedding in the pages in chain.
+func (c *Converter) embed(relPath, heading string, chain []string) string {
+	if len(chain) > maxEmbedDepth {
+		return embedError("Embeds are nested too deeply")
+	}
+	for _, p := range chain {
+		if p == relPath {
+			return embedError("Cannot embed " + relPath + " in itself")
+		}
+	}
+	if c.wiki.read == nil {
+		return embedError("Cannot read " + relPath)
+	}
+	source, err := c.wiki.read(relPath)
+	if err != nil {
+		return embedError(fmt.Sprintf("Cannot read %s: %v", relPath, err))
+	}
+
+	doc := c.md.Parser().Parse(text.NewReader(source), parser.WithContext(newWikiContext(relPath, chain)))
+	var blocks []ast.Node
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		blocks = append(blocks, n)
+	}
+	if heading != "" {
+		if blocks = section(blocks, headingID(heading)); blocks == nil {
+			return embedError("No heading " + heading + " in " + relPath)
+		}
+	}
+	out, err := c.render(blocks, source)
+	if err != nil {
+		return embedError(err.Error())
+	}
+	return out
+}
+
+// section returns the heading with the given ID and the blocks under it,
+// up to the next heading of the same or a higher level.
+func section(blocks []ast.Node, id string) []ast.Node {
+	for i, n := range blocks {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			continue
+		}
+		if value, ok := h.AttributeString("id"); !ok || string(value.([]byte)) != id {
+			continue
+		}
+		end := i + 1
+		for ; end < len(blocks); end++ {
+			if next, ok := blocks[end].(*ast.Heading); ok && next.Level <= h.Level {
+				break
+			}
+		}
+		return blocks[i:end]
+	}
+	return nil
+}
+
+func embedError(message string) string {
+	return `<p class="wikilink-unresolved">` + html.EscapeString(message) + "</p>\n"
+}
+
+// wikiRenderer renders WikiLink and WikiEmbed nodes.
+type wikiRenderer struct{}
+
+func (r *wikiRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.renderWikiLink)
+	reg.Register(KindWikiEmbed, r.renderWikiEmbed)
+}
+
+func (r *wikiRenderer) renderWikiLink(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	link := n.(*WikiLink)
+	name := link.Target
+	if link.Fragment != "" {
+		name += "#" + link.Fragment
+	}
+	fmt.Fprintf(w, `<a class="wikilink wikilink-unresolved" title="No page named %s">%s</a>`,
+		html.EscapeString(name), html.EscapeString(plainText(link, source)))
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *wikiRenderer) renderWikiEmbed(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	embed := n.(*WikiEmbed)
+	fmt.Fprintf(w, "<div class=\"wikilink-embed\" data-source=\"%s\">\n<div class=\"wikilink-embed-title\"><a class=\"wikilink\" href=\"%s\">%s</a></div>\n%s</div>\n",
+		html.EscapeString(embed.RelPath), html.EscapeString(embed.Href), html.EscapeString(embed.Title), embed.HTML)
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,161 @@
+// Package diagram renders diagrams such as Graphviz, PlantUML and D2 with
+// locally installed tools. The diagram source is piped to the tool, which
+// writes SVG to its output, and the result is cached by a hash of the
+// command and the source, so unchanged diagrams are not rendered again.
+package diagram
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout limits how long a tool may take to render a diagram.
+const DefaultTimeout = 30 * time.Second
+
+// commands are the default commands for each diagram language.
+var commands = map[string][]string{
+	"dot":      {"dot", "-Tsvg"},
+	"plantuml": {"plantuml", "-tsvg", "-pipe"},
+	"d2":       {"d2", "-", "-"},
+}
+
+// sandboxes are environment variables that keep a language's tool from
+// reading files or URLs named in the diagram, which comes from the
+// previewed repository.
+var sandboxes = map[string][]string{
+	"plantuml": {"PLANTUML_SECURITY_PROFILE=SANDBOX"},
+}
+
+// Languages returns the diagram languages rendered by default, sorted.
+func Languages() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultCommand returns the command that renders a diagram language by
+// default, or nil if there is none.
+func DefaultCommand(lang string) []string {
+	return commands[lang]
+}
+
+// Sandbox returns the environment variables that restrict the tool for a
+// diagram language, whatever command runs it, or nil if there are none.
+func Sandbox(lang string) []string {
+	return sandboxes[lang]
+}
+
+// Option configures optional Renderer behavior.
+type Option func(*Renderer)
+
+// WithCache caches rendered output in dir, which is created when needed.
+func WithCache(dir string) Option {
+	return func(r *Renderer) {
+		r.cacheDir = dir
+	}
+}
+
+// WithTimeout sets how long the command may run, DefaultTimeout if not set.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Renderer) {
+		r.timeout = d
+	}
+}
+
+// WithEnv adds environment variables, in "key=value" form, to those the
+// command inherits.
+func WithEnv(vars ...string) Option {
+	return func(r *Renderer) {
+		r.env = append(r.env, vars...)
+	}
+}
+
+// Renderer renders diagrams by running a command.
+type Renderer struct {
+	command  []string
+	env      []string
+	cacheDir string
+	timeout  time.Duration
+}
+
+// New returns a renderer that runs command, a program and its arguments.
+func New(command []string, opts ...Option) *Renderer {
+	r := &Renderer{command: command, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Render pipes source to the command and returns what it writes to its
+// standard output.
+func (r *Renderer) Render(source []byte) ([]byte, error) {
+	if len(r.command) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	cachePath := ""
+	if r.cacheDir != "" {
+		cachePath = filepath.Join(r.cacheDir, r.key(source))
+		if out, err := os.ReadFile(cachePath); err == nil {
+			return out, nil
+		}
+	}
+
+	name := r.command[0]
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is not installed or not in PATH", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, r.command[1:]...)
+	if len(r.env) > 0 {
+		cmd.Env = append(os.Environ(), r.env...)
+	}
+	cmd.Stdin = bytes.NewReader(source)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %s", name, r.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %v", name, err)
+	}
+
+	out := stdout.Bytes()
+	if cachePath != "" {
+		if err := os.MkdirAll(r.cacheDir, 0755); err == nil {
+			_ = os.WriteFile(cachePath, out, 0644)
+		}
+	}
+	return out, nil
+}
+
+// key returns the cache file name for source rendered by the command in
+// its environment.
+func (r *Renderer) key(source []byte) string {
+	h := sha256.New()
+	for _, arg := range slices.Concat(r.command, r.env) {
+		h.Write([]byte(arg))
+		h.Write([]byte{0})
+	}
+	h.Write(source)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package diagram
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	r := New([]string{"sh", "-c", "tr a-z A-Z"})
+	out, err := r.Render([]byte("digraph { a -> b }"))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "DIGRAPH { A -> B }" {
+		t.Errorf("Render() = %q", out)
+	}
+}
+
+func TestRenderer_Env(t *testing.T) {
+	r := New([]string{"sh", "-c", "echo $PLANTUML_SECURITY_PROFILE"}, WithEnv(Sandbox("plantuml")...))
+	out, err := r.Render(nil)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "SANDBOX" {
+		t.Errorf("PLANTUML_SECURITY_PROFILE = %q, want SANDBOX", got)
+	}
+	if New(r.command).key(nil) == r.key(nil) {
+		t.Error("expected the environment to be part of the cache key")
+	}
+}
+
+func TestRenderer_Cache(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "runs")
+	cacheDir := filepath.Join(dir, "cache")
+	r := New([]string{"sh", "-c", "echo run >> " + counter + "; cat"}, WithCache(cacheDir))
+
+	for i := 0; i < 2; i++ {
+		out, err := r.Render([]byte("<svg/>"))
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if string(out) != "<svg/>" {
+			t.Errorf("Render() = %q", out)
+		}
+	}
+	runs, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(runs), "run"); got != 1 {
+		t.Errorf("command ran %d times, want 1", got)
+	}
+
+	if _, err := r.Render([]byte("<svg></svg>")); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 cached diagrams, got %d", len(entries))
+	}
+}
+
+func TestRenderer_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       *Renderer
+		wantErr string
+	}{
+		{"missing tool", New([]string{"mdp-no-such-tool", "-Tsvg"}), "mdp-no-such-tool is not installed or not in PATH"},
+		{"tool error", New([]string{"sh", "-c", "echo 'syntax error in line 1' >&2; exit 1"}), "sh failed: syntax error in line 1"},
+		{"timeout", New([]string{"sleep", "5"}, WithTimeout(50*time.Millisecond)), "sleep timed out after 50ms"},
+		{"no command", New(nil), "no command to run"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.r.Render([]byte("a"))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Render() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	if got := strings.Join(Languages(), ","); got != "d2,dot,plantuml" {
+		t.Errorf("Languages() = %s", got)
+	}
+	if DefaultCommand("dot")[0] != "dot" || DefaultCommand("mermaid") != nil {
+		t.Error("DefaultCommand() returned the wrong command")
+	}
+}
@@ -0,0 +1,151 @@
+// Package diff compares rendered documents block by block.
+package diff
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one block of a diff.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Status describes how a file changed between two revisions.
+type Status string
+
+const (
+	Added    Status = "added"
+	Modified Status = "modified"
+	Removed  Status = "removed"
+)
+
+// Compute returns the edits that turn the blocks of old into the blocks of
+// new, using a longest common subsequence. Deletions come before
+// insertions within each changed region.
+func Compute(old, new []string) []Edit {
+	// Trim the common prefix and suffix, which is most of a typical edit
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for _, block := range old[:prefix] {
+		edits = append(edits, Edit{Equal, block})
+	}
+	edits = append(edits, lcs(old[prefix:len(old)-suffix], new[prefix:len(new)-suffix])...)
+	for _, block := range old[len(old)-suffix:] {
+		edits = append(edits, Edit{Equal, block})
+	}
+	return edits
+}
+
+// lcs diffs a and b with the classic dynamic programming table.
+func lcs(a, b []string) []Edit {
+	// table[i][j] is the length of the LCS of a[i:] and b[j:]
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	var edits, inserts []Edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, inserts...)
+			inserts = inserts[:0]
+			edits = append(edits, Edit{Equal, a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || table[i][j+1] >= table[i+1][j]):
+			inserts = append(inserts, Edit{Insert, b[j]})
+			j++
+		default:
+			edits = append(edits, Edit{Delete, a[i]})
+			i++
+		}
+	}
+	return append(edits, inserts...)
+}
+
+// Stats counts the inserted and deleted blocks.
+func Stats(edits []Edit) (inserted, deleted int) {
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			inserted++
+		case Delete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+// HTML renders the edits of one file, preceded by a header with its path,
+// status and counts. Unchanged blocks span the whole width; each changed
+// region becomes a row with the old blocks on the left and the new ones on
+// the right, which the page can also stack for an inline view.
+func HTML(path string, status Status, edits []Edit) string {
+	var buf strings.Builder
+	inserted, deleted := Stats(edits)
+	fmt.Fprintf(&buf, `<div class="diff-header"><span class="diff-status diff-status-%s">%s</span><span class="diff-path">%s</span><span class="diff-stats"><span class="diff-stat-ins">+%d</span> <span class="diff-stat-del">&minus;%d</span></span></div>`,
+		status, statusLabel(status), html.EscapeString(path), inserted, deleted)
+	buf.WriteString("\n")
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			fmt.Fprintf(&buf, "<div class=\"diff-row diff-equal\">\n%s</div>\n", edits[i].Text)
+			i++
+			continue
+		}
+
+		var old, new strings.Builder
+		for ; i < len(edits) && edits[i].Op != Equal; i++ {
+			if edits[i].Op == Delete {
+				old.WriteString(edits[i].Text)
+			} else {
+				new.WriteString(edits[i].Text)
+			}
+		}
+		fmt.Fprintf(&buf, "<div class=\"diff-row diff-change\"><div class=\"diff-old\">%s</div><div class=\"diff-new\">%s</div></div>\n", old.String(), new.String())
+	}
+	return buf.String()
+}
+
+func statusLabel(s Status) string {
+	switch s {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	default:
+		return "Modified"
+	}
+}
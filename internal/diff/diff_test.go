@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []string
+		new  []string
+		want []Edit
+	}{
+		{
+			name: "identical",
+			old:  []string{"a", "b"},
+			new:  []string{"a", "b"},
+			want: []Edit{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name: "changed block",
+			old:  []string{"a", "b", "c"},
+			new:  []string{"a", "B", "c"},
+			want: []Edit{{Equal, "a"}, {Delete, "b"}, {Insert, "B"}, {Equal, "c"}},
+		},
+		{
+			name: "insert and delete",
+			old:  []string{"a", "b", "c", "d"},
+			new:  []string{"x", "a", "c", "d", "e"},
+			want: []Edit{{Insert, "x"}, {Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Equal, "d"}, {Insert, "e"}},
+		},
+		{
+			name: "added file",
+			old:  nil,
+			new:  []string{"a"},
+			want: []Edit{{Insert, "a"}},
+		},
+		{
+			name: "removed file",
+			old:  []string{"a"},
+			new:  nil,
+			want: []Edit{{Delete, "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compute(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	edits := Compute(
+		[]string{"<h1>Title</h1>\n", "<p>Old</p>\n", "<p>Gone</p>\n"},
+		[]string{"<h1>Title</h1>\n", "<p>New</p>\n"},
+	)
+	result := HTML("docs/<a>.md", Modified, edits)
+
+	checks := []string{
+		`<span class="diff-status diff-status-modified">Modified</span>`,
+		`<span class="diff-path">docs/&lt;a&gt;.md</span>`,
+		`<span class="diff-stat-ins">+1</span> <span class="diff-stat-del">&minus;2</span>`,
+		"<div class=\"diff-row diff-equal\">\n<h1>Title</h1>\n</div>",
+		"<div class=\"diff-row diff-change\"><div class=\"diff-old\"><p>Old</p>\n<p>Gone</p>\n</div><div class=\"diff-new\"><p>New</p>\n</div></div>",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected HTML to contain %q, got:\n%s", check, result)
+		}
+	}
+
+	if !strings.Contains(HTML("a.md", Added, Compute(nil, []string{"<p>x</p>"})), `<div class="diff-old"></div>`) {
+		t.Error("expected an empty old side for an added file")
+	}
+}
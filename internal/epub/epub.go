@@ -0,0 +1,420 @@
+// Package epub packages converted markdown files into an EPUB 3 publication.
+package epub
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"mdp/internal/filetree"
+	"mdp/internal/linkrewriter"
+)
+
+// Book describes the content of an EPUB publication.
+type Book struct {
+	Title      string
+	Language   string               // BCP 47 language tag, defaults to "en"
+	Files      []filetree.FileEntry // Chapters in reading order; Content is HTML
+	Stylesheet string               // CSS applied to every chapter
+	Modified   time.Time            // Last modification date, defaults to now
+}
+
+var (
+	headingRe = regexp.MustCompile(`(?s)<h([1-6])[^>]*\sid="([^"]+)"[^>]*>(.*?)</h[1-6]>`)
+	imgRe     = regexp.MustCompile(`<img\s[^>]*>`)
+	imgSrcRe  = regexp.MustCompile(`(\ssrc=")([^"]+)(")`)
+	imgAltRe  = regexp.MustCompile(`\salt="([^"]*)"`)
+	tagRe     = regexp.MustCompile(`<[^>]*>`)
+)
+
+// heading is a heading found in a chapter, used for the navigation document.
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+// chapter is a single XHTML content document in the book.
+type chapter struct {
+	id       string // manifest item id
+	href     string // file name inside OEBPS/
+	title    string
+	body     string
+	headings []heading
+}
+
+// resource is a non-chapter file in the manifest (images).
+type resource struct {
+	id        string
+	href      string
+	mediaType string
+	data      []byte
+}
+
+// Write encodes book as an EPUB 3 archive to w.
+// Relative images are read from disk (resolved against each file's Path)
+// and embedded; links between files are rewritten to point at chapters.
+func Write(w io.Writer, book Book) error {
+	if len(book.Files) == 0 {
+		return fmt.Errorf("epub: no files to package")
+	}
+	if book.Language == "" {
+		book.Language = "en"
+	}
+	if book.Modified.IsZero() {
+		book.Modified = time.Now()
+	}
+
+	chapterHref := make(map[string]string)
+	for _, f := range book.Files {
+		chapterHref[f.ID] = chapterFileName(f.ID)
+	}
+
+	rewriter := linkrewriter.New(book.Files)
+	images := newImageSet()
+
+	var chapters []chapter
+	for _, f := range book.Files {
+		body := rewriter.RewriteLinksFunc(f.Content, f.RelPath, func(id, fragment string) string {
+			href := chapterHref[id]
+			if fragment != "" {
+				href += "#" + url.PathEscape(fragment)
+			}
+			return href
+		})
+		body = images.rewrite(body, filepath.Dir(f.Path))
+		body = toXHTML(body)
+
+		headings := extractHeadings(body)
+		title := f.Name
+		if len(headings) > 0 && headings[0].text != "" {
+			title = headings[0].text
+		}
+		body += backlinksSection(f.Backlinks, chapterHref)
+
+		chapters = append(chapters, chapter{
+			id:       "ch-" + f.ID,
+			href:     chapterHref[f.ID],
+			title:    title,
+			body:     body,
+			headings: headings,
+		})
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The mimetype file must come first and be stored uncompressed.
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"META-INF/container.xml", containerXML},
+		{"OEBPS/content.opf", packageDocument(book, chapters, images.resources)},
+		{"OEBPS/nav.xhtml", navDocument(book, chapters)},
+		{"OEBPS/style.css", book.Stylesheet},
+	}
+	for _, ch := range chapters {
+		files = append(files, struct {
+			name    string
+			content string
+		}{"OEBPS/" + ch.href, chapterDocument(book, ch)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	for _, res := range images.resources {
+		fw, err := zw.Create("OEBPS/" + res.href)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(res.data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// backlinksSection lists the chapters that link to a chapter. It is
+// added after the headings are collected so it stays out of the contents.
+func backlinksSection(backlinks []filetree.Backlink, chapterHref map[string]string) string {
+	if len(backlinks) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("<aside class=\"backlinks\">\n<h2>Linked from</h2>\n<ul>\n")
+	for _, b := range backlinks {
+		fmt.Fprintf(&buf, "<li><a href=\"%s\">%s</a>", html.EscapeString(chapterHref[b.ID]), html.EscapeString(b.Name))
+		for _, excerpt := range b.Excerpts {
+			fmt.Fprintf(&buf, "<p>%s</p>", html.EscapeString(excerpt))
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul>\n</aside>\n")
+	return buf.String()
+}
+
+// chapterFileName returns the file name of the chapter for a section ID.
+func chapterFileName(id string) string {
+	return id + ".xhtml"
+}
+
+// imageSet collects images referenced by chapters.
+type imageSet struct {
+	resources []resource
+	bySource  map[string]string // absolute path -> href in the book
+}
+
+func newImageSet() *imageSet {
+	return &imageSet{bySource: make(map[string]string)}
+}
+
+// rewrite embeds the images referenced in body and points their src
+// attributes at the embedded copies. Remote images become links, since
+// EPUB only allows remote audio, video and fonts, and images in formats
+// outside the EPUB core media types become their alt text. Images that
+// cannot be read are left unchanged.
+func (s *imageSet) rewrite(body, baseDir string) string {
+	return imgRe.ReplaceAllStringFunc(body, func(tag string) string {
+		parts := imgSrcRe.FindStringSubmatch(tag)
+		if parts == nil {
+			return tag
+		}
+		src := html.UnescapeString(parts[2])
+		alt := ""
+		if m := imgAltRe.FindStringSubmatch(tag); m != nil {
+			alt = m[1]
+		}
+
+		if strings.HasPrefix(src, "data:") {
+			return tag
+		}
+
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			text := alt
+			if text == "" {
+				text = parts[2]
+			}
+			return `<a href="` + parts[2] + `">` + text + `</a>`
+		}
+
+		decoded, err := url.PathUnescape(src)
+		if err != nil {
+			decoded = src
+		}
+		decoded, _, _ = strings.Cut(decoded, "#")
+		decoded, _, _ = strings.Cut(decoded, "?")
+
+		mediaType, ok := imageMediaType(decoded)
+		if !ok {
+			return alt
+		}
+
+		abs := decoded
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(baseDir, filepath.FromSlash(decoded))
+		}
+
+		href, ok := s.bySource[abs]
+		if !ok {
+			data, err := os.ReadFile(abs)
+			if err != nil {
+				return tag
+			}
+			n := len(s.resources) + 1
+			href = fmt.Sprintf("images/img-%d%s", n, strings.ToLower(path.Ext(decoded)))
+			s.bySource[abs] = href
+			s.resources = append(s.resources, resource{
+				id:        fmt.Sprintf("img-%d", n),
+				href:      href,
+				mediaType: mediaType,
+				data:      data,
+			})
+		}
+		return strings.Replace(tag, parts[0], parts[1]+html.EscapeString(href)+parts[3], 1)
+	})
+}
+
+// imageMediaType returns the media type of an image based on its
+// extension, and false unless it is one of the EPUB core media types.
+func imageMediaType(name string) (string, bool) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png":
+		return "image/png", true
+	case ".jpg", ".jpeg":
+		return "image/jpeg", true
+	case ".gif":
+		return "image/gif", true
+	case ".svg":
+		return "image/svg+xml", true
+	case ".webp":
+		return "image/webp", true
+	}
+	return "", false
+}
+
+// extractHeadings returns the headings with an id attribute in body.
+func extractHeadings(body string) []heading {
+	var headings []heading
+	for _, m := range headingRe.FindAllStringSubmatch(body, -1) {
+		text := strings.TrimSpace(html.UnescapeString(tagRe.ReplaceAllString(m[3], "")))
+		headings = append(headings, heading{
+			level: int(m[1][0] - '0'),
+			id:    html.UnescapeString(m[2]),
+			text:  text,
+		})
+	}
+	return headings
+}
+
+// bookID derives a stable urn:uuid identifier from the book content.
+func bookID(book Book) string {
+	h := sha1.New()
+	io.WriteString(h, book.Title)
+	for _, f := range book.Files {
+		io.WriteString(h, f.RelPath)
+		io.WriteString(h, f.Content)
+	}
+	sum := h.Sum(nil)
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// packageDocument renders the OPF package document.
+func packageDocument(book Book, chapters []chapter, resources []resource) string {
+	var buf strings.Builder
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&buf, `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" xml:lang="%s">`+"\n", html.EscapeString(book.Language))
+	buf.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&buf, "    <dc:identifier id=\"bookid\">%s</dc:identifier>\n", bookID(book))
+	fmt.Fprintf(&buf, "    <dc:title>%s</dc:title>\n", html.EscapeString(book.Title))
+	fmt.Fprintf(&buf, "    <dc:language>%s</dc:language>\n", html.EscapeString(book.Language))
+	fmt.Fprintf(&buf, "    <meta property=\"dcterms:modified\">%s</meta>\n", book.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	buf.WriteString("  </metadata>\n")
+
+	buf.WriteString("  <manifest>\n")
+	buf.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	buf.WriteString(`    <item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for _, ch := range chapters {
+		fmt.Fprintf(&buf, "    <item id=\"%s\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n",
+			html.EscapeString(ch.id), html.EscapeString(ch.href))
+	}
+	for _, res := range resources {
+		fmt.Fprintf(&buf, "    <item id=\"%s\" href=\"%s\" media-type=\"%s\"/>\n",
+			res.id, html.EscapeString(res.href), res.mediaType)
+	}
+	buf.WriteString("  </manifest>\n")
+
+	buf.WriteString("  <spine>\n")
+	for _, ch := range chapters {
+		fmt.Fprintf(&buf, "    <itemref idref=\"%s\"/>\n", html.EscapeString(ch.id))
+	}
+	buf.WriteString("  </spine>\n")
+	buf.WriteString("</package>\n")
+	return buf.String()
+}
+
+// navDocument renders the EPUB navigation document. Each chapter is listed
+// with its headings nested below it according to their level.
+func navDocument(book Book, chapters []chapter) string {
+	var buf strings.Builder
+	writeDocumentStart(&buf, book, "Contents", false)
+	buf.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n")
+	for _, ch := range chapters {
+		fmt.Fprintf(&buf, "<li><a href=\"%s\">%s</a>", html.EscapeString(ch.href), html.EscapeString(ch.title))
+
+		headings := ch.headings
+		// The first heading usually is the chapter title itself
+		if len(headings) > 0 && headings[0].text == ch.title {
+			headings = headings[1:]
+		}
+		writeHeadingList(&buf, ch.href, headings)
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ol>\n</nav>\n")
+	buf.WriteString("</body>\n</html>\n")
+	return buf.String()
+}
+
+// writeHeadingList writes headings as nested ordered lists. A heading is
+// nested below the closest preceding heading of a lower level.
+func writeHeadingList(buf *strings.Builder, href string, headings []heading) {
+	if len(headings) == 0 {
+		return
+	}
+
+	buf.WriteString("<ol>")
+	for i := 0; i < len(headings); {
+		h := headings[i]
+		fmt.Fprintf(buf, "<li><a href=\"%s#%s\">%s</a>",
+			html.EscapeString(href), html.EscapeString(h.id), html.EscapeString(h.text))
+
+		// Collect deeper headings that belong to this one
+		j := i + 1
+		for j < len(headings) && headings[j].level > h.level {
+			j++
+		}
+		writeHeadingList(buf, href, headings[i+1:j])
+		buf.WriteString("</li>")
+		i = j
+	}
+	buf.WriteString("</ol>")
+}
+
+// chapterDocument renders a chapter as an XHTML content document.
+func chapterDocument(book Book, ch chapter) string {
+	var buf strings.Builder
+	writeDocumentStart(&buf, book, ch.title, true)
+	buf.WriteString("<article class=\"markdown-body\">\n")
+	buf.WriteString(ch.body)
+	buf.WriteString("</article>\n</body>\n</html>\n")
+	return buf.String()
+}
+
+// writeDocumentStart writes the XHTML prologue up to and including <body>.
+func writeDocumentStart(buf *strings.Builder, book Book, title string, stylesheet bool) {
+	lang := html.EscapeString(book.Language)
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString("<!DOCTYPE html>\n")
+	fmt.Fprintf(buf, `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%s" xml:lang="%s">`+"\n", lang, lang)
+	buf.WriteString("<head>\n<meta charset=\"UTF-8\"/>\n")
+	fmt.Fprintf(buf, "<title>%s</title>\n", html.EscapeString(title))
+	if stylesheet {
+		buf.WriteString("<link rel=\"stylesheet\" type=\"text/css\" href=\"style.css\"/>\n")
+	}
+	buf.WriteString("</head>\n<body>\n")
+}
@@ -0,0 +1,293 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mdp/internal/filetree"
+)
+
+// readArchive writes book and returns the archive entries by name, in order.
+func readArchive(t *testing.T, book Book) ([]string, map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, book); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+
+	var names []string
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		names = append(names, f.Name)
+		contents[f.Name] = string(data)
+	}
+	return names, contents
+}
+
+func TestWrite_Structure(t *testing.T) {
+	book := Book{
+		Title: "Runbook",
+		Files: []filetree.FileEntry{
+			{ID: "intro-md", Name: "intro", RelPath: "intro.md", Content: `<h1 id="intro">Intro</h1>`},
+		},
+		Stylesheet: ".markdown-body{}",
+		Modified:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, book); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+
+	first := zr.File[0]
+	if first.Name != "mimetype" {
+		t.Errorf("expected first entry to be mimetype, got %q", first.Name)
+	}
+	if first.Method != zip.Store {
+		t.Error("expected mimetype to be stored uncompressed")
+	}
+
+	_, contents := readArchive(t, book)
+	if contents["mimetype"] != "application/epub+zip" {
+		t.Errorf("unexpected mimetype content: %q", contents["mimetype"])
+	}
+
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/style.css", "OEBPS/intro-md.xhtml"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("expected archive to contain %s", name)
+		}
+	}
+
+	opf := contents["OEBPS/content.opf"]
+	checks := []string{
+		`version="3.0"`,
+		"<dc:title>Runbook</dc:title>",
+		`<meta property="dcterms:modified">2024-01-02T03:04:05Z</meta>`,
+		`properties="nav"`,
+		`<itemref idref="ch-intro-md"/>`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(opf, check) {
+			t.Errorf("expected content.opf to contain %q", check)
+		}
+	}
+}
+
+func TestWrite_NoFiles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Book{Title: "Empty"}); err == nil {
+		t.Error("expected error for book without files")
+	}
+}
+
+func TestWrite_NavigationFromHeadings(t *testing.T) {
+	book := Book{
+		Title: "Guide",
+		Files: []filetree.FileEntry{
+			{ID: "a-md", Name: "a", RelPath: "a.md", Content: `<h1 id="setup">Setup</h1><h2 id="install">Install</h2><h3 id="linux">Linux</h3><h2 id="config">Config</h2>`},
+			{ID: "b-md", Name: "b", RelPath: "b.md", Content: `<p>No headings</p>`},
+		},
+	}
+
+	_, contents := readArchive(t, book)
+	nav := contents["OEBPS/nav.xhtml"]
+
+	checks := []string{
+		`epub:type="toc"`,
+		`<li><a href="a-md.xhtml">Setup</a><ol><li><a href="a-md.xhtml#install">Install</a><ol><li><a href="a-md.xhtml#linux">Linux</a></li></ol></li><li><a href="a-md.xhtml#config">Config</a></li></ol></li>`,
+		`<li><a href="b-md.xhtml">b</a></li>`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(nav, check) {
+			t.Errorf("expected nav.xhtml to contain %q, got: %s", check, nav)
+		}
+	}
+}
+
+func TestWrite_RewritesCrossFileLinks(t *testing.T) {
+	book := Book{
+		Title: "Links",
+		Files: []filetree.FileEntry{
+			{ID: "readme-md", Name: "README", RelPath: "README.md", Content: `<p><a href="docs/guide.md#usage">Guide</a> <a href="https://example.com">Web</a></p>`},
+			{ID: "docs-guide-md", Name: "guide", RelPath: "docs/guide.md", Content: `<p><a href="../README.md">Back</a></p>`},
+		},
+	}
+
+	_, contents := readArchive(t, book)
+
+	readme := contents["OEBPS/readme-md.xhtml"]
+	if !strings.Contains(readme, `<a href="docs-guide-md.xhtml#usage">`) {
+		t.Errorf("expected link to guide chapter with fragment, got: %s", readme)
+	}
+	if !strings.Contains(readme, `<a href="https://example.com">`) {
+		t.Errorf("expected external link to be unchanged, got: %s", readme)
+	}
+
+	guide := contents["OEBPS/docs-guide-md.xhtml"]
+	if !strings.Contains(guide, `<a href="readme-md.xhtml">`) {
+		t.Errorf("expected link back to readme chapter, got: %s", guide)
+	}
+}
+
+func TestWrite_Backlinks(t *testing.T) {
+	book := Book{
+		Title: "Backlinks",
+		Files: []filetree.FileEntry{
+			{ID: "readme-md", Name: "README", RelPath: "README.md", Content: `<h1>Readme</h1>`, Backlinks: []filetree.Backlink{
+				{ID: "docs-guide-md", Name: "guide", RelPath: "docs/guide.md", Excerpts: []string{"Back to the start & more."}},
+			}},
+			{ID: "docs-guide-md", Name: "guide", RelPath: "docs/guide.md", Content: `<p><a href="../README.md">Back</a> to the start &amp; more.</p>`},
+		},
+	}
+
+	_, contents := readArchive(t, book)
+
+	readme := contents["OEBPS/readme-md.xhtml"]
+	if !strings.Contains(readme, `<li><a href="docs-guide-md.xhtml">guide</a><p>Back to the start &amp; more.</p></li>`) {
+		t.Errorf("expected backlink to guide chapter, got: %s", readme)
+	}
+	if strings.Contains(contents["OEBPS/nav.xhtml"], "Linked from") {
+		t.Error("expected backlinks to stay out of the table of contents")
+	}
+}
+
+func TestWrite_EmbedsImages(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "diagram.png"), []byte("png-data"), 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	book := Book{
+		Title: "Images",
+		Files: []filetree.FileEntry{
+			{
+				ID:      "doc-md",
+				Name:    "doc",
+				Path:    filepath.Join(tmpDir, "doc.md"),
+				RelPath: "doc.md",
+				Content: `<p><img src="diagram.png" alt="d" /> <img src="diagram.png" alt="again" /> <img src="missing.png" alt="m" /></p>`,
+			},
+		},
+	}
+
+	_, contents := readArchive(t, book)
+
+	if contents["OEBPS/images/img-1.png"] != "png-data" {
+		t.Error("expected image to be embedded in the archive")
+	}
+
+	chapter := contents["OEBPS/doc-md.xhtml"]
+	if strings.Count(chapter, `src="images/img-1.png"`) != 2 {
+		t.Errorf("expected both references to point at the embedded image, got: %s", chapter)
+	}
+	if !strings.Contains(chapter, `src="missing.png"`) {
+		t.Errorf("expected unreadable image to be left unchanged, got: %s", chapter)
+	}
+
+	opf := contents["OEBPS/content.opf"]
+	if !strings.Contains(opf, `<item id="img-1" href="images/img-1.png" media-type="image/png"/>`) {
+		t.Errorf("expected image in manifest, got: %s", opf)
+	}
+}
+
+func TestWrite_ImagesOutsideTheBook(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "scan.bmp"), []byte("bmp-data"), 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	book := Book{
+		Title: "Images",
+		Files: []filetree.FileEntry{
+			{
+				ID:      "doc-md",
+				Name:    "doc",
+				Path:    filepath.Join(tmpDir, "doc.md"),
+				RelPath: "doc.md",
+				Content: `<p><img src="https://example.com/logo.png?v=2&amp;s=1" alt="Logo" /> <img src="scan.bmp" alt="Scan" /></p>`,
+			},
+		},
+	}
+
+	names, contents := readArchive(t, book)
+
+	chapter := contents["OEBPS/doc-md.xhtml"]
+	if !strings.Contains(chapter, `<a href="https://example.com/logo.png?v=2&amp;s=1">Logo</a>`) {
+		t.Errorf("expected the remote image to become a link, got: %s", chapter)
+	}
+	if !strings.Contains(chapter, "<p><a") || !strings.Contains(chapter, "</a> Scan</p>") {
+		t.Errorf("expected the unsupported image to become its alt text, got: %s", chapter)
+	}
+	opf := contents["OEBPS/content.opf"]
+	for _, unwanted := range []string{"remote-resources", "example.com", "octet-stream", "img-1"} {
+		if strings.Contains(opf, unwanted) {
+			t.Errorf("expected the manifest not to contain %q, got: %s", unwanted, opf)
+		}
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "OEBPS/images/") {
+			t.Errorf("expected no images in the archive, got %s", name)
+		}
+	}
+}
+
+func TestWrite_ChaptersAreWellFormedXML(t *testing.T) {
+	book := Book{
+		Title: "Raw HTML",
+		Files: []filetree.FileEntry{
+			{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>line<br>two</p>\n<p align=\"center\"><img src=\"x.png\"></p>\n<hr>"},
+			{ID: "b-md", Name: "b", RelPath: "b.md", Content: "<div><p>unclosed <b>bold\n<!-- a -- comment -->\n<input type=checkbox disabled @click=\"x\"> &nbsp;&copy; &bogus; \x01</div>"},
+			{ID: "c-md", Name: "c", RelPath: "c.md", Content: `<svg viewBox="0 0 10 10"><a xlink:href="#x"><path d="M0 0L10 10"/></a><foreignObject></foreignObject></svg><math><mi>x</mi></math>`},
+		},
+	}
+
+	names, contents := readArchive(t, book)
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".xhtml") {
+			continue
+		}
+		decoder := xml.NewDecoder(strings.NewReader(contents[name]))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s is not well-formed XML: %v\n%s", name, err, contents[name])
+			}
+		}
+	}
+
+	for name, want := range map[string]string{
+		"OEBPS/a-md.xhtml": `<p>line<br/>two</p>` + "\n" + `<p align="center"><img src="x.png"/></p>` + "\n<hr/>",
+		"OEBPS/b-md.xhtml": "<div><p>unclosed <b>bold\n\n<input type=\"checkbox\" disabled=\"\"/> \u00a0© &amp;bogus; </b></p></div>",
+		"OEBPS/c-md.xhtml": `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><a xlink:href="#x"><path d="M0 0L10 10"/></a><foreignObject/></svg><math xmlns="http://www.w3.org/1998/Math/MathML"><mi>x</mi></math>`,
+	} {
+		if !strings.Contains(contents[name], want) {
+			t.Errorf("expected %s to contain %q, got: %s", name, want, contents[name])
+		}
+	}
+}
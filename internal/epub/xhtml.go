@@ -0,0 +1,114 @@
+package epub
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// xmlNameRe matches the element and attribute names that are kept.
+var xmlNameRe = regexp.MustCompile(`^[A-Za-z_][-A-Za-z0-9_.]*$`)
+
+// voidElements are HTML elements without content or an end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// foreignNamespaces are the namespaces of SVG and MathML elements, which
+// are declared on the outermost element of each.
+var foreignNamespaces = map[string]string{
+	"svg":  "http://www.w3.org/2000/svg",
+	"math": "http://www.w3.org/1998/Math/MathML",
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// toXHTML parses an HTML fragment the way a browser would and writes it
+// back as well-formed XHTML, since chapters are XML documents that raw
+// HTML in markdown, such as <br> or unclosed tags, would otherwise break.
+// Comments are dropped, as are attributes that are not valid XML names.
+func toXHTML(body string) string {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(body), context)
+	if err != nil {
+		return textEscaper.Replace(xmlChars(body))
+	}
+	var buf strings.Builder
+	for _, n := range nodes {
+		writeXHTML(&buf, n)
+	}
+	return buf.String()
+}
+
+// writeXHTML writes n and its children as XML.
+func writeXHTML(buf *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(textEscaper.Replace(xmlChars(n.Data)))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if !xmlNameRe.MatchString(n.Data) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeXHTML(buf, c)
+		}
+		return
+	}
+
+	buf.WriteString("<" + n.Data)
+	foreign := n.Namespace != "" && (n.Parent == nil || n.Parent.Namespace != n.Namespace)
+	if foreign {
+		buf.WriteString(` xmlns="` + foreignNamespaces[n.Namespace] + `"`)
+		if n.Namespace == "svg" {
+			buf.WriteString(` xmlns:xlink="http://www.w3.org/1999/xlink"`)
+		}
+	}
+	for _, a := range n.Attr {
+		name := a.Key
+		if a.Namespace != "" {
+			name = a.Namespace + ":" + a.Key
+		}
+		if validAttr(name, n.Namespace) {
+			buf.WriteString(" " + name + `="` + attrEscaper.Replace(xmlChars(a.Val)) + `"`)
+		}
+	}
+
+	if n.FirstChild == nil && (voidElements[n.Data] || n.Namespace != "") {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteString(">")
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeXHTML(buf, c)
+	}
+	buf.WriteString("</" + n.Data + ">")
+}
+
+// validAttr reports whether an attribute can be written as XML on an
+// element in namespace. Prefixed names are kept for the prefixes that are
+// declared: xml, epub on the chapter and xlink on SVG elements.
+func validAttr(name, namespace string) bool {
+	prefix, local, ok := strings.Cut(name, ":")
+	if !ok {
+		return xmlNameRe.MatchString(name) && name != "xmlns"
+	}
+	return xmlNameRe.MatchString(local) && (prefix == "xml" || prefix == "epub" || prefix == "xlink" && namespace == "svg")
+}
+
+// xmlChars removes the control characters that XML does not allow.
+func xmlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s)
+}
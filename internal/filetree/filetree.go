@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 // FileEntry represents a single markdown file.
@@ -13,6 +14,20 @@ type FileEntry struct {
 	Name    string // Display name (filename without extension)
 	RelPath string // Relative path for display in tree
 	Content string // Converted HTML content
+	Status  string // Optional change status shown in the sidebar, e.g. "modified"
+
+	Author   string    // Optional author of the last change
+	Modified time.Time // Optional date of the last change
+
+	Backlinks []Backlink // Optional files that link to this one
+}
+
+// Backlink is a file that links to another file.
+type Backlink struct {
+	ID       string   // Section ID of the linking file
+	Name     string   // Display name of the linking file
+	RelPath  string   // Relative path of the linking file
+	Excerpts []string // Sentences around each link, as plain text
 }
 
 // TreeNode represents a node in the file tree (file or directory).
@@ -99,3 +114,19 @@ func sortTree(node *TreeNode) {
 		sortTree(child)
 	}
 }
+
+// Files returns the file entries below node in display order, i.e. the order
+// in which they appear in the sidebar.
+func (n *TreeNode) Files() []*FileEntry {
+	var files []*FileEntry
+	if !n.IsDir {
+		if n.File != nil {
+			files = append(files, n.File)
+		}
+		return files
+	}
+	for _, child := range n.Children {
+		files = append(files, child.Files()...)
+	}
+	return files
+}
@@ -152,3 +152,25 @@ func TestBuildTree_FileEntryPreserved(t *testing.T) {
 		t.Errorf("expected content to be preserved")
 	}
 }
+
+func TestTreeNode_Files(t *testing.T) {
+	files := []FileEntry{
+		{Name: "z", RelPath: "z.md"},
+		{Name: "b", RelPath: "dir/b.md"},
+		{Name: "a", RelPath: "a.md"},
+		{Name: "c", RelPath: "dir/sub/c.md"},
+	}
+
+	tree := BuildTree(files)
+	ordered := tree.Files()
+
+	expected := []string{"c", "b", "a", "z"}
+	if len(ordered) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(ordered))
+	}
+	for i, name := range expected {
+		if ordered[i].Name != name {
+			t.Errorf("Files()[%d] = %q, want %q", i, ordered[i].Name, name)
+		}
+	}
+}
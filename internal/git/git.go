@@ -0,0 +1,295 @@
+// Package git reads files and history from a local repository by running
+// the git command line tool.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Repo is a git working tree.
+type Repo struct {
+	Dir string // Top-level directory of the working tree
+}
+
+// Open returns the repository containing dir.
+func Open(dir string) (*Repo, error) {
+	out, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	top, err := filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{Dir: top}, nil
+}
+
+// run executes git in dir and returns its standard output. The error
+// includes git's own message.
+func run(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+func (r *Repo) run(args ...string) ([]byte, error) {
+	return run(r.Dir, args...)
+}
+
+// RelPath returns path relative to the top of the repository, with forward
+// slashes as git expects.
+func (r *Repo) RelPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// The path may not exist in the working tree, so resolve symlinks in
+	// the longest prefix that does
+	resolved := abs
+	rest := ""
+	for {
+		if eval, err := filepath.EvalSymlinks(resolved); err == nil {
+			resolved = filepath.Join(eval, rest)
+			break
+		}
+		parent := filepath.Dir(resolved)
+		if parent == resolved {
+			resolved = abs
+			break
+		}
+		rest = filepath.Join(filepath.Base(resolved), rest)
+		resolved = parent
+	}
+
+	rel, err := filepath.Rel(r.Dir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside repository %s", path, r.Dir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ResolveRev returns the full commit hash for rev.
+func (r *Repo) ResolveRev(rev string) (string, error) {
+	out, err := r.run("rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s", rev)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// MergeBase returns the best common ancestor of two revisions.
+func (r *Repo) MergeBase(a, b string) (string, error) {
+	out, err := r.run("merge-base", "--end-of-options", a, b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ListFiles returns the files under paths in rev, relative to the top of
+// the repository. paths are relative to the top as well; "." or no paths
+// lists the whole tree.
+func (r *Repo) ListFiles(rev string, paths ...string) ([]string, error) {
+	args := []string{"ls-tree", "-r", "-z", "--name-only", "--full-tree", "--end-of-options", rev}
+	for _, p := range paths {
+		if p != "." {
+			args = append(args, p)
+		}
+	}
+	out, err := r.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// ReadFile returns the contents of path, relative to the top of the
+// repository, as of rev.
+func (r *Repo) ReadFile(rev, path string) ([]byte, error) {
+	return r.run("cat-file", "blob", rev+":"+path)
+}
+
+// FileInfo is the git state of a file.
+type FileInfo struct {
+	Status string    // "modified", "staged", "untracked", or empty if unchanged
+	Author string    // Author of the last commit that changed the file
+	Date   time.Time // Date of that commit, zero if it was never committed
+}
+
+// Info returns the state of files, given as paths in the working tree and
+// keyed the same way. With a non-empty rev, only the last commit up to rev
+// is reported and Status is left empty. Files outside the repository are
+// omitted.
+func (r *Repo) Info(rev string, paths []string) (map[string]FileInfo, error) {
+	byRel := make(map[string]string)
+	var rels []string
+	for _, p := range paths {
+		rel, err := r.RelPath(p)
+		if err != nil {
+			continue
+		}
+		byRel[rel] = p
+		rels = append(rels, rel)
+	}
+
+	info := make(map[string]FileInfo)
+	if len(rels) == 0 {
+		return info, nil
+	}
+
+	commits, err := r.lastCommits(rev, rels)
+	if err != nil {
+		return nil, err
+	}
+	for rel, fi := range commits {
+		info[byRel[rel]] = fi
+	}
+
+	if rev == "" {
+		statuses, err := r.status(rels)
+		if err != nil {
+			return nil, err
+		}
+		for rel, status := range statuses {
+			if p, ok := byRel[rel]; ok {
+				fi := info[p]
+				fi.Status = status
+				info[p] = fi
+			}
+		}
+	}
+	return info, nil
+}
+
+// Lookup returns the state of paths in the repository containing the
+// first of them, like Info. It returns nil if there is no repository or
+// git is not installed, so callers can treat the state as optional.
+func Lookup(rev string, paths []string) map[string]FileInfo {
+	if len(paths) == 0 {
+		return nil
+	}
+	repo, err := Open(filepath.Dir(paths[0]))
+	if err != nil {
+		return nil
+	}
+	info, err := repo.Info(rev, paths)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// commitMarker starts each commit in the lastCommits log output.
+const commitMarker = "\x01"
+
+// lastCommits returns the author and date of the last commit to change
+// each of rels. It reads the log until every file has been seen.
+func (r *Repo) lastCommits(rev string, rels []string) (map[string]FileInfo, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if _, err := r.ResolveRev(rev); err != nil {
+		// No commits yet
+		return map[string]FileInfo{}, nil
+	}
+
+	args := []string{"-C", r.Dir, "-c", "core.quotePath=false", "log",
+		"--format=" + commitMarker + "%an%x09%aI", "--name-only", "--end-of-options", rev, "--"}
+	cmd := exec.Command("git", append(args, rels...)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git log: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	wanted := make(map[string]bool)
+	for _, rel := range rels {
+		wanted[rel] = true
+	}
+	found := make(map[string]FileInfo)
+	var current FileInfo
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && len(found) < len(wanted) {
+		line := scanner.Text()
+		if header, ok := strings.CutPrefix(line, commitMarker); ok {
+			author, date, _ := strings.Cut(header, "\t")
+			current = FileInfo{Author: author}
+			current.Date, _ = time.Parse(time.RFC3339, date)
+			continue
+		}
+		if wanted[line] {
+			if _, seen := found[line]; !seen {
+				found[line] = current
+			}
+		}
+	}
+	return found, nil
+}
+
+// status returns the working tree status of the changed files among rels.
+func (r *Repo) status(rels []string) (map[string]string, error) {
+	args := append([]string{"status", "--porcelain=v1", "-z", "--untracked-files=all", "--"}, rels...)
+	out, err := r.run(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]string)
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y, name := entry[0], entry[1], entry[3:]
+		switch {
+		case x == '?':
+			statuses[name] = "untracked"
+		case y != ' ':
+			statuses[name] = "modified"
+		default:
+			statuses[name] = "staged"
+		}
+		// Renames and copies are followed by the original path
+		if x == 'R' || x == 'C' {
+			i++
+		}
+	}
+	return statuses, nil
+}
+
+// GitDir returns the repository's git directory, where the index and HEAD
+// are stored.
+func (r *Repo) GitDir() (string, error) {
+	out, err := r.run("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,224 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a repository with a commit per map of files, and
+// returns its directory.
+func newTestRepo(t *testing.T, commits ...map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "-q", "-b", "main")
+	for i, files := range commits {
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		gitCmd(t, dir, "add", "-A")
+		gitCmd(t, dir, "commit", "-q", "-m", "commit "+string(rune('1'+i)))
+	}
+	return dir
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func TestOpen(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"README.md": "# Hi"})
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := Open(filepath.Join(dir, "docs"))
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(dir)
+	if repo.Dir != want {
+		t.Errorf("Dir = %q, want %q", repo.Dir, want)
+	}
+
+	if _, err := Open(t.TempDir()); err == nil {
+		t.Error("expected error outside a repository")
+	}
+}
+
+func TestRepo_ReadAndList(t *testing.T) {
+	dir := newTestRepo(t,
+		map[string]string{"README.md": "v1", "docs/a.md": "a"},
+		map[string]string{"README.md": "v2", "docs/b.md": "b"},
+	)
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+
+	files, err := repo.ListFiles("HEAD~1")
+	if err != nil {
+		t.Fatalf("ListFiles() returned error: %v", err)
+	}
+	if want := []string{"README.md", "docs/a.md"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("ListFiles(HEAD~1) = %v, want %v", files, want)
+	}
+
+	files, err = repo.ListFiles("HEAD", "docs")
+	if err != nil {
+		t.Fatalf("ListFiles() returned error: %v", err)
+	}
+	if want := []string{"docs/a.md", "docs/b.md"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("ListFiles(HEAD, docs) = %v, want %v", files, want)
+	}
+
+	content, err := repo.ReadFile("HEAD~1", "README.md")
+	if err != nil {
+		t.Fatalf("ReadFile() returned error: %v", err)
+	}
+	if string(content) != "v1" {
+		t.Errorf("ReadFile(HEAD~1, README.md) = %q, want %q", content, "v1")
+	}
+	if _, err := repo.ReadFile("HEAD~1", "docs/b.md"); err == nil {
+		t.Error("expected error for a file missing at the revision")
+	}
+}
+
+func TestRepo_ResolveRev(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"README.md": "v1"})
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+
+	hash, err := repo.ResolveRev("main")
+	if err != nil {
+		t.Fatalf("ResolveRev() returned error: %v", err)
+	}
+	if want := strings.TrimSpace(gitCmd(t, dir, "rev-parse", "HEAD")); hash != want {
+		t.Errorf("ResolveRev(main) = %q, want %q", hash, want)
+	}
+	if _, err := repo.ResolveRev("no-such-branch"); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+}
+
+func TestRepo_RelPath(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"docs/a.md": "a"})
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{dir, "."},
+		{filepath.Join(dir, "docs"), "docs"},
+		{filepath.Join(dir, "docs", "gone.md"), "docs/gone.md"},
+	}
+	for _, tt := range tests {
+		got, err := repo.RelPath(tt.path)
+		if err != nil {
+			t.Errorf("RelPath(%q) returned error: %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("RelPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := repo.RelPath(t.TempDir()); err == nil {
+		t.Error("expected error for a path outside the repository")
+	}
+}
+
+func TestRepo_Info(t *testing.T) {
+	dir := newTestRepo(t,
+		map[string]string{"a.md": "a", "b.md": "b", "c.md": "c"},
+		map[string]string{"a.md": "a2"},
+	)
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "a3")
+	write("b.md", "b2")
+	gitCmd(t, dir, "add", "b.md")
+	write("new.md", "new")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	paths := map[string]string{}
+	for _, name := range []string{"a.md", "b.md", "c.md", "new.md"} {
+		paths[name] = filepath.Join(dir, name)
+	}
+	info, err := repo.Info("", []string{paths["a.md"], paths["b.md"], paths["c.md"], paths["new.md"]})
+	if err != nil {
+		t.Fatalf("Info() returned error: %v", err)
+	}
+
+	wantStatus := map[string]string{"a.md": "modified", "b.md": "staged", "c.md": "", "new.md": "untracked"}
+	for name, want := range wantStatus {
+		if got := info[paths[name]].Status; got != want {
+			t.Errorf("Info()[%s].Status = %q, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if fi := info[paths[name]]; fi.Author != "Test" || fi.Date.IsZero() {
+			t.Errorf("Info()[%s] = %+v, want last commit by Test", name, fi)
+		}
+	}
+	if fi := info[paths["new.md"]]; !fi.Date.IsZero() {
+		t.Errorf("Info()[new.md].Date = %v, want zero for an uncommitted file", fi.Date)
+	}
+
+	// A revision reports history only
+	info, err = repo.Info("HEAD~1", []string{paths["a.md"]})
+	if err != nil {
+		t.Fatalf("Info() returned error: %v", err)
+	}
+	if fi := info[paths["a.md"]]; fi.Status != "" || fi.Author != "Test" {
+		t.Errorf("Info(HEAD~1)[a.md] = %+v, want commit without status", fi)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"a.md": "a"})
+	path := filepath.Join(dir, "a.md")
+	if fi, ok := Lookup("", []string{path})[path]; !ok || fi.Author != "Test" {
+		t.Errorf("Lookup() = %+v, %v, want last commit by Test", fi, ok)
+	}
+
+	outside := filepath.Join(t.TempDir(), "b.md")
+	if info := Lookup("", []string{outside}); info != nil {
+		t.Errorf("Lookup() outside a repository = %v, want nil", info)
+	}
+}
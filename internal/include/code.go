@@ -0,0 +1,124 @@
+package include
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+var (
+	// Region markers are comments such as "// region setup",
+	// "# region: setup" or "<!-- #region setup -->".
+	regionStart = regexp.MustCompile(`^\s*(?://+|#+|--|;+|/\*+|\*|<!--|%+|')\s*#?region:?\s+([\w.-]+)`)
+	regionEnd   = regexp.MustCompile(`^\s*(?://+|#+|--|;+|/\*+|\*|<!--|%+|')\s*#?end-?region\b`)
+)
+
+// Language returns the code block language for a file, inferred from its
+// name by the Chroma lexers used to highlight code, or "" if none match.
+func Language(path string) string {
+	lexer := lexers.Match(path)
+	if lexer == nil {
+		return ""
+	}
+	config := lexer.Config()
+	if len(config.Aliases) > 0 {
+		return config.Aliases[0]
+	}
+	return strings.ToLower(config.Name)
+}
+
+// CodeBlock wraps code in a fenced code block, with a fence longer than
+// any run of backticks in the code.
+func CodeBlock(code []byte, lang string) []byte {
+	longest := 0
+	run := 0
+	for _, c := range code {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+
+	var buf bytes.Buffer
+	buf.WriteString(fence + lang + "\n")
+	buf.Write(code)
+	if len(code) > 0 && code[len(code)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(fence + "\n")
+	return buf.Bytes()
+}
+
+// selectRegion returns the lines between the start and end markers of the
+// named region. Markers of other regions inside it are dropped.
+func selectRegion(content []byte, name string) ([]byte, error) {
+	var out bytes.Buffer
+	depth := 0
+	found := false
+	for _, line := range splitLines(content) {
+		text := string(line)
+		if m := regionStart.FindStringSubmatch(text); m != nil {
+			switch {
+			case depth > 0:
+				depth++
+			case m[1] == name:
+				depth = 1
+				found = true
+			}
+			continue
+		}
+		if regionEnd.MatchString(text) {
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					return out.Bytes(), nil
+				}
+			}
+			continue
+		}
+		if depth > 0 {
+			out.Write(line)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no region %q", name)
+	}
+	return nil, fmt.Errorf("region %q is not closed", name)
+}
+
+// dedent removes the indentation common to all non-blank lines.
+func dedent(content []byte) []byte {
+	lines := splitLines(content)
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		indent := string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+		if first {
+			prefix = indent
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return content
+	}
+	var out bytes.Buffer
+	for _, line := range lines {
+		out.Write(bytes.TrimPrefix(line, []byte(prefix)))
+	}
+	return out.Bytes()
+}
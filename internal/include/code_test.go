@@ -0,0 +1,108 @@
+package include
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand_Code(t *testing.T) {
+	read := mapReader(map[string]string{
+		"src/main.go":  "package main\n\n// region setup\nfunc setup() {\n\t// region inner\n\tinit()\n\t// endregion\n}\n// endregion\n\nfunc main() {}\n",
+		"src/tool.py":  "import os\n\n    # region: body\n    def run():\n        pass\n    # endregion\n",
+		"src/fence.md": "```go\nx\n```\n",
+		"src/notes":    "plain text\n",
+	})
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "whole file",
+			source: "<!-- include-code: src/notes -->\n",
+			want:   "```\nplain text\n```\n",
+		},
+		{
+			name:   "language from extension and lines",
+			source: "<!-- include-code: src/main.go lines=1 -->\n",
+			want:   "```go\npackage main\n```\n",
+		},
+		{
+			name:   "region with nested markers",
+			source: "{{< include-code \"src/main.go\" region=\"setup\" >}}\n",
+			want:   "```go\nfunc setup() {\n\tinit()\n}\n```\n",
+		},
+		{
+			name:   "region is dedented",
+			source: "<!-- include-code: src/tool.py region=body -->\n",
+			want:   "```python\ndef run():\n    pass\n```\n",
+		},
+		{
+			name:   "language override",
+			source: "<!-- include-code: src/notes lang=console -->\n",
+			want:   "```console\nplain text\n```\n",
+		},
+		{
+			name:   "longer fence around backticks",
+			source: "<!-- include-code: src/fence.md -->\n",
+			want:   "````md\n```go\nx\n```\n````\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, deps, err := Expand([]byte(tt.source), "doc.md", read)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+			if len(deps) != 1 {
+				t.Errorf("expected the source file as the only dependency, got %v", deps)
+			}
+		})
+	}
+}
+
+func TestExpand_CodeErrors(t *testing.T) {
+	read := mapReader(map[string]string{
+		"main.go": "// region open\nx\n",
+	})
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"missing region", "<!-- include-code: main.go region=other -->\n", `no region "other"`},
+		{"open region", "<!-- include-code: main.go region=open -->\n", `region "open" is not closed`},
+		{"lines and region", "<!-- include-code: main.go region=open lines=1-2 -->\n", "use either lines or region"},
+		{"shift", "<!-- include-code: main.go shift=1 -->\n", "shift applies to markdown includes only"},
+		{"region in markdown include", "<!-- include: main.go region=open -->\n", "region and lang apply to include-code only"},
+		{"absolute path", "<!-- include-code: /etc/passwd -->\n", "cannot include /etc/passwd: the path must be relative"},
+		{"outside root", "<!-- include-code: ../../../../../tmp/secret.txt -->\n", "cannot include ../../../../../tmp/secret.txt: it is outside the previewed directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Expand([]byte(tt.source), "doc.md", read)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expand() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":       "go",
+		"app/script.py": "python",
+		"Dockerfile":    "docker",
+		"notes.unknown": "",
+	}
+	for path, want := range tests {
+		if got := Language(path); got != want {
+			t.Errorf("Language(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+// Package include expands include directives in markdown before it is
+// converted, so shared snippets can live in one file.
+//
+// A directive stands on a line of its own and names a file relative to the
+// file it appears in:
+//
+//	<!-- include: ../shared/install.md -->
+//	{{< include "../shared/install.md" >}}
+//
+// Paths must be relative and stay within the working directory or a root
+// given with WithRoot, so a previewed file cannot read files outside it.
+//
+// Options follow the path: lines=5-10 keeps only those lines of the file and
+// shift=1 moves its headings one level down. Directives inside fenced code
+// blocks are left alone.
+//
+// The include-code directive embeds a source file as a fenced code block
+// instead, in the language its extension suggests:
+//
+//	<!-- include-code: ../cmd/main.go region=setup -->
+//
+// region=NAME keeps the lines between the marker comments "region NAME"
+// and "endregion", and lang=NAME overrides the language.
+package include
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDepth limits how deeply includes can nest.
+const maxDepth = 16
+
+var (
+	commentDirective   = regexp.MustCompile(`^([ \t]*)<!--\s*(include|include-code):?\s+(.+?)\s*-->\s*$`)
+	shortcodeDirective = regexp.MustCompile(`^([ \t]*)\{\{<\s*(include|include-code)\s+(.+?)\s*>\}\}\s*$`)
+	atxHeading         = regexp.MustCompile(`^( {0,3})(#{1,6})([ \t]|$)`)
+)
+
+// ReadFunc reads the file at path.
+type ReadFunc func(path string) ([]byte, error)
+
+// Option configures Expand.
+type Option func(*expander)
+
+// WithRoot lets directives include files under dir as well as under the
+// working directory.
+func WithRoot(dir string) Option {
+	return func(e *expander) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			e.roots = append(e.roots, abs)
+		}
+	}
+}
+
+// Directive is a parsed include directive.
+type Directive struct {
+	Path  string // File to include, relative to the including file
+	From  int    // First line to include, 1-based; 0 means the first line
+	To    int    // Last line to include; 0 means the last line
+	Shift int    // Levels to move included headings down by
+
+	Code   bool   // Embed the file as a fenced code block
+	Region string // Named region of a code file to include
+	Lang   string // Language of a code block, inferred from the path if empty
+}
+
+// Expand replaces the include directives in source, the content of the file
+// at path, with the files they name. Included files are read with read and
+// expanded in turn. It returns the expanded markdown and the cleaned paths
+// of every file included, directly or not, in the order they were first read.
+func Expand(source []byte, path string, read ReadFunc, opts ...Option) ([]byte, []string, error) {
+	e := &expander{read: read, seen: make(map[string]bool)}
+	if wd, err := os.Getwd(); err == nil {
+		e.roots = append(e.roots, wd)
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	out, err := e.expand(source, filepath.Clean(path), []string{filepath.Clean(path)})
+	if err != nil {
+		return nil, e.deps, err
+	}
+	return out, e.deps, nil
+}
+
+type expander struct {
+	read  ReadFunc
+	roots []string // Absolute directories included files must be in
+	deps  []string
+	seen  map[string]bool
+}
+
+// expand expands the directives in source, which was read from path.
+// chain holds the files being expanded, outermost first.
+func (e *expander) expand(source []byte, path string, chain []string) ([]byte, error) {
+	if !hasDirective(source) {
+		return source, nil
+	}
+
+	var out bytes.Buffer
+	var fence fenceState
+	for i, line := range splitLines(source) {
+		text := strings.TrimRight(string(line), "\r\n")
+		if fence.update(text) {
+			out.Write(line)
+			continue
+		}
+		indent, name, args, ok := parseLine(text)
+		if !ok {
+			out.Write(line)
+			continue
+		}
+
+		d, err := ParseDirective(args)
+		if err == nil && name == "include-code" {
+			d.Code = true
+		}
+		if err == nil {
+			err = d.validate()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		var included []byte
+		if d.Code {
+			included, err = e.includeCode(d, path)
+		} else {
+			included, err = e.include(d, path, chain)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		for _, l := range splitLines(included) {
+			if indent != "" && len(bytes.TrimSpace(l)) > 0 {
+				out.WriteString(indent)
+			}
+			out.Write(l)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// include reads and expands the file a directive in path names.
+func (e *expander) include(d Directive, path string, chain []string) ([]byte, error) {
+	target, err := e.resolve(d.Path, path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range chain {
+		if p == target {
+			cycle := append(append([]string{}, chain[i:]...), target)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if len(chain) > maxDepth {
+		return nil, fmt.Errorf("includes nested more than %d deep", maxDepth)
+	}
+
+	content, err := e.readDep(target)
+	if err != nil {
+		return nil, fmt.Errorf("cannot include %s: %v", d.Path, err)
+	}
+
+	if d.From > 0 || d.To > 0 {
+		content, err = selectLines(content, d.From, d.To)
+		if err != nil {
+			return nil, fmt.Errorf("cannot include %s: %v", d.Path, err)
+		}
+	} else {
+		content = stripFrontMatter(content)
+	}
+
+	content, err = e.expand(content, target, append(chain, target))
+	if err != nil {
+		return nil, err
+	}
+	if d.Shift != 0 {
+		content = ShiftHeadings(content, d.Shift)
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	return content, nil
+}
+
+// includeCode reads the source file a directive in path names and returns
+// the selected lines as a fenced code block.
+func (e *expander) includeCode(d Directive, path string) ([]byte, error) {
+	target, err := e.resolve(d.Path, path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := e.readDep(target)
+	if err != nil {
+		return nil, fmt.Errorf("cannot include %s: %v", d.Path, err)
+	}
+
+	switch {
+	case d.Region != "":
+		content, err = selectRegion(content, d.Region)
+	case d.From > 0 || d.To > 0:
+		content, err = selectLines(content, d.From, d.To)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot include %s: %v", d.Path, err)
+	}
+
+	lang := d.Lang
+	if lang == "" {
+		lang = Language(target)
+	}
+	return CodeBlock(dedent(content), lang), nil
+}
+
+// readDep reads an included file and records it as a dependency.
+func (e *expander) readDep(target string) ([]byte, error) {
+	if !e.seen[target] {
+		e.seen[target] = true
+		e.deps = append(e.deps, target)
+	}
+	return e.read(target)
+}
+
+// resolve returns the cleaned path of a file named in a directive in path.
+// The name must be relative and the file inside one of the roots.
+func (e *expander) resolve(name, path string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("cannot include %s: the path must be relative", name)
+	}
+	target := filepath.Clean(filepath.Join(filepath.Dir(path), filepath.FromSlash(name)))
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", fmt.Errorf("cannot include %s: %v", name, err)
+	}
+	for _, root := range e.roots {
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("cannot include %s: it is outside the previewed directory", name)
+}
+
+// hasDirective reports whether source may contain a directive, so files
+// without one are returned as they are.
+func hasDirective(source []byte) bool {
+	return bytes.Contains(source, []byte("include"))
+}
+
+// parseLine returns the indentation, name and arguments of a directive line.
+func parseLine(line string) (indent, name, args string, ok bool) {
+	for _, re := range []*regexp.Regexp{commentDirective, shortcodeDirective} {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return m[1], m[2], m[3], true
+		}
+	}
+	return "", "", "", false
+}
+
+// ParseDirective parses the arguments of an include directive: a path,
+// optionally quoted, followed by lines=FROM-TO, shift=N, region=NAME and
+// lang=NAME options.
+func ParseDirective(args string) (Directive, error) {
+	var d Directive
+	fields, err := splitArgs(args)
+	if err != nil {
+		return d, err
+	}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || d.Path == "" && !isOption(key) {
+			if d.Path != "" {
+				return d, fmt.Errorf("unexpected include argument %q", field)
+			}
+			d.Path = field
+			continue
+		}
+		value = unquote(value)
+		switch key {
+		case "file", "path":
+			d.Path = value
+		case "lines":
+			if d.From, d.To, err = parseRange(value); err != nil {
+				return d, err
+			}
+		case "shift":
+			if d.Shift, err = strconv.Atoi(value); err != nil {
+				return d, fmt.Errorf("invalid heading shift %q", value)
+			}
+		case "region":
+			d.Region = value
+		case "lang":
+			d.Lang = value
+		default:
+			return d, fmt.Errorf("unknown include option %q", key)
+		}
+	}
+	if d.Path == "" {
+		return d, fmt.Errorf("include directive names no file")
+	}
+	return d, nil
+}
+
+// validate reports options that do not apply to the kind of directive.
+func (d Directive) validate() error {
+	if d.Code {
+		if d.Shift != 0 {
+			return fmt.Errorf("shift applies to markdown includes only")
+		}
+		if d.Region != "" && (d.From > 0 || d.To > 0) {
+			return fmt.Errorf("use either lines or region, not both")
+		}
+		return nil
+	}
+	if d.Region != "" || d.Lang != "" {
+		return fmt.Errorf("region and lang apply to include-code only")
+	}
+	return nil
+}
+
+func isOption(key string) bool {
+	switch key {
+	case "file", "path", "lines", "shift", "region", "lang":
+		return true
+	}
+	return false
+}
+
+// splitArgs splits directive arguments at spaces outside double quotes
+// and removes the quotes around a bare path.
+func splitArgs(args string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range args {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in include directive")
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	for i, f := range fields {
+		if !strings.Contains(f, "=") || strings.HasPrefix(f, `"`) {
+			fields[i] = unquote(f)
+		}
+	}
+	return fields, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseRange parses a line range: "5-10", "5-", "-10" or "7".
+func parseRange(s string) (from, to int, err error) {
+	invalid := fmt.Errorf("invalid line range %q", s)
+	start, end, isRange := strings.Cut(s, "-")
+	if start != "" {
+		if from, err = strconv.Atoi(start); err != nil || from < 1 {
+			return 0, 0, invalid
+		}
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	if end != "" {
+		if to, err = strconv.Atoi(end); err != nil || to < 1 {
+			return 0, 0, invalid
+		}
+	}
+	if from == 0 && to == 0 || to > 0 && to < from {
+		return 0, 0, invalid
+	}
+	return from, to, nil
+}
+
+// selectLines returns lines from through to of content, 1-based and
+// inclusive. Zero means the first or last line.
+func selectLines(content []byte, from, to int) ([]byte, error) {
+	lines := splitLines(content)
+	if from == 0 {
+		from = 1
+	}
+	if to == 0 || to > len(lines) {
+		to = len(lines)
+	}
+	if from > len(lines) {
+		return nil, fmt.Errorf("file has %d lines, range starts at %d", len(lines), from)
+	}
+	return bytes.Join(lines[from-1:to], nil), nil
+}
+
+// ShiftHeadings moves the ATX headings in markdown down by levels, or up if
+// levels is negative, keeping them between level 1 and 6. Headings inside
+// fenced code blocks are left alone.
+func ShiftHeadings(markdown []byte, levels int) []byte {
+	var out bytes.Buffer
+	var fence fenceState
+	for _, line := range splitLines(markdown) {
+		text := string(line)
+		if fence.update(strings.TrimRight(text, "\r\n")) {
+			out.Write(line)
+			continue
+		}
+		m := atxHeading.FindStringSubmatchIndex(text)
+		if m == nil {
+			out.Write(line)
+			continue
+		}
+		level := m[5] - m[4] + levels
+		if level < 1 {
+			level = 1
+		}
+		if level > 6 {
+			level = 6
+		}
+		out.WriteString(text[:m[4]])
+		out.WriteString(strings.Repeat("#", level))
+		out.WriteString(text[m[5]:])
+	}
+	return out.Bytes()
+}
+
+// stripFrontMatter removes a leading YAML front matter block.
+func stripFrontMatter(content []byte) []byte {
+	if !bytes.HasPrefix(content, []byte("---\n")) && !bytes.HasPrefix(content, []byte("---\r\n")) {
+		return content
+	}
+	lines := splitLines(content)
+	for i := 1; i < len(lines); i++ {
+		switch strings.TrimRight(string(lines[i]), "\r\n") {
+		case "---", "...":
+			return bytes.TrimLeft(bytes.Join(lines[i+1:], nil), "\r\n")
+		}
+	}
+	return content
+}
+
+// splitLines splits content after each newline, keeping the newlines.
+func splitLines(content []byte) [][]byte {
+	var lines [][]byte
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:i+1])
+		content = content[i+1:]
+	}
+	return lines
+}
+
+// fenceState tracks whether lines are inside a fenced code block.
+type fenceState struct {
+	char byte // '`' or '~' while inside a fence
+	size int
+}
+
+// update reports whether line is part of a fenced code block, including
+// its opening and closing fences.
+func (f *fenceState) update(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 && f.char == 0 {
+		return false
+	}
+	n := 0
+	for n < len(trimmed) && (trimmed[n] == '`' || trimmed[n] == '~') && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if f.char != 0 {
+		if n >= f.size && trimmed[0] == f.char && strings.TrimSpace(trimmed[n:]) == "" {
+			f.char = 0
+		}
+		return true
+	}
+	if n >= 3 && !(trimmed[0] == '`' && strings.Contains(trimmed[n:], "`")) {
+		f.char = trimmed[0]
+		f.size = n
+		return true
+	}
+	return false
+}
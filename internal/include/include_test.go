@@ -0,0 +1,195 @@
+package include
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mapReader reads files from a map keyed by slash-separated path.
+func mapReader(files map[string]string) ReadFunc {
+	return func(path string) ([]byte, error) {
+		content, ok := files[filepath.ToSlash(path)]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}
+}
+
+func TestExpand(t *testing.T) {
+	files := map[string]string{
+		"shared/install.md": "## Install\n\nRun `make`.\n",
+		"shared/license.md": "---\ntitle: License\n---\n\nMIT licensed.",
+		"shared/nested.md":  "Before\n<!-- include: install.md -->\nAfter\n",
+		"shared/lines.md":   "one\ntwo\nthree\nfour\n",
+		"shared/space d.md": "spaced\n",
+	}
+	read := mapReader(files)
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "comment",
+			source: "# Title\n\n<!-- include: shared/install.md -->\n\nEnd\n",
+			want:   "# Title\n\n## Install\n\nRun `make`.\n\nEnd\n",
+		},
+		{
+			name:   "shortcode",
+			source: "{{< include \"shared/install.md\" >}}\n",
+			want:   "## Install\n\nRun `make`.\n",
+		},
+		{
+			name:   "front matter and missing newline",
+			source: "<!-- include: shared/license.md -->\nNext\n",
+			want:   "MIT licensed.\nNext\n",
+		},
+		{
+			name:   "nested relative to included file",
+			source: "<!-- include: shared/nested.md -->\n",
+			want:   "Before\n## Install\n\nRun `make`.\nAfter\n",
+		},
+		{
+			name:   "shift",
+			source: "<!-- include: shared/install.md shift=2 -->\n",
+			want:   "#### Install\n\nRun `make`.\n",
+		},
+		{
+			name:   "line range",
+			source: "<!-- include: shared/lines.md lines=2-3 -->\n",
+			want:   "two\nthree\n",
+		},
+		{
+			name:   "open line range",
+			source: "{{< include shared/lines.md lines=\"3-\" >}}\n",
+			want:   "three\nfour\n",
+		},
+		{
+			name:   "single line",
+			source: "<!-- include: shared/lines.md lines=1 -->\n",
+			want:   "one\n",
+		},
+		{
+			name:   "quoted path",
+			source: "<!-- include: \"shared/space d.md\" -->\n",
+			want:   "spaced\n",
+		},
+		{
+			name:   "indented",
+			source: "- Item\n\n  <!-- include: shared/lines.md lines=1-2 -->\n",
+			want:   "- Item\n\n  one\n  two\n",
+		},
+		{
+			name:   "inside code fence",
+			source: "```markdown\n<!-- include: shared/install.md -->\n```\n",
+			want:   "```markdown\n<!-- include: shared/install.md -->\n```\n",
+		},
+		{
+			name:   "not alone on its line",
+			source: "Text <!-- include: shared/install.md -->\n",
+			want:   "Text <!-- include: shared/install.md -->\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Expand([]byte(tt.source), "doc.md", read)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand_Dependencies(t *testing.T) {
+	read := mapReader(map[string]string{
+		"docs/a.md":      "<!-- include: ../shared/b.md -->\n<!-- include: ../shared/b.md -->\n",
+		"shared/b.md":    "<!-- include: c.md -->\n",
+		"shared/c.md":    "c\n",
+		"docs/readme.md": "",
+	})
+
+	_, deps, err := Expand([]byte("<!-- include: a.md -->\n"), "docs/readme.md", read)
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	want := []string{filepath.FromSlash("docs/a.md"), filepath.FromSlash("shared/b.md"), filepath.FromSlash("shared/c.md")}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("deps = %v, want %v", deps, want)
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	read := mapReader(map[string]string{
+		"a.md":     "<!-- include: b.md -->\n",
+		"b.md":     "text\n<!-- include: a.md -->\n",
+		"short.md": "one\n",
+	})
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"cycle", "<!-- include: a.md -->\n", "include cycle: a.md -> b.md -> a.md"},
+		{"self", "<!-- include: doc.md -->\n", "include cycle: doc.md -> doc.md"},
+		{"missing", "\n<!-- include: nope.md -->\n", "doc.md:2: cannot include nope.md"},
+		{"range past end", "<!-- include: short.md lines=5-6 -->\n", "file has 1 lines, range starts at 5"},
+		{"bad range", "<!-- include: short.md lines=3-1 -->\n", `invalid line range "3-1"`},
+		{"bad shift", "<!-- include: short.md shift=x -->\n", `invalid heading shift "x"`},
+		{"unknown option", "<!-- include: short.md indent=2 -->\n", `unknown include option "indent"`},
+		{"no file", "{{< include lines=1-2 >}}\n", "include directive names no file"},
+		{"absolute path", "<!-- include: /tmp/secret.txt -->\n", "cannot include /tmp/secret.txt: the path must be relative"},
+		{"outside root", "<!-- include: ../../../../../tmp/secret.txt -->\n", "cannot include ../../../../../tmp/secret.txt: it is outside the previewed directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Expand([]byte(tt.source), "doc.md", read)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expand() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand_WithRoot(t *testing.T) {
+	root := filepath.Dir(t.TempDir())
+	path := filepath.Join(root, "docs", "readme.md")
+	shared := filepath.Join(root, "shared.md")
+	read := func(name string) ([]byte, error) {
+		if name != shared {
+			return nil, os.ErrNotExist
+		}
+		return []byte("shared\n"), nil
+	}
+
+	source := []byte("<!-- include: ../shared.md -->\n")
+	if _, _, err := Expand(source, path, read); err == nil {
+		t.Error("expected an error for a file outside the working directory")
+	}
+	got, _, err := Expand(source, path, read, WithRoot(root))
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if string(got) != "shared\n" {
+		t.Errorf("Expand() = %q, want %q", got, "shared\n")
+	}
+}
+
+func TestShiftHeadings(t *testing.T) {
+	input := "# One\n## Two\n###### Six\n#NoSpace\n```\n# code\n```\n    # indented code\n"
+	want := "## One\n### Two\n###### Six\n#NoSpace\n```\n# code\n```\n    # indented code\n"
+	if got := string(ShiftHeadings([]byte(input), 1)); got != want {
+		t.Errorf("ShiftHeadings(1) = %q, want %q", got, want)
+	}
+	if got := string(ShiftHeadings([]byte("## Two\n# One\n"), -1)); got != "# Two\n# One\n" {
+		t.Errorf("ShiftHeadings(-1) = %q", got)
+	}
+}
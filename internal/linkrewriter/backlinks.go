@@ -0,0 +1,163 @@
+package linkrewriter
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mdp/internal/filetree"
+)
+
+// maxExcerptLength is the number of characters an excerpt is cut to.
+const maxExcerptLength = 240
+
+var (
+	hrefPattern = regexp.MustCompile(`<a\s+[^>]*href="([^"]+)"[^>]*>`)
+	// Blocks whose text makes up the context of a link
+	blockStartPattern = regexp.MustCompile(`<(?:p|li|td|th|dd|dt|h[1-6]|blockquote|figcaption|div)[\s>]`)
+	blockEndPattern   = regexp.MustCompile(`</(?:p|li|td|th|dd|dt|h[1-6]|blockquote|figcaption|div)>|<(?:p|ul|ol|dl|table|pre|div|blockquote)[\s>]`)
+	tagPattern        = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Backlinks finds the links between entries and returns, for each section
+// ID, the other entries that link to it in the order they are given. It
+// must be called before the links in entries are rewritten.
+func (lr *LinkRewriter) Backlinks(entries []filetree.FileEntry) map[string][]filetree.Backlink {
+	backlinks := make(map[string][]filetree.Backlink)
+	for _, entry := range entries {
+		sourceDir := ""
+		if i := strings.LastIndex(entry.RelPath, "/"); i >= 0 {
+			sourceDir = entry.RelPath[:i]
+		}
+
+		starts := blockStartPattern.FindAllStringIndex(entry.Content, -1)
+		seen := make(map[string]int) // target ID -> index in backlinks[target]
+		for _, m := range hrefPattern.FindAllStringSubmatchIndex(entry.Content, -1) {
+			targetID, _, ok := lr.resolve(entry.Content[m[2]:m[3]], sourceDir)
+			if !ok || targetID == entry.ID {
+				continue
+			}
+
+			i, ok := seen[targetID]
+			if !ok {
+				i = len(backlinks[targetID])
+				seen[targetID] = i
+				backlinks[targetID] = append(backlinks[targetID], filetree.Backlink{
+					ID:      entry.ID,
+					Name:    entry.Name,
+					RelPath: entry.RelPath,
+				})
+			}
+			excerpt := linkExcerpt(entry.Content, m[0], starts)
+			b := &backlinks[targetID][i]
+			if excerpt != "" && !containsString(b.Excerpts, excerpt) {
+				b.Excerpts = append(b.Excerpts, excerpt)
+			}
+		}
+	}
+	return backlinks
+}
+
+// linkExcerpt returns the sentence around the link starting at offset in
+// content. starts holds the positions of the block start tags in content.
+func linkExcerpt(content string, offset int, starts [][]int) string {
+	// The block the link is in starts at the last block start before it
+	n := sort.Search(len(starts), func(i int) bool { return starts[i][0] >= offset })
+	start := 0
+	if n > 0 {
+		start = starts[n-1][0]
+	}
+	end := len(content)
+	if loc := blockEndPattern.FindStringIndex(content[offset:]); loc != nil {
+		end = offset + loc[0]
+	}
+
+	return Excerpt(plainText(content[start:end]), plainText(content[start:offset]))
+}
+
+// Excerpt returns the sentence of text where before, the start of text up
+// to a link, ends. It is cut short like the excerpts of Backlinks.
+func Excerpt(text, before string) string {
+	text = strings.TrimSpace(collapseSpace(text))
+	linkAt := len(collapseSpace(before))
+	if linkAt > len(text) {
+		linkAt = len(text)
+	}
+	return truncate(sentenceAt(text, linkAt))
+}
+
+// sentenceAt returns the sentence of text that contains offset.
+func sentenceAt(text string, offset int) string {
+	start := 0
+	for i := 0; i < offset; i++ {
+		if isSentenceEnd(text, i) {
+			start = i + 1
+		}
+	}
+	end := len(text)
+	for i := offset; i < len(text); i++ {
+		if isSentenceEnd(text, i) {
+			end = i + 1
+			break
+		}
+	}
+	return strings.TrimSpace(text[start:end])
+}
+
+// isSentenceEnd reports whether text[i] ends a sentence: a full stop,
+// question or exclamation mark followed by a space.
+func isSentenceEnd(text string, i int) bool {
+	switch text[i] {
+	case '.', '!', '?':
+		return i+1 < len(text) && text[i+1] == ' '
+	}
+	return false
+}
+
+// plainText strips the tags from an HTML fragment and unescapes it.
+func plainText(fragment string) string {
+	return html.UnescapeString(tagPattern.ReplaceAllString(fragment, ""))
+}
+
+// collapseSpace replaces each run of white space in s with a single space
+// and drops leading white space.
+func collapseSpace(s string) string {
+	var buf strings.Builder
+	space := true
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				buf.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		buf.WriteRune(r)
+		space = false
+	}
+	return buf.String()
+}
+
+// truncate cuts s to maxExcerptLength characters at a word boundary.
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= maxExcerptLength {
+		return s
+	}
+	cut := s[:len(string([]rune(s)[:maxExcerptLength]))]
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:") + "…"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package linkrewriter
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+)
+
+var (
+	// headingIDPattern matches the id attribute of a heading tag.
+	headingIDPattern = regexp.MustCompile(`(<h[1-6]\b[^>]*?\sid=")([^"]*)(")`)
+	// fragmentLinkPattern matches a link to a fragment in the same page.
+	fragmentLinkPattern = regexp.MustCompile(`(<a\s+[^>]*?href="#)([^"]+)(")`)
+)
+
+// ScopeHeadingIDs prefixes the heading IDs in content with sectionID, the ID
+// of the file the HTML was rendered from, so that headings such as "usage"
+// stay unique when several files are inlined into one page. Fragment-only
+// links to those headings are updated to match; links to other fragments
+// are left unchanged.
+func ScopeHeadingIDs(content string, sectionID string) string {
+	prefix := html.EscapeString(sectionID) + "-"
+	ids := make(map[string]bool)
+	content = headingIDPattern.ReplaceAllStringFunc(content, func(match string) string {
+		parts := headingIDPattern.FindStringSubmatch(match)
+		if parts[2] == "" {
+			return match
+		}
+		ids[html.UnescapeString(parts[2])] = true
+		return parts[1] + prefix + parts[2] + parts[3]
+	})
+	if len(ids) == 0 {
+		return content
+	}
+
+	return fragmentLinkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		parts := fragmentLinkPattern.FindStringSubmatch(match)
+		fragment := html.UnescapeString(parts[2])
+		if decoded, err := url.PathUnescape(fragment); err == nil {
+			fragment = decoded
+		}
+		if !ids[fragment] {
+			return match
+		}
+		return parts[1] + prefix + parts[2] + parts[3]
+	})
+}
@@ -29,7 +29,22 @@ func New(entries []filetree.FileEntry) *LinkRewriter {
 
 // RewriteLinks rewrites relative .md links in HTML content to fragment identifiers.
 // sourceRelPath is the relative path of the source file (used to resolve relative links).
+// A link to a heading in another file, such as guide.md#usage, points to the
+// heading's ID as scoped by ScopeHeadingIDs.
 func (lr *LinkRewriter) RewriteLinks(html string, sourceRelPath string) string {
+	return lr.RewriteLinksFunc(html, sourceRelPath, func(id, fragment string) string {
+		if fragment != "" {
+			return "#" + id + "-" + url.PathEscape(fragment)
+		}
+		return "#" + id
+	})
+}
+
+// RewriteLinksFunc rewrites relative .md links in HTML content using target
+// to build the new href. target receives the section ID of the linked file
+// and the fragment of the original link (without the leading '#'), if any.
+// Links that do not resolve to a known file are left unchanged.
+func (lr *LinkRewriter) RewriteLinksFunc(html string, sourceRelPath string, target func(id, fragment string) string) string {
 	// Match <a href="..."> patterns
 	// This regex captures the href value including any surrounding quotes
 	re := regexp.MustCompile(`(<a\s+[^>]*href=")([^"]+)("[^>]*>)`)
@@ -45,30 +60,45 @@ func (lr *LinkRewriter) RewriteLinks(html string, sourceRelPath string) string {
 			return match
 		}
 
-		prefix := parts[1]  // <a href="
-		href := parts[2]    // the link
-		suffix := parts[3]  // ">
+		prefix := parts[1] // <a href="
+		href := parts[2]   // the link
+		suffix := parts[3] // ">
 
-		rewritten := lr.rewriteHref(href, sourceDir)
-		return prefix + rewritten + suffix
+		sectionID, fragment, ok := lr.resolve(href, sourceDir)
+		if !ok {
+			return match
+		}
+		return prefix + target(sectionID, fragment) + suffix
 	})
 }
 
-// rewriteHref rewrites a single href value if it's a relative .md link.
-func (lr *LinkRewriter) rewriteHref(href string, sourceDir string) string {
+// Resolve looks up the file a link in sourceRelPath points to. It returns
+// the section ID of the linked file and the link's fragment identifier,
+// or ok == false if href is not a relative link to a known .md file.
+func (lr *LinkRewriter) Resolve(href string, sourceRelPath string) (id string, fragment string, ok bool) {
+	sourceDir := path.Dir(sourceRelPath)
+	if sourceDir == "." {
+		sourceDir = ""
+	}
+	return lr.resolve(href, sourceDir)
+}
+
+// resolve looks up the section ID a relative .md link points to.
+// It also returns the link's fragment identifier, if any.
+func (lr *LinkRewriter) resolve(href string, sourceDir string) (string, string, bool) {
 	// Skip external links
 	if strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") {
-		return href
+		return "", "", false
 	}
 
 	// Skip fragment-only links
 	if strings.HasPrefix(href, "#") {
-		return href
+		return "", "", false
 	}
 
 	// Skip mailto and other protocols
 	if strings.Contains(href, ":") {
-		return href
+		return "", "", false
 	}
 
 	// URL decode the href for proper path resolution
@@ -78,11 +108,11 @@ func (lr *LinkRewriter) rewriteHref(href string, sourceDir string) string {
 	}
 
 	// Strip any fragment identifier from the link
-	linkPath, _, _ := strings.Cut(decodedHref, "#")
+	linkPath, fragment, _ := strings.Cut(decodedHref, "#")
 
-	// Only process .md links
-	if !strings.HasSuffix(strings.ToLower(linkPath), ".md") {
-		return href
+	// Only process links to .md files and notebooks
+	if ext := strings.ToLower(path.Ext(linkPath)); ext != ".md" && ext != ".ipynb" {
+		return "", "", false
 	}
 
 	// Resolve the relative path from the source file's directory
@@ -99,13 +129,9 @@ func (lr *LinkRewriter) rewriteHref(href string, sourceDir string) string {
 	// Normalize for lookup
 	normalized := normalizePath(resolvedPath)
 
-	// Look up the section ID
-	if sectionID, ok := lr.pathToID[normalized]; ok {
-		return "#" + sectionID
-	}
-
-	// Not found in our file set, leave unchanged
-	return href
+	// Look up the section ID; links outside our file set are left unchanged
+	sectionID, ok := lr.pathToID[normalized]
+	return sectionID, fragment, ok
 }
 
 // normalizePath normalizes a path for consistent lookups.
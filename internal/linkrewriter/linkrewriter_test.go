@@ -1,6 +1,7 @@
 package linkrewriter
 
 import (
+	"strings"
 	"testing"
 
 	"mdp/internal/filetree"
@@ -95,10 +96,10 @@ func TestRewriteLinks(t *testing.T) {
 			expected:      `<a href="./missing.md">Missing</a>`,
 		},
 		{
-			name:          "link with anchor stripped",
+			name:          "link with anchor to scoped heading",
 			html:          `<a href="./docs/guide.md#installation">Guide Install</a>`,
 			sourceRelPath: "README.md",
-			expected:      `<a href="#docs-guide-md">Guide Install</a>`,
+			expected:      `<a href="#docs-guide-md-installation">Guide Install</a>`,
 		},
 		{
 			name:          "non-md link unchanged",
@@ -170,36 +171,182 @@ func TestNormalizePath(t *testing.T) {
 	}
 }
 
-func TestRewriteHref(t *testing.T) {
+func TestRewriteLinksFunc(t *testing.T) {
 	entries := []filetree.FileEntry{
 		{ID: "readme-md", RelPath: "README.md"},
 		{ID: "docs-guide-md", RelPath: "docs/guide.md"},
 	}
 
 	lr := New(entries)
+	target := func(id, fragment string) string {
+		if fragment != "" {
+			return id + ".xhtml#" + fragment
+		}
+		return id + ".xhtml"
+	}
 
 	tests := []struct {
-		name      string
-		href      string
-		sourceDir string
-		expected  string
+		name     string
+		input    string
+		expected string
 	}{
-		{"external http", "http://example.com", "", "http://example.com"},
-		{"external https", "https://example.com", "", "https://example.com"},
-		{"fragment only", "#section", "", "#section"},
-		{"mailto", "mailto:test@example.com", "", "mailto:test@example.com"},
-		{"non-md file", "image.png", "", "image.png"},
-		{"valid md link", "README.md", "", "#readme-md"},
-		{"valid nested md link", "docs/guide.md", "", "#docs-guide-md"},
-		{"parent relative", "../README.md", "docs", "#readme-md"},
-		{"not found", "missing.md", "", "missing.md"},
+		{
+			name:     "link without fragment",
+			input:    `<a href="../README.md">Home</a>`,
+			expected: `<a href="readme-md.xhtml">Home</a>`,
+		},
+		{
+			name:     "link with fragment",
+			input:    `<a href="guide.md#usage">Usage</a>`,
+			expected: `<a href="docs-guide-md.xhtml#usage">Usage</a>`,
+		},
+		{
+			name:     "unknown file unchanged",
+			input:    `<a href="missing.md">Missing</a>`,
+			expected: `<a href="missing.md">Missing</a>`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := lr.rewriteHref(tt.href, tt.sourceDir)
+			result := lr.RewriteLinksFunc(tt.input, "docs/guide.md", target)
 			if result != tt.expected {
-				t.Errorf("rewriteHref(%q, %q) = %q, want %q", tt.href, tt.sourceDir, result, tt.expected)
+				t.Errorf("RewriteLinksFunc() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	entries := []filetree.FileEntry{
+		{ID: "readme-md", RelPath: "README.md"},
+		{ID: "docs-guide-md", RelPath: "docs/guide.md"},
+		{ID: "docs-analysis-ipynb", RelPath: "docs/analysis.ipynb"},
+	}
+	lr := New(entries)
+
+	tests := []struct {
+		href         string
+		source       string
+		wantID       string
+		wantFragment string
+		wantOK       bool
+	}{
+		{"docs/guide.md#usage", "README.md", "docs-guide-md", "usage", true},
+		{"../README.md", "docs/guide.md", "readme-md", "", true},
+		{"analysis.ipynb#results", "docs/guide.md", "docs-analysis-ipynb", "results", true},
+		{"missing.md", "README.md", "", "", false},
+		{"https://example.com/a.md", "README.md", "", "", false},
+		{"#local", "README.md", "", "", false},
+	}
+
+	for _, tt := range tests {
+		id, fragment, ok := lr.Resolve(tt.href, tt.source)
+		if id != tt.wantID || fragment != tt.wantFragment || ok != tt.wantOK {
+			t.Errorf("Resolve(%q, %q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.href, tt.source, id, fragment, ok, tt.wantID, tt.wantFragment, tt.wantOK)
+		}
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	entries := []filetree.FileEntry{
+		{ID: "readme-md", Name: "README", RelPath: "README.md", Content: `<h1 id="intro">Intro</h1>
+<p>Start here. Read the <a href="docs/guide.md">guide</a> first.
+Then stop.</p>
+<ul>
+<li>See <a href="docs/guide.md#usage">usage</a> &amp; more</li>
+<li><a href="README.md">Self</a> and <a href="https://example.com/guide.md">web</a></li>
+</ul>`},
+		{ID: "docs-guide-md", Name: "guide", RelPath: "docs/guide.md", Content: `<p>Back to <a href="../README.md">the start</a>. Read the <a href="../README.md">readme</a> twice.</p>`},
+		{ID: "docs-api-md", Name: "api", RelPath: "docs/api.md", Content: `<p>See the <a href="guide.md">guide</a>.</p>`},
+	}
+
+	backlinks := New(entries).Backlinks(entries)
+
+	guide := backlinks["docs-guide-md"]
+	if len(guide) != 2 {
+		t.Fatalf("expected 2 files linking to the guide, got %+v", guide)
+	}
+	if guide[0].ID != "readme-md" || guide[0].Name != "README" || guide[0].RelPath != "README.md" {
+		t.Errorf("expected README first, got %+v", guide[0])
+	}
+	wantExcerpts := []string{"Read the guide first.", "See usage & more"}
+	if len(guide[0].Excerpts) != len(wantExcerpts) {
+		t.Fatalf("Excerpts = %q, want %q", guide[0].Excerpts, wantExcerpts)
+	}
+	for i, want := range wantExcerpts {
+		if guide[0].Excerpts[i] != want {
+			t.Errorf("Excerpts[%d] = %q, want %q", i, guide[0].Excerpts[i], want)
+		}
+	}
+	if guide[1].ID != "docs-api-md" || len(guide[1].Excerpts) != 1 || guide[1].Excerpts[0] != "See the guide." {
+		t.Errorf("unexpected backlink from api: %+v", guide[1])
+	}
+
+	readme := backlinks["readme-md"]
+	if len(readme) != 1 || readme[0].ID != "docs-guide-md" {
+		t.Fatalf("expected only the guide to link to README (not itself), got %+v", readme)
+	}
+	if len(readme[0].Excerpts) != 2 || readme[0].Excerpts[0] != "Back to the start." || readme[0].Excerpts[1] != "Read the readme twice." {
+		t.Errorf("unexpected excerpts: %q", readme[0].Excerpts)
+	}
+
+	if got := backlinks["docs-api-md"]; len(got) != 0 {
+		t.Errorf("expected no backlinks to api, got %+v", got)
+	}
+}
+
+func TestBacklinks_LongExcerpt(t *testing.T) {
+	long := strings.Repeat("word ", 80)
+	entries := []filetree.FileEntry{
+		{ID: "a-md", RelPath: "a.md", Content: `<p>` + long + `<a href="b.md">b</a></p>`},
+		{ID: "b-md", RelPath: "b.md"},
+	}
+
+	excerpt := New(entries).Backlinks(entries)["b-md"][0].Excerpts[0]
+	if !strings.HasSuffix(excerpt, "word…") || len([]rune(excerpt)) > maxExcerptLength+1 {
+		t.Errorf("expected excerpt to be cut at a word, got %q", excerpt)
+	}
+}
+
+func TestScopeHeadingIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "heading IDs prefixed",
+			html:     `<h1 id="guide">Guide</h1><h2 id="usage" class="wide">Usage</h2>`,
+			expected: `<h1 id="docs-guide-md-guide">Guide</h1><h2 id="docs-guide-md-usage" class="wide">Usage</h2>`,
+		},
+		{
+			name:     "in-file anchor rewritten",
+			html:     `<h2 id="usage">Usage</h2><p><a href="#usage">see usage</a></p>`,
+			expected: `<h2 id="docs-guide-md-usage">Usage</h2><p><a href="#docs-guide-md-usage">see usage</a></p>`,
+		},
+		{
+			name:     "escaped anchor rewritten",
+			html:     `<h2 id="café">Café</h2><a href="#caf%C3%A9">café</a>`,
+			expected: `<h2 id="docs-guide-md-café">Café</h2><a href="#docs-guide-md-caf%C3%A9">café</a>`,
+		},
+		{
+			name:     "other anchors unchanged",
+			html:     `<h2 id="usage">Usage</h2><a href="#docs-guide-md-fn:1">1</a><a href="#missing">x</a><div id="usage-note"></div>`,
+			expected: `<h2 id="docs-guide-md-usage">Usage</h2><a href="#docs-guide-md-fn:1">1</a><a href="#missing">x</a><div id="usage-note"></div>`,
+		},
+		{
+			name:     "links to other files unchanged",
+			html:     `<h2 id="usage">Usage</h2><a href="#readme-md">README</a><a href="other.md#usage">x</a>`,
+			expected: `<h2 id="docs-guide-md-usage">Usage</h2><a href="#readme-md">README</a><a href="other.md#usage">x</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeHeadingIDs(tt.html, "docs-guide-md"); got != tt.expected {
+				t.Errorf("ScopeHeadingIDs() = %q, want %q", got, tt.expected)
 			}
 		})
 	}
@@ -0,0 +1,447 @@
+// Package lint checks markdown for markdownlint-style problems, using the
+// same parser configuration as the converter.
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+
+	"mdp/internal/converter"
+)
+
+// Problem is a single lint finding.
+type Problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`   // 1-based
+	Column  int    `json:"column"` // 1-based, in characters
+	Rule    string `json:"rule"`   // Rule ID, e.g. "MD001"
+	Name    string `json:"name"`   // Rule name, e.g. "heading-increment"
+	Message string `json:"message"`
+}
+
+// String formats the problem as file:line:col, as compilers do.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s/%s %s", p.File, p.Line, p.Column, p.Rule, p.Name, p.Message)
+}
+
+// Rule is a built-in lint rule. IDs and names follow markdownlint.
+type Rule struct {
+	ID          string
+	Name        string
+	Description string
+	check       func(d *document) []finding
+}
+
+// Rules lists the built-in rules in ID order.
+var Rules = []Rule{
+	{"MD001", "heading-increment", "Heading levels should only increment by one level at a time", checkHeadingIncrement},
+	{"MD009", "no-trailing-spaces", "Lines should not end with spaces, except two for a line break", checkTrailingSpaces},
+	{"MD013", "line-length", "Lines should not be longer than the configured length", checkLineLength},
+	{"MD024", "no-duplicate-heading", "Headings should have unique IDs so links reach them", checkDuplicateHeadings},
+	{"MD034", "no-bare-urls", "URLs should be wrapped in angle brackets or written as links", checkBareURLs},
+	{"MD040", "fenced-code-language", "Fenced code blocks should have a language", checkCodeLanguage},
+	{"MD045", "no-alt-text", "Images should have alternate text", checkAltText},
+}
+
+// LookupRule returns the rule with the given ID or name, ignoring case.
+func LookupRule(name string) (Rule, bool) {
+	for _, rule := range Rules {
+		if strings.EqualFold(rule.ID, name) || strings.EqualFold(rule.Name, name) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// DefaultLineLength is the line length allowed by MD013 unless configured.
+const DefaultLineLength = 80
+
+// Linter checks markdown files.
+type Linter struct {
+	conv       *converter.Converter
+	disabled   map[string]bool
+	lineLength int
+}
+
+// Option configures optional Linter behavior.
+type Option func(*Linter)
+
+// WithDisabled turns off rules, given by ID or name. Unknown rules are
+// ignored; use LookupRule to validate them first.
+func WithDisabled(rules ...string) Option {
+	return func(l *Linter) {
+		for _, name := range rules {
+			if rule, ok := LookupRule(name); ok {
+				l.disabled[rule.ID] = true
+			}
+		}
+	}
+}
+
+// WithLineLength sets the longest line MD013 allows. Zero keeps the default.
+func WithLineLength(n int) Option {
+	return func(l *Linter) {
+		if n > 0 {
+			l.lineLength = n
+		}
+	}
+}
+
+// New creates a Linter that parses markdown with conv.
+func New(conv *converter.Converter, opts ...Option) *Linter {
+	l := &Linter{
+		conv:       conv,
+		disabled:   make(map[string]bool),
+		lineLength: DefaultLineLength,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Lint returns the problems in source, sorted by position. file is only
+// used to label them.
+func (l *Linter) Lint(file string, source []byte) []Problem {
+	d := newDocument(l.conv.Parse(source), source, l.lineLength)
+
+	var problems []Problem
+	for _, rule := range Rules {
+		if l.disabled[rule.ID] {
+			continue
+		}
+		for _, f := range rule.check(d) {
+			line, col := d.position(f.offset)
+			problems = append(problems, Problem{
+				File:    file,
+				Line:    line,
+				Column:  col,
+				Rule:    rule.ID,
+				Name:    rule.Name,
+				Message: f.message,
+			})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// finding is a problem found by a rule, at a byte offset in the source.
+type finding struct {
+	offset  int
+	message string
+}
+
+// document is a parsed file with helpers shared by the rules.
+type document struct {
+	root       ast.Node
+	source     []byte
+	lines      [][]byte // Lines without their line endings
+	lineStarts []int
+	skipLines  map[int]bool // 0-based lines in code blocks and tables
+	lineLength int
+}
+
+func newDocument(root ast.Node, source []byte, lineLength int) *document {
+	d := &document{root: root, source: source, skipLines: make(map[int]bool), lineLength: lineLength}
+	start := 0
+	for start <= len(source) {
+		end := bytes.IndexByte(source[start:], '\n')
+		if end == -1 {
+			end = len(source) - start
+		}
+		d.lineStarts = append(d.lineStarts, start)
+		d.lines = append(d.lines, bytes.TrimSuffix(source[start:start+end], []byte("\r")))
+		start += end + 1
+	}
+
+	// Code and tables can't be wrapped or trimmed freely
+	d.walk(func(n ast.Node) {
+		switch n.Kind() {
+		case ast.KindFencedCodeBlock, ast.KindCodeBlock, east.KindTable:
+		default:
+			return
+		}
+		first, _ := d.line(blockStart(d, n))
+		last := first
+		if stop := lastStop(n); stop > 0 {
+			last, _ = d.line(stop - 1)
+		}
+		if n.Kind() == ast.KindFencedCodeBlock {
+			last++ // Closing fence
+		}
+		for i := first - 1; i < last && i < len(d.lines); i++ {
+			d.skipLines[i] = true
+		}
+	})
+	return d
+}
+
+// walk calls fn for every node in document order.
+func (d *document) walk(fn func(n ast.Node)) {
+	_ = ast.Walk(d.root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			fn(n)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// line returns the 1-based line number and its start offset for offset.
+func (d *document) line(offset int) (int, int) {
+	i := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return i + 1, d.lineStarts[i]
+}
+
+// position returns the 1-based line and column of offset.
+func (d *document) position(offset int) (int, int) {
+	line, start := d.line(offset)
+	if offset > len(d.source) {
+		offset = len(d.source)
+	}
+	return line, utf8.RuneCount(d.source[start:offset]) + 1
+}
+
+// lastStop returns the end offset of the last source segment in n, or -1
+// if n has none.
+func lastStop(n ast.Node) int {
+	if n.Type() == ast.TypeBlock {
+		if lines := n.Lines(); lines.Len() > 0 {
+			stop := lines.At(lines.Len() - 1).Stop
+			for c := n.LastChild(); c != nil; c = c.PreviousSibling() {
+				if s := lastStop(c); s > stop {
+					stop = s
+				}
+			}
+			return stop
+		}
+	}
+	if t, ok := n.(*ast.Text); ok {
+		return t.Segment.Stop
+	}
+	for c := n.LastChild(); c != nil; c = c.PreviousSibling() {
+		if stop := lastStop(c); stop >= 0 {
+			return stop
+		}
+	}
+	return -1
+}
+
+// earliest returns an offset at or before the start of n in the source:
+// the end of whatever precedes it, or the start of its enclosing block.
+func earliest(n ast.Node) int {
+	for sib := n.PreviousSibling(); sib != nil; sib = sib.PreviousSibling() {
+		if stop := lastStop(sib); stop >= 0 {
+			return stop
+		}
+	}
+	parent := n.Parent()
+	if parent == nil {
+		return 0
+	}
+	if parent.Type() == ast.TypeBlock && parent.Lines().Len() > 0 {
+		return parent.Lines().At(0).Start
+	}
+	return earliest(parent)
+}
+
+// find returns the offset of the first occurrence of pattern at or after
+// from, or from if there is none.
+func (d *document) find(from int, pattern string) int {
+	if i := bytes.Index(d.source[from:], []byte(pattern)); i >= 0 {
+		return from + i
+	}
+	return from
+}
+
+// blockStart returns the offset of the first line of block n, including
+// markers such as heading hashes and code fences.
+func blockStart(d *document, n ast.Node) int {
+	offset := -1
+	if n.Kind() == ast.KindFencedCodeBlock {
+		if fenced := n.(*ast.FencedCodeBlock); fenced.Info != nil {
+			offset = fenced.Info.Segment.Start
+		} else if fenced.Lines().Len() > 0 {
+			// The opening fence is the line before the code
+			line, _ := d.line(fenced.Lines().At(0).Start)
+			return d.lineStarts[line-2]
+		}
+	} else if n.Lines().Len() > 0 {
+		offset = n.Lines().At(0).Start
+	} else if first := n.FirstChild(); first != nil {
+		offset = earliest(first)
+		if offset == earliest(n) {
+			offset = -1
+		}
+	}
+
+	if offset < 0 {
+		// Nothing inside to go by, so take the next non-blank line after
+		// whatever precedes the block
+		line, _ := d.line(earliest(n))
+		if n.PreviousSibling() != nil {
+			line, _ = d.line(earliest(n) - 1)
+			line++
+			if n.PreviousSibling().Kind() == ast.KindFencedCodeBlock {
+				line++ // Skip its closing fence
+			}
+		}
+		for line < len(d.lines) && len(bytes.TrimSpace(d.lines[line-1])) == 0 {
+			line++
+		}
+		return d.lineStarts[line-1]
+	}
+	_, start := d.line(offset)
+	return start
+}
+
+// headingText returns the plain text of a heading.
+func headingText(n ast.Node, source []byte) []byte {
+	var buf bytes.Buffer
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+		case *ast.String:
+			buf.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.Bytes()
+}
+
+func checkHeadingIncrement(d *document) []finding {
+	var findings []finding
+	prev := 0
+	d.walk(func(n ast.Node) {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			return
+		}
+		if prev > 0 && h.Level > prev+1 {
+			findings = append(findings, finding{blockStart(d, h),
+				fmt.Sprintf("Heading level skipped: expected h%d, got h%d", prev+1, h.Level)})
+		}
+		prev = h.Level
+	})
+	return findings
+}
+
+func checkTrailingSpaces(d *document) []finding {
+	var findings []finding
+	for i, line := range d.lines {
+		if d.skipLines[i] {
+			continue
+		}
+		trimmed := bytes.TrimRight(line, " \t")
+		trailing := line[len(trimmed):]
+		// Two spaces after text are a hard line break
+		if len(trailing) == 0 || len(trimmed) > 0 && string(trailing) == "  " {
+			continue
+		}
+		findings = append(findings, finding{d.lineStarts[i] + len(trimmed), "Trailing whitespace"})
+	}
+	return findings
+}
+
+func checkLineLength(d *document) []finding {
+	var findings []finding
+	for i, line := range d.lines {
+		if d.skipLines[i] || utf8.RuneCount(line) <= d.lineLength {
+			continue
+		}
+		// Allow long lines that can't be wrapped, such as URLs
+		cut := 0
+		for n := 0; n < d.lineLength; n++ {
+			_, size := utf8.DecodeRune(line[cut:])
+			cut += size
+		}
+		if !bytes.ContainsAny(line[cut:], " \t") {
+			continue
+		}
+		findings = append(findings, finding{d.lineStarts[i] + cut,
+			fmt.Sprintf("Line is %d characters long (maximum %d)", utf8.RuneCount(line), d.lineLength)})
+	}
+	return findings
+}
+
+func checkDuplicateHeadings(d *document) []finding {
+	var findings []finding
+	firstLine := make(map[string]int)
+	d.walk(func(n ast.Node) {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			return
+		}
+		// A fresh context gives the ID the heading would get on its own;
+		// the converter adds a suffix to later duplicates
+		id := string(parser.NewContext().IDs().Generate(headingText(h, d.source), ast.KindHeading))
+		offset := blockStart(d, h)
+		line, _ := d.line(offset)
+		if first, ok := firstLine[id]; ok {
+			findings = append(findings, finding{offset,
+				fmt.Sprintf("Duplicate heading ID %q (first used on line %d)", id, first)})
+			return
+		}
+		firstLine[id] = line
+	})
+	return findings
+}
+
+func checkBareURLs(d *document) []finding {
+	var findings []finding
+	d.walk(func(n ast.Node) {
+		link, ok := n.(*ast.AutoLink)
+		if !ok {
+			return
+		}
+		label := string(link.Label(d.source))
+		offset := d.find(earliest(link), label)
+		if offset > 0 && d.source[offset-1] == '<' {
+			return
+		}
+		findings = append(findings, finding{offset, fmt.Sprintf("Bare URL: %s", label)})
+	})
+	return findings
+}
+
+func checkCodeLanguage(d *document) []finding {
+	var findings []finding
+	d.walk(func(n ast.Node) {
+		if fenced, ok := n.(*ast.FencedCodeBlock); ok && fenced.Info == nil {
+			findings = append(findings, finding{blockStart(d, fenced), "Fenced code block has no language"})
+		}
+	})
+	return findings
+}
+
+func checkAltText(d *document) []finding {
+	var findings []finding
+	d.walk(func(n ast.Node) {
+		img, ok := n.(*ast.Image)
+		if !ok || len(bytes.TrimSpace(headingText(img, d.source))) > 0 {
+			return
+		}
+		findings = append(findings, finding{d.find(earliest(img), "!["),
+			fmt.Sprintf("Image has no alt text: %s", img.Destination)})
+	})
+	return findings
+}
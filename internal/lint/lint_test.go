@@ -0,0 +1,192 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"mdp/internal/converter"
+)
+
+func TestLint_Rules(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []Problem
+	}{
+		{
+			name:     "heading increment",
+			markdown: "# Title\n\n### Skipped\n\n## Back\n\n### Fine\n",
+			want:     []Problem{{Line: 3, Column: 1, Rule: "MD001"}},
+		},
+		{
+			name:     "trailing spaces",
+			markdown: "one \ntwo  \nthree\n\n   \n",
+			want:     []Problem{{Line: 1, Column: 4, Rule: "MD009"}, {Line: 5, Column: 1, Rule: "MD009"}},
+		},
+		{
+			name:     "trailing spaces in code",
+			markdown: "```go\nx := 1   \n```\n",
+		},
+		{
+			name:     "line length",
+			markdown: strings.Repeat("word ", 20) + "end\n" + strings.Repeat("x", 100) + "\n\n    " + strings.Repeat("code ", 20) + "\n",
+			want:     []Problem{{Line: 1, Column: 81, Rule: "MD013"}},
+		},
+		{
+			name:     "duplicate headings",
+			markdown: "# Setup\n\nText\n\n## Setup\n\n## setup!\n\n## Other\n",
+			want:     []Problem{{Line: 5, Column: 1, Rule: "MD024"}, {Line: 7, Column: 1, Rule: "MD024"}},
+		},
+		{
+			name:     "bare urls",
+			markdown: "See https://example.com or <https://example.org>\nand [a link](https://example.net).\n",
+			want:     []Problem{{Line: 1, Column: 5, Rule: "MD034"}},
+		},
+		{
+			name:     "code language",
+			markdown: "```\nplain\n```\n\n```go\ncode\n```\n\n- item\n\n  ~~~\n  ~~~\n",
+			want:     []Problem{{Line: 1, Column: 1, Rule: "MD040"}, {Line: 11, Column: 1, Rule: "MD040"}},
+		},
+		{
+			name:     "alt text",
+			markdown: "Logo: ![](logo.png) and ![Icon](icon.png)\n",
+			want:     []Problem{{Line: 1, Column: 7, Rule: "MD045"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(converter.New()).Lint("test.md", []byte(tt.markdown))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lint() = %v, want %d problems", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].Line != want.Line || got[i].Column != want.Column || got[i].Rule != want.Rule {
+					t.Errorf("problem %d = %v, want %s at %d:%d", i, got[i], want.Rule, want.Line, want.Column)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_Options(t *testing.T) {
+	markdown := []byte("# Title\n\n### Skipped\n\n" + strings.Repeat("word ", 20) + "end\n")
+
+	all := New(converter.New()).Lint("a.md", markdown)
+	if len(all) != 2 {
+		t.Fatalf("Lint() = %v, want 2 problems", all)
+	}
+
+	got := New(converter.New(), WithDisabled("md001", "line-length")).Lint("a.md", markdown)
+	if len(got) != 0 {
+		t.Errorf("Lint() with rules disabled = %v, want none", got)
+	}
+
+	got = New(converter.New(), WithLineLength(120)).Lint("a.md", markdown)
+	if len(got) != 1 || got[0].Rule != "MD001" {
+		t.Errorf("Lint() with longer lines = %v, want only MD001", got)
+	}
+}
+
+func TestProblem_String(t *testing.T) {
+	p := Problem{File: "docs/a.md", Line: 3, Column: 1, Rule: "MD001", Name: "heading-increment", Message: "Heading level skipped"}
+	want := "docs/a.md:3:1: MD001/heading-increment Heading level skipped"
+	if got := p.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestLookupRule(t *testing.T) {
+	for _, name := range []string{"MD013", "md013", "line-length"} {
+		if rule, ok := LookupRule(name); !ok || rule.ID != "MD013" {
+			t.Errorf("LookupRule(%q) = %v, %v, want MD013", name, rule.ID, ok)
+		}
+	}
+	if _, ok := LookupRule("MD999"); ok {
+		t.Error("expected unknown rule")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("WriteJSON(nil) = %q, want []", buf.String())
+	}
+
+	buf.Reset()
+	problems := []Problem{{File: "a.md", Line: 1, Column: 2, Rule: "MD034", Name: "no-bare-urls", Message: "Bare URL"}}
+	if err := WriteJSON(&buf, problems); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Problem
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0] != problems[0] {
+		t.Errorf("WriteJSON() round trip = %v, want %v", decoded, problems)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	problems := []Problem{{File: "docs/a.md", Line: 4, Column: 2, Rule: "MD045", Name: "no-alt-text", Message: "Image has no alt text"}}
+	if err := WriteSARIF(&buf, problems, "1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+					Rules   []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %s", buf.String())
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "mdp" || run.Tool.Driver.Version != "1.2.3" || len(run.Tool.Driver.Rules) != len(Rules) {
+		t.Errorf("unexpected driver: %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(run.Results))
+	}
+	result := run.Results[0]
+	if result.RuleID != "MD045" || run.Tool.Driver.Rules[result.RuleIndex].ID != "MD045" {
+		t.Errorf("result rule = %s (index %d), want MD045", result.RuleID, result.RuleIndex)
+	}
+	loc := result.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "docs/a.md" || loc.Region.StartLine != 4 || loc.Region.StartColumn != 2 {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+}
@@ -0,0 +1,278 @@
+// Package notebook converts Jupyter notebooks to markdown, so they can be
+// previewed, searched and exported alongside markdown files.
+//
+// Markdown cells are kept as they are. Code cells become fenced code blocks
+// in the notebook's language, highlighted like any other code, and their
+// outputs follow them: text as plain code blocks, images inline, HTML as
+// raw HTML and errors as their traceback.
+package notebook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"mdp/internal/include"
+)
+
+// ansiEscape matches the terminal color codes in tracebacks.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// IsNotebook reports whether path is a Jupyter notebook.
+func IsNotebook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// Option configures optional conversion behavior.
+type Option func(*options)
+
+type options struct {
+	safe bool
+}
+
+// WithSafeMode prefers plain text and PNG, JPEG or GIF images to HTML and
+// SVG outputs, which safe mode would omit.
+func WithSafeMode() Option {
+	return func(o *options) {
+		o.safe = true
+	}
+}
+
+// notebook is the part of the nbformat 4 schema that is rendered.
+type notebook struct {
+	Format   int    `json:"nbformat"`
+	Cells    []cell `json:"cells"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+type cell struct {
+	Type           string                     `json:"cell_type"`
+	Source         text                       `json:"source"`
+	ExecutionCount *int                       `json:"execution_count"`
+	Outputs        []output                   `json:"outputs"`
+	Attachments    map[string]map[string]text `json:"attachments"`
+	Metadata       struct {
+		Language string `json:"language"`
+	} `json:"metadata"`
+}
+
+type output struct {
+	Type           string                     `json:"output_type"`
+	Name           string                     `json:"name"`
+	Text           text                       `json:"text"`
+	Data           map[string]json.RawMessage `json:"data"`
+	ExecutionCount *int                       `json:"execution_count"`
+	ErrorName      string                     `json:"ename"`
+	ErrorValue     string                     `json:"evalue"`
+	Traceback      []string                   `json:"traceback"`
+}
+
+// text is a multiline string, stored either as a string or as a list of
+// lines that keep their line endings.
+type text string
+
+func (t *text) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = text(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = text(s)
+	return nil
+}
+
+// Markdown converts a notebook to markdown.
+func Markdown(content []byte, opts ...Option) ([]byte, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, fmt.Errorf("invalid notebook: %w", err)
+	}
+	if nb.Format < 4 {
+		return nil, fmt.Errorf("unsupported notebook format %d, only version 4 and later are supported", nb.Format)
+	}
+	lang := nb.Metadata.LanguageInfo.Name
+	if lang == "" {
+		lang = nb.Metadata.KernelSpec.Language
+	}
+
+	var buf bytes.Buffer
+	for _, c := range nb.Cells {
+		switch c.Type {
+		case "markdown":
+			buf.WriteString(withAttachments(string(c.Source), c.Attachments))
+			buf.WriteString("\n\n")
+		case "code":
+			cellLang := lang
+			if c.Metadata.Language != "" {
+				cellLang = c.Metadata.Language
+			}
+			if c.Source != "" {
+				buf.Write(include.CodeBlock([]byte(c.Source), fenceInfo(cellLang, prompt("In", c.ExecutionCount))))
+				buf.WriteString("\n")
+			}
+			for _, out := range mergeStreams(c.Outputs) {
+				writeOutput(&buf, out, o)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// withAttachments replaces links to a markdown cell's attachments, such as
+// attachment:image.png, with data URLs.
+func withAttachments(source string, attachments map[string]map[string]text) string {
+	for name, data := range attachments {
+		for mediaType, encoded := range data {
+			source = strings.ReplaceAll(source, "attachment:"+name, dataURL(mediaType, string(encoded)))
+			break
+		}
+	}
+	return source
+}
+
+// mergeStreams joins consecutive outputs to the same stream, which
+// kernels may split while a cell runs.
+func mergeStreams(outputs []output) []output {
+	var merged []output
+	for _, out := range outputs {
+		if n := len(merged); n > 0 && out.Type == "stream" && merged[n-1].Type == "stream" && merged[n-1].Name == out.Name {
+			merged[n-1].Text += out.Text
+			continue
+		}
+		merged = append(merged, out)
+	}
+	return merged
+}
+
+// writeOutput writes a code cell's output as markdown.
+func writeOutput(buf *bytes.Buffer, out output, o options) {
+	switch out.Type {
+	case "stream":
+		writeText(buf, string(out.Text), out.Name)
+	case "error":
+		lines := make([]string, len(out.Traceback))
+		for i, line := range out.Traceback {
+			lines[i] = ansiEscape.ReplaceAllString(line, "")
+		}
+		writeText(buf, strings.Join(lines, "\n"), out.ErrorName+": "+out.ErrorValue)
+	case "execute_result", "display_data":
+		writeData(buf, out.Data, prompt("Out", out.ExecutionCount), o)
+	}
+}
+
+// writeData writes the richest representation of a rich output that can be
+// shown.
+func writeData(buf *bytes.Buffer, data map[string]json.RawMessage, title string, o options) {
+	value := func(mediaType string) (string, bool) {
+		raw, ok := data[mediaType]
+		if !ok {
+			return "", false
+		}
+		var t text
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return "", false
+		}
+		return string(t), true
+	}
+
+	if html, ok := value("text/html"); ok && !o.safe {
+		// A blank line would end the HTML block and parse the rest as
+		// markdown
+		var lines []string
+		for _, line := range strings.Split(html, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+		buf.WriteString("<div class=\"notebook-output\">\n")
+		buf.WriteString(strings.Join(lines, "\n"))
+		buf.WriteString("\n</div>\n\n")
+		return
+	}
+	for _, mediaType := range []string{"image/svg+xml", "image/png", "image/jpeg", "image/gif"} {
+		if mediaType == "image/svg+xml" && o.safe {
+			continue
+		}
+		if image, ok := value(mediaType); ok {
+			if mediaType == "image/svg+xml" {
+				image = base64.StdEncoding.EncodeToString([]byte(image))
+			}
+			fmt.Fprintf(buf, "![Output](%s)\n\n", dataURL(mediaType, image))
+			return
+		}
+	}
+	if markdown, ok := value("text/markdown"); ok {
+		buf.WriteString(markdown)
+		buf.WriteString("\n\n")
+		return
+	}
+	if plain, ok := value("text/plain"); ok {
+		writeText(buf, plain, title)
+	}
+}
+
+// writeText writes text output as a plain code block with a title.
+func writeText(buf *bytes.Buffer, s, title string) {
+	if s == "" {
+		return
+	}
+	buf.Write(include.CodeBlock([]byte(s), fenceInfo("text", title)))
+	buf.WriteString("\n")
+}
+
+// titleQuotes replaces the characters that would end a title or its code
+// block's opening fence.
+var titleQuotes = strings.NewReplacer(`"`, "'", "`", "'")
+
+// fenceInfo returns the info string of a fenced code block in lang, with a
+// title if it is not empty. The title is kept to one line: an error
+// message, for one, may span several lines and contain color codes.
+func fenceInfo(lang, title string) string {
+	if lang == "" {
+		lang = "text"
+	}
+	title = strings.Join(strings.FieldsFunc(ansiEscape.ReplaceAllString(title, ""), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+	if title == "" {
+		return lang
+	}
+	return lang + ` title="` + titleQuotes.Replace(title) + `"`
+}
+
+// prompt returns a cell's prompt, such as "In [3]", or "" if it was not run.
+func prompt(name string, count *int) string {
+	if count == nil {
+		return ""
+	}
+	return name + " [" + strconv.Itoa(*count) + "]"
+}
+
+// dataURL returns a data URL for base64 encoded data, which notebooks may
+// wrap over several lines.
+func dataURL(mediaType, encoded string) string {
+	encoded = strings.Join(strings.Fields(encoded), "")
+	return "data:" + mediaType + ";base64," + encoded
+}
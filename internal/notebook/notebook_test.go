@@ -0,0 +1,132 @@
+package notebook
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample = `{
+ "nbformat": 4,
+ "nbformat_minor": 5,
+ "metadata": {
+  "kernelspec": {"language": "python", "name": "python3"},
+  "language_info": {"name": "python"}
+ },
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": ["# Analysis\n", "\n", "![chart](attachment:chart.png)"],
+   "attachments": {"chart.png": {"image/png": "iVBORw0KGgo=\n"}}
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 3,
+   "metadata": {},
+   "source": "print('hi')\nprint('there')\n1 + 1",
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["hi\n"]},
+    {"output_type": "stream", "name": "stdout", "text": ["there\n"]},
+    {"output_type": "execute_result", "execution_count": 3, "metadata": {}, "data": {"text/plain": ["2"]}}
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 4,
+   "metadata": {},
+   "source": "df",
+   "outputs": [
+    {"output_type": "display_data", "metadata": {}, "data": {
+     "text/html": ["<table>\n", "\n", "<tr><td>1</td></tr>\n", "</table>"],
+     "image/png": "iVBORw0KGgo=",
+     "text/plain": ["   a\n", "0  1"]
+    }}
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 5,
+   "metadata": {},
+   "source": "1 / 0",
+   "outputs": [
+    {"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero",
+     "traceback": ["\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"]}
+   ]
+  },
+  {
+   "cell_type": "raw",
+   "metadata": {},
+   "source": "not shown"
+  }
+ ]
+}`
+
+func TestMarkdown(t *testing.T) {
+	got, err := Markdown([]byte(sample))
+	if err != nil {
+		t.Fatalf("Markdown() error = %v", err)
+	}
+	want := "# Analysis\n\n![chart](data:image/png;base64,iVBORw0KGgo=)\n\n" +
+		"```python title=\"In [3]\"\nprint('hi')\nprint('there')\n1 + 1\n```\n\n" +
+		"```text title=\"stdout\"\nhi\nthere\n```\n\n" +
+		"```text title=\"Out [3]\"\n2\n```\n\n" +
+		"```python title=\"In [4]\"\ndf\n```\n\n" +
+		"<div class=\"notebook-output\">\n<table>\n<tr><td>1</td></tr>\n</table>\n</div>\n\n" +
+		"```python title=\"In [5]\"\n1 / 0\n```\n\n" +
+		"```text title=\"ZeroDivisionError: division by zero\"\nZeroDivisionError: division by zero\n```\n\n"
+	if string(got) != want {
+		t.Errorf("Markdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkdown_MultilineError(t *testing.T) {
+	nb := `{"nbformat": 4, "metadata": {}, "cells": [{"cell_type": "code", "execution_count": 1, "metadata": {}, "source": "f()",
+	 "outputs": [{"output_type": "error", "ename": "ValueError", "evalue": "bad \"value\"\n\tin ` + "`f`" + `\r\n\u001b[0;31mhere\u001b[0m\u0007",
+	  "traceback": ["ValueError: bad value"]}]}]}`
+	got, err := Markdown([]byte(nb))
+	if err != nil {
+		t.Fatalf("Markdown() error = %v", err)
+	}
+	want := "```text title=\"ValueError: bad 'value' in 'f' here\"\nValueError: bad value\n```\n"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("Markdown() =\n%s\nwant it to contain\n%s", got, want)
+	}
+}
+
+func TestMarkdown_SafeMode(t *testing.T) {
+	got, err := Markdown([]byte(sample), WithSafeMode())
+	if err != nil {
+		t.Fatalf("Markdown() error = %v", err)
+	}
+	if strings.Contains(string(got), "<table>") {
+		t.Error("expected HTML output to be left out in safe mode")
+	}
+	if !strings.Contains(string(got), "![Output](data:image/png;base64,iVBORw0KGgo=)") {
+		t.Errorf("expected the PNG output in safe mode, got:\n%s", got)
+	}
+}
+
+func TestMarkdown_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"invalid JSON", "{", "invalid notebook"},
+		{"old format", `{"nbformat": 3, "worksheets": []}`, "unsupported notebook format 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Markdown([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Markdown() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsNotebook(t *testing.T) {
+	if !IsNotebook("analysis/Report.IPYNB") || IsNotebook("README.md") {
+		t.Error("IsNotebook() did not match by extension")
+	}
+}
@@ -0,0 +1,127 @@
+package pdf
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+
+	"mdp/internal/filetree"
+	"mdp/internal/linkrewriter"
+)
+
+// collectBacklinks finds the links between chapters and returns, for each
+// chapter ID, the other chapters that link to it in reading order, with
+// the sentences around the links.
+func (l *layout) collectBacklinks() map[string][]filetree.Backlink {
+	backlinks := make(map[string][]filetree.Backlink)
+	for _, ch := range l.doc.Chapters {
+		seen := make(map[string]int) // target ID -> index in backlinks[target]
+		ast.Walk(ch.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			link, ok := n.(*ast.Link)
+			if !ok || !entering {
+				return ast.WalkContinue, nil
+			}
+			targetID, _, ok := l.rewriter.Resolve(string(link.Destination), ch.Entry.RelPath)
+			if !ok || targetID == ch.Entry.ID {
+				return ast.WalkSkipChildren, nil
+			}
+
+			i, ok := seen[targetID]
+			if !ok {
+				i = len(backlinks[targetID])
+				seen[targetID] = i
+				backlinks[targetID] = append(backlinks[targetID], filetree.Backlink{
+					ID:      ch.Entry.ID,
+					Name:    ch.Entry.Name,
+					RelPath: ch.Entry.RelPath,
+				})
+			}
+			excerpt := linkExcerpt(link, ch.Source)
+			b := &backlinks[targetID][i]
+			if excerpt != "" && !slices.Contains(b.Excerpts, excerpt) {
+				b.Excerpts = append(b.Excerpts, excerpt)
+			}
+			return ast.WalkSkipChildren, nil
+		})
+	}
+	return backlinks
+}
+
+// linkExcerpt returns the sentence around link in the block that
+// contains it.
+func linkExcerpt(link ast.Node, src []byte) string {
+	block := link.Parent()
+	for block != nil && block.Type() != ast.TypeBlock {
+		block = block.Parent()
+	}
+	if block == nil {
+		return ""
+	}
+
+	var before strings.Builder
+	ast.Walk(block, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n == link {
+			return ast.WalkStop, nil
+		}
+		if entering {
+			writeText(&before, n, src)
+		}
+		return ast.WalkContinue, nil
+	})
+	return linkrewriter.Excerpt(plainText(block, src), before.String())
+}
+
+// backlinks prints the chapters that link to the current one, each linked
+// to its start and followed by the sentences with the links. The section
+// is left out of the contents and bookmarks.
+func (l *layout) backlinks(backlinks []filetree.Backlink) {
+	if len(backlinks) == 0 {
+		return
+	}
+	pdf := l.pdf
+	_, pageHeight := pdf.GetPageSize()
+	if pdf.GetY() > pageHeight-pageMargin-25 {
+		pdf.AddPage()
+	} else {
+		pdf.Ln(blockGap * 1.5)
+	}
+
+	size := headingSizes[3]
+	l.bold++
+	l.withFont(size, size*0.5, func() {
+		l.write("Linked from")
+	})
+	l.bold--
+	l.applyFont()
+	pdf.Ln(size * 0.5)
+	l.rule(0.5)
+
+	left, _, _, _ := pdf.GetMargins()
+	saved := l.color
+	for _, b := range backlinks {
+		l.source = b.RelPath
+		l.applyFont()
+		pdf.SetX(left)
+		pdf.CellFormat(listIndent, l.lineHeight, "•", "", 0, "L", false, 0, "")
+		pdf.SetLeftMargin(left + listIndent)
+
+		l.linkID, l.color = l.links[headingKey(b.ID, "")], l.colors.link
+		l.applyFont()
+		l.write(b.Name)
+		l.linkID, l.color = 0, l.colors.muted
+		l.applyFont()
+		pdf.Ln(l.lineHeight)
+		for _, excerpt := range b.Excerpts {
+			l.write(excerpt)
+			pdf.Ln(l.lineHeight)
+		}
+
+		l.color = saved
+		pdf.SetLeftMargin(left)
+		pdf.SetX(left)
+	}
+	l.applyFont()
+	l.source = l.chapter.Entry.RelPath
+	pdf.Ln(blockGap)
+}
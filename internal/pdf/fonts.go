@@ -0,0 +1,138 @@
+package pdf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// Font families embedded in every document. The Go fonts cover Latin,
+// Greek and Cyrillic text.
+const (
+	sansFont = "Go"
+	monoFont = "GoMono"
+)
+
+var fontFiles = []struct {
+	family, style string
+	ttf           []byte
+}{
+	{sansFont, "", goregular.TTF},
+	{sansFont, "B", gobold.TTF},
+	{sansFont, "I", goitalic.TTF},
+	{sansFont, "BI", gobolditalic.TTF},
+	{monoFont, "", gomono.TTF},
+	{monoFont, "B", gomonobold.TTF},
+	{monoFont, "I", gomonoitalic.TTF},
+	{monoFont, "BI", gomonobolditalic.TTF},
+}
+
+// replacementChar is printed in place of characters the fonts lack.
+const replacementChar = '\uFFFD'
+
+// missingGlyphs lists the characters the fonts lack in one piece of text.
+type missingGlyphs struct {
+	source string
+	chars  []rune
+}
+
+// addFonts registers the embedded fonts with pdf.
+func addFonts(pdf *fpdf.Fpdf) {
+	for _, f := range fontFiles {
+		pdf.AddUTF8FontFromBytes(f.family, f.style, f.ttf)
+	}
+}
+
+// coverageFonts are the regular fonts of each family, whose characters
+// the other styles share.
+var coverageFonts = sync.OnceValues(func() ([]*sfnt.Font, error) {
+	var fonts []*sfnt.Font
+	for _, ttf := range [][]byte{goregular.TTF, gomono.TTF} {
+		f, err := sfnt.Parse(ttf)
+		if err != nil {
+			return nil, err
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+})
+
+// hasGlyph reports whether every font family can show r.
+func (l *layout) hasGlyph(r rune) bool {
+	if ok, seen := l.glyphs[r]; seen {
+		return ok
+	}
+	fonts, err := coverageFonts()
+	ok := err == nil
+	var buf sfnt.Buffer
+	for _, f := range fonts {
+		if i, err := f.GlyphIndex(&buf, r); err != nil || i == 0 {
+			ok = false
+		}
+	}
+	l.glyphs[r] = ok
+	return ok
+}
+
+// text prepares s to be printed. Tabs become spaces and invisible control
+// and format characters are removed. A character the fonts cannot show
+// becomes the replacement character and is recorded for a warning.
+func (l *layout) text(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case r == '\n':
+			return r
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		if !l.hasGlyph(r) {
+			l.missingGlyph(r)
+			return replacementChar
+		}
+		return r
+	}, s)
+}
+
+// missingGlyph records that the text named by l.source contains r.
+func (l *layout) missingGlyph(r rune) {
+	for i := range l.missing {
+		if m := &l.missing[i]; m.source == l.source {
+			if !slices.Contains(m.chars, r) {
+				m.chars = append(m.chars, r)
+			}
+			return
+		}
+	}
+	l.missing = append(l.missing, missingGlyphs{source: l.source, chars: []rune{r}})
+}
+
+// warnings describes the characters that were replaced, one message for
+// each piece of text that contained any.
+func (l *layout) warnings() []string {
+	var msgs []string
+	for _, m := range l.missing {
+		chars := fmt.Sprintf("%q (U+%04X)", string(m.chars[0]), m.chars[0])
+		if n := len(m.chars) - 1; n == 1 {
+			chars += " and 1 other character"
+		} else if n > 1 {
+			chars += fmt.Sprintf(" and %d other characters", n)
+		}
+		msgs = append(msgs, fmt.Sprintf("%s contains %s that the PDF fonts cannot show (they cover Latin, Greek and Cyrillic), printed as %q; export to EPUB to keep them", m.source, chars, string(replacementChar)))
+	}
+	return msgs
+}
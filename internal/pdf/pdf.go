@@ -0,0 +1,1122 @@
+// Package pdf renders parsed markdown documents to paginated PDF files
+// without relying on a browser.
+package pdf
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF decoder for image checks
+	_ "image/jpeg" // Register JPEG decoder for image checks
+	_ "image/png"  // Register PNG decoder for image checks
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/go-pdf/fpdf"
+	emojiast "github.com/yuin/goldmark-emoji/ast"
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/util"
+
+	"mdp/internal/converter"
+	"mdp/internal/filetree"
+	"mdp/internal/linkrewriter"
+)
+
+// Chapter is a single markdown file in a PDF document.
+type Chapter struct {
+	Entry  filetree.FileEntry // Identifies the file; Content is not used
+	Source []byte             // Markdown source
+	Root   ast.Node           // Parsed source, see converter.Parse
+}
+
+// Document describes the content of a PDF export.
+type Document struct {
+	Title      string
+	Chapters   []Chapter // In reading order
+	PageBreaks bool      // Start every chapter on a new page
+	CodeStyle  string    // Chroma style for code blocks, defaults to "github", or "monokai" when Dark
+	Dark       bool      // Light text on dark pages
+	Created    time.Time // Creation date shown on the cover, defaults to now
+
+	// Warn, if set, receives problems that do not stop the export, such
+	// as characters the fonts cannot show.
+	Warn func(message string)
+}
+
+// Page layout in millimetres and points.
+const (
+	pageMargin     = 20.0
+	bodyFontSize   = 10.5
+	bodyLineHeight = 5.2
+	codeFontSize   = 8.5
+	codeLineHeight = 4.2
+	blockGap       = 2.8
+	listIndent     = 6.0
+	quoteIndent    = 5.0
+	tocMaxLevel    = 2
+	footerFontSize = 8.5
+)
+
+// headingSizes maps heading levels to font sizes.
+var headingSizes = [7]float64{0, 20, 16, 13.5, 12, 11, 10.5}
+
+// palette holds the colours used throughout the document.
+type palette struct {
+	page, text, muted, link, border, codeBg [3]int
+}
+
+// Light and dark palettes, after GitHub's.
+var (
+	lightPalette = palette{
+		page:   [3]int{255, 255, 255},
+		text:   [3]int{31, 35, 40},
+		muted:  [3]int{89, 99, 110},
+		link:   [3]int{9, 105, 218},
+		border: [3]int{209, 217, 224},
+		codeBg: [3]int{246, 248, 250},
+	}
+	darkPalette = palette{
+		page:   [3]int{13, 17, 23},
+		text:   [3]int{230, 237, 243},
+		muted:  [3]int{145, 152, 161},
+		link:   [3]int{68, 147, 248},
+		border: [3]int{61, 68, 77},
+		codeBg: [3]int{21, 27, 35},
+	}
+)
+
+// tocEntry is a line in the table of contents.
+type tocEntry struct {
+	key   string // link key, see headingKey
+	title string
+	level int
+	file  string // RelPath of the chapter
+}
+
+// Write renders doc as a PDF to w: a cover page, a table of contents with
+// page numbers, then each chapter, followed by the chapters that link to
+// it. Code blocks are highlighted with Chroma.
+func Write(w io.Writer, doc Document) error {
+	if len(doc.Chapters) == 0 {
+		return fmt.Errorf("pdf: no chapters to render")
+	}
+	if doc.Created.IsZero() {
+		doc.Created = time.Now()
+	}
+	if doc.CodeStyle == "" {
+		doc.CodeStyle = "github"
+		if doc.Dark {
+			doc.CodeStyle = "monokai"
+		}
+	}
+
+	// The table of contents precedes the content, so the document is laid
+	// out twice: the first pass records the page of every heading, the
+	// second prints those page numbers. Both passes produce identical pages.
+	first, err := render(doc, nil)
+	if err != nil {
+		return err
+	}
+	second, err := render(doc, first.pages)
+	if err != nil {
+		return err
+	}
+	if doc.Warn != nil {
+		for _, msg := range second.warnings() {
+			doc.Warn(msg)
+		}
+	}
+	return second.pdf.Output(w)
+}
+
+// layout holds the state of a single rendering pass.
+type layout struct {
+	pdf    *fpdf.Fpdf
+	doc    Document
+	style  *chroma.Style
+	colors palette
+
+	glyphs  map[rune]bool   // characters the fonts can show, see hasGlyph
+	source  string          // names the text being laid out, for warnings
+	missing []missingGlyphs // characters the fonts cannot show, by source
+
+	rewriter *linkrewriter.LinkRewriter
+	links    map[string]int // heading key -> PDF link
+	pages    map[string]int // heading key -> page number
+	toc      []tocEntry
+
+	chapter      *Chapter
+	outlineLevel int
+
+	// Inline formatting state
+	bold, italic, strike, mono int
+	size                       float64
+	lineHeight                 float64
+	color                      [3]int
+	linkID                     int
+	linkURL                    string
+}
+
+// render lays out the whole document. knownPages holds the heading pages
+// from a previous pass and may be nil.
+func render(doc Document, knownPages map[string]int) (*layout, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.SetTitle(doc.Title, true)
+	pdf.SetCreator("mdp", false)
+	pdf.SetCreationDate(doc.Created)
+	pdf.SetCatalogSort(true)
+	pdf.AliasNbPages("")
+	addFonts(pdf)
+
+	style := styles.Get(doc.CodeStyle)
+	if style == nil {
+		style = styles.Fallback
+	}
+
+	colors := lightPalette
+	if doc.Dark {
+		colors = darkPalette
+	}
+
+	l := &layout{
+		pdf:        pdf,
+		doc:        doc,
+		style:      style,
+		colors:     colors,
+		glyphs:     make(map[rune]bool),
+		links:      make(map[string]int),
+		pages:      make(map[string]int),
+		size:       bodyFontSize,
+		lineHeight: bodyLineHeight,
+		color:      colors.text,
+	}
+
+	var entries []filetree.FileEntry
+	for _, ch := range doc.Chapters {
+		entries = append(entries, ch.Entry)
+	}
+	l.rewriter = linkrewriter.New(entries)
+	l.collectHeadings()
+	backlinks := l.collectBacklinks()
+
+	pdf.SetHeaderFunc(l.background)
+	pdf.SetFooterFunc(l.footer)
+
+	l.cover()
+	l.tableOfContents(knownPages)
+
+	for i := range doc.Chapters {
+		l.chapter = &doc.Chapters[i]
+		l.source = l.chapter.Entry.RelPath
+		if i == 0 || doc.PageBreaks {
+			pdf.AddPage()
+		} else {
+			pdf.Ln(blockGap * 3)
+		}
+		pdf.SetLink(l.links[headingKey(l.chapter.Entry.ID, "")], -1, -1)
+		l.pages[headingKey(l.chapter.Entry.ID, "")] = pdf.PageNo()
+		l.outlineLevel = -1
+		l.blocks(l.chapter.Root)
+		l.backlinks(backlinks[l.chapter.Entry.ID])
+	}
+
+	if pdf.Err() {
+		return nil, fmt.Errorf("pdf: %v", pdf.Error())
+	}
+	return l, nil
+}
+
+// headingKey identifies a heading (or, with an empty id, the chapter start).
+func headingKey(chapterID, headingID string) string {
+	return chapterID + "#" + headingID
+}
+
+// collectHeadings creates a link for every chapter and heading and gathers
+// the table of contents entries.
+func (l *layout) collectHeadings() {
+	for _, ch := range l.doc.Chapters {
+		l.links[headingKey(ch.Entry.ID, "")] = l.pdf.AddLink()
+
+		var headings []tocEntry
+		ast.Walk(ch.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			h, ok := n.(*ast.Heading)
+			if !ok || !entering {
+				return ast.WalkContinue, nil
+			}
+			key := headingKey(ch.Entry.ID, headingID(h))
+			l.links[key] = l.pdf.AddLink()
+			if h.Level <= tocMaxLevel {
+				headings = append(headings, tocEntry{key: key, title: plainText(h, ch.Source), level: h.Level, file: ch.Entry.RelPath})
+			}
+			return ast.WalkSkipChildren, nil
+		})
+
+		if len(headings) == 0 {
+			headings = append(headings, tocEntry{key: headingKey(ch.Entry.ID, ""), title: ch.Entry.Name, level: 1, file: ch.Entry.RelPath})
+		}
+		l.toc = append(l.toc, headings...)
+	}
+}
+
+// headingID returns the id attribute assigned to a heading by the parser.
+func headingID(h *ast.Heading) string {
+	if id, ok := h.AttributeString("id"); ok {
+		if b, ok := id.([]byte); ok {
+			return string(b)
+		}
+	}
+	return ""
+}
+
+// background fills every page with the palette's page colour, unless it
+// is white.
+func (l *layout) background() {
+	if l.colors.page == lightPalette.page {
+		return
+	}
+	width, height := l.pdf.GetPageSize()
+	l.pdf.SetFillColor(l.colors.page[0], l.colors.page[1], l.colors.page[2])
+	l.pdf.Rect(0, 0, width, height, "F")
+}
+
+// footer prints the page number on every page but the cover.
+func (l *layout) footer() {
+	if l.pdf.PageNo() == 1 {
+		return
+	}
+	l.pdf.SetY(-pageMargin + 6)
+	l.pdf.SetFont(sansFont, "", footerFontSize)
+	l.pdf.SetTextColor(l.colors.muted[0], l.colors.muted[1], l.colors.muted[2])
+	l.pdf.CellFormat(0, 5, fmt.Sprintf("%d / {nb}", l.pdf.PageNo()), "", 0, "C", false, 0, "")
+}
+
+// cover prints the title page.
+func (l *layout) cover() {
+	pdf := l.pdf
+	pdf.AddPage()
+	_, pageHeight := pdf.GetPageSize()
+
+	pdf.SetY(pageHeight / 3)
+	pdf.SetFont(sansFont, "B", 28)
+	pdf.SetTextColor(l.colors.text[0], l.colors.text[1], l.colors.text[2])
+	l.source = "the title"
+	pdf.MultiCell(0, 12, l.text(l.doc.Title), "", "C", false)
+
+	pdf.Ln(6)
+	pdf.SetFont(sansFont, "", 12)
+	pdf.SetTextColor(l.colors.muted[0], l.colors.muted[1], l.colors.muted[2])
+	if n := len(l.doc.Chapters); n > 1 {
+		pdf.CellFormat(0, 7, fmt.Sprintf("%d documents", n), "", 1, "C", false, 0, "")
+	}
+	pdf.CellFormat(0, 7, l.doc.Created.Format("January 2, 2006"), "", 1, "C", false, 0, "")
+}
+
+// tableOfContents prints the contents pages. Page numbers are taken from
+// knownPages and left blank when it is nil.
+func (l *layout) tableOfContents(knownPages map[string]int) {
+	pdf := l.pdf
+	pdf.AddPage()
+	pdf.SetFont(sansFont, "B", headingSizes[1])
+	pdf.SetTextColor(l.colors.text[0], l.colors.text[1], l.colors.text[2])
+	pdf.CellFormat(0, 10, "Contents", "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	pageWidth, _ := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pageMargin
+	const numberWidth = 15.0
+
+	for _, entry := range l.toc {
+		indent := float64(entry.level-1) * listIndent
+		style := ""
+		if entry.level == 1 {
+			style = "B"
+		}
+		pdf.SetFont(sansFont, style, bodyFontSize)
+
+		l.source = entry.file
+		title := l.text(entry.title)
+		available := contentWidth - indent - numberWidth - 2
+		for runes := []rune(title); pdf.GetStringWidth(title) > available && len(runes) > 1; {
+			runes = runes[:len(runes)-1]
+			title = strings.TrimRight(string(runes), " ") + "…"
+		}
+
+		page := ""
+		if knownPages != nil {
+			page = strconv.Itoa(knownPages[entry.key])
+		}
+
+		link := l.links[entry.key]
+		pdf.SetX(pageMargin + indent)
+		pdf.CellFormat(contentWidth-indent-numberWidth, 7, title, "", 0, "L", false, link, "")
+		pdf.CellFormat(numberWidth, 7, page, "", 1, "R", false, link, "")
+	}
+}
+
+// blocks renders the block children of n.
+func (l *layout) blocks(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		l.block(c)
+	}
+}
+
+// block renders a single block node.
+func (l *layout) block(n ast.Node) {
+	switch node := n.(type) {
+	case *ast.Heading:
+		l.heading(node)
+	case *ast.Paragraph:
+		if img := soleImage(node); img != nil && l.image(img) {
+			return
+		}
+		l.paragraph(node)
+		l.pdf.Ln(blockGap)
+	case *ast.TextBlock:
+		l.paragraph(node)
+		if node.NextSibling() != nil {
+			l.pdf.Ln(blockGap)
+		}
+	case *ast.List:
+		l.list(node)
+	case *ast.Blockquote:
+		l.blockquote(node)
+	case *ast.FencedCodeBlock:
+		if node.Info != nil {
+			info := string(node.Info.Segment.Value(l.chapter.Source))
+			if opts, ok := converter.ParseCodeBlockOptions(info); ok && opts.Title != "" {
+				l.codeTitle(opts.Title)
+			}
+		}
+		l.codeBlock(node, string(node.Language(l.chapter.Source)))
+	case *ast.CodeBlock:
+		l.codeBlock(node, "")
+	case *ast.ThematicBreak:
+		l.rule(1)
+	case *extast.Table:
+		l.table(node)
+	case *extast.DefinitionList:
+		l.definitionList(node)
+	case *extast.FootnoteList:
+		l.footnotes(node)
+	case *converter.WikiEmbed:
+		l.wikiEmbed(node)
+	case *ast.HTMLBlock:
+		// Raw HTML cannot be represented in the PDF
+	default:
+		l.blocks(n)
+	}
+}
+
+// heading renders a heading, registering its link, outline entry and page.
+func (l *layout) heading(h *ast.Heading) {
+	pdf := l.pdf
+	_, pageHeight := pdf.GetPageSize()
+
+	// Avoid leaving a heading alone at the bottom of a page
+	if pdf.GetY() > pageHeight-pageMargin-25 {
+		pdf.AddPage()
+	} else if pdf.GetY() > pageMargin+1 {
+		pdf.Ln(blockGap * 1.5)
+	}
+
+	key := headingKey(l.chapter.Entry.ID, headingID(h))
+	pdf.SetLink(l.links[key], -1, -1)
+	l.pages[key] = pdf.PageNo()
+
+	// Outline levels may only increase one step at a time
+	level := h.Level - 1
+	if level > l.outlineLevel+1 {
+		level = l.outlineLevel + 1
+	}
+	l.outlineLevel = level
+	pdf.Bookmark(l.text(plainText(h, l.chapter.Source)), level, -1)
+
+	size := headingSizes[h.Level]
+	l.bold++
+	l.withFont(size, size*0.5, func() {
+		l.inline(h)
+	})
+	l.bold--
+	l.applyFont()
+	pdf.Ln(size * 0.5)
+
+	if h.Level <= 2 {
+		l.rule(0.5)
+	} else {
+		pdf.Ln(1.5)
+	}
+}
+
+// paragraph renders the inline content of n followed by a line break.
+func (l *layout) paragraph(n ast.Node) {
+	l.applyFont()
+	l.inline(n)
+	l.pdf.Ln(l.lineHeight)
+}
+
+// list renders an ordered or unordered list.
+func (l *layout) list(list *ast.List) {
+	pdf := l.pdf
+	left, _, _, _ := pdf.GetMargins()
+	number := list.Start
+	if number == 0 {
+		number = 1
+	}
+
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "•"
+		if list.IsOrdered() {
+			marker = fmt.Sprintf("%d%c", number, list.Marker)
+			number++
+		}
+
+		l.applyFont()
+		pdf.SetX(left)
+		pdf.CellFormat(listIndent, l.lineHeight, marker, "", 0, "L", false, 0, "")
+		pdf.SetLeftMargin(left + listIndent)
+		l.blocks(item)
+		pdf.SetLeftMargin(left)
+		pdf.SetX(left)
+	}
+	pdf.Ln(blockGap)
+}
+
+// definitionList renders each term in bold with its definitions indented
+// below it.
+func (l *layout) definitionList(list *extast.DefinitionList) {
+	pdf := l.pdf
+	left, _, _, _ := pdf.GetMargins()
+	for c := list.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c.(type) {
+		case *extast.DefinitionTerm:
+			l.bold++
+			l.paragraph(c)
+			l.bold--
+			l.applyFont()
+		case *extast.DefinitionDescription:
+			pdf.SetLeftMargin(left + listIndent)
+			pdf.SetX(left + listIndent)
+			l.blocks(c)
+			pdf.SetLeftMargin(left)
+			pdf.SetX(left)
+		}
+	}
+	pdf.Ln(blockGap)
+}
+
+// footnotes renders the notes at the end of a chapter below a rule,
+// numbered like their references.
+func (l *layout) footnotes(list *extast.FootnoteList) {
+	pdf := l.pdf
+	left, _, _, _ := pdf.GetMargins()
+	l.rule(1)
+	for c := list.FirstChild(); c != nil; c = c.NextSibling() {
+		note, ok := c.(*extast.Footnote)
+		if !ok {
+			continue
+		}
+		l.applyFont()
+		pdf.SetX(left)
+		pdf.CellFormat(listIndent, l.lineHeight, fmt.Sprintf("[%d]", note.Index), "", 0, "L", false, 0, "")
+		pdf.SetLeftMargin(left + listIndent)
+		l.blocks(note)
+		pdf.SetLeftMargin(left)
+		pdf.SetX(left)
+	}
+}
+
+// blockquote renders quoted blocks indented, muted and with a left border.
+func (l *layout) blockquote(q *ast.Blockquote) {
+	pdf := l.pdf
+	left, _, _, _ := pdf.GetMargins()
+	startY, startPage := pdf.GetY(), pdf.PageNo()
+
+	saved := l.color
+	l.color = l.colors.muted
+	pdf.SetLeftMargin(left + quoteIndent)
+	pdf.SetX(left + quoteIndent)
+	l.blocks(q)
+	pdf.SetLeftMargin(left)
+	pdf.SetX(left)
+	l.color = saved
+
+	endY := pdf.GetY() - blockGap
+	if startPage != pdf.PageNo() {
+		startY = pageMargin
+	}
+	pdf.SetDrawColor(l.colors.border[0], l.colors.border[1], l.colors.border[2])
+	pdf.SetLineWidth(1)
+	pdf.Line(left+1, startY, left+1, endY)
+	pdf.SetLineWidth(0.2)
+}
+
+// wikiEmbed renders an embedded page as a quoted link to its chapter.
+// The embedded content is already HTML, which the PDF cannot lay out.
+func (l *layout) wikiEmbed(e *converter.WikiEmbed) {
+	pdf := l.pdf
+	left, _, _, _ := pdf.GetMargins()
+	startY, startPage := pdf.GetY(), pdf.PageNo()
+
+	saved := l.color
+	l.color = l.colors.muted
+	pdf.SetLeftMargin(left + quoteIndent)
+	pdf.SetX(left + quoteIndent)
+	l.applyFont()
+	l.write("Embedded: ")
+	l.bold++
+	l.link(e.Href, func() {
+		l.write(e.Title)
+	})
+	l.bold--
+	pdf.Ln(l.lineHeight)
+	pdf.SetLeftMargin(left)
+	pdf.SetX(left)
+	l.color = saved
+	l.applyFont()
+
+	if startPage != pdf.PageNo() {
+		startY = pageMargin
+	}
+	pdf.SetDrawColor(l.colors.border[0], l.colors.border[1], l.colors.border[2])
+	pdf.SetLineWidth(1)
+	pdf.Line(left+1, startY, left+1, pdf.GetY())
+	pdf.SetLineWidth(0.2)
+	pdf.Ln(blockGap)
+}
+
+// codeToken is a highlighted run of text within a single line.
+type codeToken struct {
+	text  string
+	entry chroma.StyleEntry
+}
+
+// codeTitle renders the title of a code block, such as its file name,
+// above it.
+func (l *layout) codeTitle(title string) {
+	l.pdf.SetFont(monoFont, "B", codeFontSize)
+	l.pdf.SetTextColor(l.colors.muted[0], l.colors.muted[1], l.colors.muted[2])
+	l.pdf.CellFormat(0, codeLineHeight+1, l.text(title), "", 1, "L", false, 0, "")
+	l.pdf.SetTextColor(l.colors.text[0], l.colors.text[1], l.colors.text[2])
+}
+
+// codeBlock renders a code block with syntax highlighting. Long lines are
+// wrapped at the right margin.
+func (l *layout) codeBlock(n ast.Node, language string) {
+	pdf := l.pdf
+	var src strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		src.Write(seg.Value(l.chapter.Source))
+	}
+	code := strings.ReplaceAll(strings.TrimRight(src.String(), "\n"), "\t", "    ")
+
+	pdf.SetFont(monoFont, "", codeFontSize)
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	width := pageWidth - left - right
+	const padding = 3.0
+	charWidth := pdf.GetStringWidth("m")
+	maxChars := int((width - 2*padding) / charWidth)
+
+	bg, fg := l.colors.codeBg, l.colors.text
+	e := l.style.Get(chroma.Background)
+	if e.Background.IsSet() {
+		bg = [3]int{int(e.Background.Red()), int(e.Background.Green()), int(e.Background.Blue())}
+	}
+	if e.Colour.IsSet() {
+		fg = [3]int{int(e.Colour.Red()), int(e.Colour.Green()), int(e.Colour.Blue())}
+	}
+
+	row := func(h float64) {
+		if pdf.GetY()+h > pageHeight-pageMargin {
+			pdf.AddPage()
+		}
+		pdf.SetFillColor(bg[0], bg[1], bg[2])
+		pdf.Rect(left, pdf.GetY(), width, h, "F")
+	}
+
+	row(padding)
+	pdf.Ln(padding)
+	for _, line := range l.highlight(code, language) {
+		for _, visual := range wrapTokens(line, maxChars) {
+			row(codeLineHeight)
+			pdf.SetX(left + padding)
+			for _, tok := range visual {
+				style := ""
+				if tok.entry.Bold == chroma.Yes {
+					style += "B"
+				}
+				if tok.entry.Italic == chroma.Yes {
+					style += "I"
+				}
+				pdf.SetFont(monoFont, style, codeFontSize)
+				c := fg
+				if tok.entry.Colour.IsSet() {
+					c = [3]int{int(tok.entry.Colour.Red()), int(tok.entry.Colour.Green()), int(tok.entry.Colour.Blue())}
+				}
+				pdf.SetTextColor(c[0], c[1], c[2])
+				text := l.text(tok.text)
+				pdf.CellFormat(pdf.GetStringWidth(text), codeLineHeight, text, "", 0, "L", false, 0, "")
+			}
+			pdf.Ln(codeLineHeight)
+		}
+	}
+	row(padding)
+	pdf.Ln(padding + blockGap)
+	l.applyFont()
+}
+
+// highlight tokenises code and splits the tokens into lines.
+func (l *layout) highlight(code, language string) [][]codeToken {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	lines := [][]codeToken{nil}
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		for _, text := range strings.Split(code, "\n") {
+			lines = append(lines, []codeToken{{text: text}})
+		}
+		return lines[1:]
+	}
+
+	for _, token := range iterator.Tokens() {
+		entry := l.style.Get(token.Type)
+		parts := strings.Split(token.Value, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], codeToken{text: part, entry: entry})
+			}
+		}
+	}
+	// Drop the empty line produced by a trailing newline
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// wrapTokens splits a line of tokens into visual lines of at most maxChars
+// characters.
+func wrapTokens(line []codeToken, maxChars int) [][]codeToken {
+	if maxChars < 1 {
+		maxChars = 1
+	}
+	visual := [][]codeToken{nil}
+	used := 0
+	for _, tok := range line {
+		runes := []rune(tok.text)
+		for len(runes) > 0 {
+			if used == maxChars {
+				visual = append(visual, nil)
+				used = 0
+			}
+			n := maxChars - used
+			if n > len(runes) {
+				n = len(runes)
+			}
+			visual[len(visual)-1] = append(visual[len(visual)-1], codeToken{text: string(runes[:n]), entry: tok.entry})
+			used += n
+			runes = runes[n:]
+		}
+	}
+	return visual
+}
+
+// table renders a GFM table with column widths proportional to content.
+func (l *layout) table(t *extast.Table) {
+	pdf := l.pdf
+	var rows [][]string
+	header := 0
+	for r := t.FirstChild(); r != nil; r = r.NextSibling() {
+		var cells []string
+		for c := r.FirstChild(); c != nil; c = c.NextSibling() {
+			cells = append(cells, l.text(plainText(c, l.chapter.Source)))
+		}
+		if _, ok := r.(*extast.TableHeader); ok {
+			header++
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	const padding = 1.5
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	available := pageWidth - left - right
+
+	pdf.SetFont(sansFont, "B", bodyFontSize)
+	widths := make([]float64, columns)
+	total := 0.0
+	for _, row := range rows {
+		for i, cell := range row {
+			if w := pdf.GetStringWidth(cell) + 2*padding + 1; w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for _, w := range widths {
+		total += w
+	}
+	if total > available {
+		for i := range widths {
+			widths[i] = widths[i] * available / total
+		}
+	}
+
+	pdf.SetDrawColor(l.colors.border[0], l.colors.border[1], l.colors.border[2])
+	pdf.SetLineWidth(0.2)
+	for r, row := range rows {
+		style := ""
+		if r < header {
+			style = "B"
+		}
+		pdf.SetFont(sansFont, style, bodyFontSize)
+
+		wrapped := make([][]string, columns)
+		lineCount := 1
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			wrapped[i] = pdf.SplitText(cell, widths[i]-2*padding)
+			if len(wrapped[i]) > lineCount {
+				lineCount = len(wrapped[i])
+			}
+		}
+		height := float64(lineCount)*l.lineHeight + padding
+
+		if pdf.GetY()+height > pageHeight-pageMargin {
+			pdf.AddPage()
+		}
+		y := pdf.GetY()
+		x := left
+		for i := 0; i < columns; i++ {
+			fill := "D"
+			if r < header || (r-header)%2 == 1 {
+				pdf.SetFillColor(l.colors.codeBg[0], l.colors.codeBg[1], l.colors.codeBg[2])
+				fill = "FD"
+			}
+			pdf.Rect(x, y, widths[i], height, fill)
+			pdf.SetTextColor(l.color[0], l.color[1], l.color[2])
+			for j, line := range wrapped[i] {
+				pdf.SetXY(x+padding, y+padding/2+float64(j)*l.lineHeight)
+				pdf.CellFormat(widths[i]-2*padding, l.lineHeight, line, "", 0, "L", false, 0, "")
+			}
+			x += widths[i]
+		}
+		pdf.SetXY(left, y+height)
+	}
+	pdf.Ln(blockGap * 1.5)
+	l.applyFont()
+}
+
+// image renders a block-level image scaled to the page width. It reports
+// false if the image cannot be embedded (remote, missing or unsupported).
+func (l *layout) image(img *ast.Image) bool {
+	pdf := l.pdf
+	dest := string(img.Destination)
+	if strings.Contains(dest, "://") || strings.HasPrefix(dest, "data:") {
+		return false
+	}
+
+	path := dest
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(l.chapter.Entry.Path), filepath.FromSlash(dest))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	_, format, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil {
+		return false
+	}
+
+	options := fpdf.ImageOptions{ImageType: format, ReadDpi: true}
+	info := pdf.RegisterImageOptions(path, options)
+	if info == nil || pdf.Err() {
+		pdf.ClearError()
+		return false
+	}
+
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	maxWidth := pageWidth - left - right
+	maxHeight := pageHeight - 2*pageMargin - 10
+
+	w, h := info.Width(), info.Height()
+	if w > maxWidth {
+		h = h * maxWidth / w
+		w = maxWidth
+	}
+	if h > maxHeight {
+		w = w * maxHeight / h
+		h = maxHeight
+	}
+
+	if pdf.GetY()+h > pageHeight-pageMargin {
+		pdf.AddPage()
+	}
+	y := pdf.GetY()
+	pdf.ImageOptions(path, left, y, w, h, false, options, 0, "")
+	pdf.SetXY(left, y+h)
+	pdf.Ln(blockGap * 1.5)
+	return true
+}
+
+// rule draws a horizontal line across the content area.
+func (l *layout) rule(width float64) {
+	pdf := l.pdf
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	y := pdf.GetY() + 1
+	pdf.SetDrawColor(l.colors.border[0], l.colors.border[1], l.colors.border[2])
+	pdf.SetLineWidth(width * 0.4)
+	pdf.Line(left, y, pageWidth-right, y)
+	pdf.SetLineWidth(0.2)
+	pdf.Ln(blockGap + 1)
+}
+
+// inline renders the inline children of n at the current position.
+func (l *layout) inline(n ast.Node) {
+	src := l.chapter.Source
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch node := c.(type) {
+		case *ast.Text:
+			value := node.Segment.Value(src)
+			if !node.IsRaw() {
+				value = unescape(value)
+			}
+			l.write(string(value))
+			if node.HardLineBreak() {
+				l.pdf.Ln(l.lineHeight)
+			} else if node.SoftLineBreak() {
+				l.write(" ")
+			}
+		case *ast.String:
+			if node.IsCode() {
+				// Typographer output such as &ldquo;
+				l.write(string(unescape(node.Value)))
+			} else {
+				l.write(string(node.Value))
+			}
+		case *extast.FootnoteLink:
+			l.write(fmt.Sprintf("[%d]", node.Index))
+		case *extast.FootnoteBacklink:
+			// Readers of a PDF find their own way back
+		case *emojiast.Emoji:
+			// The PDF fonts have no emoji
+			l.write(":" + string(node.ShortName) + ":")
+		case *ast.CodeSpan:
+			l.mono++
+			l.applyFont()
+			l.inline(node)
+			l.mono--
+			l.applyFont()
+		case *ast.Emphasis:
+			if node.Level >= 2 {
+				l.bold++
+			} else {
+				l.italic++
+			}
+			l.applyFont()
+			l.inline(node)
+			if node.Level >= 2 {
+				l.bold--
+			} else {
+				l.italic--
+			}
+			l.applyFont()
+		case *ast.Link:
+			l.link(string(node.Destination), func() { l.inline(node) })
+		case *ast.AutoLink:
+			url := string(node.URL(src))
+			label := string(node.Label(src))
+			if node.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(url, "mailto:") {
+				url = "mailto:" + url
+			}
+			l.link(url, func() { l.write(label) })
+		case *ast.Image:
+			l.italic++
+			l.applyFont()
+			l.write(plainText(node, src))
+			l.italic--
+			l.applyFont()
+		case *extast.Strikethrough:
+			l.strike++
+			l.applyFont()
+			l.inline(node)
+			l.strike--
+			l.applyFont()
+		case *extast.TaskCheckBox:
+			if node.IsChecked {
+				l.write("[x] ")
+			} else {
+				l.write("[ ] ")
+			}
+		case *ast.RawHTML:
+			// Raw HTML cannot be represented in the PDF
+		default:
+			l.inline(c)
+		}
+	}
+}
+
+// link renders content as a link to dest. Links to headings and other
+// files in the document become internal links.
+func (l *layout) link(dest string, content func()) {
+	savedColor, savedID, savedURL := l.color, l.linkID, l.linkURL
+
+	switch {
+	case strings.HasPrefix(dest, "#"):
+		l.linkID = l.links[headingKey(l.chapter.Entry.ID, dest[1:])]
+	case strings.Contains(dest, ":"):
+		l.linkURL = dest
+	default:
+		if id, fragment, ok := l.rewriter.Resolve(dest, l.chapter.Entry.RelPath); ok {
+			l.linkID = l.links[headingKey(id, fragment)]
+			if l.linkID == 0 {
+				l.linkID = l.links[headingKey(id, "")]
+			}
+		}
+	}
+
+	if l.linkID != 0 || l.linkURL != "" {
+		l.color = l.colors.link
+	}
+	l.applyFont()
+	content()
+
+	l.color, l.linkID, l.linkURL = savedColor, savedID, savedURL
+	l.applyFont()
+}
+
+// write prints text with the current inline formatting.
+func (l *layout) write(text string) {
+	if text == "" {
+		return
+	}
+	text = l.text(text)
+	switch {
+	case l.linkID != 0:
+		l.pdf.WriteLinkID(l.lineHeight, text, l.linkID)
+	case l.linkURL != "":
+		l.pdf.WriteLinkString(l.lineHeight, text, l.linkURL)
+	default:
+		l.pdf.Write(l.lineHeight, text)
+	}
+}
+
+// withFont renders content with a different font size and line height.
+func (l *layout) withFont(size, lineHeight float64, content func()) {
+	savedSize, savedLineHeight := l.size, l.lineHeight
+	l.size, l.lineHeight = size, lineHeight
+	l.applyFont()
+	content()
+	l.size, l.lineHeight = savedSize, savedLineHeight
+	l.applyFont()
+}
+
+// applyFont selects the font and colour for the current inline state.
+func (l *layout) applyFont() {
+	family := sansFont
+	size := l.size
+	if l.mono > 0 {
+		family = monoFont
+		size = l.size * 0.9
+	}
+
+	style := ""
+	if l.bold > 0 {
+		style += "B"
+	}
+	if l.italic > 0 {
+		style += "I"
+	}
+	if l.strike > 0 {
+		style += "S"
+	}
+	if l.linkID != 0 || l.linkURL != "" {
+		style += "U"
+	}
+
+	l.pdf.SetFont(family, style, size)
+	l.pdf.SetTextColor(l.color[0], l.color[1], l.color[2])
+}
+
+// soleImage returns the image if it is the only content of a paragraph.
+func soleImage(p *ast.Paragraph) *ast.Image {
+	if p.ChildCount() != 1 {
+		return nil
+	}
+	img, _ := p.FirstChild().(*ast.Image)
+	return img
+}
+
+// plainText returns the text content of n without formatting.
+func plainText(n ast.Node, src []byte) string {
+	var buf strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			writeText(&buf, c, src)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// writeText writes the text of a single inline node, not its children.
+func writeText(buf *strings.Builder, n ast.Node, src []byte) {
+	switch node := n.(type) {
+	case *ast.Text:
+		value := node.Segment.Value(src)
+		if !node.IsRaw() {
+			value = unescape(value)
+		}
+		buf.Write(value)
+		if node.SoftLineBreak() || node.HardLineBreak() {
+			buf.WriteByte(' ')
+		}
+	case *ast.String:
+		buf.Write(node.Value)
+	case *ast.AutoLink:
+		buf.Write(node.Label(src))
+	}
+}
+
+// unescape resolves backslash escapes and character references the same
+// way the HTML renderer does.
+func unescape(b []byte) []byte {
+	b = util.UnescapePunctuations(b)
+	b = util.ResolveNumericReferences(b)
+	return util.ResolveEntityNames(b)
+}
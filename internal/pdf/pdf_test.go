@@ -0,0 +1,285 @@
+package pdf
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+
+	"mdp/internal/converter"
+	"mdp/internal/filetree"
+)
+
+// chapter parses source the same way the converter does.
+func chapter(id, relPath, source string) Chapter {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+	src := []byte(source)
+	return Chapter{
+		Entry:  filetree.FileEntry{ID: id, Name: strings.TrimSuffix(relPath, ".md"), RelPath: relPath},
+		Source: src,
+		Root:   md.Parser().Parse(text.NewReader(src)),
+	}
+}
+
+// pageCount counts the page objects in a PDF.
+func pageCount(data []byte) int {
+	return bytes.Count(data, []byte("/Type /Page\n"))
+}
+
+func TestWrite_Basic(t *testing.T) {
+	doc := Document{
+		Title: "Manual",
+		Chapters: []Chapter{
+			chapter("intro-md", "intro.md", "# Intro\n\nSome **bold** and `code`.\n\n```go\nfunc main() {}\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |\n"),
+		},
+		Created: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Fatalf("expected PDF header, got %q", data[:min(len(data), 8)])
+	}
+	// Cover, table of contents and one content page
+	if got := pageCount(data); got != 3 {
+		t.Errorf("expected 3 pages, got %d", got)
+	}
+	if !bytes.Contains(data, []byte("/Outlines")) {
+		t.Error("expected document outline for bookmarks")
+	}
+}
+
+func TestWrite_NoChapters(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Document{Title: "Empty"}); err == nil {
+		t.Error("expected error for document without chapters")
+	}
+}
+
+func TestWrite_PageBreaks(t *testing.T) {
+	chapters := []Chapter{
+		chapter("a-md", "a.md", "# A\n\nSee [b](b.md#b).\n"),
+		chapter("b-md", "b.md", "# B\n\nBack to [a](a.md).\n"),
+	}
+
+	var continuous, broken bytes.Buffer
+	if err := Write(&continuous, Document{Title: "T", Chapters: chapters}); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if err := Write(&broken, Document{Title: "T", Chapters: chapters, PageBreaks: true}); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	if got := pageCount(continuous.Bytes()); got != 3 {
+		t.Errorf("expected chapters to share a page, got %d pages", got)
+	}
+	if got := pageCount(broken.Bytes()); got != 4 {
+		t.Errorf("expected each chapter on its own page, got %d pages", got)
+	}
+}
+
+func TestWrite_LongDocument(t *testing.T) {
+	var src strings.Builder
+	for i := 0; i < 40; i++ {
+		src.WriteString("## Section\n\nLorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor.\n\n")
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, Document{Title: "Long", Chapters: []Chapter{chapter("long-md", "long.md", src.String())}})
+	if err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if got := pageCount(buf.Bytes()); got < 4 {
+		t.Errorf("expected content to span several pages, got %d pages", got)
+	}
+}
+
+func TestWrite_Extensions(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote, extension.DefinitionList, extension.Typographer, emoji.Emoji))
+	src := []byte("# Terms\n\n\"Quoted\" -- text...[^1] :rocket:\n\n```go title=\"main.go\" {1}\npackage main\n```\n\nTerm\n: Definition one\n: Definition two\n\n[^1]: A note.\n")
+	doc := Document{
+		Title: "Manual",
+		Chapters: []Chapter{{
+			Entry:  filetree.FileEntry{ID: "terms-md", Name: "terms", RelPath: "terms.md"},
+			Source: src,
+			Root:   md.Parser().Parse(text.NewReader(src)),
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if got := pageCount(buf.Bytes()); got != 3 {
+		t.Errorf("expected 3 pages, got %d", got)
+	}
+}
+
+func TestWrite_Backlinks(t *testing.T) {
+	doc := Document{
+		Title: "Manual",
+		Chapters: []Chapter{
+			chapter("a-md", "a.md", "# A\n\nIntro. See [the guide](docs/guide.md#usage) for *more*. Done.\n\n- Also [guide](docs/guide.md).\n"),
+			chapter("docs-guide-md", "docs/guide.md", "# Guide\n\n## Usage\n\nBack to [A](../a.md) and [itself](guide.md).\n"),
+		},
+	}
+	if err := Write(io.Discard, doc); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	l, err := render(doc, nil)
+	if err != nil {
+		t.Fatalf("render() returned error: %v", err)
+	}
+	backlinks := l.collectBacklinks()
+	guide := backlinks["docs-guide-md"]
+	if len(guide) != 1 || guide[0].ID != "a-md" || guide[0].RelPath != "a.md" {
+		t.Fatalf("backlinks to the guide = %+v, want one from a.md", guide)
+	}
+	if want := []string{"See the guide for more.", "Also guide."}; !slices.Equal(guide[0].Excerpts, want) {
+		t.Errorf("excerpts = %q, want %q", guide[0].Excerpts, want)
+	}
+	if a := backlinks["a-md"]; len(a) != 1 || a[0].ID != "docs-guide-md" {
+		t.Errorf("backlinks to a.md = %+v, want one from the guide", a)
+	}
+}
+
+func TestWrite_WikiEmbed(t *testing.T) {
+	conv := converter.New(converter.WithWikiLinks(
+		[]converter.WikiPage{{RelPath: "a.md"}, {RelPath: "notes.md", Title: "Notes"}},
+		func(relPath string) ([]byte, error) { return []byte("# Notes\n\nShared.\n"), nil },
+	))
+	parsed := func(id, relPath, source string) Chapter {
+		c := chapter(id, relPath, source)
+		c.Root = conv.ParseFile(c.Source, relPath)
+		return c
+	}
+	links := func(source string) int {
+		doc := Document{
+			Title:    "Manual",
+			Chapters: []Chapter{parsed("a-md", "a.md", source), parsed("notes-md", "notes.md", "# Notes\n\nShared.\n")},
+		}
+		var buf bytes.Buffer
+		if err := Write(&buf, doc); err != nil {
+			t.Fatalf("Write() returned error: %v", err)
+		}
+		return bytes.Count(buf.Bytes(), []byte("/Subtype /Link"))
+	}
+
+	without, with := links("# A\n\nIntro.\n"), links("# A\n\n![[Notes]]\n")
+	if with != without+1 {
+		t.Errorf("link annotations = %d with the embed, %d without; want the embed to link to its chapter", with, without)
+	}
+}
+
+func TestWrite_Dark(t *testing.T) {
+	doc := Document{
+		Title:    "Manual",
+		Chapters: []Chapter{chapter("a-md", "a.md", "# A\n\n```go\nfunc main() {}\n```\n")},
+		Dark:     true,
+	}
+	if err := Write(io.Discard, doc); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	l, err := render(doc, nil)
+	if err != nil {
+		t.Fatalf("render() returned error: %v", err)
+	}
+	if l.colors != darkPalette {
+		t.Errorf("colors = %v, want the dark palette", l.colors)
+	}
+}
+
+func TestWrite_Unicode(t *testing.T) {
+	doc := Document{
+		Title: "Руководство",
+		Chapters: []Chapter{
+			chapter("ru-md", "ru.md", "# Привет, мир\n\nΚαλημέρα — “quoted” … `код`\u200b\n\n```\nfmt.Println(\"Привет\")\n```\n"),
+		},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("/FontFile2")) {
+		t.Error("expected the fonts to be embedded")
+	}
+
+	tests := []struct {
+		name string
+		doc  Document
+		want []string
+	}{
+		{"chapter", Document{Title: "T", Chapters: []Chapter{chapter("ja-md", "docs/ja.md", "# 日本語\n\n本\n")}}, []string{`docs/ja.md contains "日" (U+65E5) and 2 other characters that the PDF fonts cannot show`}},
+		{"code", Document{Title: "T", Chapters: []Chapter{chapter("a-md", "a.md", "Done ✅\n\n```\n// 漢\n```\n")}}, []string{`a.md contains "✅" (U+2705) and 1 other character`}},
+		{"title", Document{Title: "手册", Chapters: []Chapter{chapter("a-md", "a.md", "# A\n")}}, []string{`the title contains "手" (U+624B) and 1 other character`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []string
+			tt.doc.Warn = func(msg string) { warnings = append(warnings, msg) }
+			if err := Write(io.Discard, tt.doc); err != nil {
+				t.Fatalf("Write() returned error: %v", err)
+			}
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %d", warnings, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(warnings[i], want) || !strings.Contains(warnings[i], "printed as \"\uFFFD\"") {
+					t.Errorf("warning = %q, want it to contain %q", warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapTokens(t *testing.T) {
+	line := []codeToken{{text: "abcd"}, {text: "efg"}}
+
+	tests := []struct {
+		maxChars int
+		expected []string
+	}{
+		{10, []string{"abcdefg"}},
+		{4, []string{"abcd", "efg"}},
+		{3, []string{"abc", "def", "g"}},
+		{0, []string{"a", "b", "c", "d", "e", "f", "g"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, visual := range wrapTokens(line, tt.maxChars) {
+			var s strings.Builder
+			for _, tok := range visual {
+				s.WriteString(tok.text)
+			}
+			got = append(got, s.String())
+		}
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("wrapTokens(maxChars=%d) = %v, want %v", tt.maxChars, got, tt.expected)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	ch := chapter("x-md", "x.md", "# Hello *world* &amp; `code`\n")
+	heading := ch.Root.FirstChild()
+	if got := plainText(heading, ch.Source); got != "Hello world & code" {
+		t.Errorf("plainText() = %q, want %q", got, "Hello world & code")
+	}
+}
@@ -16,7 +16,12 @@ import (
 	"mdp/internal/browser"
 	"mdp/internal/converter"
 	"mdp/internal/filetree"
+	"mdp/internal/git"
+	"mdp/internal/include"
 	"mdp/internal/linkrewriter"
+	"mdp/internal/lint"
+	"mdp/internal/notebook"
+	"mdp/internal/spell"
 	"mdp/internal/template"
 )
 
@@ -34,27 +39,128 @@ type Server struct {
 	files     []string
 	baseDir   string
 	conv      *converter.Converter
+	linter    *lint.Linter
 	watcher   *fsnotify.Watcher
 	clients   map[*websocket.Conn]bool
 	clientsMu sync.RWMutex
 	htmlCache string
 	cacheMu   sync.RWMutex
+
+	slidesCache string
+	slides      bool
+	gitDir      string // Watched for staging and commits, empty outside a repository
+
+	dict      *spell.Dictionary // Nil turns spell checking off
+	wordLists []string          // Reloaded into dict when they change
+	speller   *spell.Checker
+
+	includes  bool            // Expand include directives
+	included  map[string]bool // Files included by the previewed files
+	includeMu sync.Mutex
+
+	notebookOpts []notebook.Option
+
+	templateOpts []template.Option
+}
+
+// Option configures optional Server behavior.
+type Option func(*options)
+
+type options struct {
+	converterOpts []converter.Option
+	templateOpts  []template.Option
+	lintOpts      []lint.Option
+	notebookOpts  []notebook.Option
+	dict          *spell.Dictionary
+	wordLists     []string
+	slides        bool
+	includes      bool
+}
+
+// WithConverterOptions sets the options used to convert markdown.
+func WithConverterOptions(opts ...converter.Option) Option {
+	return func(o *options) {
+		o.converterOpts = append(o.converterOpts, opts...)
+	}
+}
+
+// WithTemplateOptions sets the options used to generate pages.
+func WithTemplateOptions(opts ...template.Option) Option {
+	return func(o *options) {
+		o.templateOpts = append(o.templateOpts, opts...)
+	}
+}
+
+// WithLintOptions sets the rules used for the lint panel.
+func WithLintOptions(opts ...lint.Option) Option {
+	return func(o *options) {
+		o.lintOpts = append(o.lintOpts, opts...)
+	}
+}
+
+// WithNotebookOptions sets the options used to convert notebooks.
+func WithNotebookOptions(opts ...notebook.Option) Option {
+	return func(o *options) {
+		o.notebookOpts = append(o.notebookOpts, opts...)
+	}
+}
+
+// WithSpelling underlines words not in dict, or in the word list files,
+// in the preview. The word lists are reloaded when they change.
+func WithSpelling(dict *spell.Dictionary, wordLists ...string) Option {
+	return func(o *options) {
+		o.dict = dict
+		o.wordLists = append(o.wordLists, wordLists...)
+	}
+}
+
+// WithIncludes expands include directives in the previewed files. Included
+// files are watched too, and a change to one rebuilds the preview.
+func WithIncludes() Option {
+	return func(o *options) {
+		o.includes = true
+	}
+}
+
+// WithSlides opens the browser on the slides view instead of the preview.
+// Both views are always served, at / and /slides.
+func WithSlides() Option {
+	return func(o *options) {
+		o.slides = true
+	}
 }
 
 // New creates a new live reload server.
-func New(port int, files []string) (*Server, error) {
+func New(port int, files []string, opts ...Option) (*Server, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
+	conv := converter.New(o.converterOpts...)
 	s := &Server{
-		port:    port,
-		files:   files,
-		baseDir: findCommonBase(files),
-		conv:    converter.New(),
-		watcher: watcher,
-		clients: make(map[*websocket.Conn]bool),
+		port:         port,
+		files:        files,
+		baseDir:      findCommonBase(files),
+		conv:         conv,
+		linter:       lint.New(conv, o.lintOpts...),
+		watcher:      watcher,
+		clients:      make(map[*websocket.Conn]bool),
+		templateOpts: o.templateOpts,
+		slides:       o.slides,
+		dict:         o.dict,
+		wordLists:    o.wordLists,
+		includes:     o.includes,
+		included:     make(map[string]bool),
+		notebookOpts: o.notebookOpts,
+	}
+	if err := s.loadWordLists(); err != nil {
+		return nil, err
 	}
 
 	return s, nil
@@ -86,12 +192,37 @@ func (s *Server) Start() error {
 		}
 	}
 
+	// Watch the git index and HEAD so status badges follow staging and
+	// commits. Only the directory itself is watched, not objects or refs.
+	if repo, err := git.Open(s.baseDir); err == nil {
+		if gitDir, err := repo.GitDir(); err == nil {
+			if err := s.watcher.Add(gitDir); err != nil {
+				log.Printf("Warning: could not watch git directory %s: %v", gitDir, err)
+			} else {
+				s.gitDir = gitDir
+			}
+		}
+	}
+
+	// Watch the directories of word lists, since editors often replace
+	// files rather than write them
+	for _, path := range s.wordLists {
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(dir); err != nil || dirs[dir] {
+			continue
+		}
+		if err := s.watcher.Add(dir); err != nil {
+			log.Printf("Warning: could not watch directory %s: %v", dir, err)
+		}
+	}
+
 	// Start file watcher goroutine
 	go s.watchFiles()
 
 	// Setup HTTP handlers using a new ServeMux to avoid conflicts
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/slides", s.handleSlides)
 	mux.HandleFunc("/ws", s.handleWebSocket)
 
 	// Try to find an available port
@@ -101,6 +232,9 @@ func (s *Server) Start() error {
 	}
 
 	url := fmt.Sprintf("http://localhost:%d", s.port)
+	if s.slides {
+		url += "/slides"
+	}
 
 	fmt.Printf("Starting live reload server at %s\n", url)
 	fmt.Printf("Watching %d file(s) for changes\n", len(s.files))
@@ -160,6 +294,15 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(html))
 }
 
+func (s *Server) handleSlides(w http.ResponseWriter, r *http.Request) {
+	s.cacheMu.RLock()
+	html := s.slidesCache
+	s.cacheMu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -191,9 +334,18 @@ func (s *Server) watchFiles() {
 				return
 			}
 
-			// Only react to write and create events for .md files
+			// Only react to write and create events for .md, .ipynb, .csv
+			// and .tsv files, word lists, included files and the git index
+			// and HEAD
 			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-				if strings.HasSuffix(strings.ToLower(event.Name), ".md") {
+				if s.isWordList(event.Name) {
+					log.Printf("Word list changed: %s", event.Name)
+					if err := s.loadWordLists(); err != nil {
+						log.Printf("Error loading word lists: %v", err)
+						continue
+					}
+				}
+				if strings.HasSuffix(strings.ToLower(event.Name), ".md") || notebook.IsNotebook(event.Name) || converter.IsTableFile(event.Name) || s.isGitStateFile(event.Name) || s.isWordList(event.Name) || s.isIncluded(event.Name) {
 					log.Printf("File changed: %s", event.Name)
 					if err := s.regenerateHTML(); err != nil {
 						log.Printf("Error regenerating HTML: %v", err)
@@ -212,11 +364,118 @@ func (s *Server) watchFiles() {
 	}
 }
 
+// isGitStateFile reports whether path is the git index or HEAD, which
+// change when files are staged or committed.
+func (s *Server) isGitStateFile(path string) bool {
+	if s.gitDir == "" || filepath.Dir(path) != s.gitDir {
+		return false
+	}
+	name := filepath.Base(path)
+	return name == "index" || name == "HEAD"
+}
+
+// isWordList reports whether path is one of the spell checking word lists.
+func (s *Server) isWordList(path string) bool {
+	for _, wordList := range s.wordLists {
+		if filepath.Clean(path) == filepath.Clean(wordList) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIncluded reports whether path is included by one of the previewed files.
+func (s *Server) isIncluded(path string) bool {
+	s.includeMu.Lock()
+	defer s.includeMu.Unlock()
+	return s.included[filepath.Clean(path)]
+}
+
+// markdownSource returns the Markdown to convert for content, read from
+// path: CSV and TSV files as a table, notebooks as their cells and outputs,
+// and Markdown with its include directives expanded. The directories of newly included files are
+// watched, so editing, adding or replacing one rebuilds the files that
+// include it.
+func (s *Server) markdownSource(path string, content []byte) ([]byte, error) {
+	if converter.IsTableFile(path) {
+		return converter.TableFileMarkdown(path, content), nil
+	}
+	if notebook.IsNotebook(path) {
+		return notebook.Markdown(content, s.notebookOpts...)
+	}
+	if !s.includes {
+		return content, nil
+	}
+	expanded, deps, err := include.Expand(content, path, os.ReadFile, include.WithRoot(s.baseDir))
+
+	s.includeMu.Lock()
+	defer s.includeMu.Unlock()
+	for _, dep := range deps {
+		if s.included[dep] {
+			continue
+		}
+		s.included[dep] = true
+		if err := s.watcher.Add(filepath.Dir(dep)); err != nil {
+			log.Printf("Warning: could not watch directory %s: %v", filepath.Dir(dep), err)
+		}
+	}
+	return expanded, err
+}
+
+// loadWordLists rebuilds the spell checker from the dictionary and the
+// current contents of the word lists.
+func (s *Server) loadWordLists() error {
+	if s.dict == nil {
+		return nil
+	}
+	dict := s.dict
+	if len(s.wordLists) > 0 {
+		dict = dict.Clone()
+		for _, path := range s.wordLists {
+			if err := dict.AddWordFile(path); err != nil {
+				return err
+			}
+		}
+	}
+	s.speller = spell.New(s.conv, dict)
+	return nil
+}
+
+// isMarkdown reports whether path is a Markdown file rather than a notebook
+// or a CSV or TSV file.
+func isMarkdown(path string) bool {
+	return !notebook.IsNotebook(path) && !converter.IsTableFile(path)
+}
+
+// lintProblems returns the lint problems in a file. Notebooks, CSV and TSV
+// files are not Markdown and are not linted.
+func (s *Server) lintProblems(path string, content []byte) []lint.Problem {
+	if !isMarkdown(path) {
+		return nil
+	}
+	return s.linter.Lint(path, content)
+}
+
+// misspellings returns the misspelled words in a file, or nil if spell
+// checking is off or the file is not Markdown.
+func (s *Server) misspellings(path string, content []byte) []spell.Misspelling {
+	if s.speller == nil || !isMarkdown(path) {
+		return nil
+	}
+	return s.speller.Check(path, content)
+}
+
 func (s *Server) regenerateHTML() error {
+	var err error
 	if len(s.files) == 1 {
-		return s.regenerateSingleFile()
+		err = s.regenerateSingleFile()
+	} else {
+		err = s.regenerateMultiFile()
+	}
+	if err != nil {
+		return err
 	}
-	return s.regenerateMultiFile()
+	return s.regenerateSlides()
 }
 
 func (s *Server) regenerateSingleFile() error {
@@ -226,8 +485,12 @@ func (s *Server) regenerateSingleFile() error {
 	if err != nil {
 		return fmt.Errorf("error reading file: %w", err)
 	}
+	source, err := s.markdownSource(filePath, content)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
 
-	htmlContent, err := s.conv.Convert(content)
+	htmlContent, err := s.conv.ConvertFile(source, filepath.Base(filePath))
 	if err != nil {
 		return fmt.Errorf("error converting markdown: %w", err)
 	}
@@ -235,7 +498,13 @@ func (s *Server) regenerateSingleFile() error {
 	filename := filepath.Base(filePath)
 	title := strings.TrimSuffix(filename, filepath.Ext(filename))
 
-	html := template.GenerateWithLiveReload(title, htmlContent, s.port)
+	opts := append(s.viewOptions("/slides"),
+		template.WithLintProblems(s.lintProblems(filePath, content)),
+		template.WithMisspellings(s.misspellings(filePath, content)))
+	if info, ok := git.Lookup("", []string{filePath})[filePath]; ok {
+		opts = append(opts, template.WithFileMeta(info.Status, info.Author, info.Date))
+	}
+	html := template.GenerateWithLiveReload(title, htmlContent, s.port, opts...)
 
 	s.cacheMu.Lock()
 	s.htmlCache = html
@@ -246,6 +515,8 @@ func (s *Server) regenerateSingleFile() error {
 
 func (s *Server) regenerateMultiFile() error {
 	var entries []filetree.FileEntry
+	var problems []lint.Problem
+	var misspellings []spell.Misspelling
 
 	for _, path := range s.files {
 		content, err := os.ReadFile(path)
@@ -253,14 +524,21 @@ func (s *Server) regenerateMultiFile() error {
 			return fmt.Errorf("error reading %s: %w", path, err)
 		}
 
-		htmlContent, err := s.conv.Convert(content)
+		source, err := s.markdownSource(path, content)
 		if err != nil {
-			return fmt.Errorf("error converting %s: %w", path, err)
+			return fmt.Errorf("error reading %s: %w", path, err)
 		}
 
 		relPath := strings.TrimPrefix(path, s.baseDir)
 		relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
 
+		htmlContent, err := s.conv.ConvertFile(source, relPath)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", path, err)
+		}
+		problems = append(problems, s.lintProblems(path, content)...)
+		misspellings = append(misspellings, s.misspellings(path, content)...)
+
 		entries = append(entries, filetree.FileEntry{
 			ID:      sanitizeID(relPath),
 			Path:    path,
@@ -270,15 +548,30 @@ func (s *Server) regenerateMultiFile() error {
 		})
 	}
 
-	// Rewrite relative .md links to fragment identifiers
+	// Collect backlinks, then rewrite relative .md links to fragment
+	// identifiers and make heading IDs unique across files
 	rewriter := linkrewriter.New(entries)
+	backlinks := rewriter.Backlinks(entries)
 	for i := range entries {
+		entries[i].Backlinks = backlinks[entries[i].ID]
 		entries[i].Content = rewriter.RewriteLinks(entries[i].Content, entries[i].RelPath)
+		entries[i].Content = linkrewriter.ScopeHeadingIDs(entries[i].Content, entries[i].ID)
+	}
+
+	// Badge changed files and show their last commit
+	info := git.Lookup("", s.files)
+	for i := range entries {
+		if fi, ok := info[entries[i].Path]; ok {
+			entries[i].Status = fi.Status
+			entries[i].Author = fi.Author
+			entries[i].Modified = fi.Date
+		}
 	}
 
 	tree := filetree.BuildTree(entries)
 	title := s.generateTitle()
-	html := template.GenerateMultiWithLiveReload(title, tree, entries, s.port)
+	opts := append(s.viewOptions("/slides"), template.WithLintProblems(problems), template.WithMisspellings(misspellings))
+	html := template.GenerateMultiWithLiveReload(title, tree, entries, s.port, opts...)
 
 	s.cacheMu.Lock()
 	s.htmlCache = html
@@ -287,6 +580,51 @@ func (s *Server) regenerateMultiFile() error {
 	return nil
 }
 
+// regenerateSlides renders all files as one presentation.
+func (s *Server) regenerateSlides() error {
+	var slides []converter.Slide
+	for _, path := range s.files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		content, err = s.markdownSource(path, content)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		relPath := strings.TrimPrefix(path, s.baseDir)
+		relPath = strings.TrimPrefix(relPath, string(filepath.Separator))
+
+		fileSlides, err := s.conv.ConvertSlides(content, relPath)
+		if err != nil {
+			return fmt.Errorf("error converting %s: %w", path, err)
+		}
+		slides = append(slides, fileSlides...)
+	}
+
+	title := s.generateTitle()
+	if len(s.files) == 1 {
+		filename := filepath.Base(s.files[0])
+		title = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	html := template.GenerateSlidesWithLiveReload(title, slides, s.port, s.viewOptions("/")...)
+
+	s.cacheMu.Lock()
+	s.slidesCache = html
+	s.cacheMu.Unlock()
+
+	return nil
+}
+
+// viewOptions returns the template options for the preview or the slides,
+// linking to the other view at url.
+func (s *Server) viewOptions(url string) []template.Option {
+	opts := make([]template.Option, 0, len(s.templateOpts)+1)
+	opts = append(opts, s.templateOpts...)
+	return append(opts, template.WithViewToggle(url))
+}
+
 func (s *Server) notifyClients() {
 	s.clientsMu.RLock()
 	defer s.clientsMu.RUnlock()
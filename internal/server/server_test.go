@@ -5,12 +5,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"mdp/internal/converter"
+	"mdp/internal/lint"
+	"mdp/internal/spell"
+	"mdp/internal/template"
 )
 
 func TestNew(t *testing.T) {
@@ -280,6 +286,42 @@ func TestServer_regenerateSingleFile(t *testing.T) {
 	}
 }
 
+func TestServer_SafeMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "test.md")
+	content := "# Untrusted\n\n<img src=x onerror=\"alert(1)\">"
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	srv, err := New(8080, []string{tmpFile},
+		WithConverterOptions(converter.WithSafeMode()),
+		WithTemplateOptions(template.WithSafeMode()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateHTML(); err != nil {
+		t.Fatalf("regenerateHTML() error = %v", err)
+	}
+
+	srv.cacheMu.RLock()
+	html := srv.htmlCache
+	srv.cacheMu.RUnlock()
+
+	if strings.Contains(html, "onerror") {
+		t.Error("safe mode should omit raw HTML")
+	}
+	if !strings.Contains(html, "Content-Security-Policy") {
+		t.Error("safe mode should add a Content-Security-Policy")
+	}
+	if !strings.Contains(html, "connect-src ws://localhost:8080") {
+		t.Error("Content-Security-Policy should allow the live reload connection")
+	}
+}
+
 func TestServer_regenerateMultiFile(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -322,6 +364,100 @@ func TestServer_regenerateMultiFile(t *testing.T) {
 	}
 }
 
+func TestServer_Backlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	file1 := filepath.Join(tmpDir, "readme.md")
+	file2 := filepath.Join(tmpDir, "guide.md")
+	if err := os.WriteFile(file1, []byte("# README\n\nRead the [guide](guide.md) next.\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte("# Guide\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	srv, err := New(8080, []string{file1, file2})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateMultiFile(); err != nil {
+		t.Fatalf("regenerateMultiFile() error = %v", err)
+	}
+
+	srv.cacheMu.RLock()
+	html := srv.htmlCache
+	srv.cacheMu.RUnlock()
+
+	if !strings.Contains(html, `<a class="backlinks-file" href="#readme-md" title="readme.md">readme</a><p class="backlinks-excerpt">Read the guide next.</p>`) {
+		t.Error("expected the guide to list the readme in its backlinks")
+	}
+}
+
+func TestServer_Includes(t *testing.T) {
+	tmpDir := t.TempDir()
+	sharedDir := filepath.Join(tmpDir, "shared")
+	if err := os.Mkdir(sharedDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	snippet := filepath.Join(sharedDir, "install.txt")
+	file := filepath.Join(tmpDir, "readme.md")
+	if err := os.WriteFile(snippet, []byte("# Install\n\nRun the installer.\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	script := filepath.Join(sharedDir, "install.sh")
+	if err := os.WriteFile(script, []byte("make install\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("# Readme\n\n<!-- include: shared/install.txt shift=1 -->\n\n<!-- include-code: shared/install.sh -->\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	srv, err := New(8080, []string{file}, WithIncludes())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateHTML(); err != nil {
+		t.Fatalf("regenerateHTML() error = %v", err)
+	}
+	srv.cacheMu.RLock()
+	html, slides := srv.htmlCache, srv.slidesCache
+	srv.cacheMu.RUnlock()
+
+	if !strings.Contains(html, `<h2 id="install">Install</h2>`) || !strings.Contains(html, "Run the installer.") {
+		t.Error("expected the preview to contain the included file with its heading shifted")
+	}
+	if !strings.Contains(slides, "Run the installer.") {
+		t.Error("expected the slides to contain the included file")
+	}
+	for _, path := range []string{snippet, script} {
+		if !srv.isIncluded(path) {
+			t.Errorf("expected %s to be watched as an included file", path)
+		}
+	}
+	if !strings.Contains(html, "make install") {
+		t.Error("expected the preview to contain the included code")
+	}
+	if srv.isIncluded(filepath.Join(sharedDir, "other.txt")) {
+		t.Error("expected files that are not included to be ignored")
+	}
+
+	// Without WithIncludes the directive is left alone
+	plain, err := New(8080, []string{file})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer plain.Stop()
+	if err := plain.regenerateSingleFile(); err != nil {
+		t.Fatalf("regenerateSingleFile() error = %v", err)
+	}
+	if strings.Contains(plain.htmlCache, "Run the installer.") {
+		t.Error("expected includes to be off by default")
+	}
+}
+
 func TestServer_regenerateHTML_NonExistentFile(t *testing.T) {
 	srv, err := New(8080, []string{"/nonexistent/file.md"})
 	if err != nil {
@@ -487,3 +623,243 @@ func TestServer_findAvailablePort(t *testing.T) {
 		}
 	})
 }
+
+func TestServer_handleSlides(t *testing.T) {
+	tmpDir := t.TempDir()
+	file1 := filepath.Join(tmpDir, "a.md")
+	file2 := filepath.Join(tmpDir, "b.md")
+	if err := os.WriteFile(file1, []byte("# First\n\n---\n\n# Second\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte("# Third\n\nNote: from b.md\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	srv, err := New(8080, []string{file1, file2}, WithSlides())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateHTML(); err != nil {
+		t.Fatalf("Failed to regenerate HTML: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleSlides(rec, httptest.NewRequest(http.MethodGet, "/slides", nil))
+
+	body := rec.Body.String()
+	if n := strings.Count(body, `<section class="slide markdown-body"`); n != 3 {
+		t.Errorf("handleSlides() rendered %d slides, want 3", n)
+	}
+	if !strings.Contains(body, "<p>from b.md</p>") {
+		t.Error("handleSlides() should include speaker notes")
+	}
+	if !strings.Contains(body, `slides-exit-btn" href="/"`) {
+		t.Error("handleSlides() should link back to the preview")
+	}
+	if !strings.Contains(body, "ws://localhost:8080/ws") {
+		t.Error("handleSlides() should live reload")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `topbar-present-btn" href="/slides"`) {
+		t.Error("handleIndex() should link to the slides view")
+	}
+}
+
+func TestServer_GitStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", tmpDir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	file1 := filepath.Join(tmpDir, "a.md")
+	file2 := filepath.Join(tmpDir, "b.md")
+	if err := os.WriteFile(file1, []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	git("add", "a.md")
+	git("commit", "-q", "-m", "Add a")
+	if err := os.WriteFile(file2, []byte("# B"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(8080, []string{file1, file2})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateHTML(); err != nil {
+		t.Fatalf("Failed to regenerate HTML: %v", err)
+	}
+	srv.cacheMu.RLock()
+	html := srv.htmlCache
+	srv.cacheMu.RUnlock()
+	if !strings.Contains(html, `data-file="b-md" data-status="untracked"`) {
+		t.Error("regenerateHTML() should badge untracked files")
+	}
+	if !strings.Contains(html, "</time> by Test") {
+		t.Error("regenerateHTML() should show the last commit")
+	}
+
+	srv.gitDir = filepath.Join(tmpDir, ".git")
+	tests := map[string]bool{
+		filepath.Join(tmpDir, ".git", "index"):      true,
+		filepath.Join(tmpDir, ".git", "HEAD"):       true,
+		filepath.Join(tmpDir, ".git", "index.lock"): false,
+		filepath.Join(tmpDir, "index"):              false,
+	}
+	for path, want := range tests {
+		if got := srv.isGitStateFile(path); got != want {
+			t.Errorf("isGitStateFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestServer_LintPanel(t *testing.T) {
+	tmpDir := t.TempDir()
+	file1 := filepath.Join(tmpDir, "a.md")
+	file2 := filepath.Join(tmpDir, "b.md")
+	if err := os.WriteFile(file1, []byte("# A\n\n### Skipped\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte("# B\n\n```\ncode\n```\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	srv, err := New(8080, []string{file1, file2}, WithLintOptions(lint.WithDisabled("MD040")))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateHTML(); err != nil {
+		t.Fatalf("Failed to regenerate HTML: %v", err)
+	}
+	srv.cacheMu.RLock()
+	html := srv.htmlCache
+	srv.cacheMu.RUnlock()
+
+	if !strings.Contains(html, `<a class="lint-location" href="#a-md">a.md:3:1</a>`) {
+		t.Error("regenerateHTML() should show lint problems")
+	}
+	if strings.Contains(html, ">MD040<") {
+		t.Error("regenerateHTML() should skip disabled rules")
+	}
+}
+
+func TestServer_CSVAndNotebooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	file1 := filepath.Join(tmpDir, "a.md")
+	file2 := filepath.Join(tmpDir, "people.csv")
+	file3 := filepath.Join(tmpDir, "analysis.ipynb")
+	if err := os.WriteFile(file1, []byte("# A\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := os.WriteFile(file2, []byte("name,team\nAda,core\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	nb := `{"nbformat": 4, "metadata": {}, "cells": [{"cell_type": "markdown", "metadata": {}, "source": "Notebook text"}]}`
+	if err := os.WriteFile(file3, []byte(nb), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	srv, err := New(8080, []string{file1, file2, file3})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.regenerateHTML(); err != nil {
+		t.Fatalf("Failed to regenerate HTML: %v", err)
+	}
+	srv.cacheMu.RLock()
+	html := srv.htmlCache
+	srv.cacheMu.RUnlock()
+
+	if !strings.Contains(html, "<td>Ada</td>") {
+		t.Error("regenerateHTML() should render CSV files as tables")
+	}
+	if !strings.Contains(html, "<p>Notebook text</p>") {
+		t.Error("regenerateHTML() should render notebooks")
+	}
+	if strings.Contains(html, "people.csv:1:1") || strings.Contains(html, "analysis.ipynb:1:1") {
+		t.Error("regenerateHTML() should not lint CSV files or notebooks")
+	}
+}
+
+func TestServer_Spelling(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "a.md")
+	if err := os.WriteFile(file, []byte("# Title\n\nThe zorp and the blix.\n"), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	wordList := filepath.Join(tmpDir, "words.txt")
+	if err := os.WriteFile(wordList, []byte("blix\n"), 0644); err != nil {
+		t.Fatalf("Failed to create word list: %v", err)
+	}
+
+	dict, err := spell.Builtin()
+	if err != nil {
+		t.Fatalf("Failed to load dictionary: %v", err)
+	}
+	srv, err := New(8080, []string{file}, WithSpelling(dict, wordList))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	spellData := func() string {
+		t.Helper()
+		if err := srv.regenerateHTML(); err != nil {
+			t.Fatalf("Failed to regenerate HTML: %v", err)
+		}
+		srv.cacheMu.RLock()
+		defer srv.cacheMu.RUnlock()
+		start := strings.Index(srv.htmlCache, `id="mdp-spell-data">`)
+		if start < 0 {
+			return ""
+		}
+		rest := srv.htmlCache[start:]
+		return rest[:strings.Index(rest, "</script>")]
+	}
+
+	data := spellData()
+	if !strings.Contains(data, `"zorp"`) || strings.Contains(data, `"blix"`) {
+		t.Errorf("expected only zorp to be misspelled, got %s", data)
+	}
+
+	if !srv.isWordList(wordList) {
+		t.Error("isWordList() should match the word list")
+	}
+	if err := os.WriteFile(wordList, []byte("blix\nzorp\n"), 0644); err != nil {
+		t.Fatalf("Failed to update word list: %v", err)
+	}
+	if err := srv.loadWordLists(); err != nil {
+		t.Fatalf("loadWordLists() error = %v", err)
+	}
+	if data := spellData(); data != "" {
+		t.Errorf("expected no misspellings after updating the word list, got %s", data)
+	}
+	if dict.Check("zorp") {
+		t.Error("word lists should not change the base dictionary")
+	}
+}
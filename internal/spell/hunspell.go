@@ -0,0 +1,457 @@
+package spell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dictionary is a word list loaded from a Hunspell dictionary: an affix
+// file (.aff) and a word file (.dic). It supports the parts of the format
+// that simple dictionaries use: PFX and SFX rules with strip strings and
+// conditions, cross products, FLAG long and num, TRY, REP and NOSUGGEST.
+// Compounding and continuation classes are ignored.
+type Dictionary struct {
+	words     map[string]bool
+	nosuggest map[string]bool // words that are accepted but never suggested
+	try       string
+	rep       [][2]string
+}
+
+// affix is one PFX or SFX rule.
+type affix struct {
+	prefix    bool
+	cross     bool
+	strip     string
+	add       string
+	condition []charClass
+}
+
+// charClass matches one character of an affix condition: a literal, a
+// [set], a [^negated set] or ".".
+type charClass struct {
+	any     bool
+	negated bool
+	chars   string
+}
+
+func (c charClass) matches(r rune) bool {
+	if c.any {
+		return true
+	}
+	return strings.ContainsRune(c.chars, r) != c.negated
+}
+
+// NewDictionary returns an empty dictionary, for word lists alone.
+func NewDictionary() *Dictionary {
+	return &Dictionary{words: make(map[string]bool), nosuggest: make(map[string]bool)}
+}
+
+// LoadDictionary reads a Hunspell dictionary from its affix and word files.
+func LoadDictionary(aff, dic io.Reader) (*Dictionary, error) {
+	d := NewDictionary()
+	affixes, flagMode, nosuggest, err := d.parseAffixes(aff)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(dic)
+	first := true
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if first {
+			// The first line is the approximate word count
+			first = false
+			if _, err := strconv.Atoi(text); err == nil {
+				continue
+			}
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		// Morphological fields follow a tab or space
+		if i := strings.IndexAny(text, "\t "); i >= 0 {
+			text = text[:i]
+		}
+		word, flags := splitFlags(text)
+		flagList := parseFlags(flags, flagMode)
+		forms := d.expand(word, flagList, affixes)
+		if nosuggest != "" && slices.Contains(flagList, nosuggest) {
+			for _, form := range forms {
+				d.nosuggest[form] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// splitFlags splits a .dic entry into the word and its flags, allowing
+// escaped slashes in the word.
+func splitFlags(entry string) (string, string) {
+	for i := 0; i < len(entry); i++ {
+		if entry[i] == '\\' && i+1 < len(entry) && entry[i+1] == '/' {
+			entry = entry[:i] + entry[i+1:]
+			continue
+		}
+		if entry[i] == '/' {
+			return entry[:i], entry[i+1:]
+		}
+	}
+	return entry, ""
+}
+
+// parseFlags splits a flag string according to the FLAG setting.
+func parseFlags(flags, mode string) []string {
+	if flags == "" {
+		return nil
+	}
+	var out []string
+	switch mode {
+	case "long":
+		for i := 0; i+1 < len(flags); i += 2 {
+			out = append(out, flags[i:i+2])
+		}
+	case "num":
+		out = strings.Split(flags, ",")
+	default:
+		for _, r := range flags {
+			out = append(out, string(r))
+		}
+	}
+	return out
+}
+
+// parseAffixes reads the affix file, returning the rules by flag, the
+// FLAG setting and the NOSUGGEST flag.
+func (d *Dictionary) parseAffixes(r io.Reader) (map[string][]affix, string, string, error) {
+	affixes := make(map[string][]affix)
+	flagMode, nosuggest := "", ""
+	cross := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "SET":
+			if len(fields) > 1 && !strings.EqualFold(fields[1], "UTF-8") {
+				return nil, "", "", fmt.Errorf("line %d: unsupported encoding %s (only UTF-8 is supported)", line, fields[1])
+			}
+		case "FLAG":
+			if len(fields) > 1 {
+				flagMode = fields[1]
+				if flagMode == "UTF-8" {
+					flagMode = ""
+				}
+			}
+		case "NOSUGGEST":
+			if len(fields) > 1 {
+				nosuggest = fields[1]
+			}
+		case "TRY":
+			if len(fields) > 1 {
+				d.try = fields[1]
+			}
+		case "REP":
+			// The first REP line is the count
+			if len(fields) > 2 {
+				d.rep = append(d.rep, [2]string{
+					strings.ReplaceAll(fields[1], "_", " "),
+					strings.ReplaceAll(fields[2], "_", " "),
+				})
+			}
+		case "PFX", "SFX":
+			if len(fields) < 4 {
+				return nil, "", "", fmt.Errorf("line %d: malformed %s rule", line, fields[0])
+			}
+			flag := fields[1]
+			// The header line is "SFX flag Y|N count"
+			if _, err := strconv.Atoi(fields[3]); err == nil && (fields[2] == "Y" || fields[2] == "N") && len(fields) == 4 {
+				cross[flag] = fields[2] == "Y"
+				continue
+			}
+			a := affix{prefix: fields[0] == "PFX", cross: cross[flag]}
+			if fields[2] != "0" {
+				a.strip = fields[2]
+			}
+			add, _ := splitFlags(fields[3])
+			if add != "0" {
+				a.add = add
+			}
+			condition := "."
+			if len(fields) > 4 {
+				condition = fields[4]
+			}
+			var err error
+			if a.condition, err = parseCondition(condition); err != nil {
+				return nil, "", "", fmt.Errorf("line %d: %v", line, err)
+			}
+			affixes[flag] = append(affixes[flag], a)
+		}
+	}
+	return affixes, flagMode, nosuggest, scanner.Err()
+}
+
+// parseCondition parses an affix condition such as "[^aeiou]y".
+func parseCondition(s string) ([]charClass, error) {
+	var classes []charClass
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			classes = append(classes, charClass{any: true})
+			i++
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated condition %q", s)
+			}
+			set := s[i+1 : i+end]
+			c := charClass{}
+			if strings.HasPrefix(set, "^") {
+				c.negated = true
+				set = set[1:]
+			}
+			c.chars = set
+			classes = append(classes, c)
+			i += end + 1
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			classes = append(classes, charClass{chars: string(r)})
+			i += size
+		}
+	}
+	return classes, nil
+}
+
+// matchesCondition reports whether word starts (prefixes) or ends
+// (suffixes) with characters matching the condition.
+func (a affix) matchesCondition(word string) bool {
+	runes := []rune(word)
+	if len(runes) < len(a.condition) {
+		return false
+	}
+	offset := 0
+	if !a.prefix {
+		offset = len(runes) - len(a.condition)
+	}
+	for i, c := range a.condition {
+		if !c.matches(runes[offset+i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply returns the word with the affix applied, if its condition holds.
+func (a affix) apply(word string) (string, bool) {
+	if !a.matchesCondition(word) {
+		return "", false
+	}
+	if a.prefix {
+		if !strings.HasPrefix(word, a.strip) {
+			return "", false
+		}
+		return a.add + word[len(a.strip):], true
+	}
+	if !strings.HasSuffix(word, a.strip) {
+		return "", false
+	}
+	return word[:len(word)-len(a.strip)] + a.add, true
+}
+
+// expand adds word and every form of it produced by its affix flags, and
+// returns them.
+func (d *Dictionary) expand(word string, flags []string, affixes map[string][]affix) []string {
+	forms := []string{word}
+	d.words[word] = true
+
+	var prefixes, suffixes []affix
+	for _, flag := range flags {
+		for _, a := range affixes[flag] {
+			if a.prefix {
+				prefixes = append(prefixes, a)
+			} else {
+				suffixes = append(suffixes, a)
+			}
+		}
+	}
+
+	var suffixed []string
+	for _, s := range suffixes {
+		if form, ok := s.apply(word); ok {
+			d.words[form] = true
+			forms = append(forms, form)
+			if s.cross {
+				suffixed = append(suffixed, form)
+			}
+		}
+	}
+	for _, p := range prefixes {
+		if form, ok := p.apply(word); ok {
+			d.words[form] = true
+			forms = append(forms, form)
+		}
+		if !p.cross {
+			continue
+		}
+		for _, form := range suffixed {
+			if combined, ok := p.apply(form); ok {
+				d.words[combined] = true
+				forms = append(forms, combined)
+			}
+		}
+	}
+	return forms
+}
+
+// AddWords adds words from a plain list, one per line. Blank lines and
+// lines starting with # are skipped.
+func (d *Dictionary) AddWords(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(word, "#") {
+			continue
+		}
+		d.Add(word)
+	}
+	return scanner.Err()
+}
+
+// Add adds words to the dictionary.
+func (d *Dictionary) Add(words ...string) {
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			d.words[word] = true
+		}
+	}
+}
+
+// Clone returns a copy of the dictionary, so words can be added to it
+// without changing d.
+func (d *Dictionary) Clone() *Dictionary {
+	c := &Dictionary{words: make(map[string]bool, len(d.words)), nosuggest: d.nosuggest, try: d.try, rep: d.rep}
+	for word := range d.words {
+		c.words[word] = true
+	}
+	return c
+}
+
+// Len returns the number of word forms in the dictionary.
+func (d *Dictionary) Len() int {
+	return len(d.words)
+}
+
+// Check reports whether word is spelled correctly. As in Hunspell, a
+// lowercase entry also accepts the word capitalized or in all caps, and a
+// capitalized entry accepts all caps, but not the other way round.
+func (d *Dictionary) Check(word string) bool {
+	word = strings.ReplaceAll(word, "’", "'")
+	if d.words[word] {
+		return true
+	}
+	lower := strings.ToLower(word)
+	switch {
+	case isCapitalized(word):
+		return d.words[lower]
+	case word == strings.ToUpper(word):
+		return d.words[lower] || d.words[capitalize(lower)]
+	}
+	return false
+}
+
+func isCapitalized(word string) bool {
+	r, size := utf8.DecodeRuneInString(word)
+	rest := word[size:]
+	return unicode.IsUpper(r) && rest == strings.ToLower(rest)
+}
+
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
+
+// maxSuggestions limits the suggestions returned for a word.
+const maxSuggestions = 5
+
+// Suggest returns likely corrections for a misspelled word: REP
+// replacements first, then words one edit away using the TRY characters.
+func (d *Dictionary) Suggest(word string) []string {
+	var suggestions []string
+	seen := map[string]bool{word: true}
+	add := func(candidate string) {
+		if len(suggestions) < maxSuggestions && !seen[candidate] && !d.nosuggest[candidate] && !d.nosuggest[strings.ToLower(candidate)] && d.Check(candidate) {
+			suggestions = append(suggestions, candidate)
+		}
+		seen[candidate] = true
+	}
+
+	capitalized := isCapitalized(word)
+	base := word
+	if capitalized {
+		base = strings.ToLower(word)
+	}
+	fix := func(candidate string) string {
+		if capitalized {
+			return capitalize(candidate)
+		}
+		return candidate
+	}
+
+	for _, rep := range d.rep {
+		for i := strings.Index(base, rep[0]); i >= 0; {
+			add(fix(base[:i] + rep[1] + base[i+len(rep[0]):]))
+			next := strings.Index(base[i+1:], rep[0])
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+
+	try := d.try
+	if try == "" {
+		try = "esianrtolcdugmphbyfvkwzxjq"
+	}
+	runes := []rune(base)
+	var buf bytes.Buffer
+	edit := func(parts ...string) string {
+		buf.Reset()
+		for _, p := range parts {
+			buf.WriteString(p)
+		}
+		return fix(buf.String())
+	}
+	// Swapped neighbours and missing or extra letters are the most
+	// common typing mistakes, so try those before replacements
+	for i := 0; i+1 < len(runes); i++ {
+		add(edit(string(runes[:i]), string(runes[i+1]), string(runes[i]), string(runes[i+2:])))
+	}
+	for i := range runes {
+		add(edit(string(runes[:i]), string(runes[i+1:])))
+	}
+	for i := 0; i <= len(runes); i++ {
+		for _, c := range try {
+			add(edit(string(runes[:i]), string(c), string(runes[i:])))
+		}
+	}
+	for i := range runes {
+		for _, c := range try {
+			if c != runes[i] {
+				add(edit(string(runes[:i]), string(c), string(runes[i+1:])))
+			}
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,37 @@
+package spell
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteText writes one misspelling per line as file:line:col.
+func WriteText(w io.Writer, misspellings []Misspelling) error {
+	for _, m := range misspellings {
+		if _, err := io.WriteString(w, m.String()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the misspellings as a JSON array.
+func WriteJSON(w io.Writer, misspellings []Misspelling) error {
+	if misspellings == nil {
+		misspellings = []Misspelling{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(misspellings)
+}
+
+// WriteList writes the distinct misspelled words, one per line, in the
+// format of a word list such as .mdp/words.txt.
+func WriteList(w io.Writer, misspellings []Misspelling) error {
+	for _, word := range Words(misspellings) {
+		if _, err := io.WriteString(w, word+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package spell checks the prose in markdown documents against Hunspell
+// dictionaries and project word lists.
+package spell
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+
+	"mdp/internal/converter"
+)
+
+//go:embed dict/en_US.aff
+var builtinAff []byte
+
+//go:embed dict/en_US.dic
+var builtinDic []byte
+
+//go:embed dict/extra.dic
+var builtinExtra []byte
+
+// Builtin returns the bundled English (US) dictionary: the SCOWL en_US
+// Hunspell dictionary, with software terms and common British spellings.
+func Builtin() (*Dictionary, error) {
+	return LoadDictionary(bytes.NewReader(builtinAff), io.MultiReader(bytes.NewReader(builtinDic), bytes.NewReader(builtinExtra)))
+}
+
+// LoadDictionaryFiles reads a Hunspell dictionary from path.aff and
+// path.dic. A path ending in either extension is accepted as well.
+func LoadDictionaryFiles(path string) (*Dictionary, error) {
+	path = strings.TrimSuffix(strings.TrimSuffix(path, ".aff"), ".dic")
+	aff, err := os.Open(path + ".aff")
+	if err != nil {
+		return nil, fmt.Errorf("Error reading dictionary: %v", err)
+	}
+	defer aff.Close()
+	dic, err := os.Open(path + ".dic")
+	if err != nil {
+		return nil, fmt.Errorf("Error reading dictionary: %v", err)
+	}
+	defer dic.Close()
+
+	d, err := LoadDictionary(aff, dic)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading dictionary %s: %v", path, err)
+	}
+	return d, nil
+}
+
+// AddWordFile adds the words in a word list file, such as .mdp/words.txt.
+// A missing file is not an error.
+func (d *Dictionary) AddWordFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading word list: %v", err)
+	}
+	defer f.Close()
+	if err := d.AddWords(f); err != nil {
+		return fmt.Errorf("Error reading word list %s: %v", path, err)
+	}
+	return nil
+}
+
+// Misspelling is a word not found in the dictionary.
+type Misspelling struct {
+	File        string   `json:"file"`
+	Line        int      `json:"line"`   // 1-based
+	Column      int      `json:"column"` // 1-based, in characters
+	Word        string   `json:"word"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// String formats the misspelling as file:line:col, as compilers do.
+func (m Misspelling) String() string {
+	s := fmt.Sprintf("%s:%d:%d: %s", m.File, m.Line, m.Column, m.Word)
+	if len(m.Suggestions) > 0 {
+		s += " (did you mean " + strings.Join(m.Suggestions, ", ") + "?)"
+	}
+	return s
+}
+
+// Checker finds misspelled words in markdown.
+type Checker struct {
+	conv *converter.Converter
+	dict *Dictionary
+}
+
+// New creates a Checker that parses markdown with conv and looks words up
+// in dict.
+func New(conv *converter.Converter, dict *Dictionary) *Checker {
+	return &Checker{conv: conv, dict: dict}
+}
+
+// Check returns the misspelled words in source, in document order. Code,
+// links, raw HTML and YAML front matter are skipped. file is only used to
+// label them.
+func (c *Checker) Check(file string, source []byte) []Misspelling {
+	skip := frontMatterEnd(source)
+	suggestions := make(map[string][]string)
+
+	var found []Misspelling
+	_ = ast.Walk(c.conv.Parse(source), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindCodeSpan, ast.KindCodeBlock, ast.KindFencedCodeBlock,
+			ast.KindHTMLBlock, ast.KindRawHTML, ast.KindLink, ast.KindAutoLink:
+			return ast.WalkSkipChildren, nil
+		}
+		text, ok := n.(*ast.Text)
+		if !ok || text.Segment.Start < skip || continues(text.PreviousSibling(), text) {
+			return ast.WalkContinue, nil
+		}
+
+		// The parser splits text at characters such as _ and *, so join
+		// adjacent text nodes to check words like snake_case whole
+		start, stop := text.Segment.Start, text.Segment.Stop
+		for next := text.NextSibling(); next != nil && continues(text, next); next = next.NextSibling() {
+			text = next.(*ast.Text)
+			stop = text.Segment.Stop
+		}
+
+		for _, w := range words(source[start:stop]) {
+			if c.dict.Check(w.text) {
+				continue
+			}
+			if _, ok := suggestions[w.text]; !ok {
+				suggestions[w.text] = c.dict.Suggest(w.text)
+			}
+			line, col := position(source, start+w.offset)
+			found = append(found, Misspelling{
+				File:        file,
+				Line:        line,
+				Column:      col,
+				Word:        w.text,
+				Suggestions: suggestions[w.text],
+			})
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+// continues reports whether next is a text node that starts where the
+// text node prev ends.
+func continues(prev, next ast.Node) bool {
+	p, ok := prev.(*ast.Text)
+	if !ok {
+		return false
+	}
+	n, ok := next.(*ast.Text)
+	return ok && !p.SoftLineBreak() && !p.HardLineBreak() && p.Segment.Stop == n.Segment.Start
+}
+
+// word is a word in a text segment, at a byte offset.
+type word struct {
+	text   string
+	offset int
+}
+
+// words splits text into the words worth checking. Tokens that look like
+// code or names rather than prose are left out: anything with digits,
+// underscores, dots or slashes inside, all-caps abbreviations, and
+// camelCase identifiers.
+func words(text []byte) []word {
+	var out []word
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+		start := i
+		for i < len(text) {
+			r, size := utf8.DecodeRune(text[i:])
+			if isWordRune(r) || isJoiner(r) && i+size < len(text) && isWordRune(firstRune(text[i+size:])) {
+				i += size
+				continue
+			}
+			break
+		}
+		token := string(text[start:i])
+		// Possessives are checked without the 's
+		token = strings.TrimSuffix(strings.TrimSuffix(token, "'s"), "’s")
+		if !looksLikeCode(token) && utf8.RuneCountInString(token) > 1 {
+			out = append(out, word{token, start})
+		}
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// isJoiner reports whether r can join two parts of one token, as in
+// "don't", "e.g" or "file.go".
+func isJoiner(r rune) bool {
+	return r == '\'' || r == '’' || r == '.' || r == '/' || r == '@'
+}
+
+func firstRune(b []byte) rune {
+	r, _ := utf8.DecodeRune(b)
+	return r
+}
+
+func looksLikeCode(token string) bool {
+	if strings.ContainsAny(token, "0123456789_./@") {
+		return true
+	}
+	if token == strings.ToUpper(token) {
+		return true // Abbreviation such as API
+	}
+	// Mixed case after the first letter, such as GitHub or camelCase
+	_, size := utf8.DecodeRuneInString(token)
+	rest := token[size:]
+	return rest != strings.ToLower(rest)
+}
+
+// frontMatterEnd returns the offset just after YAML front matter at the
+// start of source, or 0 if there is none.
+func frontMatterEnd(source []byte) int {
+	if !bytes.HasPrefix(source, []byte("---\n")) && !bytes.HasPrefix(source, []byte("---\r\n")) {
+		return 0
+	}
+	offset := bytes.IndexByte(source, '\n') + 1
+	for offset < len(source) {
+		end := bytes.IndexByte(source[offset:], '\n')
+		line := source[offset:]
+		if end >= 0 {
+			line = source[offset : offset+end]
+		}
+		if trimmed := string(bytes.TrimRight(line, " \t\r")); trimmed == "---" || trimmed == "..." {
+			if end < 0 {
+				return len(source)
+			}
+			return offset + end + 1
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+	return 0
+}
+
+// position returns the 1-based line and column of offset in source.
+func position(source []byte, offset int) (int, int) {
+	line := bytes.Count(source[:offset], []byte("\n")) + 1
+	start := bytes.LastIndexByte(source[:offset], '\n') + 1
+	return line, utf8.RuneCount(source[start:offset]) + 1
+}
+
+// Words returns the distinct misspelled words, sorted, for adding to a
+// project word list.
+func Words(misspellings []Misspelling) []string {
+	seen := make(map[string]bool)
+	var list []string
+	for _, m := range misspellings {
+		if !seen[m.Word] {
+			seen[m.Word] = true
+			list = append(list, m.Word)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
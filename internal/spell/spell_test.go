@@ -0,0 +1,260 @@
+package spell
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mdp/internal/converter"
+)
+
+const testAff = `SET UTF-8
+TRY esianrtolcdugmphbyfvkwzxjq
+REP 1
+REP f ph
+
+PFX U Y 1
+PFX U 0 un .
+
+SFX S Y 3
+SFX S 0 s [^sxy]
+SFX S y ies [^aeiou]y
+SFX S 0 es [sx]
+
+SFX D N 2
+SFX D 0 d e
+SFX D 0 ed [^e]
+`
+
+const testDic = `6
+box/S
+city/S
+happy/U
+lock/DSU
+London
+graph
+`
+
+func testDictionary(t *testing.T) *Dictionary {
+	t.Helper()
+	d, err := LoadDictionary(strings.NewReader(testAff), strings.NewReader(testDic))
+	if err != nil {
+		t.Fatalf("LoadDictionary() error = %v", err)
+	}
+	return d
+}
+
+func TestLoadDictionary_Affixes(t *testing.T) {
+	d := testDictionary(t)
+
+	for _, word := range []string{"box", "boxes", "city", "cities", "happy", "unhappy",
+		"lock", "locks", "locked", "unlock", "unlocks", "London", "graph"} {
+		if !d.Check(word) {
+			t.Errorf("Check(%q) = false, want true", word)
+		}
+	}
+	// "unlocked" needs D, which does not allow cross products
+	for _, word := range []string{"boxs", "citys", "unlocked", "happys", "london", "graphs"} {
+		if d.Check(word) {
+			t.Errorf("Check(%q) = true, want false", word)
+		}
+	}
+}
+
+func TestLoadDictionary_Errors(t *testing.T) {
+	_, err := LoadDictionary(strings.NewReader("SET ISO8859-1\n"), strings.NewReader("1\nword\n"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported encoding") {
+		t.Errorf("LoadDictionary() error = %v, want unsupported encoding", err)
+	}
+	_, err = LoadDictionary(strings.NewReader("SFX S Y 1\nSFX S 0 s [^y\n"), strings.NewReader("1\nword/S\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadDictionary() error = %v, want error on line 2", err)
+	}
+}
+
+func TestDictionary_CheckCase(t *testing.T) {
+	d := testDictionary(t)
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{"Box", true},
+		{"BOXES", true},
+		{"bOX", false},
+		{"LONDON", true},
+		{"london", false},
+	}
+	for _, tt := range tests {
+		if got := d.Check(tt.word); got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestDictionary_Suggest(t *testing.T) {
+	d := testDictionary(t)
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"grafh", "graph"}, // REP f ph
+		{"lcok", "lock"},   // swap
+		{"boxx", "box"},    // extra letter
+		{"Lodon", "London"},
+		{"Cty", "City"},
+	}
+	for _, tt := range tests {
+		got := d.Suggest(tt.word)
+		if len(got) == 0 || got[0] != tt.want {
+			t.Errorf("Suggest(%q) = %v, want %q first", tt.word, got, tt.want)
+		}
+	}
+	if got := d.Suggest("zzzzzzzz"); len(got) != 0 {
+		t.Errorf("Suggest(zzzzzzzz) = %v, want none", got)
+	}
+}
+
+func TestDictionary_NoSuggest(t *testing.T) {
+	d, err := LoadDictionary(strings.NewReader("NOSUGGEST !\nSFX S Y 1\nSFX S 0 s .\n"), strings.NewReader("2\ndarn/!S\ndam/S\n"))
+	if err != nil {
+		t.Fatalf("LoadDictionary() error = %v", err)
+	}
+	if !d.Check("darns") {
+		t.Error("expected NOSUGGEST words to be accepted")
+	}
+	if got := d.Suggest("darm"); len(got) != 1 || got[0] != "dam" {
+		t.Errorf("Suggest(darm) = %v, want [dam]", got)
+	}
+}
+
+func TestDictionary_WordLists(t *testing.T) {
+	d := testDictionary(t)
+	c := d.Clone()
+	if err := c.AddWords(strings.NewReader("# project words\ngoldmark\n\n  mdp  \n")); err != nil {
+		t.Fatal(err)
+	}
+	c.Add("Hunspell")
+	for _, word := range []string{"goldmark", "mdp", "Hunspell", "box"} {
+		if !c.Check(word) {
+			t.Errorf("Check(%q) = false after adding it", word)
+		}
+	}
+	if d.Check("goldmark") {
+		t.Error("adding words to a clone changed the original")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "words.txt")
+	if err := c.AddWordFile(path); err != nil {
+		t.Errorf("AddWordFile() for a missing file error = %v", err)
+	}
+	os.WriteFile(path, []byte("frontmatter\n"), 0644)
+	if err := c.AddWordFile(path); err != nil || !c.Check("frontmatter") {
+		t.Errorf("AddWordFile() error = %v, Check(frontmatter) = %v", err, c.Check("frontmatter"))
+	}
+}
+
+func TestLoadDictionaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "test.aff"), []byte(testAff), 0644)
+	os.WriteFile(filepath.Join(dir, "test.dic"), []byte(testDic), 0644)
+
+	for _, path := range []string{"test", "test.dic", "test.aff"} {
+		d, err := LoadDictionaryFiles(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatalf("LoadDictionaryFiles(%s) error = %v", path, err)
+		}
+		if !d.Check("cities") {
+			t.Errorf("LoadDictionaryFiles(%s) did not load the affixes", path)
+		}
+	}
+	if _, err := LoadDictionaryFiles(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadDictionaryFiles() with missing files should fail")
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	d, err := Builtin()
+	if err != nil {
+		t.Fatalf("Builtin() error = %v", err)
+	}
+	for _, word := range []string{"the", "isn't", "don't", "categories", "configured", "rendering",
+		"quickly", "unlikely", "markdown", "repository", "colour", "README", "Monday",
+		"jungle", "orchestra", "symphony", "pumpkin", "pies", "parked", "cathedral", "goroutines"} {
+		if !d.Check(word) {
+			t.Errorf("Check(%q) = false, want true", word)
+		}
+	}
+	for _, word := range []string{"teh", "recieve", "seperate", "occurence", "wich"} {
+		if d.Check(word) {
+			t.Errorf("Check(%q) = true, want false", word)
+		}
+	}
+	if got := d.Suggest("recieve"); len(got) == 0 || got[0] != "receive" {
+		t.Errorf("Suggest(recieve) = %v, want receive first", got)
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	d := testDictionary(t)
+	d.Add("a", "in", "and", "the", "is", "see", "here")
+	checker := New(converter.New(), d)
+
+	markdown := "---\ntitle: Zorp\n---\n# The boxs\n\nA cyty `zorp` and [zorp](http://zorp.example) in\nthe London's box.\n\n" +
+		"```\nzorp\n```\n\nSee file.go, API, GitHub, v2 and snake_case here. Lodon and lodon.\n\n<div>zorp</div>\n"
+	got := checker.Check("doc.md", []byte(markdown))
+
+	want := []Misspelling{
+		{File: "doc.md", Line: 4, Column: 7, Word: "boxs", Suggestions: []string{"box", "boxes"}},
+		{File: "doc.md", Line: 6, Column: 3, Word: "cyty", Suggestions: []string{"city"}},
+		{File: "doc.md", Line: 13, Column: 51, Word: "Lodon", Suggestions: []string{"London"}},
+		{File: "doc.md", Line: 13, Column: 61, Word: "lodon"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestWords(t *testing.T) {
+	misspellings := []Misspelling{{Word: "zorp"}, {Word: "blix"}, {Word: "zorp"}}
+	if got, want := Words(misspellings), []string{"blix", "zorp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Words() = %v, want %v", got, want)
+	}
+}
+
+func TestReports(t *testing.T) {
+	misspellings := []Misspelling{
+		{File: "a.md", Line: 2, Column: 5, Word: "teh", Suggestions: []string{"the", "tea"}},
+		{File: "a.md", Line: 3, Column: 1, Word: "zorp"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, misspellings); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.md:2:5: teh (did you mean the, tea?)\na.md:3:1: zorp\n"
+	if buf.String() != want {
+		t.Errorf("WriteText() = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("WriteJSON(nil) = %q, %v, want []", buf.String(), err)
+	}
+	buf.Reset()
+	WriteJSON(&buf, misspellings)
+	var decoded []Misspelling
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded, misspellings) {
+		t.Errorf("WriteJSON() round trip = %v, %v", decoded, err)
+	}
+
+	buf.Reset()
+	WriteList(&buf, append(misspellings, misspellings...))
+	if buf.String() != "teh\nzorp\n" {
+		t.Errorf("WriteList() = %q", buf.String())
+	}
+}
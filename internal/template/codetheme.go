@@ -0,0 +1,144 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// switcherCodeThemes are offered by the in-page code theme switcher, in
+// addition to the configured light and dark themes.
+var switcherCodeThemes = []string{
+	"github", "monokai", "dracula", "nord", "gruvbox",
+	"solarized-light", "solarized-dark", "vs", "xcode", "xcode-dark",
+}
+
+// IsCodeTheme reports whether name is a known Chroma style.
+func IsCodeTheme(name string) bool {
+	_, ok := styles.Registry[name]
+	return ok
+}
+
+// CodeThemes returns the names of all Chroma styles, sorted.
+func CodeThemes() []string {
+	return styles.Names()
+}
+
+// chromaStyleCSS generates the stylesheet for a Chroma style. Selectors are
+// scoped to .markdown-body so they take precedence over the GitHub styles
+// for pre and code elements.
+func chromaStyleCSS(name string) string {
+	var buf strings.Builder
+	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.ClassPrefix("hl-"))
+	if err := formatter.WriteCSS(&buf, styles.Get(name)); err != nil {
+		return ""
+	}
+	return strings.ReplaceAll(buf.String(), "*/ .hl-", "*/ .markdown-body .hl-")
+}
+
+// codeThemeCSS returns the syntax highlighting styles for the configured
+// light and dark themes. An empty name keeps the built-in GitHub styles
+// for that color scheme.
+func codeThemeCSS(light, dark string) string {
+	if light == "" && dark == "" {
+		return chromaCSS
+	}
+
+	themeCSS := func(name string) string {
+		if name == "" {
+			return chromaCSS
+		}
+		return chromaStyleCSS(name)
+	}
+	return fmt.Sprintf("@media (prefers-color-scheme: light) {\n%s\n}\n@media (prefers-color-scheme: dark) {\n%s\n}\n",
+		themeCSS(light), themeCSS(dark))
+}
+
+// codeThemeSwitcherScript returns the script for the topbar code theme
+// switcher. Each theme's stylesheet is embedded; choosing one disables the
+// default styles and is remembered in localStorage.
+func codeThemeSwitcherScript(light, dark string) string {
+	type theme struct {
+		Name string `json:"name"`
+		CSS  string `json:"css"`
+	}
+
+	var themes []theme
+	seen := make(map[string]bool)
+	for _, name := range append([]string{light, dark}, switcherCodeThemes...) {
+		if name == "" || seen[name] || !IsCodeTheme(name) {
+			continue
+		}
+		seen[name] = true
+		themes = append(themes, theme{Name: name, CSS: chromaStyleCSS(name)})
+	}
+
+	data, _ := json.Marshal(themes)
+	return fmt.Sprintf(codeThemeScript, data)
+}
+
+const codeThemeScript = `
+    <script>
+        (function() {
+            'use strict';
+
+            var STORAGE_KEY = 'mdp-code-theme';
+            var themes = %s;
+            var select = document.querySelector('.code-theme-select');
+            var base = document.getElementById('mdp-code-theme');
+            var override = document.createElement('style');
+            document.head.appendChild(override);
+
+            function loadChoice() {
+                try {
+                    return localStorage.getItem(STORAGE_KEY) || '';
+                } catch (e) {
+                    return '';
+                }
+            }
+
+            function saveChoice(name) {
+                try {
+                    if (name) {
+                        localStorage.setItem(STORAGE_KEY, name);
+                    } else {
+                        localStorage.removeItem(STORAGE_KEY);
+                    }
+                } catch (e) {}
+            }
+
+            function applyCodeTheme(name) {
+                var css = '';
+                themes.forEach(function(theme) {
+                    if (theme.name === name) css = theme.css;
+                });
+                override.textContent = css;
+                if (base) base.disabled = css !== '';
+                if (select) select.value = css ? name : '';
+            }
+
+            if (select) {
+                var defaultOption = document.createElement('option');
+                defaultOption.value = '';
+                defaultOption.textContent = 'Default theme';
+                select.appendChild(defaultOption);
+
+                themes.forEach(function(theme) {
+                    var option = document.createElement('option');
+                    option.value = theme.name;
+                    option.textContent = theme.name;
+                    select.appendChild(option);
+                });
+
+                select.addEventListener('change', function() {
+                    saveChoice(select.value);
+                    applyCodeTheme(select.value);
+                });
+            }
+
+            applyCodeTheme(loadChoice());
+        })();
+    </script>`
@@ -0,0 +1,172 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ColorSchemes lists the accepted color scheme modes. "system" follows
+// the operating system preference.
+var ColorSchemes = []string{"light", "dark", "system"}
+
+// IsColorScheme reports whether mode is one of ColorSchemes.
+func IsColorScheme(mode string) bool {
+	for _, m := range ColorSchemes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// colorSchemeQuery matches a prefers-color-scheme media feature.
+var colorSchemeQuery = regexp.MustCompile(`\(\s*prefers-color-scheme\s*:\s*(light|dark)\s*\)`)
+
+// fixColorScheme rewrites the prefers-color-scheme media rules in css so
+// that only the rules for mode, light or dark, apply, as the script does
+// in the browser. Other modes leave css unchanged.
+func fixColorScheme(css, mode string) string {
+	if mode != "light" && mode != "dark" {
+		return css
+	}
+	return colorSchemeQuery.ReplaceAllStringFunc(css, func(match string) string {
+		if colorSchemeQuery.FindStringSubmatch(match)[1] == mode {
+			return "(min-width: 0px)"
+		}
+		return "(max-width: -1px)"
+	})
+}
+
+// colorSchemeScript returns the script that applies the light/dark/system
+// toggle. It runs at the end of the head, before the page is painted, and
+// rewrites every prefers-color-scheme media rule in the page's stylesheets
+// so the embedded GitHub, Chroma and template styles all follow the chosen
+// mode. defaultMode applies until the reader picks a mode, which is then
+// remembered in localStorage.
+func colorSchemeScript(defaultMode string) string {
+	if !IsColorScheme(defaultMode) {
+		defaultMode = "system"
+	}
+	return fmt.Sprintf(colorSchemeScriptTemplate, defaultMode)
+}
+
+const colorSchemeScriptTemplate = `<script>
+        (function() {
+            'use strict';
+
+            var STORAGE_KEY = 'mdp-color-scheme';
+            var MODES = ['light', 'dark', 'system'];
+            var TITLES = {
+                light: 'Light theme (click for dark)',
+                dark: 'Dark theme (click for system)',
+                system: 'System theme (click for light)'
+            };
+            var ICONS = {
+                light: '<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><circle cx="12" cy="12" r="4"></circle><path d="M12 2v2M12 20v2M4.93 4.93l1.41 1.41M17.66 17.66l1.41 1.41M2 12h2M20 12h2M6.34 17.66l-1.41 1.41M19.07 4.93l-1.41 1.41"></path></svg>',
+                dark: '<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M12 3a6 6 0 0 0 9 9 9 9 0 1 1-9-9Z"></path></svg>',
+                system: '<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><rect x="2" y="3" width="20" height="14" rx="2"></rect><path d="M8 21h8M12 17v4"></path></svg>'
+            };
+
+            var systemDark = window.matchMedia ? window.matchMedia('(prefers-color-scheme: dark)') : null;
+            var originalMedia = new WeakMap();
+            var listeners = [];
+            var mode = loadMode() || '%s';
+
+            function loadMode() {
+                try {
+                    var stored = localStorage.getItem(STORAGE_KEY);
+                    return MODES.indexOf(stored) !== -1 ? stored : '';
+                } catch (e) {
+                    return '';
+                }
+            }
+
+            function saveMode() {
+                try {
+                    localStorage.setItem(STORAGE_KEY, mode);
+                } catch (e) {}
+            }
+
+            function isDark() {
+                if (mode === 'system') {
+                    return !!systemDark && systemDark.matches;
+                }
+                return mode === 'dark';
+            }
+
+            // Force prefers-color-scheme conditions to match the chosen mode
+            function rewriteRules(rules) {
+                for (var i = 0; i < rules.length; i++) {
+                    var rule = rules[i];
+                    if (rule.media && rule.cssRules) {
+                        if (!originalMedia.has(rule)) {
+                            originalMedia.set(rule, rule.media.mediaText);
+                        }
+                        var text = originalMedia.get(rule);
+                        if (text.indexOf('prefers-color-scheme') !== -1) {
+                            rule.media.mediaText = mode === 'system' ? text : text.replace(
+                                /\(\s*prefers-color-scheme\s*:\s*(light|dark)\s*\)/g,
+                                function(match, scheme) {
+                                    return scheme === mode ? '(min-width: 0px)' : '(max-width: -1px)';
+                                });
+                        }
+                    }
+                    if (rule.cssRules) {
+                        rewriteRules(rule.cssRules);
+                    }
+                }
+            }
+
+            function applyMode() {
+                for (var i = 0; i < document.styleSheets.length; i++) {
+                    try {
+                        rewriteRules(document.styleSheets[i].cssRules);
+                    } catch (e) {}
+                }
+                document.documentElement.setAttribute('data-color-scheme', mode);
+                document.querySelectorAll('.color-scheme-btn').forEach(function(btn) {
+                    btn.innerHTML = ICONS[mode];
+                    btn.title = TITLES[mode];
+                });
+            }
+
+            function notify() {
+                listeners.forEach(function(fn) {
+                    fn(isDark());
+                });
+            }
+
+            function setMode(newMode) {
+                if (MODES.indexOf(newMode) === -1 || newMode === mode) return;
+                var wasDark = isDark();
+                mode = newMode;
+                saveMode();
+                applyMode();
+                if (isDark() !== wasDark) notify();
+            }
+
+            if (systemDark) {
+                systemDark.addEventListener('change', function() {
+                    if (mode === 'system') notify();
+                });
+            }
+
+            window.mdpColorScheme = {
+                isDark: isDark,
+                getMode: function() { return mode; },
+                setMode: setMode,
+                onChange: function(fn) { listeners.push(fn); }
+            };
+
+            applyMode();
+
+            document.addEventListener('DOMContentLoaded', function() {
+                applyMode();
+                document.querySelectorAll('.color-scheme-btn').forEach(function(btn) {
+                    btn.addEventListener('click', function() {
+                        setMode(MODES[(MODES.indexOf(mode) + 1) %% MODES.length]);
+                    });
+                });
+            });
+        })();
+    </script>`
@@ -0,0 +1,174 @@
+package template
+
+import (
+	"mdp/internal/filetree"
+)
+
+const diffCSS = `
+:root {
+    --diff-ins-bg: #dafbe1;
+    --diff-ins-border: #1a7f37;
+    --diff-del-bg: #ffebe9;
+    --diff-del-border: #d1242f;
+}
+
+@media (prefers-color-scheme: dark) {
+    :root {
+        --diff-ins-bg: #2ea04326;
+        --diff-ins-border: #3fb950;
+        --diff-del-bg: #f8514926;
+        --diff-del-border: #f85149;
+    }
+}
+
+.diff-header {
+    display: flex;
+    align-items: center;
+    gap: 12px;
+    padding-bottom: 12px;
+    margin-bottom: 16px;
+    border-bottom: 1px solid var(--sidebar-border);
+    font-size: 14px;
+}
+
+.diff-path {
+    font-family: ui-monospace, SFMono-Regular, "SF Mono", Menlo, Consolas, monospace;
+    color: var(--fg-color);
+}
+
+.diff-status {
+    padding: 2px 8px;
+    border-radius: 12px;
+    font-size: 12px;
+    font-weight: 600;
+    color: #ffffff;
+    background: #9a6700;
+}
+
+.diff-status-added {
+    background: var(--diff-ins-border);
+}
+
+.diff-status-removed {
+    background: var(--diff-del-border);
+}
+
+.diff-stats {
+    margin-left: auto;
+    font-weight: 600;
+}
+
+.diff-stat-ins {
+    color: var(--diff-ins-border);
+}
+
+.diff-stat-del {
+    color: var(--diff-del-border);
+}
+
+.diff-change {
+    display: grid;
+    grid-template-columns: 1fr 1fr;
+    gap: 12px;
+    margin-bottom: 16px;
+}
+
+.diff-old,
+.diff-new {
+    min-width: 0;
+    padding: 8px 12px;
+    border-left: 3px solid transparent;
+    border-radius: 4px;
+}
+
+.diff-old {
+    background: var(--diff-del-bg);
+    border-left-color: var(--diff-del-border);
+}
+
+.diff-new {
+    background: var(--diff-ins-bg);
+    border-left-color: var(--diff-ins-border);
+}
+
+.diff-old:empty,
+.diff-new:empty {
+    background: transparent;
+    border-left-color: transparent;
+}
+
+.diff-old > :last-child,
+.diff-new > :last-child {
+    margin-bottom: 0;
+}
+
+/* Inline view stacks old above new */
+.diff-inline .diff-change {
+    display: block;
+}
+
+.diff-inline .diff-old:not(:empty) {
+    margin-bottom: 4px;
+}
+
+.diff-inline .diff-old:empty,
+.diff-inline .diff-new:empty {
+    display: none;
+}
+
+.diff-view-btn {
+    font-size: 13px;
+    font-weight: 500;
+    gap: 6px;
+}
+`
+
+const diffViewScript = `
+    <script>
+        (function() {
+            'use strict';
+
+            var STORAGE_KEY = 'mdp-diff-view';
+            var view = 'split';
+            try {
+                view = localStorage.getItem(STORAGE_KEY) === 'inline' ? 'inline' : 'split';
+            } catch (e) {}
+
+            var btn = document.createElement('button');
+            btn.className = 'topbar-btn diff-view-btn';
+            btn.setAttribute('aria-label', 'Toggle side-by-side and inline diff');
+            var divider = document.createElement('div');
+            divider.className = 'topbar-divider';
+
+            function apply() {
+                document.body.classList.toggle('diff-inline', view === 'inline');
+                btn.textContent = view === 'inline' ? 'Inline' : 'Side by side';
+                btn.title = view === 'inline' ? 'Show side by side' : 'Show inline';
+            }
+
+            btn.addEventListener('click', function() {
+                view = view === 'inline' ? 'split' : 'inline';
+                try {
+                    localStorage.setItem(STORAGE_KEY, view);
+                } catch (e) {}
+                apply();
+            });
+
+            var topbar = document.querySelector('.topbar-right');
+            if (topbar) {
+                topbar.insertBefore(divider, topbar.firstChild);
+                topbar.insertBefore(btn, divider);
+            }
+            apply();
+        })();
+    </script>`
+
+// GenerateDiff creates a page with sidebar navigation for rendered diffs
+// made with diff.HTML, with a toggle between side-by-side and inline views.
+// Set FileEntry.Status to badge each file in the sidebar.
+func GenerateDiff(title string, tree *filetree.TreeNode, files []filetree.FileEntry, opts ...Option) string {
+	o := newOptions(opts)
+	o.extraCSS = diffCSS
+	o.extraScripts = diffViewScript
+	return generateMulti(title, tree, files, 0, o)
+}
@@ -0,0 +1,650 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"mdp/internal/filetree"
+)
+
+const graphCSS = `
+.graph-overlay {
+    position: fixed;
+    inset: 0;
+    z-index: 1000;
+    display: none;
+    flex-direction: column;
+    background: var(--sidebar-bg);
+}
+
+.graph-overlay.active {
+    display: flex;
+}
+
+.graph-toolbar {
+    display: flex;
+    align-items: center;
+    gap: 12px;
+    height: var(--topbar-height);
+    padding: 0 16px;
+    border-bottom: 1px solid var(--sidebar-border);
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 13px;
+    color: var(--fg-muted);
+}
+
+.graph-title {
+    margin: 0;
+    font-size: 14px;
+    font-weight: 600;
+    color: var(--fg-color);
+}
+
+.graph-search {
+    flex: 0 1 280px;
+    height: 32px;
+    padding: 0 10px;
+    border: 1px solid var(--sidebar-border);
+    border-radius: 6px;
+    font-size: 13px;
+    color: var(--fg-color);
+    background: transparent;
+    outline: none;
+}
+
+.graph-search:focus {
+    border-color: var(--sidebar-active);
+}
+
+.graph-stats {
+    margin-left: auto;
+}
+
+.graph-close {
+    margin-left: 4px;
+}
+
+.graph-body {
+    position: relative;
+    flex: 1;
+    min-height: 0;
+}
+
+.graph-canvas {
+    display: block;
+    width: 100%;
+    height: 100%;
+    cursor: grab;
+}
+
+.graph-canvas.dragging {
+    cursor: grabbing;
+}
+
+.graph-canvas.pointing {
+    cursor: pointer;
+}
+
+.graph-legend {
+    position: absolute;
+    left: 16px;
+    bottom: 16px;
+    max-height: 40%;
+    overflow-y: auto;
+    margin: 0;
+    padding: 8px 12px;
+    list-style: none;
+    background: var(--sidebar-bg);
+    border: 1px solid var(--sidebar-border);
+    border-radius: 6px;
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 12px;
+    color: var(--fg-muted);
+}
+
+.graph-legend li {
+    display: flex;
+    align-items: center;
+    gap: 6px;
+    line-height: 20px;
+}
+
+.graph-legend-swatch {
+    width: 10px;
+    height: 10px;
+    border-radius: 50%;
+}
+
+.graph-hint {
+    position: absolute;
+    right: 16px;
+    bottom: 16px;
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 12px;
+    color: var(--fg-muted);
+}
+
+@media print {
+    .graph-overlay {
+        display: none !important;
+    }
+}
+`
+
+const graphButtonHTML = `
+            <button class="topbar-btn topbar-graph-btn" aria-label="Link graph" title="Link graph (G)">
+                <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><circle cx="6" cy="6" r="2.5"></circle><circle cx="18" cy="8" r="2.5"></circle><circle cx="9" cy="18" r="2.5"></circle><path d="M8.3 7l7.4 .6"></path><path d="M6.6 8.4l1.8 7.2"></path><path d="M16.4 10l-5.6 6.2"></path></svg>
+            </button>`
+
+const graphScript = `
+    <script>
+        (function() {
+            var dataEl = document.getElementById('mdp-graph-data');
+            if (!dataEl) {
+                return;
+            }
+            var data = JSON.parse(dataEl.textContent);
+            var COLORS = ['#0969da', '#1a7f37', '#bf3989', '#9a6700', '#8250df', '#cf222e', '#1b7c83', '#bc4c00', '#6e7781', '#4d2d00'];
+
+            var nodes = data.nodes.map(function(n) {
+                return { id: n.id, name: n.name, path: n.path, group: n.group, x: 0, y: 0, vx: 0, vy: 0, degree: 0, links: {} };
+            });
+            var byId = {};
+            nodes.forEach(function(n) { byId[n.id] = n; });
+            var edges = [];
+            (data.edges || []).forEach(function(e) {
+                var s = byId[e.source], t = byId[e.target];
+                if (!s || !t) {
+                    return;
+                }
+                edges.push({ source: s, target: t });
+                s.degree++;
+                t.degree++;
+                s.links[t.id] = true;
+                t.links[s.id] = true;
+            });
+
+            // Files in the same directory form a cluster with its own color
+            var groups = [];
+            var groupIndex = {};
+            nodes.forEach(function(n) {
+                if (!(n.group in groupIndex)) {
+                    groupIndex[n.group] = groups.length;
+                    groups.push({ name: n.group, color: COLORS[groups.length % COLORS.length], x: 0, y: 0 });
+                }
+                n.cluster = groups[groupIndex[n.group]];
+            });
+            var spread = 60 * Math.sqrt(nodes.length);
+            groups.forEach(function(g, i) {
+                var angle = 2 * Math.PI * i / groups.length;
+                g.x = groups.length > 1 ? Math.cos(angle) * spread : 0;
+                g.y = groups.length > 1 ? Math.sin(angle) * spread : 0;
+            });
+            nodes.forEach(function(n, i) {
+                var angle = i * 2.399963;
+                var r = 12 * Math.sqrt(i + 1);
+                n.x = n.cluster.x + Math.cos(angle) * r;
+                n.y = n.cluster.y + Math.sin(angle) * r;
+            });
+
+            var overlay = document.createElement('div');
+            overlay.className = 'graph-overlay';
+            overlay.setAttribute('role', 'dialog');
+            overlay.setAttribute('aria-label', 'Link graph');
+            overlay.innerHTML =
+                '<div class="graph-toolbar">' +
+                    '<h2 class="graph-title">Link graph</h2>' +
+                    '<input type="text" class="graph-search" placeholder="Highlight files..." autocomplete="off">' +
+                    '<span class="graph-stats"></span>' +
+                    '<button class="topbar-btn graph-close" aria-label="Close graph" title="Close (Esc)">' +
+                        '<svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><line x1="18" y1="6" x2="6" y2="18"></line><line x1="6" y1="6" x2="18" y2="18"></line></svg>' +
+                    '</button>' +
+                '</div>' +
+                '<div class="graph-body">' +
+                    '<canvas class="graph-canvas"></canvas>' +
+                    '<ul class="graph-legend"></ul>' +
+                    '<div class="graph-hint">Drag to move, scroll to zoom, click a file to open it</div>' +
+                '</div>';
+            document.body.appendChild(overlay);
+
+            var canvas = overlay.querySelector('.graph-canvas');
+            var ctx = canvas.getContext('2d');
+            var search = overlay.querySelector('.graph-search');
+            overlay.querySelector('.graph-stats').textContent =
+                nodes.length + (nodes.length === 1 ? ' file, ' : ' files, ') + edges.length + (edges.length === 1 ? ' link' : ' links');
+
+            var legend = overlay.querySelector('.graph-legend');
+            if (groups.length > 1) {
+                groups.forEach(function(g) {
+                    var li = document.createElement('li');
+                    var swatch = document.createElement('span');
+                    swatch.className = 'graph-legend-swatch';
+                    swatch.style.background = g.color;
+                    li.appendChild(swatch);
+                    li.appendChild(document.createTextNode(g.name || '/'));
+                    legend.appendChild(li);
+                });
+            } else {
+                legend.style.display = 'none';
+            }
+
+            var view = { x: 0, y: 0, scale: 1 };
+            var alpha = 1;
+            var running = false;
+            var hovered = null;
+            var query = '';
+            var width = 0, height = 0;
+
+            function radius(n) {
+                return 5 + Math.sqrt(n.degree) * 2;
+            }
+
+            function matches(n) {
+                return query !== '' && (n.name.toLowerCase().indexOf(query) >= 0 || n.path.toLowerCase().indexOf(query) >= 0);
+            }
+
+            function activeId() {
+                var section = document.querySelector('.content-section.active');
+                return section ? section.id : '';
+            }
+
+            // One step of a force layout: nodes repel each other, links pull
+            // their ends together and each node is drawn to its cluster.
+            function tick() {
+                var i, j, a, b, dx, dy, d2, d, f;
+                for (i = 0; i < nodes.length; i++) {
+                    a = nodes[i];
+                    for (j = i + 1; j < nodes.length; j++) {
+                        b = nodes[j];
+                        dx = b.x - a.x;
+                        dy = b.y - a.y;
+                        d2 = dx * dx + dy * dy || 0.01;
+                        if (d2 > 250000) {
+                            continue;
+                        }
+                        f = 900 / d2 * alpha;
+                        d = Math.sqrt(d2);
+                        a.vx -= dx / d * f;
+                        a.vy -= dy / d * f;
+                        b.vx += dx / d * f;
+                        b.vy += dy / d * f;
+                    }
+                }
+                edges.forEach(function(e) {
+                    dx = e.target.x - e.source.x;
+                    dy = e.target.y - e.source.y;
+                    d = Math.sqrt(dx * dx + dy * dy) || 0.1;
+                    f = (d - 90) * 0.03 * alpha;
+                    e.source.vx += dx / d * f;
+                    e.source.vy += dy / d * f;
+                    e.target.vx -= dx / d * f;
+                    e.target.vy -= dy / d * f;
+                });
+                groups.forEach(function(g) {
+                    g.cx = 0;
+                    g.cy = 0;
+                    g.count = 0;
+                });
+                nodes.forEach(function(n) {
+                    n.cluster.cx += n.x;
+                    n.cluster.cy += n.y;
+                    n.cluster.count++;
+                });
+                nodes.forEach(function(n) {
+                    var g = n.cluster;
+                    n.vx += ((g.cx / g.count) - n.x) * 0.02 * alpha - n.x * 0.002 * alpha;
+                    n.vy += ((g.cy / g.count) - n.y) * 0.02 * alpha - n.y * 0.002 * alpha;
+                    if (n === drag.node) {
+                        n.vx = 0;
+                        n.vy = 0;
+                        return;
+                    }
+                    n.vx *= 0.6;
+                    n.vy *= 0.6;
+                    n.x += n.vx;
+                    n.y += n.vy;
+                });
+                alpha *= 0.985;
+            }
+
+            function color(name, fallback) {
+                var value = getComputedStyle(document.documentElement).getPropertyValue(name).trim();
+                return value || fallback;
+            }
+
+            function draw() {
+                var fg = color('--fg-color', '#1f2328');
+                var muted = color('--fg-muted', '#59636e');
+                var border = color('--sidebar-border', '#d1d9e0');
+                var accent = color('--sidebar-active', '#0969da');
+                var current = activeId();
+                var focus = hovered;
+                var highlighting = query !== '';
+
+                ctx.setTransform(1, 0, 0, 1, 0, 0);
+                ctx.clearRect(0, 0, canvas.width, canvas.height);
+                var dpr = window.devicePixelRatio || 1;
+                ctx.setTransform(dpr * view.scale, 0, 0, dpr * view.scale, dpr * (width / 2 + view.x), dpr * (height / 2 + view.y));
+
+                // Directory clusters
+                if (groups.length > 1) {
+                    groups.forEach(function(g) {
+                        if (!g.count) {
+                            return;
+                        }
+                        var cx = g.cx / g.count, cy = g.cy / g.count, r = 0;
+                        nodes.forEach(function(n) {
+                            if (n.cluster === g) {
+                                r = Math.max(r, Math.sqrt((n.x - cx) * (n.x - cx) + (n.y - cy) * (n.y - cy)) + radius(n));
+                            }
+                        });
+                        r += 16;
+                        ctx.beginPath();
+                        ctx.arc(cx, cy, r, 0, 2 * Math.PI);
+                        ctx.globalAlpha = 0.07;
+                        ctx.fillStyle = g.color;
+                        ctx.fill();
+                        ctx.globalAlpha = 1;
+                        ctx.font = '600 11px -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif';
+                        ctx.textAlign = 'center';
+                        ctx.fillStyle = g.color;
+                        ctx.fillText(g.name || '/', cx, cy - r - 6);
+                    });
+                }
+
+                edges.forEach(function(e) {
+                    var lit = focus && (e.source === focus || e.target === focus);
+                    var dim = (focus && !lit) || (highlighting && !matches(e.source) && !matches(e.target));
+                    var dx = e.target.x - e.source.x, dy = e.target.y - e.source.y;
+                    var d = Math.sqrt(dx * dx + dy * dy) || 1;
+                    var r = radius(e.target) + 2;
+                    var tx = e.target.x - dx / d * r, ty = e.target.y - dy / d * r;
+                    ctx.globalAlpha = dim ? 0.15 : 1;
+                    ctx.strokeStyle = lit ? accent : border;
+                    ctx.fillStyle = lit ? accent : border;
+                    ctx.lineWidth = (lit ? 2 : 1) / view.scale;
+                    ctx.beginPath();
+                    ctx.moveTo(e.source.x, e.source.y);
+                    ctx.lineTo(tx, ty);
+                    ctx.stroke();
+                    // Arrow head at the linked file
+                    var size = 6 / Math.sqrt(view.scale);
+                    ctx.beginPath();
+                    ctx.moveTo(tx, ty);
+                    ctx.lineTo(tx - dx / d * size - dy / d * size / 2, ty - dy / d * size + dx / d * size / 2);
+                    ctx.lineTo(tx - dx / d * size + dy / d * size / 2, ty - dy / d * size - dx / d * size / 2);
+                    ctx.closePath();
+                    ctx.fill();
+                });
+                ctx.globalAlpha = 1;
+
+                ctx.font = '12px -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif';
+                ctx.textAlign = 'center';
+                nodes.forEach(function(n) {
+                    var match = matches(n);
+                    var near = focus && (n === focus || focus.links[n.id]);
+                    var dim = (focus && !near) || (highlighting && !match);
+                    var r = radius(n);
+                    ctx.globalAlpha = dim ? 0.2 : 1;
+                    ctx.beginPath();
+                    ctx.arc(n.x, n.y, r, 0, 2 * Math.PI);
+                    ctx.fillStyle = n.cluster.color;
+                    ctx.fill();
+                    if (match || n.id === current) {
+                        ctx.lineWidth = 3 / view.scale;
+                        ctx.strokeStyle = match ? '#d4a72c' : accent;
+                        ctx.beginPath();
+                        ctx.arc(n.x, n.y, r + 3 / view.scale, 0, 2 * Math.PI);
+                        ctx.stroke();
+                    }
+                    if (view.scale > 0.6 || match || near || n.id === current) {
+                        ctx.fillStyle = match || near ? fg : muted;
+                        ctx.fillText(n.name, n.x, n.y + r + 14 / view.scale);
+                    }
+                });
+                ctx.globalAlpha = 1;
+            }
+
+            function frame() {
+                if (alpha > 0.02) {
+                    tick();
+                }
+                draw();
+                if (alpha > 0.02 && overlay.classList.contains('active')) {
+                    requestAnimationFrame(frame);
+                } else {
+                    running = false;
+                }
+            }
+
+            function start(heat) {
+                alpha = Math.max(alpha, heat);
+                if (!running) {
+                    running = true;
+                    requestAnimationFrame(frame);
+                }
+            }
+
+            function resize() {
+                var dpr = window.devicePixelRatio || 1;
+                width = canvas.clientWidth;
+                height = canvas.clientHeight;
+                canvas.width = width * dpr;
+                canvas.height = height * dpr;
+                draw();
+            }
+
+            function toGraph(e) {
+                var rect = canvas.getBoundingClientRect();
+                return {
+                    x: (e.clientX - rect.left - width / 2 - view.x) / view.scale,
+                    y: (e.clientY - rect.top - height / 2 - view.y) / view.scale
+                };
+            }
+
+            function nodeAt(p) {
+                for (var i = nodes.length - 1; i >= 0; i--) {
+                    var n = nodes[i];
+                    var r = radius(n) + 3;
+                    if ((n.x - p.x) * (n.x - p.x) + (n.y - p.y) * (n.y - p.y) <= r * r) {
+                        return n;
+                    }
+                }
+                return null;
+            }
+
+            function openFile(id) {
+                closeGraph();
+                var link = document.querySelector('.file-tree a[data-file="' + CSS.escape(id) + '"]');
+                if (link) {
+                    link.click();
+                } else {
+                    location.hash = id;
+                }
+            }
+
+            var drag = { node: null, panning: false, moved: false, x: 0, y: 0 };
+
+            canvas.addEventListener('mousedown', function(e) {
+                var p = toGraph(e);
+                drag.node = nodeAt(p);
+                drag.panning = !drag.node;
+                drag.moved = false;
+                drag.x = e.clientX;
+                drag.y = e.clientY;
+                canvas.classList.add('dragging');
+            });
+
+            window.addEventListener('mousemove', function(e) {
+                if (!overlay.classList.contains('active')) {
+                    return;
+                }
+                if (drag.node || drag.panning) {
+                    var dx = e.clientX - drag.x, dy = e.clientY - drag.y;
+                    if (Math.abs(dx) + Math.abs(dy) > 3) {
+                        drag.moved = true;
+                    }
+                    drag.x = e.clientX;
+                    drag.y = e.clientY;
+                    if (drag.node) {
+                        drag.node.x += dx / view.scale;
+                        drag.node.y += dy / view.scale;
+                        start(0.3);
+                    } else {
+                        view.x += dx;
+                        view.y += dy;
+                        draw();
+                    }
+                    return;
+                }
+                if (e.target !== canvas) {
+                    return;
+                }
+                var n = nodeAt(toGraph(e));
+                if (n !== hovered) {
+                    hovered = n;
+                    canvas.classList.toggle('pointing', !!n);
+                    canvas.title = n ? n.path : '';
+                    draw();
+                }
+            });
+
+            window.addEventListener('mouseup', function() {
+                if (!drag.node && !drag.panning) {
+                    return;
+                }
+                var clicked = drag.node && !drag.moved ? drag.node : null;
+                drag.node = null;
+                drag.panning = false;
+                canvas.classList.remove('dragging');
+                if (clicked) {
+                    openFile(clicked.id);
+                }
+            });
+
+            canvas.addEventListener('mouseleave', function() {
+                if (hovered) {
+                    hovered = null;
+                    canvas.classList.remove('pointing');
+                    draw();
+                }
+            });
+
+            canvas.addEventListener('wheel', function(e) {
+                e.preventDefault();
+                var rect = canvas.getBoundingClientRect();
+                var mx = e.clientX - rect.left - width / 2, my = e.clientY - rect.top - height / 2;
+                var scale = Math.min(4, Math.max(0.2, view.scale * Math.exp(-e.deltaY * 0.0015)));
+                view.x = mx - (mx - view.x) * scale / view.scale;
+                view.y = my - (my - view.y) * scale / view.scale;
+                view.scale = scale;
+                draw();
+            }, { passive: false });
+
+            search.addEventListener('input', function() {
+                query = search.value.trim().toLowerCase();
+                draw();
+            });
+
+            search.addEventListener('keydown', function(e) {
+                if (e.key === 'Enter') {
+                    for (var i = 0; i < nodes.length; i++) {
+                        if (matches(nodes[i])) {
+                            openFile(nodes[i].id);
+                            return;
+                        }
+                    }
+                }
+            });
+
+            function openGraph() {
+                overlay.classList.add('active');
+                document.body.style.overflow = 'hidden';
+                resize();
+                start(alpha > 0.02 ? alpha : 0.05);
+                search.focus();
+            }
+
+            function closeGraph() {
+                overlay.classList.remove('active');
+                document.body.style.overflow = '';
+                hovered = null;
+            }
+
+            overlay.querySelector('.graph-close').addEventListener('click', closeGraph);
+            var button = document.querySelector('.topbar-graph-btn');
+            if (button) {
+                button.addEventListener('click', openGraph);
+            }
+            window.addEventListener('resize', function() {
+                if (overlay.classList.contains('active')) {
+                    resize();
+                }
+            });
+
+            document.addEventListener('keydown', function(e) {
+                if (overlay.classList.contains('active')) {
+                    if (e.key === 'Escape') {
+                        e.preventDefault();
+                        closeGraph();
+                    }
+                    return;
+                }
+                var target = e.target;
+                var isTyping = target.tagName === 'INPUT' || target.tagName === 'TEXTAREA' || target.isContentEditable;
+                if (e.key === 'g' && !e.metaKey && !e.ctrlKey && !e.altKey && !isTyping) {
+                    e.preventDefault();
+                    openGraph();
+                }
+            });
+        })();
+    </script>`
+
+// graphNode is a file in the link graph. Group is its directory, which
+// files are clustered by.
+type graphNode struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Group string `json:"group"`
+}
+
+// graphEdge is a link from the file Source to the file Target.
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// graphView returns the link graph data and the script that draws it.
+// Edges come from FileEntry.Backlinks, so they are the links resolved by
+// the link rewriter.
+func graphView(files []filetree.FileEntry) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	nodes := make([]graphNode, 0, len(files))
+	var edges []graphEdge
+	for _, f := range files {
+		group := path.Dir(filepath.ToSlash(f.RelPath))
+		if group == "." {
+			group = ""
+		}
+		nodes = append(nodes, graphNode{ID: f.ID, Name: f.Name, Path: f.RelPath, Group: group})
+		for _, b := range f.Backlinks {
+			edges = append(edges, graphEdge{Source: b.ID, Target: f.ID})
+		}
+	}
+
+	// json.Marshal escapes <, > and &, so the data cannot end the script
+	data, err := json.Marshal(struct {
+		Nodes []graphNode `json:"nodes"`
+		Edges []graphEdge `json:"edges"`
+	}{nodes, edges})
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\n    <script type=\"application/json\" id=\"mdp-graph-data\">%s</script>", data) + graphScript
+}
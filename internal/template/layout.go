@@ -0,0 +1,90 @@
+package template
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Page is the data passed to a custom layout. Title is plain text; the
+// other slots are pre-rendered HTML and are inserted unescaped:
+//
+//	{{.Title}}    document title
+//	{{.Head}}     styles, color scheme script and security policy, for <head>
+//	{{.Sidebar}}  file tree as nested <ul> lists linking to #<file-id>
+//	              (empty in single-file mode)
+//	{{.Content}}  rendered markdown in <article class="markdown-body">; in
+//	              multi-file mode one <section id="<file-id>"> per file
+//	{{.Scripts}}  Mermaid, code theme, copy button and live reload scripts,
+//	              for the end of <body>
+//	{{.Multi}}    true when previewing more than one file
+type Page struct {
+	Title   string
+	Head    htmltemplate.HTML
+	Sidebar htmltemplate.HTML
+	Content htmltemplate.HTML
+	Scripts htmltemplate.HTML
+	Multi   bool
+}
+
+// Layout is a custom page layout that replaces the built-in templates.
+type Layout struct {
+	tmpl *htmltemplate.Template
+}
+
+// ParseLayout reads a Go html/template file for use with WithLayout. The
+// template is executed once with an empty Page to catch errors early.
+func ParseLayout(path string) (*Layout, error) {
+	tmpl, err := htmltemplate.New(filepath.Base(path)).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing layout %s: %v", path, err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, Page{}); err != nil {
+		return nil, fmt.Errorf("Error in layout %s: %v", path, err)
+	}
+	return &Layout{tmpl: tmpl}, nil
+}
+
+// render executes the layout. Errors are shown in the page, since they
+// can only come from data-dependent template logic.
+func (l *Layout) render(page Page) string {
+	var buf strings.Builder
+	if err := l.tmpl.Execute(&buf, page); err != nil {
+		return fmt.Sprintf("<!DOCTYPE html>\n<pre>Error in layout: %s</pre>\n", htmltemplate.HTMLEscapeString(err.Error()))
+	}
+	return buf.String()
+}
+
+// layoutHead returns the head markup for a custom layout: the markdown and
+// code styles, custom CSS and the color scheme script.
+func layoutHead(o options, securityHead string, script func(string) string) string {
+	var buf strings.Builder
+	buf.WriteString(strings.TrimPrefix(securityHead, "\n"))
+	fmt.Fprintf(&buf, "\n    <style>\n%s\n    </style>", githubMarkdownCSS+wikiLinkCSS+extensionCSS+codeBlockCSS)
+	fmt.Fprintf(&buf, "\n    <style id=\"mdp-code-theme\">\n%s\n    </style>", codeThemeCSS(o.codeThemeLight, o.codeThemeDark))
+	if css := o.extraCSS + lintStyles(o) + spellStyles(o); css != "" {
+		fmt.Fprintf(&buf, "\n    <style>\n%s\n    </style>", css)
+	}
+	buf.WriteString(customCSSStyle(o.customCSS))
+	buf.WriteString("\n    " + script(colorSchemeScript(o.colorScheme)))
+	return buf.String()
+}
+
+// customCSSStyle returns a style element for user-supplied CSS, or nothing.
+func customCSSStyle(css string) string {
+	if css == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n    <style id=\"mdp-custom-css\">\n%s\n    </style>", css)
+}
+
+// ReadCustomCSS reads a stylesheet for use with WithCustomCSS.
+func ReadCustomCSS(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading CSS %s: %v", path, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,176 @@
+package template
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"mdp/internal/filetree"
+)
+
+const lintCSS = `
+:root {
+    --lint-bg: #ffffff;
+    --lint-border: #d1d9e0;
+    --lint-warning: #9a6700;
+}
+
+@media (prefers-color-scheme: dark) {
+    :root {
+        --lint-bg: #161b22;
+        --lint-border: #3d444d;
+        --lint-warning: #d29922;
+    }
+}
+
+.lint-panel {
+    position: fixed;
+    right: 16px;
+    bottom: 16px;
+    z-index: 240;
+    max-width: min(560px, calc(100vw - 32px));
+    background: var(--lint-bg);
+    border: 1px solid var(--lint-border);
+    border-radius: 8px;
+    box-shadow: 0 4px 12px rgba(0, 0, 0, 0.15);
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 13px;
+    color: var(--fg-color);
+}
+
+.lint-panel summary {
+    padding: 6px 12px;
+    cursor: pointer;
+    font-weight: 600;
+    user-select: none;
+}
+
+.lint-panel[data-count="0"] summary {
+    color: var(--fg-muted);
+    font-weight: 400;
+}
+
+.lint-panel summary::marker {
+    color: var(--fg-muted);
+}
+
+.lint-count {
+    color: var(--lint-warning);
+}
+
+.lint-list {
+    max-height: 40vh;
+    overflow-y: auto;
+    margin: 0;
+    padding: 4px 0;
+    list-style: none;
+    border-top: 1px solid var(--lint-border);
+}
+
+.lint-list li {
+    padding: 4px 12px;
+    line-height: 1.5;
+}
+
+.lint-location,
+.lint-rule {
+    font-family: ui-monospace, SFMono-Regular, "SF Mono", Menlo, Consolas, monospace;
+    font-size: 12px;
+}
+
+.lint-location {
+    color: var(--fg-muted);
+}
+
+a.lint-location {
+    color: var(--accent-color, #0969da);
+    text-decoration: none;
+}
+
+a.lint-location:hover {
+    text-decoration: underline;
+}
+
+.lint-rule {
+    margin: 0 6px;
+    color: var(--lint-warning);
+}
+
+@media print {
+    .lint-panel {
+        display: none;
+    }
+}
+`
+
+// lintPanelScript keeps the panel open across live reloads.
+const lintPanelScript = `
+    <script>
+        (function() {
+            'use strict';
+            var panel = document.getElementById('mdp-lint-panel');
+            if (!panel) {
+                return;
+            }
+            var KEY = 'mdp-lint-open';
+            try {
+                panel.open = sessionStorage.getItem(KEY) === '1';
+            } catch (e) {}
+            panel.addEventListener('toggle', function() {
+                try {
+                    sessionStorage.setItem(KEY, panel.open ? '1' : '0');
+                } catch (e) {}
+            });
+        })();
+    </script>`
+
+// lintStyles returns the lint panel styles if the page shows one.
+func lintStyles(o options) string {
+	if !o.lint {
+		return ""
+	}
+	return lintCSS
+}
+
+// lintPanel renders the problems as a collapsible panel in the corner of
+// the page. In multi-file pages each location links to its file, found by
+// matching Problem.File against FileEntry.Path.
+func lintPanel(o options, files []filetree.FileEntry) string {
+	if !o.lint {
+		return ""
+	}
+
+	byPath := make(map[string]filetree.FileEntry)
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+
+	var buf strings.Builder
+	summary := "No lint problems"
+	switch len(o.lintProblems) {
+	case 0:
+	case 1:
+		summary = `<span class="lint-count">1 lint problem</span>`
+	default:
+		summary = fmt.Sprintf(`<span class="lint-count">%d lint problems</span>`, len(o.lintProblems))
+	}
+	fmt.Fprintf(&buf, "\n    <details class=\"lint-panel\" id=\"mdp-lint-panel\" data-count=\"%d\">\n        <summary>%s</summary>",
+		len(o.lintProblems), summary)
+
+	if len(o.lintProblems) > 0 {
+		buf.WriteString("\n        <ul class=\"lint-list\">")
+		for _, p := range o.lintProblems {
+			location := fmt.Sprintf(`<span class="lint-location">%d:%d</span>`, p.Line, p.Column)
+			if f, ok := byPath[p.File]; ok {
+				location = fmt.Sprintf(`<a class="lint-location" href="#%s">%s:%d:%d</a>`,
+					html.EscapeString(f.ID), html.EscapeString(f.RelPath), p.Line, p.Column)
+			}
+			fmt.Fprintf(&buf, "\n            <li>%s<span class=\"lint-rule\" title=\"%s\">%s</span>%s</li>",
+				location, html.EscapeString(p.Name), html.EscapeString(p.Rule), html.EscapeString(p.Message))
+		}
+		buf.WriteString("\n        </ul>")
+	}
+	buf.WriteString("\n    </details>")
+	buf.WriteString(lintPanelScript)
+	return buf.String()
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"fmt"
 	"html"
+	htmltemplate "html/template"
 	"strings"
 
 	"mdp/internal/filetree"
@@ -12,17 +13,17 @@ const multiFileTemplate = `<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">%s
     <title>%s</title>
     <style>
         %s
     </style>
-    <style>
+    <style id="mdp-code-theme">
         %s
     </style>
     <style>
         %s
-    </style>
+    </style>%s
 </head>
 <body>
     <script>if(history.scrollRestoration)history.scrollRestoration='manual';window.scrollTo(0,0);</script>
@@ -37,7 +38,7 @@ const multiFileTemplate = `<!DOCTYPE html>
         <div class="topbar-center">
             <span class="topbar-brand">MARKDOWN PREVIEW</span>
         </div>
-        <div class="topbar-right">
+        <div class="topbar-right">%s%s
             <button class="topbar-btn topbar-search-btn" aria-label="Search files" title="Search files (⌘K)">
                 <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path stroke="none" d="M0 0h24v24H0z" fill="none"/><path d="M10 10m-7 0a7 7 0 1 0 14 0a7 7 0 1 0 -14 0" /><path d="M21 21l-6 -6" /></svg>
             </button>
@@ -47,6 +48,10 @@ const multiFileTemplate = `<!DOCTYPE html>
                 <span class="topbar-comment-count">0</span>
             </button>
             <div class="topbar-divider"></div>
+            <button class="topbar-btn color-scheme-btn" aria-label="Toggle light/dark theme" title="Toggle light/dark theme"></button>
+            <div class="topbar-divider"></div>
+            <select class="topbar-select code-theme-select" aria-label="Code theme" title="Code theme"></select>
+            <div class="topbar-divider"></div>
             <button class="topbar-btn topbar-help-btn" aria-label="Keyboard shortcuts" title="Keyboard shortcuts (?)">
                 <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><circle cx="12" cy="12" r="10"></circle><path d="M9.09 9a3 3 0 0 1 5.83 1c0 2-3 3-3 3"></path><line x1="12" y1="17" x2="12.01" y2="17"></line></svg>
             </button>
@@ -178,6 +183,10 @@ const multiFileTemplate = `<!DOCTYPE html>
                     <span class="shortcut-action">Toggle sidebar</span>
                     <span class="shortcut-keys"><kbd>⌘</kbd><kbd>B</kbd></span>
                 </div>
+                <div class="shortcut-row">
+                    <span class="shortcut-action">Show link graph</span>
+                    <span class="shortcut-keys"><kbd>G</kbd></span>
+                </div>
                 <div class="shortcut-row">
                     <span class="shortcut-action">Add comment to selection</span>
                     <span class="shortcut-keys"><kbd>C</kbd></span>
@@ -252,6 +261,11 @@ html {
     scroll-margin-top: 100vh;
 }
 
+/* Anchors inside a file, such as headings and footnotes, scroll below the topbar */
+.content-section [id] {
+    scroll-margin-top: calc(var(--topbar-height) + 16px);
+}
+
 body {
     margin: 0;
     padding: 0;
@@ -442,6 +456,106 @@ body {
     margin: 0 4px;
 }
 
+.topbar-select {
+    height: 32px;
+    max-width: 150px;
+    padding: 0 8px;
+    background: transparent;
+    border: 1px solid var(--sidebar-border);
+    border-radius: 6px;
+    color: var(--fg-muted);
+    font-family: inherit;
+    font-size: 12px;
+    cursor: pointer;
+}
+
+.topbar-select:hover {
+    color: var(--fg-color);
+    background: var(--sidebar-hover);
+}
+
+.topbar-select option {
+    color: #1f2328;
+    background: #ffffff;
+}
+
+.file-meta {
+    display: flex;
+    align-items: center;
+    flex-wrap: wrap;
+    gap: 8px;
+    margin-bottom: 16px;
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 13px;
+    color: var(--fg-muted);
+}
+
+.file-meta-status {
+    padding: 0 8px;
+    border-radius: 10px;
+    font-size: 12px;
+    font-weight: 600;
+    line-height: 20px;
+    color: #ffffff;
+    background: #9a6700;
+}
+
+.file-meta-status[data-status="added"],
+.file-meta-status[data-status="untracked"] {
+    background: #1a7f37;
+}
+
+.file-meta-status[data-status="removed"] {
+    background: #d1242f;
+}
+
+.file-meta-status[data-status="staged"] {
+    background: #0969da;
+}
+
+.backlinks {
+    margin-top: 48px;
+    padding-top: 16px;
+    border-top: 1px solid var(--sidebar-border);
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 14px;
+}
+
+.backlinks-title {
+    margin: 0 0 12px;
+    font-size: 12px;
+    font-weight: 600;
+    letter-spacing: 0.5px;
+    text-transform: uppercase;
+    color: var(--fg-muted);
+}
+
+.backlinks-list {
+    margin: 0;
+    padding: 0;
+    list-style: none;
+}
+
+.backlinks-list li + li {
+    margin-top: 12px;
+}
+
+.backlinks-file {
+    font-weight: 600;
+    color: var(--sidebar-active);
+    text-decoration: none;
+}
+
+.backlinks-file:hover {
+    text-decoration: underline;
+}
+
+.backlinks-excerpt {
+    margin: 4px 0 0;
+    color: var(--fg-muted);
+    line-height: 1.5;
+}
+
 .sidebar {
     position: fixed;
     left: 0;
@@ -565,6 +679,29 @@ body {
     background: var(--sidebar-hover);
 }
 
+.file-tree a[data-badge]::after {
+    content: attr(data-badge);
+    float: right;
+    margin-left: 8px;
+    font-size: 11px;
+    font-weight: 600;
+    line-height: 20px;
+    color: #9a6700;
+}
+
+.file-tree a[data-status="added"]::after,
+.file-tree a[data-status="untracked"]::after {
+    color: #1a7f37;
+}
+
+.file-tree a[data-status="removed"]::after {
+    color: #d1242f;
+}
+
+.file-tree a[data-status="staged"]::after {
+    color: #0969da;
+}
+
 .file-tree a.active {
     background: var(--sidebar-active-bg);
     border-left-color: var(--sidebar-active);
@@ -1555,7 +1692,8 @@ body:has(.sidebar.collapsed) .content {
         height: auto !important;
     }
 
-    .mermaid-source {
+    .mermaid-source,
+    .diagram-source {
         display: none !important;
     }
 
@@ -1647,6 +1785,37 @@ const sidebarJS = `
         }
     }
 
+    // showAnchor shows the file containing the element with the given ID
+    // and scrolls to it. Heading IDs are prefixed with the ID of their
+    // file, so a plain ID such as "usage" finds the heading in the file
+    // being shown.
+    function showAnchor(id, skipHistory) {
+        try {
+            id = decodeURIComponent(id);
+        } catch (err) {}
+        var target = document.getElementById(id);
+        if (!target) {
+            var active = document.querySelector('.content-section.active');
+            target = active && document.getElementById(active.id + '-' + id);
+        }
+        var section = target && target.parentElement && target.parentElement.closest('.content-section');
+        if (!section) return false;
+
+        var state = { fileId: section.id };
+        if (!section.classList.contains('active') || isInitialLoad) {
+            showFile(section.id, skipHistory);
+            history.replaceState(state, '', '#' + target.id);
+        } else if (skipHistory) {
+            history.replaceState(state, '', '#' + target.id);
+        } else {
+            history.pushState(state, '', '#' + target.id);
+        }
+        requestAnimationFrame(function() {
+            target.scrollIntoView();
+        });
+        return true;
+    }
+
     function openSidebar() {
         if (isMobile()) {
             sidebar.classList.add('open');
@@ -1713,7 +1882,10 @@ const sidebarJS = `
                 return;
             }
         }
-        // If not a file section, let the browser handle it (regular anchor)
+        // Otherwise it is an anchor such as a heading, maybe in another file
+        if (showAnchor(fileId)) {
+            e.preventDefault();
+        }
     });
 
     openBtn.addEventListener('click', openSidebar);
@@ -1840,7 +2012,7 @@ const sidebarJS = `
         }
         if (exists) {
             showFile(fileId, true);
-        } else if (fileLinks.length > 0) {
+        } else if (!showAnchor(fileId, true) && fileLinks.length > 0) {
             showFile(fileLinks[0].dataset.file, true);
         }
     } else if (fileLinks.length > 0) {
@@ -1849,6 +2021,10 @@ const sidebarJS = `
 
     // Handle browser back/forward navigation
     window.addEventListener('popstate', function(e) {
+        var hash = window.location.hash.slice(1);
+        if (e.state && e.state.fileId && hash !== e.state.fileId && showAnchor(hash, true)) {
+            return;
+        }
         var fileId;
         if (e.state && e.state.fileId) {
             fileId = e.state.fileId;
@@ -2074,8 +2250,12 @@ const sidebarJS = `
         wrapper.appendChild(btn);
 
         btn.addEventListener('click', function() {
-            var code = pre.querySelector('code');
-            var text = code ? code.textContent : pre.textContent;
+            // Copy only the code: no line numbers or removed diff lines
+            var code = (pre.querySelector('code') || pre).cloneNode(true);
+            code.querySelectorAll('.hl-ln, .code-line-removed').forEach(function(el) {
+                el.remove();
+            });
+            var text = code.textContent;
             navigator.clipboard.writeText(text).then(function() {
                 btn.innerHTML = checkIcon;
                 btn.classList.add('copied');
@@ -2765,12 +2945,14 @@ const multiFileMermaidScript = `
         (function() {
             'use strict';
 
-            // Find all mermaid code blocks
-            var mermaidBlocks = document.querySelectorAll('.markdown-body pre code.language-mermaid');
+            // Find all mermaid code blocks, except the source of blocks already
+            // rendered by a configured renderer
+            var mermaidBlocks = document.querySelectorAll('.markdown-body pre code.language-mermaid:not(.diagram-source code)');
             if (mermaidBlocks.length === 0) return;
 
-            // Detect dark mode
+            // Detect dark mode, honouring the light/dark/system toggle
             function isDarkMode() {
+                if (window.mdpColorScheme) return window.mdpColorScheme.isDark();
                 return window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches;
             }
 
@@ -2871,7 +3053,12 @@ const multiFileMermaidScript = `
                     }
 
                     // Listen for theme changes and re-render diagrams
-                    if (window.matchMedia) {
+                    if (window.mdpColorScheme) {
+                        window.mdpColorScheme.onChange(function() {
+                            var newTheme = isDarkMode() ? 'dark' : 'default';
+                            rerenderAllDiagrams(newTheme, 'mermaid-theme');
+                        });
+                    } else if (window.matchMedia) {
                         window.matchMedia('(prefers-color-scheme: dark)').addEventListener('change', function() {
                             var newTheme = isDarkMode() ? 'dark' : 'default';
                             rerenderAllDiagrams(newTheme, 'mermaid-theme');
@@ -2903,37 +3090,48 @@ const multiFileMermaidScript = `
     </script>`
 
 // GenerateMulti creates an HTML document with sidebar navigation for multiple files.
-func GenerateMulti(title string, tree *filetree.TreeNode, files []filetree.FileEntry) string {
-	sidebarHTML := generateSidebarHTML(tree)
-	contentHTML := generateContentSections(files)
-
-	return fmt.Sprintf(multiFileTemplate,
-		html.EscapeString(title),
-		githubMarkdownCSS,
-		chromaCSS,
-		sidebarCSS,
-		sidebarHTML,
-		contentHTML,
-		sidebarJS,
-		multiFileMermaidScript,
-	)
+func GenerateMulti(title string, tree *filetree.TreeNode, files []filetree.FileEntry, opts ...Option) string {
+	return generateMulti(title, tree, files, 0, newOptions(opts))
 }
 
 // GenerateMultiWithLiveReload creates an HTML document with sidebar navigation and live reload.
-func GenerateMultiWithLiveReload(title string, tree *filetree.TreeNode, files []filetree.FileEntry, port int) string {
+func GenerateMultiWithLiveReload(title string, tree *filetree.TreeNode, files []filetree.FileEntry, port int, opts ...Option) string {
+	return generateMulti(title, tree, files, port, newOptions(opts))
+}
+
+func generateMulti(title string, tree *filetree.TreeNode, files []filetree.FileEntry, port int, o options) string {
 	sidebarHTML := generateSidebarHTML(tree)
 	contentHTML := generateContentSections(files)
-	liveReloadScript := fmt.Sprintf(multiFileLiveReloadScript, port)
+	scripts := lintPanel(o, files) + spellMarks(o, files) + codeThemeSwitcherScript(o.codeThemeLight, o.codeThemeDark) + csvTableScript + multiFileMermaidScript + o.extraScripts
+	if port > 0 {
+		scripts += fmt.Sprintf(multiFileLiveReloadScript, port)
+	}
+	head, script := o.security(port)
+
+	if o.layout != nil {
+		return o.layout.render(Page{
+			Title:   title,
+			Head:    htmltemplate.HTML(layoutHead(o, head, script)),
+			Sidebar: htmltemplate.HTML(sidebarHTML),
+			Content: htmltemplate.HTML(contentHTML),
+			Scripts: htmltemplate.HTML(script(copyButtonScript + scripts)),
+			Multi:   true,
+		})
+	}
 
-	return fmt.Sprintf(multiFileTemplate,
+	return fmt.Sprintf(script(multiFileTemplate),
+		head,
 		html.EscapeString(title),
-		githubMarkdownCSS,
-		chromaCSS,
-		sidebarCSS,
+		githubMarkdownCSS+wikiLinkCSS+extensionCSS+codeBlockCSS,
+		codeThemeCSS(o.codeThemeLight, o.codeThemeDark),
+		sidebarCSS+graphCSS+o.extraCSS+lintStyles(o)+spellStyles(o),
+		customCSSStyle(o.customCSS)+"\n    "+script(colorSchemeScript(o.colorScheme)),
+		presentButton(o.viewToggle),
+		graphButtonHTML,
 		sidebarHTML,
 		contentHTML,
 		sidebarJS,
-		multiFileMermaidScript+liveReloadScript,
+		script(graphView(files)+scripts),
 	)
 }
 
@@ -2961,15 +3159,60 @@ func renderTreeNode(buf *strings.Builder, node *filetree.TreeNode) {
 	} else if node.File != nil {
 		buf.WriteString("<li>")
 		buf.WriteString(fmt.Sprintf(
-			`<a href="#%s" data-file="%s">%s</a>`,
+			`<a href="#%s" data-file="%s"%s>%s</a>`,
 			html.EscapeString(node.File.ID),
 			html.EscapeString(node.File.ID),
+			statusAttrs(node.File.Status),
 			html.EscapeString(node.File.Name),
 		))
 		buf.WriteString("</li>")
 	}
 }
 
+// statusBadges are the sidebar badge letters for file statuses.
+var statusBadges = map[string]string{
+	"added":     "A",
+	"modified":  "M",
+	"removed":   "D",
+	"renamed":   "R",
+	"staged":    "S",
+	"untracked": "U",
+}
+
+// statusAttrs returns the attributes that badge a sidebar link with its
+// status. The badge is drawn with CSS so it stays out of the link text.
+func statusAttrs(status string) string {
+	if status == "" {
+		return ""
+	}
+	badge, ok := statusBadges[status]
+	if !ok {
+		badge = strings.ToUpper(status[:1])
+	}
+	return fmt.Sprintf(` data-status="%s" data-badge="%s" title="%s"`,
+		html.EscapeString(status), html.EscapeString(badge), html.EscapeString(strings.ToUpper(status[:1])+status[1:]))
+}
+
+// backlinksHTML lists the files that link to a file, each with the
+// sentences its links appear in.
+func backlinksHTML(backlinks []filetree.Backlink) string {
+	if len(backlinks) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString(`<aside class="backlinks"><h2 class="backlinks-title">Linked from</h2><ul class="backlinks-list">`)
+	for _, b := range backlinks {
+		fmt.Fprintf(&buf, `<li><a class="backlinks-file" href="#%s" title="%s">%s</a>`,
+			html.EscapeString(b.ID), html.EscapeString(b.RelPath), html.EscapeString(b.Name))
+		for _, excerpt := range b.Excerpts {
+			fmt.Fprintf(&buf, `<p class="backlinks-excerpt">%s</p>`, html.EscapeString(excerpt))
+		}
+		buf.WriteString(`</li>`)
+	}
+	buf.WriteString(`</ul></aside>`)
+	return buf.String()
+}
+
 // generateContentSections creates the content divs for each file.
 func generateContentSections(files []filetree.FileEntry) string {
 	var buf strings.Builder
@@ -2979,10 +3222,12 @@ func generateContentSections(files []filetree.FileEntry) string {
 			class = "content-section active"
 		}
 		buf.WriteString(fmt.Sprintf(
-			`<section id="%s" class="%s"><article class="markdown-body">%s</article></section>`,
+			`<section id="%s" class="%s">%s<article class="markdown-body">%s</article>%s</section>`,
 			html.EscapeString(f.ID),
 			class,
+			fileMeta(f.Status, f.Author, f.Modified),
 			f.Content,
+			backlinksHTML(f.Backlinks),
 		))
 	}
 	return buf.String()
@@ -0,0 +1,172 @@
+package template
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"mdp/internal/lint"
+	"mdp/internal/spell"
+)
+
+// Option configures optional page generation behavior.
+type Option func(*options)
+
+type options struct {
+	safe           bool
+	codeThemeLight string
+	codeThemeDark  string
+	colorScheme    string
+	customCSS      string
+	layout         *Layout
+	viewToggle     string
+	fileStatus     string
+	fileAuthor     string
+	fileModified   time.Time
+	lint           bool
+	lintProblems   []lint.Problem
+	misspellings   []spell.Misspelling
+
+	// Set by page generators that extend the multi-file page
+	extraCSS     string
+	extraScripts string
+}
+
+// WithSafeMode generates pages for untrusted content: a strict
+// Content-Security-Policy only allows the page's own scripts to run,
+// and Mermaid diagrams are rendered with its strict security level.
+// Use together with converter.WithSafeMode.
+func WithSafeMode() Option {
+	return func(o *options) {
+		o.safe = true
+	}
+}
+
+// WithCodeThemes highlights code with the given Chroma styles in light and
+// dark mode. An empty name keeps the built-in GitHub styles for that mode.
+func WithCodeThemes(light, dark string) Option {
+	return func(o *options) {
+		o.codeThemeLight = light
+		o.codeThemeDark = dark
+	}
+}
+
+// WithColorScheme sets the initial color scheme: "light", "dark" or
+// "system" (the default). Readers can still switch it in the page.
+func WithColorScheme(mode string) Option {
+	return func(o *options) {
+		o.colorScheme = mode
+	}
+}
+
+// WithCustomCSS adds a stylesheet after the built-in styles, so its rules
+// take precedence. See ReadCustomCSS.
+func WithCustomCSS(css string) Option {
+	return func(o *options) {
+		o.customCSS = css
+	}
+}
+
+// WithLayout replaces the built-in page layout. See ParseLayout and Page.
+func WithLayout(l *Layout) Option {
+	return func(o *options) {
+		o.layout = l
+	}
+}
+
+// WithViewToggle links the page to another view of the same document: a
+// preview gets a button to present it as slides, and slides get a button
+// to exit to the preview. The live server serves both views.
+func WithViewToggle(url string) Option {
+	return func(o *options) {
+		o.viewToggle = url
+	}
+}
+
+// WithFileMeta shows a single document's change status (e.g. "modified")
+// and the author and date of its last change above it. Multi-file pages
+// take these from each FileEntry instead.
+func WithFileMeta(status, author string, modified time.Time) Option {
+	return func(o *options) {
+		o.fileStatus = status
+		o.fileAuthor = author
+		o.fileModified = modified
+	}
+}
+
+// WithLintProblems shows lint problems in a collapsible panel, or that
+// there are none. In multi-file pages each problem links to its file.
+func WithLintProblems(problems []lint.Problem) Option {
+	return func(o *options) {
+		o.lint = true
+		o.lintProblems = problems
+	}
+}
+
+// WithMisspellings underlines misspelled words in the preview, with the
+// suggested corrections as a tooltip.
+func WithMisspellings(misspellings []spell.Misspelling) Option {
+	return func(o *options) {
+		o.misspellings = misspellings
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// mermaidOrigin is where the templates load Mermaid from.
+const mermaidOrigin = "https://cdn.jsdelivr.net"
+
+const cspMetaTag = `
+    <meta http-equiv="Content-Security-Policy" content="%s">`
+
+// security returns extra markup for the document head and a function that
+// prepares the template and each script for o. In safe mode every script tag
+// gets a nonce, the head carries a policy that only allows scripts with that
+// nonce, and Mermaid uses its strict security level. port is the live reload
+// port, or 0.
+func (o options) security(port int) (string, func(string) string) {
+	if !o.safe {
+		return "", func(s string) string { return s }
+	}
+
+	nonce := newNonce()
+	script := func(s string) string {
+		s = strings.ReplaceAll(s, "securityLevel: 'loose'", "securityLevel: 'strict'")
+		return strings.ReplaceAll(s, "<script", `<script nonce="`+nonce+`"`)
+	}
+	return fmt.Sprintf(cspMetaTag, contentSecurityPolicy(nonce, port)), script
+}
+
+// contentSecurityPolicy returns the policy used in safe mode. Inline event
+// handlers, javascript: URLs, plugins, frames and forms are all blocked.
+func contentSecurityPolicy(nonce string, port int) string {
+	connect := "'none'"
+	if port > 0 {
+		connect = fmt.Sprintf("ws://localhost:%d", port)
+	}
+	return strings.Join([]string{
+		"default-src 'none'",
+		"script-src 'nonce-" + nonce + "' " + mermaidOrigin,
+		"style-src 'unsafe-inline'",
+		"img-src * data:",
+		"font-src data: " + mermaidOrigin,
+		"connect-src " + connect,
+		"base-uri 'none'",
+		"form-action 'none'",
+	}, "; ")
+}
+
+// newNonce returns a random value for the script-src nonce.
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
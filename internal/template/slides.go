@@ -0,0 +1,834 @@
+package template
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"mdp/internal/converter"
+)
+
+const slidesTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">%s
+    <title>%s</title>
+    <style>
+        %s
+    </style>
+    <style id="mdp-code-theme">
+        %s
+    </style>
+    <style>
+        %s
+    </style>%s
+</head>
+<body class="slides-body">
+    <div class="slides-stage">
+        <div class="slides-deck">
+%s
+        </div>
+    </div>
+    <div class="slides-progress"><div class="slides-progress-bar"></div></div>
+    <nav class="slides-controls" aria-label="Slide controls">%s
+        <button class="slides-btn slides-prev-btn" aria-label="Previous slide" title="Previous slide (←)">
+            <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><polyline points="15 18 9 12 15 6"></polyline></svg>
+        </button>
+        <span class="slides-counter"></span>
+        <button class="slides-btn slides-next-btn" aria-label="Next slide" title="Next slide (→)">
+            <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><polyline points="9 18 15 12 9 6"></polyline></svg>
+        </button>
+        <div class="slides-divider"></div>
+        <button class="slides-btn color-scheme-btn" aria-label="Toggle light/dark theme" title="Toggle light/dark theme"></button>
+        <button class="slides-btn slides-presenter-btn" aria-label="Presenter view" title="Presenter view (P)">
+            <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><rect x="2" y="3" width="20" height="14" rx="2"></rect><path d="M8 21h8M12 17v4M7 8h6M7 12h10"></path></svg>
+        </button>
+        <button class="slides-btn slides-fullscreen-btn" aria-label="Fullscreen" title="Fullscreen (F)">
+            <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M8 3H5a2 2 0 0 0-2 2v3M21 8V5a2 2 0 0 0-2-2h-3M3 16v3a2 2 0 0 0 2 2h3M16 21h3a2 2 0 0 0 2-2v-3"></path></svg>
+        </button>
+    </nav>
+    %s
+</body>
+</html>`
+
+const slidesExitLink = `
+        <a class="slides-btn slides-exit-btn" href="%s" aria-label="Exit slides" title="Exit slides">
+            <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><line x1="18" y1="6" x2="6" y2="18"></line><line x1="6" y1="6" x2="18" y2="18"></line></svg>
+        </a>
+        <div class="slides-divider"></div>`
+
+const slidesCSS = `
+:root {
+    --fg-color: #1f2328;
+    --fg-muted: #59636e;
+    --bg-color: #ffffff;
+    --panel-bg: #f6f8fa;
+    --panel-border: #d1d9e0;
+    --panel-hover: #e6e8eb;
+    --accent-color: #0969da;
+}
+
+@media (prefers-color-scheme: dark) {
+    :root {
+        --fg-color: #e6edf3;
+        --fg-muted: #9198a1;
+        --bg-color: #0d1117;
+        --panel-bg: #161b22;
+        --panel-border: #3d444d;
+        --panel-hover: #21262d;
+        --accent-color: #58a6ff;
+    }
+}
+
+html,
+body {
+    height: 100%;
+    margin: 0;
+    overflow: hidden;
+}
+
+body {
+    background: var(--bg-color);
+    color: var(--fg-color);
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+}
+
+/* Slides are laid out at 1280x720 and scaled to fit the window */
+.slides-stage {
+    position: fixed;
+    inset: 0;
+    overflow: hidden;
+}
+
+.slides-deck {
+    position: absolute;
+    top: 0;
+    left: 0;
+    width: 1280px;
+    height: 720px;
+    transform-origin: 0 0;
+}
+
+.slide.markdown-body {
+    position: absolute;
+    inset: 0;
+    box-sizing: border-box;
+    display: none;
+    flex-direction: column;
+    justify-content: center;
+    padding: 56px 80px;
+    overflow: auto;
+    font-size: 28px;
+    background: transparent;
+}
+
+.slide.markdown-body.active {
+    display: flex;
+}
+
+.slide.markdown-body > * {
+    flex-shrink: 0;
+}
+
+.slide.markdown-body.slide-title {
+    align-items: center;
+    text-align: center;
+}
+
+.slide.markdown-body h1,
+.slide.markdown-body h2 {
+    border-bottom: none;
+    padding-bottom: 0;
+}
+
+.slide.markdown-body h1 {
+    font-size: 2.2em;
+}
+
+.slide.markdown-body h2 {
+    font-size: 1.6em;
+}
+
+.slide.markdown-body pre {
+    font-size: 0.7em;
+}
+
+.slide.markdown-body img {
+    max-height: 520px;
+}
+
+.slide-notes {
+    display: none !important;
+}
+
+.slides-progress {
+    position: fixed;
+    left: 0;
+    right: 0;
+    bottom: 0;
+    height: 4px;
+}
+
+.slides-progress-bar {
+    width: 0;
+    height: 100%;
+    background: var(--accent-color);
+    transition: width 0.2s ease;
+}
+
+.slides-controls {
+    position: fixed;
+    right: 16px;
+    bottom: 16px;
+    display: flex;
+    align-items: center;
+    gap: 4px;
+    padding: 4px;
+    background: var(--panel-bg);
+    border: 1px solid var(--panel-border);
+    border-radius: 8px;
+    z-index: 10;
+    transition: opacity 0.3s ease;
+}
+
+.slides-idle .slides-controls {
+    opacity: 0;
+    pointer-events: none;
+}
+
+.slides-idle {
+    cursor: none;
+}
+
+.slides-btn {
+    display: flex;
+    align-items: center;
+    justify-content: center;
+    background: transparent;
+    border: none;
+    border-radius: 6px;
+    padding: 6px;
+    cursor: pointer;
+    color: var(--fg-muted);
+    transition: all 0.2s ease;
+}
+
+.slides-btn:hover {
+    color: var(--fg-color);
+    background: var(--panel-hover);
+}
+
+.slides-btn svg {
+    width: 18px;
+    height: 18px;
+}
+
+.slides-divider {
+    width: 1px;
+    height: 20px;
+    margin: 0 4px;
+    background: var(--panel-border);
+}
+
+.slides-counter {
+    min-width: 64px;
+    font-size: 13px;
+    color: var(--fg-muted);
+    text-align: center;
+    font-variant-numeric: tabular-nums;
+}
+
+/* Presenter view */
+.presenter-header {
+    position: fixed;
+    top: 0;
+    left: 0;
+    right: 0;
+    height: 56px;
+    display: flex;
+    align-items: center;
+    gap: 32px;
+    padding: 0 16px;
+    font-size: 20px;
+    font-variant-numeric: tabular-nums;
+}
+
+.presenter-timer {
+    cursor: pointer;
+}
+
+.presenter-clock,
+.presenter-counter {
+    color: var(--fg-muted);
+}
+
+.presenter-mode .slides-stage {
+    top: 56px;
+    left: 16px;
+    right: calc(36vw + 16px);
+    bottom: 16px;
+    border: 1px solid var(--panel-border);
+    border-radius: 8px;
+}
+
+.presenter-side {
+    position: fixed;
+    top: 56px;
+    right: 16px;
+    bottom: 16px;
+    width: calc(36vw - 16px);
+    display: flex;
+    flex-direction: column;
+    gap: 8px;
+}
+
+.presenter-label {
+    font-size: 12px;
+    font-weight: 600;
+    color: var(--fg-muted);
+    text-transform: uppercase;
+    letter-spacing: 0.5px;
+}
+
+.presenter-next {
+    position: relative;
+    flex-shrink: 0;
+    aspect-ratio: 16 / 9;
+    overflow: hidden;
+    border: 1px solid var(--panel-border);
+    border-radius: 8px;
+}
+
+.presenter-end {
+    display: flex;
+    align-items: center;
+    justify-content: center;
+    height: 100%;
+    color: var(--fg-muted);
+}
+
+.presenter-notes {
+    flex: 1;
+    overflow: auto;
+    font-size: 20px;
+    line-height: 1.5;
+}
+
+.presenter-notes p {
+    margin: 0 0 12px;
+}
+
+.presenter-empty {
+    color: var(--fg-muted);
+}
+
+.presenter-mode .slides-progress {
+    display: none;
+}
+
+@page {
+    size: 1280px 720px;
+    margin: 0;
+}
+
+@media print {
+    html,
+    body {
+        height: auto;
+        overflow: visible;
+    }
+
+    .slides-stage,
+    .slides-deck {
+        position: static;
+        width: auto;
+        height: auto;
+        transform: none !important;
+    }
+
+    .slide.markdown-body {
+        position: relative;
+        display: flex;
+        width: 1280px;
+        height: 720px;
+        break-after: page;
+    }
+
+    .slides-progress,
+    .slides-controls {
+        display: none;
+    }
+}
+`
+
+const slidesJS = `
+    <script>
+        (function() {
+            'use strict';
+
+            var WIDTH = 1280;
+            var HEIGHT = 720;
+
+            var slides = Array.prototype.slice.call(document.querySelectorAll('.slides-deck > .slide'));
+            var stage = document.querySelector('.slides-stage');
+            var deck = stage.querySelector('.slides-deck');
+            var counter = document.querySelector('.slides-counter');
+            var progress = document.querySelector('.slides-progress-bar');
+            var presenter = /^#presenter/.test(location.hash);
+            var current = 0;
+            var peer = null;
+            var channel = window.BroadcastChannel ? new BroadcastChannel('mdp-slides:' + location.pathname) : null;
+            var presenterView = null;
+
+            // Center slides that only contain headings
+            slides.forEach(function(slide) {
+                var blocks = Array.prototype.filter.call(slide.children, function(el) {
+                    return !el.classList.contains('slide-notes');
+                });
+                if (blocks.length > 0 && blocks.every(function(el) { return /^H[1-6]$/.test(el.tagName); })) {
+                    slide.classList.add('slide-title');
+                }
+            });
+
+            function fit(container, content) {
+                var w = container.clientWidth;
+                var h = container.clientHeight;
+                var scale = Math.min(w / WIDTH, h / HEIGHT);
+                var x = (w - WIDTH * scale) / 2;
+                var y = (h - HEIGHT * scale) / 2;
+                content.style.transform = 'translate(' + x + 'px, ' + y + 'px) scale(' + scale + ')';
+            }
+
+            function fitAll() {
+                fit(stage, deck);
+                if (presenterView) fit(presenterView.next, presenterView.nextDeck);
+            }
+
+            function slideFromHash() {
+                var match = location.hash.match(/(\d+)$/);
+                return match ? parseInt(match[1], 10) - 1 : 0;
+            }
+
+            // Mermaid diagrams are rendered when their slide is first shown
+            function renderDiagrams(slide) {
+                if (slide && window.mdpRenderSlideDiagrams) {
+                    return window.mdpRenderSlideDiagrams(slide);
+                }
+                return Promise.resolve();
+            }
+
+            function show(index, fromPeer) {
+                if (slides.length === 0) return;
+                current = Math.max(0, Math.min(slides.length - 1, index));
+
+                slides.forEach(function(slide, i) {
+                    slide.classList.toggle('active', i === current);
+                    slide.setAttribute('aria-hidden', i === current ? 'false' : 'true');
+                });
+                counter.textContent = (current + 1) + ' / ' + slides.length;
+                progress.style.width = ((current + 1) / slides.length * 100) + '%';
+
+                var hash = (presenter ? '#presenter/' : '#') + (current + 1);
+                if (location.hash !== hash) {
+                    try {
+                        history.replaceState(null, '', hash);
+                    } catch (e) {}
+                }
+
+                renderDiagrams(slides[current]);
+                if (presenterView) updatePresenter();
+                if (!fromPeer) broadcast();
+            }
+
+            // Keep the audience and presenter windows on the same slide
+            function broadcast() {
+                var message = { mdpSlide: current };
+                if (peer && !peer.closed) peer.postMessage(message, '*');
+                if (channel) channel.postMessage(message);
+            }
+
+            function receive(data, source) {
+                if (!data || typeof data !== 'object') return;
+                if (typeof data.mdpSlide === 'number') {
+                    if (source) peer = source;
+                    if (data.mdpSlide !== current) show(data.mdpSlide, true);
+                } else if (data.mdpSlideHello) {
+                    if (source) peer = source;
+                    broadcast();
+                }
+            }
+
+            window.addEventListener('message', function(event) {
+                receive(event.data, event.source);
+            });
+            if (channel) {
+                channel.onmessage = function(event) {
+                    receive(event.data, null);
+                };
+            }
+
+            function openPresenter() {
+                if (presenter) return;
+                var url = location.href.split('#')[0] + '#presenter/' + (current + 1);
+                var win = window.open(url, 'mdp-presenter', 'width=1100,height=700');
+                if (win) peer = win;
+            }
+
+            function toggleFullscreen() {
+                if (document.fullscreenElement) {
+                    document.exitFullscreen();
+                } else if (document.documentElement.requestFullscreen) {
+                    document.documentElement.requestFullscreen();
+                }
+            }
+
+            function pad(n) {
+                return (n < 10 ? '0' : '') + n;
+            }
+
+            function buildPresenter() {
+                document.body.classList.add('presenter-mode');
+
+                var header = document.createElement('div');
+                header.className = 'presenter-header';
+                var timer = document.createElement('span');
+                timer.className = 'presenter-timer';
+                timer.title = 'Click or press R to restart';
+                var clock = document.createElement('span');
+                clock.className = 'presenter-clock';
+                var count = document.createElement('span');
+                count.className = 'presenter-counter';
+                header.appendChild(timer);
+                header.appendChild(clock);
+                header.appendChild(count);
+
+                var side = document.createElement('div');
+                side.className = 'presenter-side';
+                var nextLabel = document.createElement('div');
+                nextLabel.className = 'presenter-label';
+                nextLabel.textContent = 'Next';
+                var next = document.createElement('div');
+                next.className = 'presenter-next';
+                var nextDeck = document.createElement('div');
+                nextDeck.className = 'slides-deck';
+                next.appendChild(nextDeck);
+                var notesLabel = document.createElement('div');
+                notesLabel.className = 'presenter-label';
+                notesLabel.textContent = 'Notes';
+                var notes = document.createElement('div');
+                notes.className = 'presenter-notes';
+                side.appendChild(nextLabel);
+                side.appendChild(next);
+                side.appendChild(notesLabel);
+                side.appendChild(notes);
+
+                document.body.appendChild(header);
+                document.body.appendChild(side);
+
+                var started = Date.now();
+                function tick() {
+                    var elapsed = Math.floor((Date.now() - started) / 1000);
+                    timer.textContent = pad(Math.floor(elapsed / 60)) + ':' + pad(elapsed % 60);
+                    var now = new Date();
+                    clock.textContent = pad(now.getHours()) + ':' + pad(now.getMinutes());
+                }
+                function restart() {
+                    started = Date.now();
+                    tick();
+                }
+                timer.addEventListener('click', restart);
+                tick();
+                setInterval(tick, 1000);
+
+                presenterView = {
+                    count: count,
+                    next: next,
+                    nextDeck: nextDeck,
+                    notes: notes,
+                    restart: restart
+                };
+            }
+
+            function updatePresenter() {
+                var view = presenterView;
+                view.count.textContent = 'Slide ' + (current + 1) + ' of ' + slides.length;
+
+                view.notes.innerHTML = '';
+                var notes = slides[current].querySelector('.slide-notes');
+                if (notes) {
+                    Array.prototype.forEach.call(notes.childNodes, function(node) {
+                        view.notes.appendChild(node.cloneNode(true));
+                    });
+                } else {
+                    var empty = document.createElement('p');
+                    empty.className = 'presenter-empty';
+                    empty.textContent = 'No notes for this slide.';
+                    view.notes.appendChild(empty);
+                }
+
+                var next = slides[current + 1];
+                view.nextDeck.innerHTML = '';
+                if (!next) {
+                    var end = document.createElement('div');
+                    end.className = 'presenter-end';
+                    end.textContent = 'End of presentation';
+                    view.nextDeck.appendChild(end);
+                    fit(view.next, view.nextDeck);
+                    return;
+                }
+                renderDiagrams(next).then(function() {
+                    if (slides[current + 1] !== next) return;
+                    var clone = next.cloneNode(true);
+                    clone.classList.add('active');
+                    clone.setAttribute('aria-hidden', 'true');
+                    view.nextDeck.innerHTML = '';
+                    view.nextDeck.appendChild(clone);
+                    fit(view.next, view.nextDeck);
+                });
+            }
+
+            document.addEventListener('keydown', function(e) {
+                if (e.metaKey || e.ctrlKey || e.altKey) return;
+                if (e.target.closest && e.target.closest('input, textarea, select')) return;
+
+                switch (e.key) {
+                    case 'ArrowRight':
+                    case 'ArrowDown':
+                    case 'PageDown':
+                    case 'Enter':
+                    case 'l':
+                    case 'j':
+                        show(current + 1);
+                        break;
+                    case ' ':
+                        show(current + (e.shiftKey ? -1 : 1));
+                        break;
+                    case 'ArrowLeft':
+                    case 'ArrowUp':
+                    case 'PageUp':
+                    case 'Backspace':
+                    case 'h':
+                    case 'k':
+                        show(current - 1);
+                        break;
+                    case 'Home':
+                        show(0);
+                        break;
+                    case 'End':
+                        show(slides.length - 1);
+                        break;
+                    case 'f':
+                        toggleFullscreen();
+                        break;
+                    case 'p':
+                        openPresenter();
+                        break;
+                    case 'r':
+                        if (!presenterView) return;
+                        presenterView.restart();
+                        break;
+                    default:
+                        return;
+                }
+                e.preventDefault();
+            });
+
+            // Swipe on touch screens
+            var touchX = null;
+            stage.addEventListener('touchstart', function(e) {
+                touchX = e.touches[0].clientX;
+            }, { passive: true });
+            stage.addEventListener('touchend', function(e) {
+                if (touchX === null) return;
+                var dx = e.changedTouches[0].clientX - touchX;
+                touchX = null;
+                if (Math.abs(dx) > 50) show(current + (dx < 0 ? 1 : -1));
+            });
+
+            // Buttons give focus back to the page so Space and Enter keep navigating
+            function onClick(selector, fn) {
+                var btn = document.querySelector(selector);
+                btn.addEventListener('click', function() {
+                    btn.blur();
+                    fn();
+                });
+                return btn;
+            }
+            onClick('.slides-prev-btn', function() { show(current - 1); });
+            onClick('.slides-next-btn', function() { show(current + 1); });
+            onClick('.slides-fullscreen-btn', toggleFullscreen);
+            var presenterBtn = onClick('.slides-presenter-btn', openPresenter);
+            if (presenter) presenterBtn.style.display = 'none';
+
+            // Hide the controls and cursor while the mouse is still
+            var idleTimer = null;
+            document.addEventListener('mousemove', function() {
+                document.body.classList.remove('slides-idle');
+                clearTimeout(idleTimer);
+                idleTimer = setTimeout(function() {
+                    document.body.classList.add('slides-idle');
+                }, 2500);
+            });
+
+            window.addEventListener('hashchange', function() {
+                show(slideFromHash());
+            });
+            window.addEventListener('resize', fitAll);
+            document.addEventListener('mdp-mermaid-ready', function() {
+                show(current, true);
+            });
+
+            if (presenter) buildPresenter();
+            fitAll();
+            show(slideFromHash(), true);
+
+            if (presenter && window.opener) {
+                peer = window.opener;
+                peer.postMessage({ mdpSlideHello: true }, '*');
+            }
+        })();
+    </script>`
+
+const slidesMermaidScript = `
+    <script type="module">
+        (function() {
+            'use strict';
+
+            if (!document.querySelector('.slide pre code.language-mermaid:not(.diagram-source code)')) return;
+
+            // Detect dark mode, honouring the light/dark/system toggle
+            function isDarkMode() {
+                if (window.mdpColorScheme) return window.mdpColorScheme.isDark();
+                return window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches;
+            }
+
+            import('https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs')
+                .then(function(module) {
+                    var mermaid = module.default;
+                    var diagramCounter = 0;
+
+                    function initialize() {
+                        mermaid.initialize({
+                            startOnLoad: false,
+                            theme: isDarkMode() ? 'dark' : 'default',
+                            securityLevel: 'loose'
+                        });
+                    }
+
+                    function draw(wrapper) {
+                        var rendered = wrapper.querySelector('.mermaid-rendered');
+                        return mermaid.render('mermaid-slide-' + (++diagramCounter), wrapper.dataset.source)
+                            .then(function(result) {
+                                rendered.innerHTML = result.svg;
+                            })
+                            .catch(function(err) {
+                                var error = document.createElement('div');
+                                error.className = 'mermaid-error';
+                                error.textContent = 'Error rendering diagram: ' + err.message;
+                                rendered.innerHTML = '';
+                                rendered.appendChild(error);
+                            });
+                    }
+
+                    // Render the diagrams of one slide, the first time it is shown
+                    window.mdpRenderSlideDiagrams = function(slide) {
+                        var pending = [];
+                        slide.querySelectorAll('pre > code.language-mermaid:not(.diagram-source code)').forEach(function(codeEl) {
+                            var preEl = codeEl.parentElement;
+                            var wrapper = document.createElement('div');
+                            wrapper.className = 'mermaid-wrapper';
+                            wrapper.dataset.source = codeEl.textContent;
+                            var rendered = document.createElement('div');
+                            rendered.className = 'mermaid-rendered';
+                            wrapper.appendChild(rendered);
+                            preEl.parentNode.replaceChild(wrapper, preEl);
+                            pending.push(draw(wrapper));
+                        });
+                        return Promise.all(pending);
+                    };
+
+                    initialize();
+                    if (window.mdpColorScheme) {
+                        window.mdpColorScheme.onChange(function() {
+                            initialize();
+                            document.querySelectorAll('.slides-deck > .slide .mermaid-wrapper').forEach(draw);
+                        });
+                    }
+
+                    document.dispatchEvent(new Event('mdp-mermaid-ready'));
+                })
+                .catch(function(err) {
+                    console.error('Failed to load Mermaid.js:', err);
+                });
+        })();
+    </script>`
+
+const slidesMermaidCSS = `
+.slide .mermaid-rendered {
+    display: flex;
+    justify-content: center;
+}
+
+.slide .mermaid-rendered svg {
+    max-width: 100%;
+    max-height: 540px;
+}
+
+.slide .mermaid-error {
+    color: #cf222e;
+    font-size: 0.6em;
+}
+`
+
+// GenerateSlides creates a presentation with one full-screen slide per
+// entry, keyboard navigation and a presenter view.
+func GenerateSlides(title string, slides []converter.Slide, opts ...Option) string {
+	return generateSlides(title, slides, 0, newOptions(opts))
+}
+
+// GenerateSlidesWithLiveReload creates a presentation with live reload support.
+func GenerateSlidesWithLiveReload(title string, slides []converter.Slide, port int, opts ...Option) string {
+	return generateSlides(title, slides, port, newOptions(opts))
+}
+
+func generateSlides(title string, slides []converter.Slide, port int, o options) string {
+	scripts := slidesJS + slidesMermaidScript
+	if port > 0 {
+		scripts += fmt.Sprintf(liveReloadScript, port)
+	}
+	var exit string
+	if o.viewToggle != "" {
+		exit = fmt.Sprintf(slidesExitLink, html.EscapeString(o.viewToggle))
+	}
+	head, script := o.security(port)
+
+	return fmt.Sprintf(script(slidesTemplate),
+		head,
+		html.EscapeString(title),
+		githubMarkdownCSS+wikiLinkCSS+extensionCSS+codeBlockCSS,
+		codeThemeCSS(o.codeThemeLight, o.codeThemeDark),
+		slidesCSS+slidesMermaidCSS,
+		customCSSStyle(o.customCSS)+"\n    "+script(colorSchemeScript(o.colorScheme)),
+		generateSlideSections(slides),
+		exit,
+		script(scripts),
+	)
+}
+
+// generateSlideSections creates a section per slide, with its notes in a
+// hidden aside for the presenter view.
+func generateSlideSections(slides []converter.Slide) string {
+	var buf strings.Builder
+	for i, slide := range slides {
+		fmt.Fprintf(&buf, "            <section class=\"slide markdown-body\" id=\"slide-%d\" aria-label=\"Slide %d\">\n", i+1, i+1)
+		buf.WriteString(slide.Content)
+		if slide.Notes != "" {
+			buf.WriteString("<aside class=\"slide-notes\">\n")
+			buf.WriteString(slide.Notes)
+			buf.WriteString("</aside>\n")
+		}
+		buf.WriteString("            </section>\n")
+	}
+	return buf.String()
+}
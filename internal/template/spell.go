@@ -0,0 +1,175 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"mdp/internal/filetree"
+)
+
+const spellCSS = `
+.markdown-body mark.spell-error {
+    background: none;
+    color: inherit;
+    text-decoration: underline wavy #cf222e;
+    text-decoration-skip-ink: none;
+    text-underline-offset: 3px;
+    cursor: help;
+}
+
+@media (prefers-color-scheme: dark) {
+    .markdown-body mark.spell-error {
+        text-decoration-color: #f85149;
+    }
+}
+
+@media print {
+    .markdown-body mark.spell-error {
+        text-decoration: none;
+    }
+}
+`
+
+// spellScript underlines misspelled words in the rendered text. The words
+// come from the page's spell data, grouped by the element whose text they
+// were found in, and are matched in text nodes outside code and links.
+const spellScript = `
+    <script>
+        (function() {
+            'use strict';
+            var data = document.getElementById('mdp-spell-data');
+            if (!data) {
+                return;
+            }
+            var groups = JSON.parse(data.textContent);
+            var SKIP = ['CODE', 'PRE', 'A', 'SCRIPT', 'STYLE', 'KBD', 'SVG', 'MARK', 'TEXTAREA'];
+            var WORD = /[\p{L}\p{N}_]+(?:['’./@][\p{L}\p{N}_]+)*/gu;
+
+            function skipped(node, root) {
+                for (var el = node.parentNode; el && el !== root; el = el.parentNode) {
+                    if (SKIP.indexOf(el.nodeName.toUpperCase()) !== -1 || el.classList.contains('mermaid')) {
+                        return true;
+                    }
+                }
+                return false;
+            }
+
+            function check(word, words) {
+                if (Object.prototype.hasOwnProperty.call(words, word)) {
+                    return word;
+                }
+                var base = word.replace(/['’]s$/, '');
+                if (base !== word && Object.prototype.hasOwnProperty.call(words, base)) {
+                    return base;
+                }
+                return null;
+            }
+
+            function mark(textNode, words) {
+                var text = textNode.nodeValue;
+                var match, last = 0, found = false;
+                var fragment = document.createDocumentFragment();
+                WORD.lastIndex = 0;
+                while ((match = WORD.exec(text)) !== null) {
+                    var word = check(match[0], words);
+                    if (!word) {
+                        continue;
+                    }
+                    found = true;
+                    fragment.appendChild(document.createTextNode(text.slice(last, match.index)));
+                    var el = document.createElement('mark');
+                    el.className = 'spell-error';
+                    el.textContent = word;
+                    var suggestions = words[word];
+                    el.title = suggestions.length ? 'Did you mean ' + suggestions.join(', ') + '?' : 'Not in dictionary';
+                    fragment.appendChild(el);
+                    last = match.index + word.length;
+                }
+                if (found) {
+                    fragment.appendChild(document.createTextNode(text.slice(last)));
+                    textNode.parentNode.replaceChild(fragment, textNode);
+                }
+            }
+
+            groups.forEach(function(group) {
+                var root = group.section ? document.getElementById(group.section) : document;
+                if (!root) {
+                    return;
+                }
+                root.querySelectorAll('.markdown-body').forEach(function(body) {
+                    var walker = document.createTreeWalker(body, NodeFilter.SHOW_TEXT);
+                    var nodes = [];
+                    while (walker.nextNode()) {
+                        if (!skipped(walker.currentNode, body)) {
+                            nodes.push(walker.currentNode);
+                        }
+                    }
+                    nodes.forEach(function(node) {
+                        mark(node, group.words);
+                    });
+                });
+            });
+        })();
+    </script>`
+
+// spellGroup is the misspelled words in one file, with their suggestions.
+// Section is the ID of the file's section in multi-file pages.
+type spellGroup struct {
+	Section string              `json:"section,omitempty"`
+	Words   map[string][]string `json:"words"`
+}
+
+// spellStyles returns the misspelling styles if the page marks any.
+func spellStyles(o options) string {
+	if len(o.misspellings) == 0 {
+		return ""
+	}
+	return spellCSS
+}
+
+// spellMarks returns the spell data and the script that underlines the
+// misspelled words. In multi-file pages misspellings are matched to files
+// by Misspelling.File and FileEntry.Path.
+func spellMarks(o options, files []filetree.FileEntry) string {
+	if len(o.misspellings) == 0 {
+		return ""
+	}
+
+	sections := make(map[string]string)
+	for _, f := range files {
+		sections[f.Path] = f.ID
+	}
+	var groups []spellGroup
+	index := make(map[string]int)
+	for _, m := range o.misspellings {
+		section := ""
+		if files != nil {
+			id, ok := sections[m.File]
+			if !ok {
+				continue
+			}
+			section = id
+		}
+		i, ok := index[section]
+		if !ok {
+			i = len(groups)
+			index[section] = i
+			groups = append(groups, spellGroup{Section: section, Words: make(map[string][]string)})
+		}
+		suggestions := m.Suggestions
+		if suggestions == nil {
+			suggestions = []string{}
+		}
+		groups[i].Words[m.Word] = suggestions
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+
+	// json.Marshal escapes <, > and &, so the data cannot end the script
+	data, err := json.Marshal(groups)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\n    <script type=\"application/json\" id=\"mdp-spell-data\">%s</script>", data) + spellScript
+}
@@ -3,6 +3,10 @@ package template
 import (
 	_ "embed"
 	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"strings"
+	"time"
 )
 
 //go:embed github-markdown.min.css
@@ -144,6 +148,63 @@ const commentsCSS = `
     margin: 0 4px;
 }
 
+.topbar-select {
+    height: 32px;
+    max-width: 150px;
+    padding: 0 8px;
+    background: transparent;
+    border: 1px solid var(--panel-border);
+    border-radius: 6px;
+    color: var(--fg-muted);
+    font-family: inherit;
+    font-size: 12px;
+    cursor: pointer;
+}
+
+.topbar-select:hover {
+    color: var(--fg-color);
+    background: var(--panel-hover);
+}
+
+.topbar-select option {
+    color: #1f2328;
+    background: #ffffff;
+}
+
+.file-meta {
+    display: flex;
+    align-items: center;
+    flex-wrap: wrap;
+    gap: 8px;
+    margin-bottom: 16px;
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+    font-size: 13px;
+    color: var(--fg-muted);
+}
+
+.file-meta-status {
+    padding: 0 8px;
+    border-radius: 10px;
+    font-size: 12px;
+    font-weight: 600;
+    line-height: 20px;
+    color: #ffffff;
+    background: #9a6700;
+}
+
+.file-meta-status[data-status="added"],
+.file-meta-status[data-status="untracked"] {
+    background: #1a7f37;
+}
+
+.file-meta-status[data-status="removed"] {
+    background: #d1242f;
+}
+
+.file-meta-status[data-status="staged"] {
+    background: #0969da;
+}
+
 /* Comment Button */
 .comment-btn {
     position: absolute;
@@ -952,7 +1013,8 @@ const commentsCSS = `
         height: auto !important;
     }
 
-    .mermaid-source {
+    .mermaid-source,
+    .diagram-source {
         display: none !important;
     }
 
@@ -985,12 +1047,12 @@ const htmlTemplate = `<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">%s
     <title>%s</title>
     <style>
         %s
     </style>
-    <style>
+    <style id="mdp-code-theme">
         %s
     </style>
     <style>
@@ -1050,7 +1112,7 @@ const htmlTemplate = `<!DOCTYPE html>
                 background: #eaeef2;
             }
         }
-    </style>
+    </style>%s
 </head>
 <body>
     <!-- Desktop Top Bar -->
@@ -1059,12 +1121,16 @@ const htmlTemplate = `<!DOCTYPE html>
         <div class="topbar-center">
             <span class="topbar-brand">MARKDOWN PREVIEW</span>
         </div>
-        <div class="topbar-right">
+        <div class="topbar-right">%s
             <button class="topbar-btn topbar-comment-btn" aria-label="Toggle comments" title="Toggle comments (⌘/)">
                 <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15a2 2 0 0 1-2 2H7l-4 4V5a2 2 0 0 1 2-2h14a2 2 0 0 1 2 2z"></path></svg>
                 <span class="topbar-comment-count">0</span>
             </button>
             <div class="topbar-divider"></div>
+            <button class="topbar-btn color-scheme-btn" aria-label="Toggle light/dark theme" title="Toggle light/dark theme"></button>
+            <div class="topbar-divider"></div>
+            <select class="topbar-select code-theme-select" aria-label="Code theme" title="Code theme"></select>
+            <div class="topbar-divider"></div>
             <button class="topbar-btn topbar-help-btn" aria-label="Keyboard shortcuts" title="Keyboard shortcuts (?)">
                 <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><circle cx="12" cy="12" r="10"></circle><path d="M9.09 9a3 3 0 0 1 5.83 1c0 2-3 3-3 3"></path><line x1="12" y1="17" x2="12.01" y2="17"></line></svg>
             </button>
@@ -1075,6 +1141,7 @@ const htmlTemplate = `<!DOCTYPE html>
         </div>
     </header>
 
+%s
     <article class="markdown-body">
         %s
     </article>
@@ -1110,8 +1177,12 @@ const copyButtonScript = `
                 wrapper.appendChild(btn);
 
                 btn.addEventListener('click', function() {
-                    var code = pre.querySelector('code');
-                    var text = code ? code.textContent : pre.textContent;
+                    // Copy only the code: no line numbers or removed diff lines
+                    var code = (pre.querySelector('code') || pre).cloneNode(true);
+                    code.querySelectorAll('.hl-ln, .code-line-removed').forEach(function(el) {
+                        el.remove();
+                    });
+                    var text = code.textContent;
                     navigator.clipboard.writeText(text).then(function() {
                         btn.innerHTML = checkIcon;
                         btn.classList.add('copied');
@@ -1125,6 +1196,71 @@ const copyButtonScript = `
         })();
     </script>`
 
+// csvTableScript makes the tables rendered from CSV and TSV sortable by
+// clicking a column header, and adds a box above each to filter its rows.
+const csvTableScript = `
+    <script>
+        (function() {
+            function cellText(row, column) {
+                var cell = row.cells[column];
+                return cell ? cell.textContent.trim() : '';
+            }
+
+            function compare(a, b) {
+                var x = Number(a.replace(/,/g, ''));
+                var y = Number(b.replace(/,/g, ''));
+                if (a !== '' && b !== '' && !isNaN(x) && !isNaN(y)) return x - y;
+                return a.localeCompare(b, undefined, { numeric: true, sensitivity: 'base' });
+            }
+
+            document.querySelectorAll('.markdown-body .csv-table').forEach(function(wrapper) {
+                var table = wrapper.querySelector('table');
+                var tbody = table.tBodies[0];
+                if (!table.tHead || !tbody) return;
+                var rows = Array.prototype.slice.call(tbody.rows);
+                var headers = table.tHead.rows[0].cells;
+
+                var filter = document.createElement('input');
+                filter.type = 'search';
+                filter.className = 'csv-table-filter';
+                filter.placeholder = 'Filter ' + rows.length + (rows.length === 1 ? ' row' : ' rows');
+                filter.setAttribute('aria-label', 'Filter rows');
+                wrapper.insertBefore(filter, table);
+                filter.addEventListener('input', function() {
+                    var query = filter.value.trim().toLowerCase();
+                    rows.forEach(function(row) {
+                        row.hidden = query !== '' && row.textContent.toLowerCase().indexOf(query) === -1;
+                    });
+                });
+
+                Array.prototype.forEach.call(headers, function(th, column) {
+                    th.tabIndex = 0;
+                    th.setAttribute('aria-sort', 'none');
+                    function sort() {
+                        var ascending = th.getAttribute('aria-sort') !== 'ascending';
+                        Array.prototype.forEach.call(headers, function(other) {
+                            other.setAttribute('aria-sort', 'none');
+                        });
+                        th.setAttribute('aria-sort', ascending ? 'ascending' : 'descending');
+                        rows.slice().sort(function(a, b) {
+                            var order = compare(cellText(a, column), cellText(b, column));
+                            return ascending ? order : -order;
+                        }).forEach(function(row) {
+                            tbody.appendChild(row);
+                        });
+                    }
+                    th.addEventListener('click', sort);
+                    th.addEventListener('keydown', function(e) {
+                        if (e.key === 'Enter' || e.key === ' ') {
+                            e.preventDefault();
+                            sort();
+                        }
+                    });
+                });
+            });
+        })();
+    </script>`
+
 const liveReloadScript = `
     <script>
         (function() {
@@ -1148,12 +1284,14 @@ const mermaidScript = `
         (function() {
             'use strict';
 
-            // Find all mermaid code blocks
-            var mermaidBlocks = document.querySelectorAll('.markdown-body pre code.language-mermaid');
+            // Find all mermaid code blocks, except the source of blocks already
+            // rendered by a configured renderer
+            var mermaidBlocks = document.querySelectorAll('.markdown-body pre code.language-mermaid:not(.diagram-source code)');
             if (mermaidBlocks.length === 0) return;
 
-            // Detect dark mode
+            // Detect dark mode, honouring the light/dark/system toggle
             function isDarkMode() {
+                if (window.mdpColorScheme) return window.mdpColorScheme.isDark();
                 return window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches;
             }
 
@@ -1254,7 +1392,12 @@ const mermaidScript = `
                     }
 
                     // Listen for theme changes and re-render diagrams
-                    if (window.matchMedia) {
+                    if (window.mdpColorScheme) {
+                        window.mdpColorScheme.onChange(function() {
+                            var newTheme = isDarkMode() ? 'dark' : 'default';
+                            rerenderAllDiagrams(newTheme, 'mermaid-theme');
+                        });
+                    } else if (window.matchMedia) {
                         window.matchMedia('(prefers-color-scheme: dark)').addEventListener('change', function() {
                             var newTheme = isDarkMode() ? 'dark' : 'default';
                             rerenderAllDiagrams(newTheme, 'mermaid-theme');
@@ -2007,14 +2150,332 @@ const commentsHTML = `
         </div>
     </div>`
 
+const presentButtonHTML = `
+            <a class="topbar-btn topbar-present-btn" href="%s" aria-label="Present as slides" title="Present as slides">
+                <svg xmlns="http://www.w3.org/2000/svg" width="20" height="20" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M2 3h20M3 3v11a2 2 0 0 0 2 2h14a2 2 0 0 0 2-2V3M12 16v5M8 21l4-5 4 5"></path></svg>
+            </a>
+            <div class="topbar-divider"></div>`
+
+// presentButton returns the top bar link to the slides view, or nothing.
+func presentButton(url string) string {
+	if url == "" {
+		return ""
+	}
+	return fmt.Sprintf(presentButtonHTML, html.EscapeString(url))
+}
+
+// fileMeta returns the line above a document with its change status and
+// last change, or nothing if neither is known. It sits outside the
+// markdown body so comment anchors are unaffected.
+func fileMeta(status, author string, modified time.Time) string {
+	var parts []string
+	if status != "" {
+		parts = append(parts, fmt.Sprintf(`<span class="file-meta-status" data-status="%s">%s</span>`,
+			html.EscapeString(status), html.EscapeString(strings.ToUpper(status[:1])+status[1:])))
+	}
+	if !modified.IsZero() {
+		changed := fmt.Sprintf(`Last modified <time datetime="%s">%s</time>`,
+			modified.Format(time.RFC3339), modified.Format("Jan 2, 2006"))
+		if author != "" {
+			changed += " by " + html.EscapeString(author)
+		}
+		parts = append(parts, "<span>"+changed+"</span>")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return `
+    <div class="file-meta">` + strings.Join(parts, "") + `</div>`
+}
+
 // Generate creates a complete HTML document with the given title and content.
-func Generate(title, content string) string {
-	scripts := copyButtonScript + mermaidScript + commentsJS
-	return fmt.Sprintf(htmlTemplate, title, githubMarkdownCSS, chromaCSS, commentsCSS, content, commentsHTML, scripts)
+func Generate(title, content string, opts ...Option) string {
+	return generate(title, content, 0, newOptions(opts))
 }
 
 // GenerateWithLiveReload creates an HTML document with live reload support.
-func GenerateWithLiveReload(title, content string, port int) string {
-	scripts := copyButtonScript + mermaidScript + commentsJS + fmt.Sprintf(liveReloadScript, port)
-	return fmt.Sprintf(htmlTemplate, title, githubMarkdownCSS, chromaCSS, commentsCSS, content, commentsHTML, scripts)
+func GenerateWithLiveReload(title, content string, port int, opts ...Option) string {
+	return generate(title, content, port, newOptions(opts))
+}
+
+func generate(title, content string, port int, o options) string {
+	var liveReload string
+	if port > 0 {
+		liveReload = fmt.Sprintf(liveReloadScript, port)
+	}
+	codeTheme := codeThemeSwitcherScript(o.codeThemeLight, o.codeThemeDark)
+	head, script := o.security(port)
+
+	if o.layout != nil {
+		return o.layout.render(Page{
+			Title:   title,
+			Head:    htmltemplate.HTML(layoutHead(o, head, script)),
+			Content: htmltemplate.HTML(fileMeta(o.fileStatus, o.fileAuthor, o.fileModified) + `<article class="markdown-body">` + content + `</article>`),
+			Scripts: htmltemplate.HTML(script(lintPanel(o, nil) + spellMarks(o, nil) + codeTheme + copyButtonScript + csvTableScript + mermaidScript + liveReload)),
+		})
+	}
+
+	scripts := lintPanel(o, nil) + spellMarks(o, nil) + codeTheme + copyButtonScript + csvTableScript + mermaidScript + commentsJS + liveReload
+	codeCSS := codeThemeCSS(o.codeThemeLight, o.codeThemeDark)
+	headEnd := customCSSStyle(o.customCSS) + "\n    " + script(colorSchemeScript(o.colorScheme))
+	return fmt.Sprintf(script(htmlTemplate), head, title, githubMarkdownCSS+wikiLinkCSS+extensionCSS+codeBlockCSS, codeCSS, commentsCSS+lintStyles(o)+spellStyles(o), headEnd, presentButton(o.viewToggle), fileMeta(o.fileStatus, o.fileAuthor, o.fileModified), content, commentsHTML, script(scripts))
+}
+
+// wikiLinkCSS styles wiki links and embedded pages, see
+// converter.WithWikiLinks.
+const wikiLinkCSS = `
+.markdown-body .wikilink-unresolved {
+    color: #cf222e;
+    text-decoration: underline dashed;
+    text-underline-offset: 3px;
+    cursor: help;
+}
+
+.markdown-body .wikilink-embed {
+    margin: 0 0 16px;
+    padding: 0 16px;
+    border-left: 3px solid #d1d9e0;
+}
+
+.markdown-body img.wikilink-embed {
+    padding: 0;
+    border: 0;
+}
+
+.markdown-body .wikilink-embed-title {
+    margin-bottom: 8px;
+    font-size: 12px;
+    font-weight: 600;
+}
+
+@media (prefers-color-scheme: dark) {
+    .markdown-body .wikilink-unresolved {
+        color: #f85149;
+    }
+
+    .markdown-body .wikilink-embed {
+        border-left-color: #3d444d;
+    }
+}
+`
+
+// extensionCSS styles the optional markdown extensions: footnotes,
+// definition lists and custom emoji.
+const extensionCSS = `
+.markdown-body a.footnote-ref::before {
+    content: "[";
+}
+
+.markdown-body a.footnote-ref::after {
+    content: "]";
+}
+
+.markdown-body .footnotes {
+    margin-top: 32px;
+    padding-top: 16px;
+    border-top: 1px solid #d1d9e0;
+    font-size: 12px;
+    color: #59636e;
+}
+
+.markdown-body .footnotes > hr {
+    display: none;
+}
+
+.markdown-body .footnotes li:target {
+    color: #1f2328;
+}
+
+.markdown-body a.footnote-backref {
+    text-decoration: none;
+}
+
+.markdown-body dl {
+    padding: 0;
+}
+
+.markdown-body img.emoji {
+    width: auto;
+    height: 1.25em;
+}
+
+.markdown-body dl dt {
+    padding: 0;
+    margin-top: 16px;
+    font-size: 1em;
+    font-style: italic;
+    font-weight: 600;
+}
+
+.markdown-body dl dd {
+    padding: 0 16px;
+    margin-bottom: 16px;
+    margin-left: 0;
+}
+
+@media (prefers-color-scheme: dark) {
+    .markdown-body .footnotes {
+        border-top-color: #3d444d;
+        color: #9198a1;
+    }
+
+    .markdown-body .footnotes li:target {
+        color: #f0f6fc;
+    }
+}
+`
+
+// codeBlockCSS styles code block titles, highlighted, added and removed
+// lines, the tables rendered from csv and tsv blocks, and diagrams. Code
+// themes that style highlighted lines take precedence.
+const codeBlockCSS = `
+.markdown-body .code-block {
+    margin-bottom: 16px;
+}
+
+.markdown-body .code-block-title {
+    padding: 8px 16px;
+    font-family: var(--fontStack-monospace, ui-monospace, SFMono-Regular, SF Mono, Menlo, Consolas, Liberation Mono, monospace);
+    font-size: 12px;
+    color: var(--fgColor-muted, #59636e);
+    background-color: var(--bgColor-muted, #f6f8fa);
+    border-bottom: 1px solid var(--borderColor-default, #d1d9e0);
+    border-radius: 6px 6px 0 0;
+}
+
+.markdown-body .code-block pre {
+    margin-bottom: 0;
+    border-top-left-radius: 0;
+    border-top-right-radius: 0;
+}
+
+.markdown-body .hl-hl,
+.markdown-body .code-line-added,
+.markdown-body .code-line-removed {
+    position: relative;
+    display: flex;
+    margin: 0 -16px;
+    padding: 0 16px;
+}
+
+.markdown-body .hl-hl {
+    background-color: rgba(212, 167, 44, 0.2);
+}
+
+.markdown-body .code-line-added {
+    background-color: rgba(46, 160, 67, 0.15);
+}
+
+.markdown-body .code-line-removed {
+    background-color: rgba(248, 81, 73, 0.15);
+}
+
+.markdown-body .code-line-added::before,
+.markdown-body .code-line-removed::before {
+    position: absolute;
+    left: 4px;
+    user-select: none;
+}
+
+.markdown-body .code-line-added::before {
+    content: "+";
+    color: #1a7f37;
+}
+
+.markdown-body .code-line-removed::before {
+    content: "-";
+    color: #d1242f;
+}
+
+.markdown-body .csv-table {
+    margin-bottom: 16px;
+}
+
+.markdown-body .csv-table table {
+    margin-bottom: 0;
+}
+
+.markdown-body .csv-table-filter {
+    display: block;
+    width: 100%;
+    max-width: 320px;
+    margin-bottom: 8px;
+    padding: 5px 12px;
+    font: inherit;
+    font-size: 14px;
+    color: inherit;
+    background-color: var(--bgColor-default, #ffffff);
+    border: 1px solid var(--borderColor-default, #d1d9e0);
+    border-radius: 6px;
+}
+
+.markdown-body .csv-table th[aria-sort] {
+    cursor: pointer;
+    user-select: none;
+    white-space: nowrap;
+}
+
+.markdown-body .csv-table th[aria-sort]::after {
+    content: "\2195";
+    margin-left: 4px;
+    color: var(--fgColor-muted, #59636e);
+    opacity: 0.5;
+}
+
+.markdown-body .csv-table th[aria-sort="ascending"]::after {
+    content: "\2191";
+    opacity: 1;
+}
+
+.markdown-body .csv-table th[aria-sort="descending"]::after {
+    content: "\2193";
+    opacity: 1;
+}
+
+.markdown-body .diagram {
+    margin-bottom: 16px;
+    overflow-x: auto;
+    text-align: center;
+}
+
+.markdown-body .diagram svg {
+    max-width: 100%;
+    height: auto;
+}
+
+.markdown-body .diagram-error {
+    margin-bottom: 8px;
+    padding: 8px 16px;
+    font-size: 14px;
+    color: #d1242f;
+    background-color: rgba(248, 81, 73, 0.1);
+    border-left: 4px solid #d1242f;
+    border-radius: 6px;
+    white-space: pre-wrap;
+}
+
+@media (prefers-color-scheme: dark) {
+    .markdown-body .csv-table-filter {
+        background-color: var(--bgColor-default, #0d1117);
+        border-color: var(--borderColor-default, #3d444d);
+    }
+
+    .markdown-body .diagram-error {
+        color: #ff7b72;
+        border-left-color: #f85149;
+    }
+}
+`
+
+// MarkdownCSS returns the GitHub markdown and syntax highlighting styles
+// used by the HTML templates, followed by any custom CSS, for embedding in
+// other output formats. With a light or dark color scheme, only the styles
+// for that scheme apply.
+func MarkdownCSS(opts ...Option) string {
+	o := newOptions(opts)
+	css := githubMarkdownCSS + wikiLinkCSS + extensionCSS + codeBlockCSS + "\n" + codeThemeCSS(o.codeThemeLight, o.codeThemeDark)
+	if o.customCSS != "" {
+		css += "\n" + o.customCSS
+	}
+	return fixColorScheme(css, o.colorScheme)
 }
@@ -1,10 +1,16 @@
 package template
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"mdp/internal/converter"
 	"mdp/internal/filetree"
+	"mdp/internal/lint"
+	"mdp/internal/spell"
 )
 
 func TestGenerate_ContainsTitle(t *testing.T) {
@@ -294,6 +300,48 @@ func TestGenerate_CopyButtonSkipsMermaid(t *testing.T) {
 	}
 }
 
+func TestGenerate_CopyButtonCopiesOnlyCode(t *testing.T) {
+	for name, result := range map[string]string{
+		"single": Generate("Test", "<p>Content</p>"),
+		"multi":  GenerateMulti("Test", &filetree.TreeNode{}, nil),
+	} {
+		if !strings.Contains(result, "querySelectorAll('.hl-ln, .code-line-removed')") {
+			t.Errorf("%s: expected the copy button to leave out line numbers and removed lines", name)
+		}
+		if !strings.Contains(result, ".markdown-body .code-block-title {") {
+			t.Errorf("%s: expected code block title styles", name)
+		}
+	}
+}
+
+func TestGenerate_CSVTableScript(t *testing.T) {
+	for name, result := range map[string]string{
+		"single": Generate("Test", "<p>Content</p>"),
+		"multi":  GenerateMulti("Test", &filetree.TreeNode{}, nil),
+	} {
+		if !strings.Contains(result, "querySelectorAll('.markdown-body .csv-table')") {
+			t.Errorf("%s: expected the CSV table sort and filter script", name)
+		}
+		if !strings.Contains(result, ".markdown-body .csv-table-filter {") {
+			t.Errorf("%s: expected CSV table styles", name)
+		}
+	}
+}
+
+func TestGenerate_MermaidSkipsRenderedSource(t *testing.T) {
+	for name, result := range map[string]string{
+		"single": Generate("Test", "<p>Content</p>"),
+		"multi":  GenerateMulti("Test", &filetree.TreeNode{}, nil),
+	} {
+		if !strings.Contains(result, "code.language-mermaid:not(.diagram-source code)") {
+			t.Errorf("%s: expected mermaid to skip the source of rendered blocks", name)
+		}
+		if !strings.Contains(result, ".diagram-source {") {
+			t.Errorf("%s: expected rendered block sources to be hidden when printing", name)
+		}
+	}
+}
+
 func TestGenerate_MermaidJavaScript(t *testing.T) {
 	result := Generate("Test", "<p>Content</p>")
 
@@ -443,3 +491,579 @@ func TestGenerateMultiWithLiveReload_MermaidIncluded(t *testing.T) {
 		t.Error("expected WebSocket in multifile live reload output")
 	}
 }
+
+func TestGenerate_SafeMode(t *testing.T) {
+	result := Generate("Test", "<p>Content</p>", WithSafeMode())
+
+	if !strings.Contains(result, `<meta http-equiv="Content-Security-Policy"`) {
+		t.Fatal("expected Content-Security-Policy meta tag in safe mode")
+	}
+	if !strings.Contains(result, "securityLevel: 'strict'") || strings.Contains(result, "securityLevel: 'loose'") {
+		t.Error("expected Mermaid to use the strict security level in safe mode")
+	}
+	if !strings.Contains(result, "connect-src 'none'") {
+		t.Error("expected no connections to be allowed without live reload")
+	}
+
+	// Every script must carry the nonce allowed by the policy
+	start := strings.Index(result, "'nonce-") + len("'nonce-")
+	nonce := result[start : start+strings.Index(result[start:], "'")]
+	scripts := strings.Count(result, "<script")
+	if scripts == 0 || strings.Count(result, `<script nonce="`+nonce+`"`) != scripts {
+		t.Errorf("expected all %d scripts to carry nonce %q", scripts, nonce)
+	}
+}
+
+func TestGenerate_DefaultHasNoCSP(t *testing.T) {
+	result := Generate("Test", "<p>Content</p>")
+
+	if strings.Contains(result, "Content-Security-Policy") {
+		t.Error("expected no Content-Security-Policy outside safe mode")
+	}
+	if strings.Contains(result, "nonce=") {
+		t.Error("expected no script nonces outside safe mode")
+	}
+}
+
+func TestGenerateMultiWithLiveReload_SafeMode(t *testing.T) {
+	files := []filetree.FileEntry{
+		{ID: "readme-md", Name: "README", RelPath: "README.md", Content: "<p>Readme</p>"},
+	}
+	tree := filetree.BuildTree(files)
+
+	result := GenerateMultiWithLiveReload("Test", tree, files, 9000, WithSafeMode())
+
+	if !strings.Contains(result, "connect-src ws://localhost:9000") {
+		t.Error("expected policy to allow the live reload WebSocket")
+	}
+	if strings.Contains(result, "securityLevel: 'loose'") {
+		t.Error("expected Mermaid to use the strict security level in safe mode")
+	}
+	if strings.Count(result, "<script") != strings.Count(result, "<script nonce=") {
+		t.Error("expected every script to carry a nonce")
+	}
+}
+
+func TestGenerate_DefaultCodeTheme(t *testing.T) {
+	result := Generate("Test", "<p>Content</p>")
+
+	if !strings.Contains(result, `<style id="mdp-code-theme">`) {
+		t.Error("expected code theme stylesheet to be identifiable by the switcher")
+	}
+	if !strings.Contains(result, chromaCSS) {
+		t.Error("expected built-in GitHub code styles by default")
+	}
+	if !strings.Contains(result, `class="topbar-select code-theme-select"`) {
+		t.Error("expected code theme switcher in topbar")
+	}
+	if !strings.Contains(result, "mdp-code-theme") || !strings.Contains(result, `"name":"monokai"`) {
+		t.Error("expected switcher script with embedded themes")
+	}
+}
+
+func TestGenerate_CodeThemes(t *testing.T) {
+	result := Generate("Test", "<p>Content</p>", WithCodeThemes("vs", "monokai"))
+
+	light := strings.Index(result, "@media (prefers-color-scheme: light) {")
+	dark := strings.Index(result, "@media (prefers-color-scheme: dark) {\n/* Background */")
+	if light == -1 || dark == -1 {
+		t.Fatal("expected generated styles for light and dark mode")
+	}
+	// Monokai's background only appears in the dark block
+	if !strings.Contains(result[dark:], "background-color: #272822") {
+		t.Error("expected monokai styles in dark mode")
+	}
+	if !strings.Contains(result, ".markdown-body .hl-chroma .hl-k") {
+		t.Error("expected generated selectors to be scoped to .markdown-body")
+	}
+}
+
+func TestCodeThemeCSS_PartialConfig(t *testing.T) {
+	css := codeThemeCSS("", "dracula")
+
+	if !strings.Contains(css, "@media (prefers-color-scheme: light) {\n"+chromaCSS) {
+		t.Error("expected built-in styles for the unconfigured light mode")
+	}
+	if !strings.Contains(css, chromaStyleCSS("dracula")) {
+		t.Error("expected dracula styles for dark mode")
+	}
+}
+
+func TestCodeThemeSwitcherScript(t *testing.T) {
+	script := codeThemeSwitcherScript("abap", "monokai")
+
+	// Configured themes come first, without duplicates
+	abap := strings.Index(script, `"name":"abap"`)
+	monokai := strings.Index(script, `"name":"monokai"`)
+	github := strings.Index(script, `"name":"github"`)
+	if abap == -1 || monokai == -1 || github == -1 || !(abap < monokai && monokai < github) {
+		t.Errorf("expected configured themes before the default list")
+	}
+	if strings.Count(script, `"name":"monokai"`) != 1 {
+		t.Error("expected each theme once")
+	}
+	if strings.Contains(script, "</style>") || strings.Contains(script, "<script>alert") {
+		t.Error("expected embedded CSS to be JSON-escaped")
+	}
+}
+
+func TestIsCodeTheme(t *testing.T) {
+	if !IsCodeTheme("dracula") {
+		t.Error("expected dracula to be a code theme")
+	}
+	if IsCodeTheme("no-such-theme") {
+		t.Error("expected unknown name to be rejected")
+	}
+}
+
+func TestMarkdownCSS_CodeThemes(t *testing.T) {
+	if !strings.Contains(MarkdownCSS(), chromaCSS) {
+		t.Error("expected built-in code styles by default")
+	}
+	if !strings.Contains(MarkdownCSS(WithCodeThemes("monokai", "")), chromaStyleCSS("monokai")) {
+		t.Error("expected configured code theme in exported styles")
+	}
+}
+
+func TestMarkdownCSS_ColorScheme(t *testing.T) {
+	if css := MarkdownCSS(WithColorScheme("system")); !strings.Contains(css, "prefers-color-scheme") {
+		t.Error("expected the system color scheme to follow the reader")
+	}
+	css := MarkdownCSS(WithColorScheme("dark"))
+	if strings.Contains(css, "prefers-color-scheme") {
+		t.Error("expected no prefers-color-scheme rules with a fixed color scheme")
+	}
+	if !strings.Contains(css, "@media (min-width: 0px){.markdown-body,[data-theme=dark]") ||
+		!strings.Contains(css, "@media (max-width: -1px){.markdown-body,[data-theme=light]") {
+		t.Error("expected only the dark GitHub styles to apply")
+	}
+}
+
+func TestGenerate_ColorSchemeToggle(t *testing.T) {
+	result := Generate("Test", "<p>Content</p>")
+
+	checks := []string{
+		`class="topbar-btn color-scheme-btn"`,
+		"window.mdpColorScheme",
+		"mdp-color-scheme",
+		"|| 'system';",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected output to contain %q", check)
+		}
+	}
+
+	// The toggle must run before the body so the page is painted in the right mode
+	if strings.Index(result, "window.mdpColorScheme =") > strings.Index(result, "</head>") {
+		t.Error("expected color scheme script in the document head")
+	}
+}
+
+func TestGenerate_ColorSchemeDefault(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"light", "|| 'light';"},
+		{"dark", "|| 'dark';"},
+		{"bogus", "|| 'system';"},
+	}
+
+	for _, tt := range tests {
+		result := Generate("Test", "<p>Content</p>", WithColorScheme(tt.mode))
+		if !strings.Contains(result, tt.expected) {
+			t.Errorf("WithColorScheme(%q): expected %q in output", tt.mode, tt.expected)
+		}
+	}
+}
+
+func TestGenerate_MermaidFollowsColorScheme(t *testing.T) {
+	files := []filetree.FileEntry{{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>a</p>"}}
+	outputs := map[string]string{
+		"single": Generate("Test", "<p>Content</p>"),
+		"multi":  GenerateMulti("Test", filetree.BuildTree(files), files),
+	}
+
+	for name, result := range outputs {
+		if !strings.Contains(result, "if (window.mdpColorScheme) return window.mdpColorScheme.isDark();") {
+			t.Errorf("%s: expected Mermaid dark mode detection to use the toggle", name)
+		}
+		if !strings.Contains(result, "window.mdpColorScheme.onChange(function() {") {
+			t.Errorf("%s: expected Mermaid to re-render on toggle", name)
+		}
+		if !strings.Contains(result, `class="topbar-btn color-scheme-btn"`) {
+			t.Errorf("%s: expected color scheme toggle in topbar", name)
+		}
+	}
+}
+
+func TestIsColorScheme(t *testing.T) {
+	for _, mode := range []string{"light", "dark", "system"} {
+		if !IsColorScheme(mode) {
+			t.Errorf("expected %q to be valid", mode)
+		}
+	}
+	if IsColorScheme("auto") {
+		t.Error("expected unknown mode to be invalid")
+	}
+}
+
+func TestGenerate_CustomCSS(t *testing.T) {
+	css := ".markdown-body h1 { color: rebeccapurple; }"
+	files := []filetree.FileEntry{{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>a</p>"}}
+	outputs := map[string]string{
+		"single": Generate("Test", "<p>Content</p>", WithCustomCSS(css)),
+		"multi":  GenerateMulti("Test", filetree.BuildTree(files), files, WithCustomCSS(css)),
+	}
+
+	for name, result := range outputs {
+		custom := strings.Index(result, `<style id="mdp-custom-css">`)
+		if custom == -1 || !strings.Contains(result, css) {
+			t.Errorf("%s: expected custom CSS in output", name)
+			continue
+		}
+		// Custom rules must come after the built-in styles to override them
+		if strings.LastIndex(result[:custom], "</style>") < strings.Index(result, githubMarkdownCSS) {
+			t.Errorf("%s: expected custom CSS after the built-in styles", name)
+		}
+		if custom > strings.Index(result, "</head>") {
+			t.Errorf("%s: expected custom CSS in the head", name)
+		}
+	}
+}
+
+func writeLayout(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "layout.html")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write layout: %v", err)
+	}
+	return path
+}
+
+const testLayout = `<!DOCTYPE html>
+<html>
+<head><title>{{.Title}} | ACME Docs</title>{{.Head}}</head>
+<body>
+<header class="acme">ACME</header>
+{{if .Multi}}<nav>{{.Sidebar}}</nav>{{end}}
+<main>{{.Content}}</main>
+{{.Scripts}}
+</body>
+</html>`
+
+func TestGenerate_Layout(t *testing.T) {
+	layout, err := ParseLayout(writeLayout(t, testLayout))
+	if err != nil {
+		t.Fatalf("ParseLayout() returned error: %v", err)
+	}
+
+	result := Generate("A <b> Title", "<p>Hello</p>", WithLayout(layout), WithCustomCSS("h1{}"))
+
+	checks := []string{
+		"<title>A &lt;b&gt; Title | ACME Docs</title>",
+		`<header class="acme">ACME</header>`,
+		`<main><article class="markdown-body"><p>Hello</p></article></main>`,
+		`<style id="mdp-code-theme">`,
+		`<style id="mdp-custom-css">`,
+		"window.mdpColorScheme",
+		"mermaid",
+		"btn.className = 'code-copy-btn'",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected layout output to contain %q", check)
+		}
+	}
+	if strings.Contains(result, "<nav>") {
+		t.Error("expected no sidebar in single-file mode")
+	}
+	if strings.Contains(result, "topbar") {
+		t.Error("expected built-in chrome to be replaced by the layout")
+	}
+}
+
+func TestGenerateMulti_Layout(t *testing.T) {
+	layout, err := ParseLayout(writeLayout(t, testLayout))
+	if err != nil {
+		t.Fatalf("ParseLayout() returned error: %v", err)
+	}
+
+	files := []filetree.FileEntry{
+		{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>A</p>"},
+		{ID: "b-md", Name: "b", RelPath: "b.md", Content: "<p>B</p>"},
+	}
+	result := GenerateMultiWithLiveReload("Docs", filetree.BuildTree(files), files, 9000, WithLayout(layout), WithSafeMode())
+
+	checks := []string{
+		`<nav><ul><li><a href="#a-md" data-file="a-md">a</a></li>`,
+		`<section id="b-md" class="content-section">`,
+		"ws://localhost:9000/ws",
+		"Content-Security-Policy",
+		"btn.className = 'code-copy-btn'",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected layout output to contain %q", check)
+		}
+	}
+	if strings.Count(result, "<script") != strings.Count(result, "<script nonce=") {
+		t.Error("expected layout scripts to carry the safe mode nonce")
+	}
+}
+
+func TestParseLayout_Errors(t *testing.T) {
+	if _, err := ParseLayout(filepath.Join(t.TempDir(), "missing.html")); err == nil {
+		t.Error("expected error for missing layout")
+	}
+	if _, err := ParseLayout(writeLayout(t, "{{.Title")); err == nil {
+		t.Error("expected error for invalid template syntax")
+	}
+	if _, err := ParseLayout(writeLayout(t, "{{.Nope}}")); err == nil {
+		t.Error("expected error for unknown slot")
+	}
+}
+
+func TestGenerateSlides(t *testing.T) {
+	slides := []converter.Slide{
+		{Content: `<h1 id="deck">Deck</h1>`},
+		{Content: "<p>Body</p>", Notes: "<p>Remember this</p>"},
+	}
+	result := GenerateSlides("Q3 <Review>", slides)
+
+	checks := []string{
+		"<title>Q3 &lt;Review&gt;</title>",
+		`<section class="slide markdown-body" id="slide-1" aria-label="Slide 1">`,
+		`<section class="slide markdown-body" id="slide-2" aria-label="Slide 2">`,
+		"<aside class=\"slide-notes\">\n<p>Remember this</p>",
+		"slides-presenter-btn",
+		"mdpRenderSlideDiagrams",
+		"window.mdpColorScheme",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected slides to contain %q", check)
+		}
+	}
+	if strings.Count(result, "<aside class=\"slide-notes\">") != 1 {
+		t.Error("expected notes only for slides that have them")
+	}
+	if strings.Contains(result, "slides-exit-btn") || strings.Contains(result, "ws://") {
+		t.Error("expected no exit link or live reload without options")
+	}
+}
+
+func TestGenerateSlides_LiveReload(t *testing.T) {
+	result := GenerateSlidesWithLiveReload("Deck", []converter.Slide{{Content: "<p>A</p>"}}, 9000,
+		WithViewToggle("/"), WithSafeMode())
+
+	if !strings.Contains(result, `<a class="slides-btn slides-exit-btn" href="/"`) {
+		t.Error("expected exit link to the preview")
+	}
+	if !strings.Contains(result, "ws://localhost:9000/ws") {
+		t.Error("expected live reload script")
+	}
+	if strings.Count(result, "<script") != strings.Count(result, "<script nonce=") {
+		t.Error("expected every script to carry the safe mode nonce")
+	}
+}
+
+func TestViewToggle(t *testing.T) {
+	files := []filetree.FileEntry{{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>a</p>"}}
+	outputs := map[string][2]string{
+		"single": {Generate("Test", "<p>x</p>"), Generate("Test", "<p>x</p>", WithViewToggle("/slides"))},
+		"multi":  {GenerateMulti("Test", filetree.BuildTree(files), files), GenerateMulti("Test", filetree.BuildTree(files), files, WithViewToggle("/slides"))},
+	}
+	for name, pair := range outputs {
+		if strings.Contains(pair[0], "topbar-present-btn") {
+			t.Errorf("%s: expected no present button by default", name)
+		}
+		if !strings.Contains(pair[1], `<a class="topbar-btn topbar-present-btn" href="/slides"`) {
+			t.Errorf("%s: expected present button linking to the slides", name)
+		}
+	}
+}
+
+func TestGenerateDiff(t *testing.T) {
+	files := []filetree.FileEntry{
+		{ID: "a-md", Name: "a", RelPath: "a.md", Content: `<div class="diff-row diff-equal"><p>a</p></div>`, Status: "modified"},
+		{ID: "b-md", Name: "b", RelPath: "b.md", Content: "<p>b</p>", Status: "added"},
+		{ID: "c-md", Name: "c", RelPath: "c.md", Content: "<p>c</p>"},
+	}
+	result := GenerateDiff("main..feature", filetree.BuildTree(files), files)
+
+	checks := []string{
+		`<a href="#a-md" data-file="a-md" data-status="modified" data-badge="M" title="Modified">a</a>`,
+		`<a href="#b-md" data-file="b-md" data-status="added" data-badge="A" title="Added">b</a>`,
+		`<a href="#c-md" data-file="c-md">c</a>`,
+		".diff-inline .diff-change",
+		"mdp-diff-view",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected diff page to contain %q", check)
+		}
+	}
+
+	if strings.Contains(GenerateMulti("Test", filetree.BuildTree(files), files), "mdp-diff-view") {
+		t.Error("expected the diff view toggle only on diff pages")
+	}
+}
+
+func TestGenerate_FileMeta(t *testing.T) {
+	modified := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	result := Generate("Test", "<p>x</p>", WithFileMeta("modified", "Ada <Lovelace>", modified))
+
+	checks := []string{
+		`<span class="file-meta-status" data-status="modified">Modified</span>`,
+		`Last modified <time datetime="2024-03-05T10:00:00Z">Mar 5, 2024</time> by Ada &lt;Lovelace&gt;`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected page to contain %q", check)
+		}
+	}
+	// Comments anchor on the article text, so the meta stays outside it
+	if strings.Index(result, `<div class="file-meta">`) > strings.Index(result, `<article class="markdown-body">`) {
+		t.Error("expected file meta before the article")
+	}
+
+	if strings.Contains(Generate("Test", "<p>x</p>"), `<div class="file-meta">`) {
+		t.Error("expected no file meta by default")
+	}
+}
+
+func TestGenerateMulti_FileMeta(t *testing.T) {
+	files := []filetree.FileEntry{
+		{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>a</p>", Status: "untracked"},
+		{ID: "b-md", Name: "b", RelPath: "b.md", Content: "<p>b</p>", Author: "Test", Modified: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	result := GenerateMulti("Test", filetree.BuildTree(files), files)
+
+	checks := []string{
+		`<a href="#a-md" data-file="a-md" data-status="untracked" data-badge="U" title="Untracked">a</a>`,
+		`<div class="file-meta"><span class="file-meta-status" data-status="untracked">Untracked</span></div><article class="markdown-body">`,
+		`Last modified <time datetime="2024-01-02T00:00:00Z">Jan 2, 2024</time> by Test`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected page to contain %q", check)
+		}
+	}
+}
+
+func TestGenerateMulti_Backlinks(t *testing.T) {
+	files := []filetree.FileEntry{
+		{ID: "a-md", Name: "a", RelPath: "a.md", Content: "<p>a</p>", Backlinks: []filetree.Backlink{
+			{ID: "docs-b-md", Name: "b", RelPath: "docs/b.md", Excerpts: []string{"See <a> for details."}},
+		}},
+		{ID: "docs-b-md", Name: "b", RelPath: "docs/b.md", Content: "<p>b</p>"},
+	}
+	result := GenerateMulti("Test", filetree.BuildTree(files), files)
+
+	want := `<article class="markdown-body"><p>a</p></article><aside class="backlinks"><h2 class="backlinks-title">Linked from</h2><ul class="backlinks-list">` +
+		`<li><a class="backlinks-file" href="#docs-b-md" title="docs/b.md">b</a><p class="backlinks-excerpt">See &lt;a&gt; for details.</p></li></ul></aside></section>`
+	if !strings.Contains(result, want) {
+		t.Errorf("expected page to contain %q", want)
+	}
+	if strings.Count(result, `<aside class="backlinks">`) != 1 {
+		t.Error("expected only the linked file to list backlinks")
+	}
+}
+
+func TestGenerateMulti_Graph(t *testing.T) {
+	files := []filetree.FileEntry{
+		{ID: "readme-md", Name: "readme", RelPath: "readme.md", Content: "<p>a</p>", Backlinks: []filetree.Backlink{
+			{ID: "docs-guide-md", Name: "guide", RelPath: "docs/guide.md"},
+		}},
+		{ID: "docs-guide-md", Name: "guide", RelPath: "docs/guide.md", Content: "<p>b</p>"},
+	}
+	result := GenerateMulti("Test", filetree.BuildTree(files), files)
+
+	checks := []string{
+		`<button class="topbar-btn topbar-graph-btn" aria-label="Link graph"`,
+		`<script type="application/json" id="mdp-graph-data">{"nodes":[` +
+			`{"id":"readme-md","name":"readme","path":"readme.md","group":""},` +
+			`{"id":"docs-guide-md","name":"guide","path":"docs/guide.md","group":"docs"}],` +
+			`"edges":[{"source":"docs-guide-md","target":"readme-md"}]}</script>`,
+		".graph-overlay {",
+	}
+	for _, check := range checks {
+		if !strings.Contains(result, check) {
+			t.Errorf("expected page to contain %q", check)
+		}
+	}
+}
+
+func TestMisspellings(t *testing.T) {
+	misspellings := []spell.Misspelling{
+		{File: "/docs/a.md", Line: 1, Column: 1, Word: "teh", Suggestions: []string{"the"}},
+		{File: "/docs/b.md", Line: 2, Column: 3, Word: "</script>"},
+	}
+
+	single := Generate("Test", "<p>teh</p>", WithMisspellings(misspellings[:1]))
+	for _, check := range []string{
+		`<script type="application/json" id="mdp-spell-data">[{"words":{"teh":["the"]}}]</script>`,
+		"mark.spell-error {",
+	} {
+		if !strings.Contains(single, check) {
+			t.Errorf("expected single page to contain %q", check)
+		}
+	}
+
+	files := []filetree.FileEntry{
+		{ID: "a-md", Path: "/docs/a.md", Name: "a", RelPath: "a.md", Content: "<p>teh</p>"},
+		{ID: "b-md", Path: "/docs/b.md", Name: "b", RelPath: "b.md", Content: "<p>b</p>"},
+	}
+	multi := GenerateMulti("Test", filetree.BuildTree(files), files, WithMisspellings(misspellings))
+	want := `[{"section":"a-md","words":{"teh":["the"]}},{"section":"b-md","words":{"\u003c/script\u003e":[]}}]`
+	if !strings.Contains(multi, want) {
+		t.Errorf("expected multi-file page to group words by section as %s", want)
+	}
+
+	if strings.Contains(Generate("Test", "<p>x</p>", WithMisspellings(nil)), "mdp-spell-data") {
+		t.Error("expected no spell data without misspellings")
+	}
+}
+
+func TestLintPanel(t *testing.T) {
+	problems := []lint.Problem{
+		{File: "/docs/a.md", Line: 3, Column: 1, Rule: "MD001", Name: "heading-increment", Message: "Heading level skipped"},
+		{File: "/docs/b.md", Line: 1, Column: 5, Rule: "MD034", Name: "no-bare-urls", Message: "Bare URL: <x>"},
+	}
+
+	single := Generate("Test", "<p>x</p>", WithLintProblems(problems[:1]))
+	for _, check := range []string{
+		`<summary><span class="lint-count">1 lint problem</span></summary>`,
+		`<li><span class="lint-location">3:1</span><span class="lint-rule" title="heading-increment">MD001</span>Heading level skipped</li>`,
+		".lint-panel {",
+	} {
+		if !strings.Contains(single, check) {
+			t.Errorf("expected single page to contain %q", check)
+		}
+	}
+
+	files := []filetree.FileEntry{
+		{ID: "a-md", Path: "/docs/a.md", Name: "a", RelPath: "a.md", Content: "<p>a</p>"},
+		{ID: "b-md", Path: "/docs/b.md", Name: "b", RelPath: "b.md", Content: "<p>b</p>"},
+	}
+	multi := GenerateMulti("Test", filetree.BuildTree(files), files, WithLintProblems(problems))
+	for _, check := range []string{
+		`<span class="lint-count">2 lint problems</span>`,
+		`<a class="lint-location" href="#a-md">a.md:3:1</a>`,
+		`Bare URL: &lt;x&gt;`,
+	} {
+		if !strings.Contains(multi, check) {
+			t.Errorf("expected multi-file page to contain %q", check)
+		}
+	}
+
+	clean := Generate("Test", "<p>x</p>", WithLintProblems(nil))
+	if !strings.Contains(clean, "<summary>No lint problems</summary>") {
+		t.Error("expected panel to report no problems")
+	}
+	if strings.Contains(Generate("Test", "<p>x</p>"), "mdp-lint-panel") {
+		t.Error("expected no lint panel by default")
+	}
+}